@@ -0,0 +1,66 @@
+package arc
+
+import (
+	"time"
+)
+
+// Version is the version of an ARC file, which sets the fields of its URL-record header lines.
+type Version int
+
+const (
+	// Version1 header lines have the fields URL IP-address Archive-date Content-type Archive-length
+	Version1 Version = 1
+	// Version2 header lines have the fields URL IP-address Archive-date Content-type Result-code
+	// Checksum Location Offset Filename Archive-length
+	Version2 Version = 2
+)
+
+// fieldCount returns the number of fields of the URL-record header lines of version v.
+func (v Version) fieldCount() int {
+	if v == Version2 {
+		return 10
+	}
+	return 5
+}
+
+// DateFormat is the layout of the Archive-date field
+const DateFormat = "20060102150405"
+
+// Header is the header line of an ARC record. Fields not in the header line of the file's
+// Version are zero, as are fields given as "-".
+type Header struct {
+	// URL is the URL of the document, or filedesc://<name> in the version block
+	URL string
+	// IP is the IP address of the server
+	IP string
+	// Date is the time the document was archived
+	Date time.Time
+	// ContentType is the MIME type of the document, "no-type" if unknown
+	ContentType string
+	// ResultCode is the protocol response code, such as 200 for HTTP
+	ResultCode int
+	// Checksum is the checksum of the document
+	Checksum string
+	// Location is the URL redirected to, if any
+	Location string
+	// Offset is the offset of the record in the file, as recorded by its writer
+	Offset int64
+	// Filename is the name of the ARC file
+	Filename string
+	// Length is the number of bytes of the record block after the header line
+	Length int64
+}
+
+// FileDesc is the version block starting an ARC file.
+type FileDesc struct {
+	// Header is the header line of the version block
+	Header
+	// Version sets the fields of the header lines of the URL records that follow
+	Version Version
+	// Origin names the organisation that created the file, such as "Alexa Internet"
+	Origin string
+	// Fields names the fields of the header lines of the URL records, as declared
+	Fields []string
+	// Metadata is the rest of the version block, an XML document in files written by Heritrix
+	Metadata []byte
+}
@@ -0,0 +1,108 @@
+package arc
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Convert reads the ARC file r and writes its records to w as WARC records:
+//
+//   - the version block becomes a warcinfo record describing the ARC file, followed by a
+//     metadata record holding its XML metadata, if any
+//   - URL records become response records, or resource records when their block is not an
+//     HTTP response, with their URL, IP address and date as WARC-Target-URI, WARC-IP-Address
+//     and WARC-Date, and their block and payload digests computed
+//   - each is followed by a metadata record referring to it, which names the ARC file and
+//     the offset of the record in it, so that the record can be traced back
+func Convert(w warc.RecordWriter, r io.Reader) error {
+	reader, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	desc := reader.FileDesc
+	name := strings.TrimPrefix(desc.URL, "filedesc://")
+
+	info := &warc.WarcInfoRecord{WARCRecord: warc.WARCRecord{RecordID: warc.NewRecordID(), Date: desc.Date}}
+	info.Software = "gwarc"
+	info.IP = desc.IP
+	info.Content = warcFields(
+		"format", "WARC File Format 1.1",
+		"description", "converted from ARC file "+name,
+		"arc-filename", name,
+		"arc-version", strconv.Itoa(int(desc.Version)),
+		"arc-origin", desc.Origin,
+	)
+	group := []any{info}
+	if len(desc.Metadata) > 0 {
+		group = append(group, &warc.WARCRecord{
+			Type:        warc.WARCTypeMetadata,
+			Date:        desc.Date,
+			TargetURI:   desc.URL,
+			RefersTo:    info.RecordID,
+			WarcinfoID:  info.RecordID,
+			ContentType: "text/xml",
+			Content:     desc.Metadata,
+		})
+	}
+	if err := w.WriteRecords(group...); err != nil {
+		return err
+	}
+
+	if _, err := reader.Next(); err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := convertRecord(w, record, reader.Header(), name, info.RecordID); err != nil {
+			return err
+		}
+	}
+}
+
+// convertRecord writes a URL record of the ARC file name and the metadata record tracing it back.
+func convertRecord(w warc.RecordWriter, record *warc.Record, header *Header, name, warcinfoID string) error {
+	record.RecordID = warc.NewRecordID()
+	record.WarcinfoID = warcinfoID
+	digested, release, err := warc.DigestRecord(record)
+	if err != nil {
+		return fmt.Errorf("ARC record at offset %d: %w", record.Offset, err)
+	}
+	defer release()
+
+	provenance := []string{"arc-filename", name, "arc-offset", strconv.FormatInt(record.Offset, 10)}
+	if header.Checksum != "" {
+		provenance = append(provenance, "arc-checksum", header.Checksum)
+	}
+	metadata := &warc.WARCRecord{
+		Type:        warc.WARCTypeMetadata,
+		Date:        record.Date,
+		TargetURI:   record.TargetURI,
+		RefersTo:    record.RecordID,
+		WarcinfoID:  warcinfoID,
+		ContentType: warc.ContentTypeWARCFields,
+		Content:     warcFields(provenance...),
+	}
+	return w.WriteRecords(digested, metadata)
+}
+
+// warcFields returns an application/warc-fields block of the given names and values, leaving
+// out empty values.
+func warcFields(namesAndValues ...string) []byte {
+	var b strings.Builder
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		if namesAndValues[i+1] != "" {
+			b.WriteString(namesAndValues[i] + ": " + namesAndValues[i+1] + "\r\n")
+		}
+	}
+	return []byte(b.String())
+}
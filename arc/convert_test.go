@@ -0,0 +1,95 @@
+package arc_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zenless-lab/gwarc/arc"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+func TestConvert(t *testing.T) {
+	metadata := `<?xml version="1.0"?><arcmetadata/>`
+	records := append([]arcRecord{
+		{version2[0].line, version2[0].block + metadata + "\n"},
+	}, version2[1:]...)
+	for _, compressed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed=%v", compressed), func(t *testing.T) {
+			data, offsets := buildARC(t, records, compressed)
+			var buf bytes.Buffer
+			if err := arc.Convert(warc.NewGzipWriter(&buf), bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			if findings := warc.ValidateReader(bytes.NewReader(buf.Bytes())); len(findings) != 0 {
+				t.Errorf("ValidateReader() = %v, want no findings", findings)
+			}
+
+			reader, err := warc.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []*warc.Record
+			var blocks []string
+			for {
+				record, err := reader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				block, err := io.ReadAll(record.Block)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, record)
+				blocks = append(blocks, string(block))
+			}
+
+			wantTypes := []warc.WARCRecordType{
+				warc.WARCTypeWarcinfo, warc.WARCTypeMetadata,
+				warc.WARCTypeResponse, warc.WARCTypeMetadata,
+				warc.WARCTypeResource, warc.WARCTypeMetadata,
+			}
+			if len(got) != len(wantTypes) {
+				t.Fatalf("converted %d records, want %d", len(got), len(wantTypes))
+			}
+			for i, typ := range wantTypes {
+				if got[i].Type != typ {
+					t.Errorf("record %d: Type = %s, want %s", i, got[i].Type, typ)
+				}
+				if i > 0 && got[i].WarcinfoID != got[0].RecordID {
+					t.Errorf("record %d: WarcinfoID = %s, want %s", i, got[i].WarcinfoID, got[0].RecordID)
+				}
+			}
+
+			if !strings.Contains(blocks[0], "arc-filename: IA-002.arc\r\n") || !strings.Contains(blocks[0], "arc-version: 2\r\n") {
+				t.Errorf("warcinfo block = %q", blocks[0])
+			}
+			if blocks[1] != metadata || got[1].RefersTo != got[0].RecordID {
+				t.Errorf("filedesc metadata = %q refers to %s", blocks[1], got[1].RefersTo)
+			}
+
+			response := got[2]
+			if response.TargetURI != "http://www.example.com/a page" || response.IPAddress != "192.0.2.1" || response.Date.Format(arc.DateFormat) != "20000101000001" {
+				t.Errorf("response header = %+v", response.WARCRecord)
+			}
+			if blocks[2] != httpBlock || response.BlockDigest != warc.Digest([]byte(httpBlock)) || response.PayloadDigest != warc.Digest([]byte("<html>Hello</html>")) {
+				t.Errorf("response block = %q, digests %s %s", blocks[2], response.BlockDigest, response.PayloadDigest)
+			}
+			for i, offset := range []int64{offsets[1], offsets[2]} {
+				metadata, described := got[3+2*i], got[2+2*i]
+				want := fmt.Sprintf("arc-filename: IA-002.arc\r\narc-offset: %d\r\n", offset)
+				if !strings.HasPrefix(blocks[3+2*i], want) || metadata.RefersTo != described.RecordID || metadata.TargetURI != described.TargetURI {
+					t.Errorf("provenance of record %d = %q, refers to %s", 2+2*i, blocks[3+2*i], metadata.RefersTo)
+				}
+			}
+			if got[4].PayloadDigest != "" || got[4].BlockDigest != warc.Digest(nil) {
+				t.Errorf("resource digests = %s %s", got[4].BlockDigest, got[4].PayloadDigest)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenless-lab/gwarc/internal/iocount"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Reader reads the records of an ARC file, plain or gzip compressed; with one gzip member
+// per record, as in .arc.gz files, the offset of every record is the offset of its member.
+//
+// Records are returned as warc.Records, so that code written for WARC records works on ARC
+// records too. The version block is a warcinfo record named after the file; URL records are
+// response records when their block is an HTTP response, and resource records otherwise.
+// They have no Version, RecordID or digests, which ARC files do not record.
+type Reader struct {
+	// Limits bounds header lines and record lengths as it does for warc.Reader. NewReader
+	// reads the version block within warc.DefaultLimits.
+	Limits warc.Limits
+
+	// FileDesc is the version block of the file
+	FileDesc *FileDesc
+
+	filename string
+
+	buf          *bufio.Reader
+	src          *iocount.Reader
+	br           *bufio.Reader
+	gz           *gzip.Reader
+	memberOffset int64
+
+	block  *io.LimitedReader
+	header *Header
+	first  *warc.Record
+}
+
+// NewReader returns a Reader reading the ARC file r, after reading its version block. When r
+// is a file, its records are marked with the file's name.
+func NewReader(r io.Reader) (*Reader, error) {
+	buf := bufio.NewReader(r)
+	reader := &Reader{buf: buf, src: iocount.NewReader(buf)}
+	if file, ok := r.(*os.File); ok {
+		reader.filename = filepath.Base(file.Name())
+	}
+
+	magic, err := reader.buf.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if reader.gz, err = gzip.NewReader(reader.src); err != nil {
+			return nil, err
+		}
+		reader.gz.Multistream(false)
+		reader.br = bufio.NewReader(reader.gz)
+	} else {
+		reader.br = bufio.NewReader(reader.src)
+	}
+
+	record, header, err := reader.readRecord(0)
+	if err == io.EOF {
+		err = &warc.ParseError{Category: warc.CategorySyntax, Err: fmt.Errorf("missing version block: %w", io.ErrUnexpectedEOF)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(header.URL, "filedesc://") {
+		return nil, &warc.ParseError{Category: warc.CategorySyntax, Line: 1, Field: "URL", Err: fmt.Errorf("file starts with %s, not a filedesc:// version block", header.URL)}
+	}
+	if header.Length > warc.DefaultLimits.MaxMemory {
+		return nil, &warc.ParseError{Category: warc.CategoryLimits, Field: "Archive-length", Err: &warc.LimitError{Limit: "MaxMemory", Max: warc.DefaultLimits.MaxMemory}}
+	}
+	block, err := io.ReadAll(record.Block)
+	if err != nil {
+		return nil, err
+	}
+	if reader.FileDesc, err = parseFileDesc(header, block); err != nil {
+		return nil, err
+	}
+
+	record.Type = warc.WARCTypeWarcinfo
+	record.TargetURI = ""
+	record.Filename = strings.TrimPrefix(header.URL, "filedesc://")
+	record.ContentType = header.ContentType
+	record.Block = bytes.NewReader(block)
+	reader.first = record
+	return reader, nil
+}
+
+// Next returns the next record, starting with the version block. Its Block is valid until
+// the following call to Next. At the end of the input Next returns io.EOF.
+func (r *Reader) Next() (*warc.Record, error) {
+	if r.first != nil {
+		record := r.first
+		r.first = nil
+		r.header = &r.FileDesc.Header
+		return record, nil
+	}
+	record, header, err := r.readRecord(r.FileDesc.Version)
+	if err != nil {
+		return nil, err
+	}
+	r.header = header
+	return record, nil
+}
+
+// Header returns the ARC header line of the record last returned by Next, with the fields
+// that have no place in a warc.Record.
+func (r *Reader) Header() *Header {
+	return r.header
+}
+
+// readRecord reads the next record, whose header line has the fields of version, or of
+// either version when version is 0.
+func (r *Reader) readRecord(version Version) (*warc.Record, *Header, error) {
+	if r.block != nil {
+		if _, err := io.Copy(io.Discard, r.block); err != nil {
+			return nil, nil, err
+		}
+		r.block = nil
+	}
+	if err := r.skipSeparator(); err != nil {
+		return nil, nil, err
+	}
+
+	offset := r.src.N() - int64(r.br.Buffered())
+	if r.gz != nil {
+		offset = r.memberOffset
+	}
+	limits := r.limits()
+
+	line, err := readLine(r.br, limits.MaxHeaderLineLength)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		category := warc.CategorySyntax
+		var limitErr *warc.LimitError
+		if errors.As(err, &limitErr) {
+			category = warc.CategoryLimits
+		}
+		return nil, nil, &warc.ParseError{Category: category, Offset: offset, Line: 1, Err: fmt.Errorf("failed to read header: %w", err)}
+	}
+	header, field, err := parseHeader(strings.TrimRight(line, "\r\n"), version)
+	if err != nil {
+		return nil, nil, &warc.ParseError{Category: warc.CategorySyntax, Offset: offset, Line: 1, Field: field, Err: err}
+	}
+	if header.Length > limits.MaxRecordSize {
+		return nil, nil, &warc.ParseError{Category: warc.CategoryLimits, Offset: offset, Field: "Archive-length", Err: &warc.LimitError{Limit: "MaxRecordSize", Max: limits.MaxRecordSize}}
+	}
+
+	record := &warc.Record{
+		WARCRecord: warc.WARCRecord{
+			Type:          warc.WARCTypeResource,
+			ContentLength: uint64(header.Length),
+			Date:          header.Date,
+			TargetURI:     header.URL,
+			IPAddress:     header.IP,
+			ContentType:   header.ContentType,
+		},
+		Offset: offset,
+		File:   r.filename,
+	}
+	if header.ContentType == "no-type" {
+		record.ContentType = ""
+	}
+	if start, _ := r.br.Peek(int(min64(header.Length, 5))); string(start) == "HTTP/" {
+		record.Type = warc.WARCTypeResponse
+		record.ContentType = "application/http; msgtype=response"
+	}
+	r.block = &io.LimitedReader{R: r.br, N: header.Length}
+	record.Block = &blockReader{r: r.block, offset: offset}
+	return record, header, nil
+}
+
+// limits returns r.Limits with its zero fields taken from warc.DefaultLimits.
+func (r *Reader) limits() warc.Limits {
+	limits := r.Limits
+	if limits.MaxHeaderLineLength <= 0 {
+		limits.MaxHeaderLineLength = warc.DefaultLimits.MaxHeaderLineLength
+	}
+	if limits.MaxRecordSize <= 0 {
+		limits.MaxRecordSize = warc.DefaultLimits.MaxRecordSize
+	}
+	return limits
+}
+
+// skipSeparator skips the newline ending the previous record, moving on to the next gzip
+// member as needed.
+func (r *Reader) skipSeparator() error {
+	for {
+		b, err := r.br.ReadByte()
+		if err == io.EOF && r.gz != nil {
+			if _, err := r.buf.Peek(1); err == nil {
+				r.memberOffset = r.src.N()
+				if err := r.gz.Reset(r.src); err != nil {
+					return err
+				}
+				r.gz.Multistream(false)
+				r.br.Reset(r.gz)
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if b != '\r' && b != '\n' {
+			return r.br.UnreadByte()
+		}
+	}
+}
+
+// readLine reads a line of at most max bytes, up to and including its LF.
+func readLine(br *bufio.Reader, max int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return "", &warc.LimitError{Limit: "MaxHeaderLineLength", Max: int64(max)}
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return string(line), err
+	}
+}
+
+// parseHeader parses a header line with the fields of version, or of the version its number
+// of fields suggests when version is 0. Spaces in URLs, which some crawlers failed to escape,
+// are kept. When the line is invalid, the name of the field at fault is returned with the error.
+func parseHeader(line string, version Version) (*Header, string, error) {
+	fields := strings.Fields(line)
+	if version == 0 {
+		version = Version1
+		if len(fields) == Version2.fieldCount() {
+			version = Version2
+		}
+	}
+	n := version.fieldCount()
+	if len(fields) < n {
+		return nil, "", fmt.Errorf("header line has %d fields, want %d: %q", len(fields), n, line)
+	}
+	if extra := len(fields) - n; extra > 0 {
+		fields = append([]string{strings.Join(fields[:extra+1], " ")}, fields[extra+1:]...)
+	}
+
+	header := &Header{URL: fields[0], IP: fields[1], ContentType: fields[3]}
+	date, err := parseDate(fields[2])
+	if err != nil {
+		return nil, "Archive-date", err
+	}
+	header.Date = date
+	if header.Length, err = strconv.ParseInt(fields[n-1], 10, 64); err != nil || header.Length < 0 {
+		return nil, "Archive-length", fmt.Errorf("invalid length %q", fields[n-1])
+	}
+	if version == Version2 {
+		if fields[4] != "-" {
+			if header.ResultCode, err = strconv.Atoi(fields[4]); err != nil {
+				return nil, "Result-code", fmt.Errorf("invalid result code %q", fields[4])
+			}
+		}
+		header.Checksum = orEmpty(fields[5])
+		header.Location = orEmpty(fields[6])
+		if fields[7] != "-" {
+			if header.Offset, err = strconv.ParseInt(fields[7], 10, 64); err != nil {
+				return nil, "Offset", fmt.Errorf("invalid offset %q", fields[7])
+			}
+		}
+		header.Filename = orEmpty(fields[8])
+	}
+	return header, "", nil
+}
+
+// parseDate parses an Archive-date of up to 14 digits, ignoring any further digits.
+func parseDate(s string) (time.Time, error) {
+	if len(s) > len(DateFormat) {
+		s = s[:len(DateFormat)]
+	}
+	if len(s) < 4 || len(s)%2 != 0 {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	date, err := time.Parse(DateFormat[:len(s)], s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return date, nil
+}
+
+// parseFileDesc parses the block of the version block: a line holding the version, a
+// reserved number and the origin, a line naming the fields of URL-record header lines,
+// and optional metadata.
+func parseFileDesc(header *Header, block []byte) (*FileDesc, error) {
+	desc := &FileDesc{Header: *header}
+	versionLine, rest, _ := bytes.Cut(block, []byte("\n"))
+	fieldsLine, metadata, _ := bytes.Cut(rest, []byte("\n"))
+
+	parts := strings.Fields(string(versionLine))
+	if len(parts) < 2 {
+		return nil, &warc.ParseError{Category: warc.CategorySyntax, Line: 2, Err: fmt.Errorf("invalid version line %q", versionLine)}
+	}
+	switch parts[0] {
+	case "1":
+		desc.Version = Version1
+	case "2":
+		desc.Version = Version2
+	default:
+		return nil, &warc.ParseError{Category: warc.CategoryVersion, Line: 2, Err: fmt.Errorf("unsupported ARC version: %s", parts[0])}
+	}
+	desc.Origin = strings.Join(parts[2:], " ")
+	desc.Fields = strings.Fields(string(fieldsLine))
+	if len(desc.Fields) != desc.Version.fieldCount() {
+		return nil, &warc.ParseError{Category: warc.CategorySyntax, Line: 3,
+			Err: fmt.Errorf("version %d files have %d fields, found %d", desc.Version, desc.Version.fieldCount(), len(desc.Fields))}
+	}
+	if metadata = bytes.TrimSpace(metadata); len(metadata) > 0 {
+		desc.Metadata = metadata
+	}
+	return desc, nil
+}
+
+func orEmpty(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// blockReader hides the LimitedReader behind a record's Block, failing with a
+// *warc.ParseError wrapping io.ErrUnexpectedEOF when the block is short.
+type blockReader struct {
+	r      *io.LimitedReader
+	offset int64
+}
+
+func (b *blockReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF && b.r.N > 0 {
+		err = &warc.ParseError{Category: warc.CategorySyntax, Offset: b.offset, Field: "Archive-length", Err: io.ErrUnexpectedEOF}
+	}
+	return n, err
+}
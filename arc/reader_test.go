@@ -0,0 +1,240 @@
+package arc_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/arc"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// arcRecord is a header line without its length and a block.
+type arcRecord struct {
+	line  string
+	block string
+}
+
+// buildARC returns the records as an ARC file, each record a gzip member when compressed,
+// and the offsets of the records.
+func buildARC(t *testing.T, records []arcRecord, compressed bool) ([]byte, []int64) {
+	t.Helper()
+	var buf bytes.Buffer
+	var offsets []int64
+	for _, record := range records {
+		offsets = append(offsets, int64(buf.Len()))
+		data := fmt.Sprintf("%s %d\n%s\n", record.line, len(record.block), record.block)
+		if !compressed {
+			buf.WriteString(data)
+			continue
+		}
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), offsets
+}
+
+const httpBlock = "HTTP/1.0 200 OK\r\nContent-Type: text/html\r\n\r\n<html>Hello</html>"
+
+var (
+	version1 = []arcRecord{
+		{"filedesc://IA-001102.arc 0.0.0.0 19960923142103 text/plain", "1 0 Alexa Internet\nURL IP-address Archive-date Content-type Archive-length\n"},
+		{"http://www.example.com/ 192.0.2.1 19960923142103 text/html", httpBlock},
+		{"dns:www.example.com 192.0.2.53 19960923142104 text/dns", "www.example.com. 300 IN A 192.0.2.1\n"},
+	}
+	version2 = []arcRecord{
+		{"filedesc://IA-002.arc 0.0.0.0 20000101000000 text/plain", "2 0 Alexa Internet\nURL IP-address Archive-date Content-type Result-code Checksum Location Offset Filename Archive-length\n"},
+		{"http://www.example.com/a page 192.0.2.1 20000101000001 text/html 200 CHECKSUM - 0 IA-002.arc", httpBlock},
+		{"http://www.example.com/old 192.0.2.1 20000101000002 no-type 302 - http://www.example.com/new 100 IA-002.arc", ""},
+	}
+)
+
+func TestReader(t *testing.T) {
+	type want struct {
+		typ         warc.WARCRecordType
+		uri         string
+		contentType string
+		date        time.Time
+		header      arc.Header
+	}
+	tests := []struct {
+		name    string
+		records []arcRecord
+		version arc.Version
+		want    []want
+	}{
+		{
+			name:    "version 1",
+			records: version1,
+			version: arc.Version1,
+			want: []want{
+				{warc.WARCTypeWarcinfo, "", "text/plain", time.Date(1996, 9, 23, 14, 21, 3, 0, time.UTC), arc.Header{}},
+				{warc.WARCTypeResponse, "http://www.example.com/", "application/http; msgtype=response", time.Date(1996, 9, 23, 14, 21, 3, 0, time.UTC), arc.Header{}},
+				{warc.WARCTypeResource, "dns:www.example.com", "text/dns", time.Date(1996, 9, 23, 14, 21, 4, 0, time.UTC), arc.Header{}},
+			},
+		},
+		{
+			name:    "version 2",
+			records: version2,
+			version: arc.Version2,
+			want: []want{
+				{warc.WARCTypeWarcinfo, "", "text/plain", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), arc.Header{}},
+				{warc.WARCTypeResponse, "http://www.example.com/a page", "application/http; msgtype=response", time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC),
+					arc.Header{ResultCode: 200, Checksum: "CHECKSUM", Filename: "IA-002.arc"}},
+				{warc.WARCTypeResource, "http://www.example.com/old", "", time.Date(2000, 1, 1, 0, 0, 2, 0, time.UTC),
+					arc.Header{ResultCode: 302, Location: "http://www.example.com/new", Offset: 100, Filename: "IA-002.arc"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, compressed := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s compressed=%v", tt.name, compressed), func(t *testing.T) {
+				data, offsets := buildARC(t, tt.records, compressed)
+				reader, err := arc.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if reader.FileDesc.Version != tt.version || reader.FileDesc.Origin != "Alexa Internet" || len(reader.FileDesc.Fields) != 5*int(tt.version) {
+					t.Errorf("FileDesc = %+v", reader.FileDesc)
+				}
+
+				var records warc.RecordReader = reader
+				for i, w := range tt.want {
+					record, err := records.Next()
+					if err != nil {
+						t.Fatalf("Next() record %d: %v", i, err)
+					}
+					if record.Type != w.typ || record.TargetURI != w.uri || record.ContentType != w.contentType || !record.Date.Equal(w.date) {
+						t.Errorf("record %d: header = %+v", i, record.WARCRecord)
+					}
+					if record.Offset != offsets[i] {
+						t.Errorf("record %d: Offset = %d, want %d", i, record.Offset, offsets[i])
+					}
+					header := reader.Header()
+					if header.ResultCode != w.header.ResultCode || header.Checksum != w.header.Checksum || header.Location != w.header.Location ||
+						header.Offset != w.header.Offset || header.Filename != w.header.Filename {
+						t.Errorf("record %d: Header() = %+v", i, header)
+					}
+					if i == 1 {
+						// The block of the first URL record is left unread, so that Next has to skip it
+						continue
+					}
+					block, err := io.ReadAll(record.Block)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if string(block) != tt.records[i].block || record.ContentLength != uint64(len(block)) {
+						t.Errorf("record %d: block = %q, ContentLength %d", i, block, record.ContentLength)
+					}
+				}
+				if _, err := records.Next(); err != io.EOF {
+					t.Errorf("Next() at end = %v, want io.EOF", err)
+				}
+			})
+		}
+	}
+}
+
+func TestReaderFileDesc(t *testing.T) {
+	metadata := `<?xml version="1.0" encoding="UTF-8"?><arcmetadata/>`
+	data, _ := buildARC(t, []arcRecord{
+		{"filedesc://IA-003.arc 0.0.0.0 20080101000000 text/plain", "1 1 InternetArchive\nURL IP-address Archive-date Content-type Archive-length\n" + metadata + "\n"},
+	}, false)
+	reader, err := arc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.FileDesc.URL != "filedesc://IA-003.arc" || string(reader.FileDesc.Metadata) != metadata || reader.FileDesc.Origin != "InternetArchive" {
+		t.Errorf("FileDesc = %+v", reader.FileDesc)
+	}
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Filename != "IA-003.arc" {
+		t.Errorf("Filename = %q, want IA-003.arc", record.Filename)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	filedesc := version1[0]
+	tests := []struct {
+		name     string
+		records  []arcRecord
+		category warc.ErrorCategory
+		field    string
+	}{
+		{
+			name:     "no version block",
+			records:  version1[1:],
+			category: warc.CategorySyntax,
+			field:    "URL",
+		},
+		{
+			name:     "unsupported version",
+			records:  []arcRecord{{filedesc.line, "3 0 Alexa Internet\nURL\n"}},
+			category: warc.CategoryVersion,
+		},
+		{
+			name:     "too few fields",
+			records:  []arcRecord{filedesc, {"http://www.example.com/ 192.0.2.1", ""}},
+			category: warc.CategorySyntax,
+		},
+		{
+			name:     "invalid date",
+			records:  []arcRecord{filedesc, {"http://www.example.com/ 192.0.2.1 yesterday text/html", ""}},
+			category: warc.CategorySyntax,
+			field:    "Archive-date",
+		},
+		{
+			name:     "long header line",
+			records:  []arcRecord{filedesc, {"http://www.example.com/" + strings.Repeat("a", 1<<17) + " 192.0.2.1 19960923142103 text/html", ""}},
+			category: warc.CategoryLimits,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := buildARC(t, tt.records, false)
+			reader, err := arc.NewReader(bytes.NewReader(data))
+			if err == nil {
+				_, err = reader.Next()
+				if err == nil {
+					_, err = reader.Next()
+				}
+			}
+			var parseErr *warc.ParseError
+			if !errors.As(err, &parseErr) || parseErr.Category != tt.category || parseErr.Field != tt.field {
+				t.Errorf("error = %v, want a %v error on %q", err, tt.category, tt.field)
+			}
+		})
+	}
+}
+
+func TestReaderShortBlock(t *testing.T) {
+	data, _ := buildARC(t, version1[:2], false)
+	data = data[:len(data)-10]
+	reader, err := arc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Next()
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(record.Block); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAll() error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,314 @@
+package cdx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// CDXJRecord is a record of a CDXJ file: a CDXRecord, and the keys of its JSON block that
+// map to none of its fields.
+type CDXJRecord struct {
+	CDXRecord
+
+	// Extras holds the keys that map to none of the fields of CDXRecord
+	Extras map[string]any
+}
+
+// cdxjKeys gives the field of the keys written by pywb and Common Crawl in the JSON block
+// of CDXJ records, in the order they are written. Other fields go by their json tag.
+var cdxjKeys = []struct {
+	key   string
+	field CDXField
+}{
+	{"url", FieldOriginalURL},
+	{"mime", FieldMIMEType},
+	{"status", FieldStatusCode},
+	{"digest", FieldNewChecksum},
+	{"length", FieldCompressedSize},
+	{"offset", FieldCompressedArcOffset},
+	{"filename", FieldFilename},
+}
+
+// cdxjField returns the field a JSON key of a CDXJ record maps to, if any.
+func cdxjField(key string) (CDXField, bool) {
+	for _, k := range cdxjKeys {
+		if k.key == key {
+			return k.field, true
+		}
+	}
+	t := reflect.TypeOf(CDXRecord{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("json") == key {
+			return CDXField(f.Tag.Get("cdx")[0]), true
+		}
+	}
+	return 0, false
+}
+
+// MarshalCDXJ returns the record as a CDXJ line, without line feed: its MassagedURL, as the
+// SURT key, its Date, and a JSON block of its other fields that are set, then its Extras.
+// The fields pywb knows go first under its keys, with numbers given as strings as it
+// does, and the others under their json tag.
+func (r CDXJRecord) MarshalCDXJ() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(orDash(r.MassagedURL))
+	buf.WriteByte(' ')
+	if r.Date.IsZero() {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteString(r.Date.UTC().Format(CDXTimestampFormat))
+	}
+	buf.WriteString(" {")
+
+	v := reflect.ValueOf(r.CDXRecord)
+	t := v.Type()
+	n := 0
+	write := func(key string, value any) error {
+		data, err := jsonValue(value)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		if n > 0 {
+			buf.WriteString(", ")
+		}
+		n++
+		keyData, _ := jsonValue(key)
+		buf.Write(keyData)
+		buf.WriteString(": ")
+		buf.Write(data)
+		return nil
+	}
+	value := func(field CDXField) (reflect.Value, bool) {
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("cdx") == string(field) {
+				return v.Field(i), true
+			}
+		}
+		return reflect.Value{}, false
+	}
+
+	for _, k := range cdxjKeys {
+		if f, ok := value(k.field); ok {
+			if s := formatField(f); s != "-" {
+				if err := write(k.key, s); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("cdx")
+		if tag == string(FieldMassagedURL) || tag == string(FieldDate) {
+			continue
+		}
+		if isCDXJKey(CDXField(tag[0])) {
+			continue
+		}
+		key := t.Field(i).Tag.Get("json")
+		f := v.Field(i)
+		s := formatField(f)
+		if s == "-" {
+			continue
+		}
+		var err error
+		if f.Kind() == reflect.String {
+			err = write(key, s)
+		} else {
+			err = write(key, json.Number(s))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(r.Extras))
+	for key := range r.Extras {
+		if _, known := cdxjField(key); !known {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := write(key, r.Extras[key]); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// isCDXJKey reports whether field is written under one of the pywb keys.
+func isCDXJKey(field CDXField) bool {
+	for _, k := range cdxjKeys {
+		if k.field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalCDXJ parses a CDXJ line into the record. The JSON keys of pywb and the json tags
+// of the fields set the fields, and other keys are kept in Extras, with numbers as
+// json.Number. Numbers may be given as JSON numbers or strings.
+func (r *CDXJRecord) UnmarshalCDXJ(line []byte) error {
+	key, rest, found := strings.Cut(strings.TrimSpace(string(line)), " ")
+	if !found {
+		return errors.New("missing timestamp")
+	}
+	timestamp, block, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+	block = strings.TrimSpace(block)
+
+	*r = CDXJRecord{}
+	if key != "-" {
+		r.MassagedURL = key
+	}
+	if timestamp != "-" {
+		date, err := parseCDXJTimestamp(timestamp)
+		if err != nil {
+			return err
+		}
+		r.Date = date
+	}
+	if block == "" {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(block))
+	dec.UseNumber()
+	var fields map[string]any
+	if err := dec.Decode(&fields); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("data after the JSON block")
+	}
+	for name, value := range fields {
+		field, known := cdxjField(name)
+		var s string
+		switch value := value.(type) {
+		case string:
+			s = value
+		case json.Number:
+			s = value.String()
+		default:
+			known = false
+		}
+		if !known {
+			if r.Extras == nil {
+				r.Extras = make(map[string]any)
+			}
+			r.Extras[name] = value
+			continue
+		}
+		if s == "" {
+			continue
+		}
+		if err := setField(&r.CDXRecord, field, s); err != nil {
+			return fmt.Errorf("key %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// MarshalCDXJ returns records as CDXJ lines, in the order given. Sorted CDXJ files, as
+// lookups expect, have their records sorted by key and timestamp.
+func MarshalCDXJ(records []CDXJRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, record := range records {
+		line, err := record.MarshalCDXJ()
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCDXJ parses CDXJ lines. Empty lines and lines starting with "!", which hold
+// metadata in some CDXJ files, are skipped. Parse failures are *ParseErrors.
+func UnmarshalCDXJ(data []byte) ([]CDXJRecord, error) {
+	var records []CDXJRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 || line[0] == '!' {
+			continue
+		}
+		var record CDXJRecord
+		if err := record.UnmarshalCDXJ(line); err != nil {
+			return nil, &ParseError{Category: warc.CategorySyntax, Line: lineNumber, Err: err}
+		}
+		records = append(records, record)
+	}
+	return records, scanError(scanner.Err(), lineNumber+1)
+}
+
+// parseCDXJTimestamp parses a timestamp of 4 to 14 digits, as pywb does: the digits
+// missing are those of padDown, as in "2024" for the start of 2024, "20241" for November
+// 2024 and "2024053" for May 31, and values out of range are brought within it, as in
+// "202400" for January 2024.
+func parseCDXJTimestamp(timestamp string) (time.Time, error) {
+	if len(timestamp) < 4 || len(timestamp) > len(CDXTimestampFormat) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if _, err := strconv.ParseUint(timestamp, 10, 64); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	digits := []byte(timestamp + padDown[len(timestamp):])
+	number := func(i int) int {
+		n, _ := strconv.Atoi(string(digits[i : i+2]))
+		return n
+	}
+	year, _ := strconv.Atoi(string(digits[:4]))
+	month := clamp(number(4), 1, 12)
+	lastDay := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return time.Date(year, time.Month(month), clamp(number(6), 1, lastDay),
+		clamp(number(8), 0, 23), clamp(number(10), 0, 59), clamp(number(12), 0, 59), 0, time.UTC), nil
+}
+
+// padDown completes partial timestamps, as PAD_14_DOWN of pywb
+const padDown = "10000101000000"
+
+// clamp returns n brought within [low, high].
+func clamp(n, low, high int) int {
+	if n < low {
+		return low
+	}
+	if n > high {
+		return high
+	}
+	return n
+}
+
+// jsonValue encodes v as JSON, without escaping HTML characters.
+func jsonValue(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// orDash returns s, or "-" for an empty s.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
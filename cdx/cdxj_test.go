@@ -0,0 +1,167 @@
+package cdx
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// CDXRecord stays comparable, and usable as a map key, the extras of CDXJ being kept apart
+var _ = map[CDXRecord]bool{CDXRecord{}: CDXRecord{} == CDXRecord{}}
+
+func TestMarshalCDXJ(t *testing.T) {
+	records := []CDXJRecord{
+		{
+			CDXRecord: CDXRecord{
+				MassagedURL:         "com,example)/",
+				Date:                time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				OriginalURL:         "http://example.com/?a=1&b=<2>",
+				MIMEType:            "text/html",
+				StatusCode:          200,
+				NewChecksum:         "ZMSA5TNJUKKRYAIM5PRUJLL24DV7QYOO",
+				CompressedSize:      1043,
+				CompressedArcOffset: 333,
+				Filename:            "example.warc.gz",
+				Title:               "Example",
+				Redirect:            "-",
+			},
+			Extras: map[string]any{"languages": "eng", "charset": "UTF-8", "url": "ignored"},
+		},
+		{
+			CDXRecord: CDXRecord{
+				MassagedURL: "com,example)/missing",
+				Date:        time.Date(2024, 1, 1, 10, 0, 1, 0, time.UTC),
+				StatusCode:  404,
+				Port:        8080,
+			},
+		},
+	}
+	want := `com,example)/ 20240101100000 {"url": "http://example.com/?a=1&b=<2>", "mime": "text/html", "status": "200", ` +
+		`"digest": "ZMSA5TNJUKKRYAIM5PRUJLL24DV7QYOO", "length": "1043", "offset": "333", "filename": "example.warc.gz", ` +
+		`"title": "Example", "charset": "UTF-8", "languages": "eng"}` + "\n" +
+		`com,example)/missing 20240101100001 {"status": "404", "port": 8080}` + "\n"
+	got, err := MarshalCDXJ(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("MarshalCDXJ() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnmarshalCDXJ(t *testing.T) {
+	data := `!meta {"format": "cdxj-gwarc"}
+com,example)/ 20240101100000 {"url": "http://example.com/", "mime": "text/html", "status": "200", "digest": "sha1:ZMSA", "length": "1043", "offset": 333, "filename": "a.warc.gz", "title": "Example", "languages": "eng", "recordNumber": 7, "nested": {"a": [1]}}
+
+com,example)/page 2024 {"url": "http://example.com/page"}
+`
+	records, err := UnmarshalCDXJ([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CDXJRecord{
+		{
+			CDXRecord: CDXRecord{
+				MassagedURL:         "com,example)/",
+				Date:                time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				OriginalURL:         "http://example.com/",
+				MIMEType:            "text/html",
+				StatusCode:          200,
+				NewChecksum:         "sha1:ZMSA",
+				CompressedSize:      1043,
+				CompressedArcOffset: 333,
+				Filename:            "a.warc.gz",
+				Title:               "Example",
+			},
+			Extras: map[string]any{
+				"languages":    "eng",
+				"recordNumber": json.Number("7"),
+				"nested":       map[string]any{"a": []any{json.Number("1")}},
+			},
+		},
+		{
+			CDXRecord: CDXRecord{
+				MassagedURL: "com,example)/page",
+				Date:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				OriginalURL: "http://example.com/page",
+			},
+		},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("UnmarshalCDXJ() =\n%+v\nwant\n%+v", records, want)
+	}
+
+	// Extras and fields survive a round trip
+	again, err := MarshalCDXJ(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, err = UnmarshalCDXJ(again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("after a round trip:\n%+v\nwant\n%+v", records, want)
+	}
+}
+
+func TestParseCDXJTimestamp(t *testing.T) {
+	tests := []struct {
+		timestamp string
+		want      time.Time
+	}{
+		{"20240315101112", time.Date(2024, 3, 15, 10, 11, 12, 0, time.UTC)},
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"20240", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"20241", time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"202412", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"202400", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"202413", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024050", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024053", time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{"2024063", time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{"2024023", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"2024010110", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"20240101259999", time.Date(2024, 1, 1, 23, 59, 59, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseCDXJTimestamp(tt.timestamp)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseCDXJTimestamp(%q) = %v, %v, want %v", tt.timestamp, got, err, tt.want)
+		}
+	}
+	for _, timestamp := range []string{"", "202", "2024x", "202401011000000"} {
+		if _, err := parseCDXJTimestamp(timestamp); err == nil {
+			t.Errorf("parseCDXJTimestamp(%q) = nil error", timestamp)
+		}
+	}
+}
+
+func TestUnmarshalCDXJErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		line int
+	}{
+		{"missing timestamp", "com,example)/\n", 1},
+		{"invalid timestamp", "com,example)/ 2024x {}\n", 1},
+		{"invalid JSON", "com,example)/ 20240101 {}\ncom,example)/ 20240101 {\"url\": }\n", 2},
+		{"invalid status", `com,example)/ 20240101 {"status": "OK"}` + "\n", 1},
+		{"trailing data", `com,example)/ 20240101 {} {}` + "\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnmarshalCDXJ([]byte(tt.data))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("got %v, want a *ParseError", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Category != warc.CategorySyntax {
+				t.Errorf("got line %d, category %s, want line %d", parseErr.Line, parseErr.Category, tt.line)
+			}
+		})
+	}
+}
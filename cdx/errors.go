@@ -0,0 +1,29 @@
+package cdx
+
+import (
+	"fmt"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// ParseError reports a line of CDX data that could not be parsed. Its Category is one of
+// the categories of warc.ParseError, so that failures of both packages can be classified alike.
+type ParseError struct {
+	Category warc.ErrorCategory
+	// Line is the line at fault, counting the header as 1
+	Line int
+	// Field is the field at fault, or 0
+	Field CDXField
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field != 0 {
+		return fmt.Sprintf("line %d, field %c: %s error: %v", e.Line, e.Field, e.Category, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s error: %v", e.Line, e.Category, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,66 @@
+package cdx
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		category warc.ErrorCategory
+		line     int
+		field    CDXField
+	}{
+		{
+			name:     "empty input",
+			input:    "",
+			category: warc.CategorySyntax,
+			line:     1,
+		},
+		{
+			name:     "invalid header",
+			input:    "INVALID N b a m s k r V g",
+			category: warc.CategorySyntax,
+			line:     1,
+		},
+		{
+			name:     "invalid field count",
+			input:    "CDX N b a\nhttp://example.com/ 20010424210312 http://example.com/\nhttp://example.com/ 20010424210312",
+			category: warc.CategorySyntax,
+			line:     3,
+		},
+		{
+			name:     "invalid date",
+			input:    "CDX N b a\n\nhttp://example.com/ yesterday http://example.com/",
+			category: warc.CategorySyntax,
+			line:     3,
+			field:    FieldDate,
+		},
+		{
+			name:     "line too long",
+			input:    "CDX N b a\n" + strings.Repeat("a", bufio.MaxScanTokenSize+1),
+			category: warc.CategoryLimits,
+			line:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got CDXFile
+			err := Unmarshal([]byte(tt.input), &got)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Unmarshal() error = %v, want a *ParseError", err)
+			}
+			if parseErr.Category != tt.category || parseErr.Line != tt.line || parseErr.Field != tt.field {
+				t.Errorf("Unmarshal() error = %+v, want %v error on line %d, field %q", parseErr, tt.category, tt.line, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package cdx
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/iocount"
+	"github.com/zenless-lab/gwarc/internal/mediatype"
+	"github.com/zenless-lab/gwarc/surt"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Index reads the WARC file name, plain or compressed, from r and returns the CDX records of
+// its response, revisit and resource records, in file order. Each has its fields N, b, a,
+// m, s, k, r, S, V and g set, as pywb and OpenWayback index them:
+//
+//   - N is the SURT of the target URI, canonicalized as pywb does, and a the URI as is;
+//   - m and s are the media type and status of HTTP responses, and m the media type of
+//     the block of other records;
+//   - k is the payload digest, without its algorithm, computed for responses without one;
+//   - r is the Location of HTTP responses;
+//   - S and V are the length and offset of the record in the file, compressed if the file is.
+//
+// Revisit records are indexed with the media type "warc/revisit" and the status of the
+// HTTP response head they may hold; their digest is that of the payload they revisit.
+// All of r is read, so that the length of the last record is known.
+func Index(name string, r io.Reader) ([]CDXRecord, error) {
+	counter := iocount.NewReader(r)
+	reader, err := warc.NewReader(counter)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []CDXRecord
+	last := -1
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if last >= 0 {
+			records[last].CompressedSize = record.Offset - records[last].CompressedArcOffset
+			last = -1
+		}
+		entry, ok := indexRecord(record)
+		if !ok {
+			continue
+		}
+		entry.Filename = name
+		records = append(records, entry)
+		last = len(records) - 1
+	}
+	// Read whatever follows the last record, so that all of r is counted
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return nil, err
+	}
+	if last >= 0 {
+		records[last].CompressedSize = counter.N() - records[last].CompressedArcOffset
+	}
+	return records, nil
+}
+
+// IndexFile indexes the WARC file at path, named by its base name in g.
+func IndexFile(path string) ([]CDXRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Index(filepath.Base(path), f)
+}
+
+// SortRecords sorts records by N and b, the order of CDX files that lookups expect.
+func SortRecords(records []CDXRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].MassagedURL != records[j].MassagedURL {
+			return records[i].MassagedURL < records[j].MassagedURL
+		}
+		return records[i].Date.Before(records[j].Date)
+	})
+}
+
+// indexRecord returns the CDX record of a response, revisit or resource record.
+func indexRecord(record *warc.Record) (CDXRecord, bool) {
+	entry := CDXRecord{
+		MassagedURL:         surt.SURT(record.TargetURI),
+		Date:                record.Date,
+		OriginalURL:         escapeSpaces(record.TargetURI),
+		CompressedArcOffset: record.Offset,
+	}
+	digest := record.PayloadDigest
+	switch record.Type {
+	case warc.WARCTypeResponse, warc.WARCTypeRevisit:
+		if !strings.HasPrefix(record.ContentType, "application/http") {
+			entry.MIMEType = mediatype.Of(record.ContentType)
+			if digest == "" {
+				digest = record.BlockDigest
+			}
+			break
+		}
+		block := bufio.NewReader(record.Block)
+		if resp, err := http.ReadResponse(block, nil); err == nil {
+			entry.StatusCode = resp.StatusCode
+			entry.MIMEType = mediatype.Of(resp.Header.Get("Content-Type"))
+			entry.Redirect = escapeSpaces(resp.Header.Get("Location"))
+			if digest == "" && record.Type == warc.WARCTypeResponse {
+				// The payload is what follows the head, without its chunked coding
+				chunked := len(resp.TransferEncoding) > 0 && resp.TransferEncoding[len(resp.TransferEncoding)-1] == "chunked"
+				if payloadDigest, err := warc.DigestPayload(block, chunked); err == nil {
+					digest = payloadDigest
+				}
+			}
+		}
+	case warc.WARCTypeResource:
+		entry.MIMEType = mediatype.Of(record.ContentType)
+		if digest == "" {
+			digest = record.BlockDigest
+		}
+	default:
+		return entry, false
+	}
+	if record.Type == warc.WARCTypeRevisit {
+		entry.MIMEType = "warc/revisit"
+	}
+	// As pywb does, only the label of SHA-1, the digest of CDX files, is dropped, so that
+	// digests of other algorithms are not taken for one
+	if len(digest) >= 5 && strings.EqualFold(digest[:5], "sha1:") {
+		digest = digest[5:]
+	}
+	entry.NewChecksum = digest
+	return entry, true
+}
+
+// escapeSpaces escapes the spaces of a URL, which would split its CDX field.
+func escapeSpaces(url string) string {
+	return strings.ReplaceAll(url, " ", "%20")
+}
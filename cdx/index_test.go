@@ -0,0 +1,230 @@
+package cdx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// indexTestWARC returns a WARC file of a request, a response, a redirect, a revisit, a
+// resource and a metadata record, with the records written.
+func indexTestWARC(t *testing.T, compression warc.Compression) ([]byte, []*warc.WARCRecord) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	payload := "<p>Hello</p>"
+	records := []*warc.WARCRecord{
+		{
+			Type:        warc.WARCTypeRequest,
+			Date:        date,
+			TargetURI:   "http://www.example.com/",
+			ContentType: warc.ContentTypeHTTPRequest,
+			Content:     []byte("GET / HTTP/1.1\r\nHost: www.example.com\r\n\r\n"),
+		},
+		{
+			Type:        warc.WARCTypeResponse,
+			Date:        date,
+			TargetURI:   "http://www.example.com/",
+			ContentType: warc.ContentTypeHTTPResponse,
+			Content: []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\nTransfer-Encoding: chunked\r\n\r\n" +
+				"c\r\n" + payload + "\r\n0\r\n\r\n"),
+		},
+		{
+			Type:          warc.WARCTypeResponse,
+			Date:          date,
+			TargetURI:     "https://Example.com:443/old page",
+			ContentType:   warc.ContentTypeHTTPResponse,
+			PayloadDigest: "sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ",
+			Content:       []byte("HTTP/1.1 301 Moved Permanently\r\nLocation: https://example.com/new page\r\n\r\n"),
+		},
+		{
+			Type:          warc.WARCTypeRevisit,
+			Date:          date.Add(time.Hour),
+			TargetURI:     "http://www.example.com/",
+			ContentType:   warc.ContentTypeHTTPResponse,
+			PayloadDigest: warc.Digest([]byte(payload)),
+			Profile:       "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest",
+			Content:       []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"),
+		},
+		{
+			Type:        warc.WARCTypeResource,
+			Date:        date,
+			TargetURI:   "file:///reports/q1.pdf",
+			ContentType: "application/pdf",
+			Content:     []byte("%PDF"),
+		},
+		{
+			Type:        warc.WARCTypeMetadata,
+			Date:        date,
+			TargetURI:   "http://www.example.com/",
+			ContentType: warc.ContentTypeWARCFields,
+			Content:     []byte("fetchTimeMs: 12\r\n"),
+		},
+	}
+	for _, record := range records {
+		record.RecordID = warc.NewRecordID()
+	}
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf)
+	w.Compression = compression
+	for _, record := range records {
+		if err := w.WriteRecords(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), records
+}
+
+func TestIndex(t *testing.T) {
+	for _, compression := range []warc.Compression{warc.CompressionNone, warc.CompressionGzip, warc.CompressionZstd} {
+		data, records := indexTestWARC(t, compression)
+		index, err := Index("test.warc", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []CDXRecord{
+			{
+				MassagedURL: "com,example)/",
+				OriginalURL: "http://www.example.com/",
+				MIMEType:    "text/html",
+				StatusCode:  200,
+				NewChecksum: strings.TrimPrefix(warc.Digest([]byte("<p>Hello</p>")), "sha1:"),
+			},
+			{
+				MassagedURL: "com,example)/old%20page",
+				OriginalURL: "https://Example.com:443/old%20page",
+				StatusCode:  301,
+				NewChecksum: "3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ",
+				Redirect:    "https://example.com/new%20page",
+			},
+			{
+				MassagedURL: "com,example)/",
+				OriginalURL: "http://www.example.com/",
+				MIMEType:    "warc/revisit",
+				StatusCode:  200,
+				NewChecksum: strings.TrimPrefix(warc.Digest([]byte("<p>Hello</p>")), "sha1:"),
+			},
+			{
+				MassagedURL: "file:///reports/q1.pdf",
+				OriginalURL: "file:///reports/q1.pdf",
+				MIMEType:    "application/pdf",
+				NewChecksum: strings.TrimPrefix(warc.Digest([]byte("%PDF")), "sha1:"),
+			},
+		}
+		sources := []*warc.WARCRecord{records[1], records[2], records[3], records[4]}
+		if len(index) != len(want) {
+			t.Fatalf("compression %d: got %d records, want %d", compression, len(index), len(want))
+		}
+		end := int64(0)
+		for i, got := range index {
+			w := want[i]
+			if got.MassagedURL != w.MassagedURL || got.OriginalURL != w.OriginalURL || got.MIMEType != w.MIMEType ||
+				got.StatusCode != w.StatusCode || got.NewChecksum != w.NewChecksum || got.Redirect != w.Redirect ||
+				!got.Date.Equal(sources[i].Date) || got.Filename != "test.warc" {
+				t.Errorf("compression %d, record %d:\n%+v\nwant\n%+v", compression, i, got, w)
+			}
+			if got.CompressedArcOffset < end || got.CompressedSize <= 0 {
+				t.Errorf("compression %d, record %d: offset %d, length %d", compression, i, got.CompressedArcOffset, got.CompressedSize)
+			}
+			end = got.CompressedArcOffset + got.CompressedSize
+			record, err := warc.ReadRecordAt(bytes.NewReader(data), int64(len(data)), got.CompressedArcOffset)
+			if err != nil {
+				t.Fatalf("compression %d, record %d: %v", compression, i, err)
+			}
+			if record.RecordID != sources[i].RecordID {
+				t.Errorf("compression %d, record %d: offset of %s, want %s", compression, i, record.RecordID, sources[i].RecordID)
+			}
+		}
+	}
+}
+
+func TestIndexDigests(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	sha256Digest := "sha256:4c9bd4a27d4bd1a1ed3d1e8ebeae6b3f2a5c9b3d0f4c2c2e0e1e8b1c9a7b0d3e"
+	records := []*warc.WARCRecord{
+		{
+			RecordID:      warc.NewRecordID(),
+			Type:          warc.WARCTypeResponse,
+			Date:          date,
+			TargetURI:     "http://example.com/",
+			ContentType:   warc.ContentTypeHTTPResponse,
+			PayloadDigest: sha256Digest,
+			Content:       []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<p>Hello</p>"),
+		},
+		{
+			RecordID:      warc.NewRecordID(),
+			Type:          warc.WARCTypeRevisit,
+			Date:          date.Add(time.Hour),
+			TargetURI:     "http://example.com/",
+			ContentType:   warc.ContentTypeHTTPResponse,
+			PayloadDigest: sha256Digest,
+			Profile:       "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest",
+			Content:       []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"),
+		},
+		{
+			RecordID:      warc.NewRecordID(),
+			Type:          warc.WARCTypeResponse,
+			Date:          date,
+			TargetURI:     "http://example.com/other",
+			ContentType:   warc.ContentTypeHTTPResponse,
+			PayloadDigest: "SHA1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ",
+			Content:       []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<p>Other</p>"),
+		},
+	}
+	var buf bytes.Buffer
+	if err := warc.NewWriter(&buf).WriteRecords(records[0], records[1], records[2]); err != nil {
+		t.Fatal(err)
+	}
+	index, err := Index("test.warc", bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The label of a digest other than SHA-1 is kept, not to be taken for one
+	want := []string{sha256Digest, sha256Digest, "3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ"}
+	if len(index) != len(want) {
+		t.Fatalf("got %d records, want %d", len(index), len(want))
+	}
+	for i, record := range index {
+		if record.NewChecksum != want[i] {
+			t.Errorf("record %d: digest %s, want %s", i, record.NewChecksum, want[i])
+		}
+	}
+}
+
+func TestIndexFormats(t *testing.T) {
+	data, _ := indexTestWARC(t, warc.CompressionNone)
+	index, err := Index("test.warc", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SortRecords(index)
+	for _, format := range []CDXFormat{CDX9, CDX11} {
+		file := NewCDXFile(format)
+		file.Records = index
+		out, err := Marshal(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+		if lines[0] != format.String() || len(lines) != len(index)+1 {
+			t.Fatalf("%s: got\n%s", format, out)
+		}
+		wantKeys := []string{"com,example)/ 20240101100000", "com,example)/ 20240101110000",
+			"com,example)/old%20page 20240101100000", "file:///reports/q1.pdf 20240101100000"}
+		for i, line := range lines[1:] {
+			fields := strings.Split(line, " ")
+			if len(fields) != len(format) || !strings.HasPrefix(line, wantKeys[i]+" ") {
+				t.Errorf("%s: line %q", format, line)
+			}
+		}
+
+		var parsed CDXFile
+		if err := Unmarshal(out, &parsed); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(parsed.Records) != len(index) || parsed.Records[1].MIMEType != "warc/revisit" {
+			t.Errorf("%s: read back %+v", format, parsed.Records)
+		}
+	}
+}
@@ -9,22 +9,27 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
 )
 
-// Unmarshal parses CDX formatted data and stores the result in v
+// Unmarshal parses CDX formatted data and stores the result in v. Parse failures are *ParseErrors.
 func Unmarshal[T any](data []byte, v T) error {
 	// Create scanner to read lines
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 
 	// Read header line
 	if !scanner.Scan() {
-		return errors.New("empty CDX file")
+		if err := scanError(scanner.Err(), 1); err != nil {
+			return err
+		}
+		return &ParseError{Category: warc.CategorySyntax, Line: 1, Err: errors.New("empty CDX file")}
 	}
 
 	// Parse header
 	header := scanner.Text()
 	if !strings.HasPrefix(header, "CDX") {
-		return fmt.Errorf("invalid CDX header: %s", header)
+		return &ParseError{Category: warc.CategorySyntax, Line: 1, Err: fmt.Errorf("invalid CDX header: %s", header)}
 	}
 
 	// Parse format from header
@@ -38,7 +43,9 @@ func Unmarshal[T any](data []byte, v T) error {
 	cdxFile := NewCDXFile(format)
 
 	// Parse records
+	lineNumber := 1
 	for scanner.Scan() {
+		lineNumber++
 		line := scanner.Text()
 		if line == "" {
 			continue
@@ -47,7 +54,7 @@ func Unmarshal[T any](data []byte, v T) error {
 		// Split line into fields
 		parts := strings.Fields(line)
 		if len(parts) != len(format) {
-			return fmt.Errorf("invalid record length: got %d, want %d", len(parts), len(format))
+			return &ParseError{Category: warc.CategorySyntax, Line: lineNumber, Err: fmt.Errorf("invalid record length: got %d, want %d", len(parts), len(format))}
 		}
 
 		// Create new record
@@ -57,7 +64,7 @@ func Unmarshal[T any](data []byte, v T) error {
 		for i, field := range format {
 			value := parts[i]
 			if err := setField(&record, field, value); err != nil {
-				return fmt.Errorf("error parsing field %c: %v", field, err)
+				return &ParseError{Category: warc.CategorySyntax, Line: lineNumber, Field: field, Err: err}
 			}
 		}
 
@@ -72,7 +79,20 @@ func Unmarshal[T any](data []byte, v T) error {
 	rv = rv.Elem()
 	rv.Set(reflect.ValueOf(*cdxFile))
 
-	return scanner.Err()
+	return scanError(scanner.Err(), lineNumber+1)
+}
+
+// scanError returns a *ParseError for an error of the scanner reading the given line.
+// Lines too long to scan are in warc.CategoryLimits.
+func scanError(err error, line int) error {
+	if err == nil {
+		return nil
+	}
+	category := warc.CategorySyntax
+	if errors.Is(err, bufio.ErrTooLong) {
+		category = warc.CategoryLimits
+	}
+	return &ParseError{Category: category, Line: line, Err: err}
 }
 
 // setField sets a field in the CDX record based on its type
@@ -84,6 +104,10 @@ func setField(record *CDXRecord, field CDXField, value string) error {
 		tag := rv.Type().Field(i).Tag.Get("cdx")
 		if tag == string(field) {
 			f := rv.Field(i)
+			if value == "-" && f.Kind() != reflect.String {
+				// An empty value, as written by Marshal for zero numbers and times
+				return nil
+			}
 
 			switch f.Kind() {
 			case reflect.String:
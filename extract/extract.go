@@ -0,0 +1,297 @@
+// Package extract writes the documents archived in WARC files to a directory tree, one file
+// for each payload under its host and path, with a manifest mapping the files back to the
+// records they came from.
+package extract
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenless-lab/gwarc/internal/mediatype"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Entry maps a file written by an Extractor to the record its payload came from.
+type Entry struct {
+	// Path is the slash-separated path of the file, relative to the directory of the
+	// Extractor
+	Path string
+	// URI is the target URI of the record
+	URI string
+	// RecordID and Date are those of the record
+	RecordID string
+	Date     time.Time
+	// ContentType is the media type of the payload
+	ContentType string
+	// Size is the length of the file, and SHA256 the hex digest of its content
+	Size   int64
+	SHA256 string
+	// Truncated is the WARC-Truncated reason of a record whose payload was cut short
+	Truncated warc.TruncatedReason
+}
+
+// Extractor writes the payloads of response and resource records to files under a
+// directory, at host/path/name, where the name is the last segment of the path, with the
+// query string after an "@" and an extension for the media type of the payload. Responses
+// other than successful HTTP responses with full content are skipped, as are records
+// of other types.
+//
+// Payloads are decoded from their transfer and content codings; those in a content coding
+// that cannot be decoded, such as br, are skipped rather than written still encoded. Names
+// that would collide with another file or directory, compared without regard to case as on
+// the file systems of Windows and macOS, are told apart with a "~2", "~3"... suffix, and
+// names too long are cut short and marked with a hash of the name in full.
+type Extractor struct {
+	// All keeps every capture of a URI: captures after the first are written with their
+	// timestamp as suffix. Otherwise only the latest capture is kept, replacing those
+	// written before it.
+	All bool
+	// MaxNameLength is the length in bytes of the longest file or directory name written;
+	// zero means DefaultMaxNameLength
+	MaxNameLength int
+
+	dir     string
+	entries []*Entry
+	// files and uris give the index in entries of every file written, by folded path, and
+	// of the latest capture of every URI
+	files map[string]int
+	uris  map[string]int
+	// dirs gives the path of every directory reserved, by folded path
+	dirs map[string]string
+}
+
+// NewExtractor returns an Extractor writing under the directory dir, created as needed.
+func NewExtractor(dir string) *Extractor {
+	return &Extractor{
+		dir:   dir,
+		files: make(map[string]int),
+		uris:  make(map[string]int),
+		dirs:  make(map[string]string),
+	}
+}
+
+// Extract reads records from r and writes their payloads. It may be called for several WARC
+// files in turn, the captures of all of them being kept or selected together.
+func (e *Extractor) Extract(r warc.RecordReader) error {
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.extract(record); err != nil {
+			return fmt.Errorf("record %s: %w", record.RecordID, err)
+		}
+	}
+}
+
+// Manifest returns the entries of the files written, in the order they were first written.
+func (e *Extractor) Manifest() []Entry {
+	entries := make([]Entry, len(e.entries))
+	for i, entry := range e.entries {
+		entries[i] = *entry
+	}
+	return entries
+}
+
+// WriteManifest writes the manifest to w as CSV, after a header row naming the columns:
+// path, uri, record_id, timestamp (as WARC-Date), content_type, size, sha256 and truncated.
+func (e *Extractor) WriteManifest(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "uri", "record_id", "timestamp", "content_type", "size", "sha256", "truncated"}); err != nil {
+		return err
+	}
+	for _, entry := range e.entries {
+		err := cw.Write([]string{
+			entry.Path,
+			entry.URI,
+			entry.RecordID,
+			entry.Date.UTC().Format(time.RFC3339Nano),
+			entry.ContentType,
+			strconv.FormatInt(entry.Size, 10),
+			entry.SHA256,
+			string(entry.Truncated),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// extract writes the payload of record, if it has one to extract.
+func (e *Extractor) extract(record *warc.Record) error {
+	var body io.Reader
+	var contentType string
+	switch {
+	case record.Type == warc.WARCTypeResponse && strings.HasPrefix(record.ContentType, "application/http"):
+		resp, err := http.ReadResponse(bufio.NewReader(record.Block), nil)
+		if err != nil {
+			// Not an HTTP response, and so no payload to extract
+			return nil
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.StatusCode == http.StatusNoContent ||
+			resp.StatusCode == http.StatusPartialContent {
+			return nil
+		}
+		contentType = resp.Header.Get("Content-Type")
+		if body, err = warc.DecodeContent(resp.Body, resp.Header.Get("Content-Encoding")); err != nil {
+			// Still encoded, the payload would not be of its media type
+			return nil
+		}
+	case record.Type == warc.WARCTypeResource:
+		contentType = record.ContentType
+		body = record.Block
+	default:
+		return nil
+	}
+	uri, err := url.Parse(record.TargetURI)
+	if err != nil || record.TargetURI == "" {
+		// Nowhere to write the payload
+		return nil
+	}
+
+	mediaType := mediatype.Of(contentType)
+	entry := Entry{
+		URI:         record.TargetURI,
+		RecordID:    record.RecordID,
+		Date:        record.Date,
+		ContentType: mediaType,
+		Truncated:   record.Truncated,
+	}
+	name := nameOf(uri, mediaType, e.maxNameLength())
+	previous, captured := e.uris[record.TargetURI]
+	var replaced *Entry
+	switch {
+	case !captured:
+		entry.Path = e.allocate(name, "")
+	case e.All:
+		entry.Path = e.allocate(name, "~"+record.Date.UTC().Format("20060102150405"))
+	case record.Date.Before(e.entries[previous].Date):
+		// A later capture is kept
+		return nil
+	default:
+		// The latest capture takes the place of the one before
+		replaced = e.entries[previous]
+		delete(e.files, fold(replaced.Path))
+		entry.Path = e.allocate(name, "")
+	}
+
+	if err := e.write(&entry, body); err != nil {
+		return err
+	}
+	if replaced != nil {
+		e.entries[previous] = &entry
+		e.files[fold(entry.Path)] = previous
+		if replaced.Path != entry.Path {
+			if err := os.Remove(e.filePath(replaced.Path)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	e.files[fold(entry.Path)] = len(e.entries)
+	if !captured {
+		e.uris[record.TargetURI] = len(e.entries)
+	}
+	e.entries = append(e.entries, &entry)
+	return nil
+}
+
+// allocate returns a path for the file named n with suffix, not taken by another file or
+// directory, and reserves its directories.
+func (e *Extractor) allocate(n fileName, suffix string) string {
+	max := e.maxNameLength()
+	dir := ""
+	for _, d := range n.dirs {
+		// A directory takes a suffix in the place of a file written before
+		candidate := path.Join(dir, d)
+		for i := 2; e.isFile(candidate); i++ {
+			candidate = path.Join(dir, shorten(d, numbered(i), max))
+		}
+		dir = candidate
+		if reserved, ok := e.dirs[fold(dir)]; ok {
+			// The directory is written under the case it was first given
+			dir = reserved
+		} else {
+			e.dirs[fold(dir)] = dir
+		}
+	}
+	p := path.Join(dir, shorten(n.stem, suffix+n.ext, max))
+	for i := 2; e.isFile(p) || e.isDir(p); i++ {
+		p = path.Join(dir, shorten(n.stem, suffix+numbered(i)+n.ext, max))
+	}
+	return p
+}
+
+// isFile reports whether a file was written at p, in any case.
+func (e *Extractor) isFile(p string) bool {
+	_, ok := e.files[fold(p)]
+	return ok
+}
+
+// isDir reports whether a directory was reserved at p, in any case.
+func (e *Extractor) isDir(p string) bool {
+	_, ok := e.dirs[fold(p)]
+	return ok
+}
+
+// write writes the payload body to the file of entry, and sets its size and digest.
+func (e *Extractor) write(entry *Entry, body io.Reader) error {
+	name := e.filePath(entry.Path)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	entry.Size, err = io.Copy(io.MultiWriter(f, h), partialReader{body})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return err
+}
+
+// filePath returns the path on disk of the file at the slash-separated path p.
+func (e *Extractor) filePath(p string) string {
+	return filepath.Join(e.dir, filepath.FromSlash(p))
+}
+
+func (e *Extractor) maxNameLength() int {
+	if e.MaxNameLength > 0 {
+		return e.MaxNameLength
+	}
+	return DefaultMaxNameLength
+}
+
+// partialReader ends at the first error reading r, so that a payload cut short, or
+// failing to decode part way, is written as far as it goes.
+type partialReader struct {
+	r io.Reader
+}
+
+func (p partialReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if err != nil {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,282 @@
+package extract_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/extract"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+var date = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// response returns a response record of an HTTP response with the given head and payload.
+func response(uri string, date time.Time, head, payload string) *warc.WARCRecord {
+	return &warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResponse,
+		Date:        date,
+		TargetURI:   uri,
+		ContentType: warc.ContentTypeHTTPResponse,
+		Content:     []byte(head + "\r\n" + payload),
+	}
+}
+
+// extract writes records to a WARC file and extracts it with e, returning the files
+// written under dir with their contents.
+func extract(t *testing.T, e *Extractor, dir string, records ...*warc.WARCRecord) map[string]string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf)
+	for _, record := range records {
+		if err := w.WriteRecords(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := warc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Extract(r); err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	err = filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		rel, _ := filepath.Rel(dir, name)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestExtract(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("body { color: red }"))
+	zw.Close()
+	long := strings.Repeat("x", 300)
+
+	records := []*warc.WARCRecord{
+		response("http://Example.com/", date, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n", "<p>Home</p>"),
+		response("http://example.com/style", date, "HTTP/1.1 200 OK\r\nContent-Type: text/css\r\nContent-Encoding: gzip\r\n", gz.String()),
+		response("http://example.com/news.php?id=1&lang=en", date,
+			"HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\nTransfer-Encoding: chunked\r\n",
+			"5\r\n<p>Ne\r\n6\r\nws</p>\r\n0\r\n\r\n"),
+		response("http://example.com/photo.jpeg", date, "HTTP/1.1 200 OK\r\nContent-Type: image/jpeg\r\n", "JPEG"),
+		response("http://example.com:8080/a/b%2Fc/d:e", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "data"),
+		response("http://example.com/"+long, date, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "long"),
+		response("http://example.com/missing", date, "HTTP/1.1 404 Not Found\r\nContent-Type: text/html\r\n", "Not found"),
+		response("http://example.com/moved", date, "HTTP/1.1 301 Moved Permanently\r\nLocation: /\r\n", ""),
+		// Not decoded, the payload is not HTML
+		response("http://example.com/brotli", date, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Encoding: br\r\n", "\x0b\x02\x80"),
+		{
+			RecordID:    warc.NewRecordID(),
+			Type:        warc.WARCTypeResource,
+			Date:        date,
+			TargetURI:   "file:///docs/report.pdf",
+			ContentType: "application/pdf",
+			Content:     []byte("%PDF"),
+		},
+	}
+	dir := t.TempDir()
+	e := NewExtractor(dir)
+	files := extract(t, e, dir, records...)
+
+	want := map[string]string{
+		"example.com/index.html":                 "<p>Home</p>",
+		"example.com/style.css":                  "body { color: red }",
+		"example.com/news.php@id=1&lang=en.html": "<p>News</p>",
+		"example.com/photo.jpeg":                 "JPEG",
+		"example.com_8080/a/b_c/d_e":             "data",
+		"file/docs/report.pdf":                   "%PDF",
+	}
+	var longName string
+	for name := range files {
+		if strings.HasPrefix(name, "example.com/xxx") {
+			longName = name
+		}
+	}
+	if base := filepath.Base(longName); len(base) > DefaultMaxNameLength || !strings.HasSuffix(base, ".txt") {
+		t.Errorf("long name %q", longName)
+	} else {
+		want[longName] = "long"
+	}
+	if len(files) != len(want) {
+		t.Errorf("got files %v", keys(files))
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("file %s = %q, want %q", name, files[name], content)
+		}
+	}
+
+	manifest := e.Manifest()
+	if len(manifest) != len(want) {
+		t.Fatalf("manifest has %d entries, want %d", len(manifest), len(want))
+	}
+	entry := manifest[2]
+	sum := sha256.Sum256([]byte("<p>News</p>"))
+	if entry.Path != "example.com/news.php@id=1&lang=en.html" || entry.URI != records[2].TargetURI ||
+		entry.RecordID != records[2].RecordID || !entry.Date.Equal(date) || entry.ContentType != "text/html" ||
+		entry.Size != 11 || entry.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("manifest entry %+v", entry)
+	}
+
+	var buf bytes.Buffer
+	if err := e.WriteManifest(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "path,uri,record_id,timestamp,content_type,size,sha256,truncated" ||
+		lines[1] != "example.com/index.html,http://Example.com/,"+records[0].RecordID+",2024-01-01T10:00:00Z,text/html,11,"+
+			manifest[0].SHA256+"," {
+		t.Errorf("manifest starts with\n%s\n%s", lines[0], lines[1])
+	}
+}
+
+func TestExtractCollisions(t *testing.T) {
+	dir := t.TempDir()
+	files := extract(t, NewExtractor(dir), dir,
+		response("http://example.com/a", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "file a"),
+		response("http://example.com/a/b.txt", date, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "file b"),
+		response("https://example.com/a", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "other a"),
+		response("http://example.com/c/", date, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "dir c"),
+		response("http://example.com/c", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "file c"),
+	)
+	want := map[string]string{
+		"example.com/a":           "file a",
+		"example.com/a~2/b.txt":   "file b",
+		"example.com/a~3":         "other a",
+		"example.com/c/index.txt": "dir c",
+		"example.com/c~2":         "file c",
+	}
+	if len(files) != len(want) {
+		t.Errorf("got files %v", keys(files))
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("file %s = %q, want %q", name, files[name], content)
+		}
+	}
+}
+
+func TestExtractCaseCollisions(t *testing.T) {
+	dir := t.TempDir()
+	e := NewExtractor(dir)
+	files := extract(t, e, dir,
+		response("http://example.com/A", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "upper a"),
+		response("http://example.com/a", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "lower a"),
+		response("http://example.com/Docs/one.txt", date, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "one"),
+		response("http://example.com/docs/two.txt", date, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "two"),
+		response("http://example.com/DOCS", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "file docs"),
+	)
+	want := map[string]string{
+		"example.com/A":            "upper a",
+		"example.com/a~2":          "lower a",
+		"example.com/Docs/one.txt": "one",
+		"example.com/Docs/two.txt": "two",
+		"example.com/DOCS~2":       "file docs",
+	}
+	if len(files) != len(want) {
+		t.Errorf("got files %v", keys(files))
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("file %s = %q, want %q", name, files[name], content)
+		}
+	}
+	if manifest := e.Manifest(); len(manifest) != len(want) {
+		t.Errorf("manifest has %d entries, want %d", len(manifest), len(want))
+	}
+}
+
+func TestExtractReservedNames(t *testing.T) {
+	dir := t.TempDir()
+	files := extract(t, NewExtractor(dir), dir,
+		response("http://example.com/con", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "con"),
+		response("http://example.com/NUL.txt", date, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "nul"),
+		response("http://example.com/aux/com1.tar.gz", date, "HTTP/1.1 200 OK\r\nContent-Type: application/gzip\r\n", "com1"),
+		response("http://example.com/console", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "console"),
+		response("http://example.com/dots./end.", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "dot"),
+		response("http://example.com/space%20", date, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n", "space"),
+	)
+	want := map[string]string{
+		"example.com/con_":              "con",
+		"example.com/NUL_.txt":          "nul",
+		"example.com/aux_/com1_.tar.gz": "com1",
+		"example.com/console":           "console",
+		"example.com/dots_/end_":        "dot",
+		"example.com/space_":            "space",
+	}
+	if len(files) != len(want) {
+		t.Errorf("got files %v", keys(files))
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("file %s = %q, want %q", name, files[name], content)
+		}
+	}
+}
+
+func TestExtractCaptures(t *testing.T) {
+	captures := []*warc.WARCRecord{
+		response("http://example.com/", date.Add(time.Hour), "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n", "second"),
+		response("http://example.com/", date, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n", "first"),
+		response("http://example.com/", date.Add(2*time.Hour), "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n", "third"),
+	}
+
+	dir := t.TempDir()
+	e := NewExtractor(dir)
+	files := extract(t, e, dir, captures...)
+	if len(files) != 1 || files["example.com/index.txt"] != "third" {
+		t.Errorf("latest capture: got files %v", files)
+	}
+	if manifest := e.Manifest(); len(manifest) != 1 || manifest[0].RecordID != captures[2].RecordID {
+		t.Errorf("latest capture: manifest %+v", manifest)
+	}
+
+	dir = t.TempDir()
+	e = NewExtractor(dir)
+	e.All = true
+	files = extract(t, e, dir, captures...)
+	want := map[string]string{
+		"example.com/index.html":                "second",
+		"example.com/index~20240101100000.html": "first",
+		"example.com/index~20240101120000.txt":  "third",
+	}
+	if len(files) != len(want) {
+		t.Errorf("all captures: got files %v", keys(files))
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("all captures: file %s = %q, want %q", name, files[name], content)
+		}
+	}
+	if manifest := e.Manifest(); len(manifest) != 3 {
+		t.Errorf("all captures: manifest has %d entries, want 3", len(manifest))
+	}
+}
+
+func keys(m map[string]string) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,227 @@
+package extract
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxNameLength is the length in bytes of the longest file name written when
+// Extractor.MaxNameLength is zero, short of the 255 bytes most file systems allow
+const DefaultMaxNameLength = 200
+
+// extensions gives the file extension of common media types.
+var extensions = map[string]string{
+	"text/html":                     ".html",
+	"application/xhtml+xml":         ".xhtml",
+	"text/css":                      ".css",
+	"text/javascript":               ".js",
+	"application/javascript":        ".js",
+	"application/x-javascript":      ".js",
+	"application/json":              ".json",
+	"application/ld+json":           ".jsonld",
+	"text/plain":                    ".txt",
+	"text/csv":                      ".csv",
+	"text/xml":                      ".xml",
+	"application/xml":               ".xml",
+	"application/rss+xml":           ".rss",
+	"application/atom+xml":          ".atom",
+	"application/pdf":               ".pdf",
+	"application/zip":               ".zip",
+	"application/gzip":              ".gz",
+	"application/msword":            ".doc",
+	"application/vnd.ms-excel":      ".xls",
+	"application/vnd.ms-powerpoint": ".ppt",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"application/wasm":         ".wasm",
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/avif":               ".avif",
+	"image/svg+xml":            ".svg",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"image/bmp":                ".bmp",
+	"image/tiff":               ".tif",
+	"font/woff":                ".woff",
+	"font/woff2":               ".woff2",
+	"font/ttf":                 ".ttf",
+	"font/otf":                 ".otf",
+	"audio/mpeg":               ".mp3",
+	"audio/ogg":                ".ogg",
+	"audio/wav":                ".wav",
+	"video/mp4":                ".mp4",
+	"video/webm":               ".webm",
+	"video/ogg":                ".ogv",
+}
+
+// aliases gives the media type of the extensions of common files not in extensions.
+var aliases = map[string]string{
+	".htm":  "text/html",
+	".jpeg": "image/jpeg",
+	".jpe":  "image/jpeg",
+	".mjs":  "text/javascript",
+	".tiff": "image/tiff",
+	".text": "text/plain",
+}
+
+// fileName is the name a payload is written under, before it is made unique and short
+// enough.
+type fileName struct {
+	// dirs are the directories of the file, from the host down
+	dirs []string
+	// stem is the file name without extension, and ext its extension
+	stem, ext string
+}
+
+// nameOf returns the file name of the payload of uri, of the given media type, with
+// directory names at most max bytes long.
+func nameOf(uri *url.URL, mediaType string, max int) fileName {
+	var n fileName
+	var segments []string
+	if uri.Host != "" {
+		host := strings.ToLower(uri.Hostname())
+		if port := uri.Port(); port != "" && !(port == "80" && uri.Scheme == "http") && !(port == "443" && uri.Scheme == "https") {
+			host += "_" + port
+		}
+		n.dirs = append(n.dirs, cleanName(host))
+		segments = strings.Split(uri.EscapedPath(), "/")
+	} else {
+		// Without a host, as for file: and urn: URIs, the scheme stands for it
+		scheme := uri.Scheme
+		if scheme == "" {
+			scheme = "_"
+		}
+		n.dirs = append(n.dirs, cleanName(scheme))
+		p := uri.EscapedPath()
+		if p == "" {
+			p = uri.Opaque
+		}
+		segments = strings.Split(p, "/")
+	}
+	// The segments of the path are all directories but the last
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		if i == len(segments)-1 {
+			n.stem = segment
+		} else if segment != "" {
+			n.dirs = append(n.dirs, cleanName(segment))
+		}
+	}
+	if n.stem == "" {
+		n.stem = "index"
+	}
+	for i, dir := range n.dirs {
+		n.dirs[i] = shorten(dir, "", max)
+	}
+
+	ext := path.Ext(n.stem)
+	want := extensions[mediaType]
+	if ext != "" && (strings.EqualFold(ext, want) || typeByExtension(ext) == mediaType) {
+		n.stem, n.ext = n.stem[:len(n.stem)-len(ext)], ext
+	} else {
+		n.ext = want
+	}
+	if uri.RawQuery != "" {
+		n.stem += "@" + uri.RawQuery
+	}
+	n.stem, n.ext = cleanName(n.stem), cleanName(n.ext)
+	return n
+}
+
+// typeByExtension returns the media type of the files with the extension ext, if known.
+func typeByExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if t, ok := aliases[ext]; ok {
+		return t
+	}
+	for t, e := range extensions {
+		if e == ext {
+			return t
+		}
+	}
+	t, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	return t
+}
+
+// cleanName replaces the characters of name not allowed in file names on common file
+// systems, and the names "." and "..". Names Windows reserves for devices, as "con" or
+// "com1.txt", take a "_" after the device name, and a trailing dot or space, which Windows
+// drops, becomes a "_".
+func cleanName(name string) string {
+	switch name {
+	case ".", "..":
+		return strings.Repeat("_", len(name))
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	device := name
+	if i := strings.IndexByte(device, '.'); i >= 0 {
+		device = device[:i]
+	}
+	if isDevice(strings.TrimRight(device, " ")) {
+		name = device + "_" + name[len(device):]
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		name = name[:len(name)-1] + "_"
+	}
+	return name
+}
+
+// isDevice reports whether name is one of the device names reserved by Windows.
+func isDevice(name string) bool {
+	switch strings.ToLower(name) {
+	case "con", "prn", "aux", "nul":
+		return true
+	}
+	if len(name) == 4 {
+		prefix := strings.ToLower(name[:3])
+		return (prefix == "com" || prefix == "lpt") && name[3] >= '1' && name[3] <= '9'
+	}
+	return false
+}
+
+// fold returns p as compared by file systems that ignore case.
+func fold(p string) string {
+	return strings.ToLower(p)
+}
+
+// shorten returns stem+tail, with stem cut short and marked with a hash of itself if
+// needed to fit in max bytes.
+func shorten(stem, tail string, max int) string {
+	if len(stem)+len(tail) <= max {
+		return stem + tail
+	}
+	sum := sha1.Sum([]byte(stem))
+	mark := "~" + hex.EncodeToString(sum[:4])
+	keep := max - len(tail) - len(mark)
+	if keep < 0 {
+		keep = 0
+	}
+	if keep > len(stem) {
+		keep = len(stem)
+	}
+	for keep > 0 && keep < len(stem) && !utf8.RuneStart(stem[keep]) {
+		keep--
+	}
+	return stem[:keep] + mark + tail
+}
+
+// numbered returns the suffix telling apart the nth file of the same name, from the second.
+func numbered(n int) string {
+	return "~" + strconv.Itoa(n)
+}
@@ -0,0 +1,346 @@
+package har
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zenless-lab/gwarc/internal/mediatype"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// captured is a record read, with its block in memory.
+type captured struct {
+	record *warc.Record
+	block  []byte
+	// paired is set once a request is matched to a response
+	paired bool
+}
+
+// Export reads records from r and returns a HAR log of the HTTP exchanges among them. Each
+// response record becomes an entry, with the request record it is paired with: one naming
+// it in WARC-Concurrent-To or named by it, or failing that, the first request not yet
+// paired with the same target URI. Timings are taken from metadata records referring to
+// the response or request, as written by Import, or from their fetchTimeMs. Entries are
+// sorted by the time their request started.
+//
+// Response payloads are decoded from their content codings; those that are not text are
+// given in base64, as are binary request bodies.
+func Export(r warc.RecordReader) (*HAR, error) {
+	var requests, responses []*captured
+	requestsByID := make(map[string]*captured)
+	requestsByURI := make(map[string][]*captured)
+	timings := make(map[string][]byte)
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		isHTTP := strings.HasPrefix(record.ContentType, "application/http")
+		switch {
+		case record.Type == warc.WARCTypeRequest && isHTTP, record.Type == warc.WARCTypeResponse && isHTTP,
+			record.Type == warc.WARCTypeMetadata && record.RefersTo != "":
+		default:
+			continue
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil {
+			return nil, err
+		}
+		c := &captured{record: record, block: block}
+		switch record.Type {
+		case warc.WARCTypeRequest:
+			requests = append(requests, c)
+			requestsByID[record.RecordID] = c
+			requestsByURI[record.TargetURI] = append(requestsByURI[record.TargetURI], c)
+		case warc.WARCTypeResponse:
+			responses = append(responses, c)
+		case warc.WARCTypeMetadata:
+			timings[record.RefersTo] = block
+		}
+	}
+
+	// Requests naming the response they were sent for
+	requestsFor := make(map[string]*captured)
+	for _, request := range requests {
+		for _, id := range request.record.ConcurrentTo {
+			requestsFor[id] = request
+		}
+	}
+
+	h := &HAR{Log: Log{Version: Version, Creator: Creator{Name: "gwarc"}, Entries: []Entry{}}}
+	for _, response := range responses {
+		request := pairRequest(response, requestsByID, requestsFor, requestsByURI)
+		entry, err := newEntry(request, response)
+		if err != nil {
+			// Not an HTTP exchange
+			continue
+		}
+		if request != nil {
+			setTimings(&entry, timings[request.record.RecordID])
+		}
+		setTimings(&entry, timings[response.record.RecordID])
+		h.Log.Entries = append(h.Log.Entries, entry)
+	}
+	sort.SliceStable(h.Log.Entries, func(i, j int) bool {
+		return h.Log.Entries[i].StartedDateTime.Before(h.Log.Entries[j].StartedDateTime)
+	})
+	return h, nil
+}
+
+// pairRequest returns the request record sent for response, if any, marking it paired.
+func pairRequest(response *captured, byID, byResponse map[string]*captured, byURI map[string][]*captured) *captured {
+	var request *captured
+	for _, id := range response.record.ConcurrentTo {
+		if c, ok := byID[id]; ok && !c.paired {
+			request = c
+			break
+		}
+	}
+	if c, ok := byResponse[response.record.RecordID]; request == nil && ok && !c.paired {
+		request = c
+	}
+	if request == nil {
+		for _, c := range byURI[response.record.TargetURI] {
+			if !c.paired {
+				request = c
+				break
+			}
+		}
+	}
+	if request != nil {
+		request.paired = true
+	}
+	return request
+}
+
+// newEntry returns the entry of an exchange, whose request may be missing.
+func newEntry(request, response *captured) (Entry, error) {
+	entry := Entry{
+		StartedDateTime: response.record.Date,
+		ServerIPAddress: response.record.IPAddress,
+		Timings:         Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+	var err error
+	if entry.Response, err = newHARResponse(response.block); err != nil {
+		return entry, err
+	}
+	if request != nil {
+		entry.StartedDateTime = request.record.Date
+		if entry.Request, err = newHARRequest(request.record.TargetURI, request.block); err == nil {
+			return entry, nil
+		}
+	}
+	// The request is known by its target URI alone
+	entry.Request = Request{
+		Method:      http.MethodGet,
+		URL:         response.record.TargetURI,
+		HTTPVersion: entry.Response.HTTPVersion,
+		Cookies:     []Cookie{},
+		Headers:     []NameValue{},
+		QueryString: queryString(response.record.TargetURI),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	return entry, nil
+}
+
+// newHARRequest returns the HAR form of the HTTP request in block, sent to target.
+func newHARRequest(target string, block []byte) (Request, error) {
+	headers, headersSize := headerFields(block)
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(block)))
+	if err != nil {
+		return Request{}, err
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return Request{}, err
+	}
+	r := Request{
+		Method:      req.Method,
+		URL:         target,
+		HTTPVersion: req.Proto,
+		Cookies:     []Cookie{},
+		Headers:     headers,
+		QueryString: queryString(target),
+		HeadersSize: headersSize,
+		BodySize:    int64(len(body)),
+	}
+	for _, c := range req.Cookies() {
+		r.Cookies = append(r.Cookies, Cookie{Name: c.Name, Value: c.Value})
+	}
+	if len(body) > 0 {
+		contentType := req.Header.Get("Content-Type")
+		r.PostData = &PostData{MimeType: contentType}
+		r.PostData.Text, r.PostData.Encoding = encodeText(body, contentType)
+		if mediatype.Of(contentType) == "application/x-www-form-urlencoded" {
+			for _, p := range splitQuery(string(body)) {
+				r.PostData.Params = append(r.PostData.Params, Param{Name: p.Name, Value: p.Value})
+			}
+		}
+	}
+	return r, nil
+}
+
+// newHARResponse returns the HAR form of the HTTP response in block.
+func newHARResponse(block []byte) (Response, error) {
+	headers, headersSize := headerFields(block)
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+	if err != nil {
+		return Response{}, err
+	}
+	// A payload cut short is given as far as it goes
+	raw, _ := io.ReadAll(resp.Body)
+	payload := raw
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		if decoder, err := warc.DecodeContent(bytes.NewReader(raw), encoding); err == nil {
+			if decoded, err := io.ReadAll(decoder); err == nil {
+				payload = decoded
+			}
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	r := Response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     []Cookie{},
+		Headers:     headers,
+		Content: Content{
+			Size:        int64(len(payload)),
+			Compression: int64(len(payload) - len(raw)),
+			MimeType:    contentType,
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: headersSize,
+		BodySize:    int64(len(raw)),
+	}
+	r.Content.Text, r.Content.Encoding = encodeText(payload, contentType)
+	for _, c := range resp.Cookies() {
+		cookie := Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		r.Cookies = append(r.Cookies, cookie)
+	}
+	return r, nil
+}
+
+// headerFields returns the header fields of the HTTP message in block, in order, and the
+// size of its head.
+func headerFields(block []byte) ([]NameValue, int64) {
+	headers := []NameValue{}
+	end := bytes.Index(block, []byte("\r\n\r\n"))
+	if end < 0 {
+		return headers, -1
+	}
+	lines := strings.Split(string(block[:end]), "\r\n")
+	for _, line := range lines[1:] {
+		if name, value, found := strings.Cut(line, ":"); found {
+			headers = append(headers, NameValue{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+		}
+	}
+	return headers, int64(end + 4)
+}
+
+// encodeText returns data as HAR text, in base64 unless it is text.
+func encodeText(data []byte, contentType string) (string, string) {
+	if len(data) == 0 {
+		return "", ""
+	}
+	if isText(data, contentType) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+// isText reports whether data, of the given content type, is text.
+func isText(data []byte, contentType string) bool {
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return false
+	}
+	t := mediatype.Of(contentType)
+	switch {
+	case t == "", strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "+xml"), strings.HasSuffix(t, "+json"):
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "ecmascript", "x-www-form-urlencoded"} {
+		if strings.Contains(t, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryString returns the query parameters of rawURL, in order.
+func queryString(rawURL string) []NameValue {
+	if u, err := url.Parse(rawURL); err == nil {
+		return splitQuery(u.RawQuery)
+	}
+	return []NameValue{}
+}
+
+// splitQuery returns the parameters of a query string or form, in order.
+func splitQuery(query string) []NameValue {
+	params := []NameValue{}
+	for _, p := range strings.Split(query, "&") {
+		if p == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(p, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params = append(params, NameValue{Name: name, Value: value})
+	}
+	return params
+}
+
+// setTimings sets the timings of entry from a metadata block.
+func setTimings(entry *Entry, block []byte) {
+	if block == nil {
+		return
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(block), "\n") {
+		if name, value, found := strings.Cut(line, ":"); found {
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	set := func(name string, v *float64) {
+		if f, err := strconv.ParseFloat(values[name], 64); err == nil {
+			*v = f
+		}
+	}
+	if fetchTime, ok := values["fetchTimeMs"]; ok {
+		if f, err := strconv.ParseFloat(fetchTime, 64); err == nil {
+			entry.Time, entry.Timings.Wait = f, f
+		}
+	}
+	set(FieldTime, &entry.Time)
+	set(FieldBlocked, &entry.Timings.Blocked)
+	set(FieldDNS, &entry.Timings.DNS)
+	set(FieldConnect, &entry.Timings.Connect)
+	set(FieldSSL, &entry.Timings.SSL)
+	set(FieldSend, &entry.Timings.Send)
+	set(FieldWait, &entry.Timings.Wait)
+	set(FieldReceive, &entry.Timings.Receive)
+	if pageref, ok := values[FieldPageref]; ok {
+		entry.Pageref = pageref
+	}
+}
@@ -0,0 +1,130 @@
+package har_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/har"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Import(warc.NewWriter(&buf), testHAR()); err != nil {
+		t.Fatal(err)
+	}
+	r, err := warc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := Export(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testHAR()
+	if h.Log.Version != Version || len(h.Log.Entries) != len(want.Log.Entries) {
+		t.Fatalf("got version %q with %d entries", h.Log.Version, len(h.Log.Entries))
+	}
+
+	page, form := h.Log.Entries[0], h.Log.Entries[1]
+	if !page.StartedDateTime.Equal(want.Log.Entries[0].StartedDateTime) || page.Time != 42.5 || page.Pageref != "page_1" {
+		t.Errorf("page started %v, time %v, pageref %q", page.StartedDateTime, page.Time, page.Pageref)
+	}
+	if page.Timings != want.Log.Entries[0].Timings {
+		t.Errorf("page timings %+v, want %+v", page.Timings, want.Log.Entries[0].Timings)
+	}
+	if page.ServerIPAddress != "93.184.216.34" {
+		t.Errorf("server IP address %q", page.ServerIPAddress)
+	}
+	if page.Request.URL != "https://example.com/index.html?q=a%20b&lang=en" || page.Request.Method != "GET" {
+		t.Errorf("page request %s %s", page.Request.Method, page.Request.URL)
+	}
+	if q := page.Request.QueryString; len(q) != 2 || q[0].Name != "q" || q[0].Value != "a b" || q[1].Name != "lang" {
+		t.Errorf("query string %+v", q)
+	}
+	if c := page.Request.Cookies; len(c) != 1 || c[0].Name != "session" || c[0].Value != "abc" {
+		t.Errorf("request cookies %+v", c)
+	}
+	if c := page.Response.Content; c.Text != "<p>Hello, world!</p>\r\n" || c.Encoding != "" || c.Size != 22 {
+		t.Errorf("page content %+v", c)
+	}
+	if page.Response.Status != 200 || page.Response.StatusText != "OK" {
+		t.Errorf("page status %d %q", page.Response.Status, page.Response.StatusText)
+	}
+
+	if form.Request.PostData == nil {
+		t.Fatal("form request without post data")
+	}
+	params := form.Request.PostData.Params
+	if len(params) != 2 || params[0].Name != "name" || params[0].Value != "Jürgen" || params[1].Value != "42" {
+		t.Errorf("form params %+v", params)
+	}
+	if c := form.Response.Content; c.Text != "R0lGOA==" || c.Encoding != "base64" || c.MimeType != "image/gif" {
+		t.Errorf("redirect content %+v", c)
+	}
+	if form.Response.RedirectURL != "/done" || form.Response.StatusText != "See Other" {
+		t.Errorf("redirect to %q, status %q", form.Response.RedirectURL, form.Response.StatusText)
+	}
+	if form.Timings.DNS != -1 || form.Timings.Wait != 10 {
+		t.Errorf("form timings %+v", form.Timings)
+	}
+}
+
+func TestExportCrawl(t *testing.T) {
+	// Records as written by a crawler: the response first, a compressed payload, and the
+	// request named by neither
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`{"ok":true}`))
+	zw.Close()
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	response := &warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResponse,
+		Date:        date,
+		TargetURI:   "http://example.com/api",
+		ContentType: warc.ContentTypeHTTPResponse,
+		Content: append([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Encoding: gzip\r\n"+
+			"Set-Cookie: id=1; Path=/; HttpOnly\r\n\r\n"), gz.Bytes()...),
+	}
+	request := &warc.WARCRecord{
+		Type:        warc.WARCTypeRequest,
+		Date:        date,
+		TargetURI:   "http://example.com/api",
+		ContentType: warc.ContentTypeHTTPRequest,
+		Content:     []byte("GET /api HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+	}
+	metadata := &warc.MetadataRecord{WARCRecord: warc.WARCRecord{RefersTo: response.RecordID}}
+	metadata.FetchTimeMs = 120
+	var buf bytes.Buffer
+	if err := warc.NewWriter(&buf).WriteRecords(response, request, metadata); err != nil {
+		t.Fatal(err)
+	}
+	r, err := warc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := Export(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Log.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(h.Log.Entries))
+	}
+	entry := h.Log.Entries[0]
+	if entry.Request.Method != "GET" || len(entry.Request.Headers) != 1 || entry.Request.Headers[0].Name != "Host" {
+		t.Errorf("request %+v", entry.Request)
+	}
+	c := entry.Response.Content
+	if c.Text != `{"ok":true}` || c.Size != 11 || c.Compression != c.Size-entry.Response.BodySize {
+		t.Errorf("content %+v, body size %d", c, entry.Response.BodySize)
+	}
+	if cookies := entry.Response.Cookies; len(cookies) != 1 || cookies[0].Name != "id" || !cookies[0].HTTPOnly || cookies[0].Path != "/" {
+		t.Errorf("cookies %+v", cookies)
+	}
+	if entry.Time != 120 || entry.Timings.Wait != 120 || entry.Timings.DNS != -1 {
+		t.Errorf("time %v, timings %+v", entry.Time, entry.Timings)
+	}
+}
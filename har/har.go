@@ -0,0 +1,177 @@
+// Package har converts between HAR files, as exported by browser developer tools, and
+// WARC records.
+package har
+
+import (
+	"time"
+)
+
+// Version is the version of the HAR format written by Export
+const Version = "1.2"
+
+// HAR is a HAR file.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the root of a HAR file.
+type Log struct {
+	Version string   `json:"version"`
+	Creator Creator  `json:"creator"`
+	Browser *Creator `json:"browser,omitempty"`
+	Pages   []Page   `json:"pages,omitempty"`
+	Entries []Entry  `json:"entries"`
+	Comment string   `json:"comment,omitempty"`
+}
+
+// Creator names the application that created a HAR file, or the browser.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Page is a page loaded, grouping the entries of its requests.
+type Page struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	ID              string      `json:"id"`
+	Title           string      `json:"title"`
+	PageTimings     PageTimings `json:"pageTimings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// PageTimings are the times, in milliseconds since the start of a page load, at which its
+// events fired.
+type PageTimings struct {
+	OnContentLoad *float64 `json:"onContentLoad,omitempty"`
+	OnLoad        *float64 `json:"onLoad,omitempty"`
+	Comment       string   `json:"comment,omitempty"`
+}
+
+// Entry is an HTTP exchange.
+type Entry struct {
+	Pageref         string    `json:"pageref,omitempty"`
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total time of the exchange in milliseconds, the sum of its Timings
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           Cache    `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	ServerIPAddress string   `json:"serverIPAddress,omitempty"`
+	Connection      string   `json:"connection,omitempty"`
+	Comment         string   `json:"comment,omitempty"`
+}
+
+// Request is an HTTP request.
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	// HeadersSize and BodySize are -1 when unknown
+	HeadersSize int64  `json:"headersSize"`
+	BodySize    int64  `json:"bodySize"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Response is an HTTP response.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	// HeadersSize and BodySize are -1 when unknown; BodySize is the size of the payload as
+	// transferred, before content decoding
+	HeadersSize int64  `json:"headersSize"`
+	BodySize    int64  `json:"bodySize"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Cookie is a cookie sent in a request or set by a response.
+type Cookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	Comment  string     `json:"comment,omitempty"`
+}
+
+// NameValue is a header field or query string parameter.
+type NameValue struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// PostData is the body of a request.
+type PostData struct {
+	MimeType string  `json:"mimeType"`
+	Params   []Param `json:"params,omitempty"`
+	Text     string  `json:"text"`
+	// Encoding is "base64" for a binary Text. It is not part of HAR 1.2, but written by
+	// some tools as it is for Content.
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Param is a parameter of a form posted.
+type Param struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Content is the payload of a response, with its content codings removed.
+type Content struct {
+	// Size is the length of the decoded payload
+	Size int64 `json:"size"`
+	// Compression is the number of bytes saved by content coding
+	Compression int64  `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	// Encoding is "base64" for a binary Text
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Cache describes the browser cache entry of a request.
+type Cache struct {
+	BeforeRequest *CacheEntry `json:"beforeRequest,omitempty"`
+	AfterRequest  *CacheEntry `json:"afterRequest,omitempty"`
+	Comment       string      `json:"comment,omitempty"`
+}
+
+// CacheEntry is the state of a cache entry.
+type CacheEntry struct {
+	Expires    *time.Time `json:"expires,omitempty"`
+	LastAccess time.Time  `json:"lastAccess"`
+	ETag       string     `json:"eTag"`
+	HitCount   int        `json:"hitCount"`
+	Comment    string     `json:"comment,omitempty"`
+}
+
+// Timings are the durations of the phases of an exchange, in milliseconds. Blocked, DNS,
+// Connect and SSL are -1 when they do not apply.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	// SSL is included in Connect
+	SSL     float64 `json:"ssl"`
+	Comment string  `json:"comment,omitempty"`
+}
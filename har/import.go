@@ -0,0 +1,212 @@
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Fields of the metadata records holding the timings of an entry, in milliseconds
+const (
+	FieldTime    = "har-time"
+	FieldBlocked = "har-blocked"
+	FieldDNS     = "har-dns"
+	FieldConnect = "har-connect"
+	FieldSSL     = "har-ssl"
+	FieldSend    = "har-send"
+	FieldWait    = "har-wait"
+	FieldReceive = "har-receive"
+	// FieldPageref names the page of the entry
+	FieldPageref = "har-pageref"
+)
+
+// Import writes every entry of h to w as a group of three records: a request record and a
+// response record, concurrent to each other and dated when the request started, and a
+// metadata record referring to the response, holding the timings of the entry in
+// fetchTimeMs and the har- fields.
+//
+// HAR files hold decoded response payloads, so the Content-Encoding of responses is
+// dropped and their Content-Length set to the length of the payload, and a payload whose
+// response lacks a Content-Type is given the MIME type of its content. A response whose
+// payload was not saved is marked with WARC-Truncated. HTTP/2 and HTTP/3 exchanges are
+// written as HTTP/1.1 messages, with their protocol in WARC-Protocol.
+func Import(w warc.RecordWriter, h *HAR) error {
+	for i := range h.Log.Entries {
+		entry := &h.Log.Entries[i]
+		records, err := importEntry(entry)
+		if err != nil {
+			return fmt.Errorf("entry %d (%s): %w", i, entry.Request.URL, err)
+		}
+		if err := w.WriteRecords(records...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importEntry returns the request, response and metadata records of entry.
+func importEntry(entry *Entry) ([]any, error) {
+	req, err := newRequest(&entry.Request)
+	if err != nil {
+		return nil, err
+	}
+	resp, truncated, err := newResponse(&entry.Response, req)
+	if err != nil {
+		return nil, err
+	}
+	request, response, err := warc.NewHTTPRecords(req, resp, entry.ServerIPAddress)
+	if err != nil {
+		return nil, err
+	}
+	date := entry.StartedDateTime.UTC()
+	request.Date, response.Date = date, date
+	if truncated {
+		response.Truncated = warc.TruncatedUnspecified
+	}
+	if protocol := protocolName(entry.Request.HTTPVersion); protocol != "" {
+		request.Extensions = map[string][]string{warc.FieldProtocol: {protocol}}
+		response.Extensions = map[string][]string{warc.FieldProtocol: {protocol}}
+	}
+
+	metadata := &warc.MetadataRecord{WARCRecord: warc.WARCRecord{
+		Date:      date,
+		TargetURI: response.TargetURI,
+		RefersTo:  response.RecordID,
+	}}
+	if entry.Time > 0 {
+		metadata.FetchTimeMs = uint64(math.Round(entry.Time))
+	}
+	t := &entry.Timings
+	metadata.Content = fields(
+		FieldTime, formatTime(entry.Time),
+		FieldBlocked, formatTime(t.Blocked),
+		FieldDNS, formatTime(t.DNS),
+		FieldConnect, formatTime(t.Connect),
+		FieldSSL, formatTime(t.SSL),
+		FieldSend, formatTime(t.Send),
+		FieldWait, formatTime(t.Wait),
+		FieldReceive, formatTime(t.Receive),
+		FieldPageref, entry.Pageref,
+	)
+	return []any{request, response, metadata}, nil
+}
+
+// newRequest returns the HTTP request of an entry.
+func newRequest(r *Request) (*http.Request, error) {
+	var body []byte
+	if r.PostData != nil {
+		var err error
+		if body, err = decodeText(r.PostData.Text, r.PostData.Encoding); err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+	}
+	req, err := http.NewRequest(r.Method, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		req.Body, req.GetBody, req.ContentLength = http.NoBody, nil, 0
+	}
+	// Record the request as sent, without the fields a Go client would add
+	req.RequestURI = req.URL.RequestURI()
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.1", 1, 1
+	if strings.EqualFold(r.HTTPVersion, "HTTP/1.0") {
+		req.Proto, req.ProtoMinor = "HTTP/1.0", 0
+	}
+	for _, h := range r.Headers {
+		switch {
+		case strings.HasPrefix(h.Name, ":"):
+			// HTTP/2 pseudo-header fields are given by the request line and Host
+			if h.Name == ":authority" && req.Host == "" {
+				req.Host = h.Value
+			}
+		case strings.EqualFold(h.Name, "Host"):
+			req.Host = h.Value
+		case strings.EqualFold(h.Name, "Content-Length"), strings.EqualFold(h.Name, "Transfer-Encoding"):
+		default:
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	return req, nil
+}
+
+// newResponse returns the HTTP response of an entry to req, and whether its payload is
+// missing from the HAR file.
+func newResponse(r *Response, req *http.Request) (*http.Response, bool, error) {
+	body, err := decodeText(r.Content.Text, r.Content.Encoding)
+	if err != nil {
+		return nil, false, fmt.Errorf("response content: %w", err)
+	}
+	resp := &http.Response{
+		StatusCode: r.Status,
+		Status:     strings.TrimSpace(strconv.Itoa(r.Status) + " " + r.StatusText),
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}
+	if strings.EqualFold(r.HTTPVersion, "HTTP/1.0") {
+		resp.ProtoMinor = 0
+	}
+	for _, h := range r.Headers {
+		switch {
+		case strings.HasPrefix(h.Name, ":"):
+		case strings.EqualFold(h.Name, "Content-Encoding"), strings.EqualFold(h.Name, "Content-Length"),
+			strings.EqualFold(h.Name, "Transfer-Encoding"):
+		default:
+			resp.Header.Add(h.Name, h.Value)
+		}
+	}
+	if resp.Header.Get("Content-Type") == "" && r.Content.MimeType != "" && len(body) > 0 {
+		resp.Header.Set("Content-Type", r.Content.MimeType)
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, len(body) == 0 && r.Content.Size > 0, nil
+}
+
+// decodeText returns the bytes of a HAR text, decoding base64 text.
+func decodeText(text, encoding string) ([]byte, error) {
+	if strings.EqualFold(encoding, "base64") {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// protocolName returns the WARC-Protocol value of an HTTP version that is not HTTP/1.
+func protocolName(httpVersion string) string {
+	switch v := strings.ToLower(httpVersion); {
+	case v == "h2" || strings.HasPrefix(v, "http/2"):
+		return "h2"
+	case v == "h3" || strings.HasPrefix(v, "h3-") || strings.HasPrefix(v, "http/3"):
+		return "h3"
+	}
+	return ""
+}
+
+// formatTime formats a duration in milliseconds.
+func formatTime(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', -1, 64)
+}
+
+// fields returns an application/warc-fields block of the given names and values, leaving
+// out empty values.
+func fields(namesAndValues ...string) []byte {
+	var b strings.Builder
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		if namesAndValues[i+1] != "" {
+			b.WriteString(namesAndValues[i] + ": " + namesAndValues[i+1] + "\r\n")
+		}
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,206 @@
+package har_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/har"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// testHAR returns a HAR log of two exchanges: a page loaded over HTTP/2 and a form posted.
+func testHAR() *HAR {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	return &HAR{Log: Log{
+		Version: Version,
+		Creator: Creator{Name: "test", Version: "1.0"},
+		Entries: []Entry{
+			{
+				Pageref:         "page_1",
+				StartedDateTime: start,
+				Time:            42.5,
+				Request: Request{
+					Method:      http.MethodGet,
+					URL:         "https://example.com/index.html?q=a%20b&lang=en",
+					HTTPVersion: "h2",
+					Cookies:     []Cookie{{Name: "session", Value: "abc"}},
+					Headers: []NameValue{
+						{Name: ":authority", Value: "example.com"},
+						{Name: "Accept", Value: "text/html"},
+						{Name: "Cookie", Value: "session=abc"},
+					},
+					QueryString: []NameValue{{Name: "q", Value: "a b"}, {Name: "lang", Value: "en"}},
+					HeadersSize: -1,
+					BodySize:    0,
+				},
+				Response: Response{
+					Status:      200,
+					StatusText:  "OK",
+					HTTPVersion: "h2",
+					Cookies:     []Cookie{},
+					Headers: []NameValue{
+						{Name: "Content-Type", Value: "text/html; charset=utf-8"},
+						{Name: "Content-Encoding", Value: "gzip"},
+						{Name: "Content-Length", Value: "20"},
+					},
+					Content:     Content{Size: 22, MimeType: "text/html; charset=utf-8", Text: "<p>Hello, world!</p>\r\n"},
+					HeadersSize: -1,
+					BodySize:    20,
+				},
+				ServerIPAddress: "93.184.216.34",
+				Timings:         Timings{Blocked: -1, DNS: 2, Connect: 10, SSL: 6, Send: 0.5, Wait: 25, Receive: 5},
+			},
+			{
+				StartedDateTime: start.Add(time.Second),
+				Time:            12,
+				Request: Request{
+					Method:      http.MethodPost,
+					URL:         "http://example.com/form",
+					HTTPVersion: "HTTP/1.1",
+					Headers:     []NameValue{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}},
+					PostData: &PostData{
+						MimeType: "application/x-www-form-urlencoded",
+						Text:     "name=J%C3%BCrgen&age=42",
+					},
+					HeadersSize: -1,
+					BodySize:    23,
+				},
+				Response: Response{
+					Status:      303,
+					StatusText:  "See Other",
+					HTTPVersion: "HTTP/1.1",
+					Headers:     []NameValue{{Name: "Location", Value: "/done"}},
+					Content:     Content{Size: 4, MimeType: "image/gif", Text: "R0lGOA==", Encoding: "base64"},
+					RedirectURL: "/done",
+					HeadersSize: -1,
+					BodySize:    4,
+				},
+				Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Send: 1, Wait: 10, Receive: 1},
+			},
+		},
+	}}
+}
+
+func TestImport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Import(warc.NewWriter(&buf), testHAR()); err != nil {
+		t.Fatal(err)
+	}
+	r, err := warc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []*warc.Record
+	var blocks []string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+		blocks = append(blocks, string(block))
+	}
+	wantTypes := []warc.WARCRecordType{
+		warc.WARCTypeRequest, warc.WARCTypeResponse, warc.WARCTypeMetadata,
+		warc.WARCTypeRequest, warc.WARCTypeResponse, warc.WARCTypeMetadata,
+	}
+	if len(records) != len(wantTypes) {
+		t.Fatalf("got %d records, want %d", len(records), len(wantTypes))
+	}
+	for i, record := range records {
+		if record.Type != wantTypes[i] {
+			t.Errorf("record %d: type %q, want %q", i, record.Type, wantTypes[i])
+		}
+	}
+
+	request, response, metadata := records[0], records[1], records[2]
+	if !request.Date.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) || !response.Date.Equal(request.Date) {
+		t.Errorf("dates %v and %v, want the start of the entry", request.Date, response.Date)
+	}
+	if response.IPAddress != "93.184.216.34" {
+		t.Errorf("IP address %q", response.IPAddress)
+	}
+	if len(request.ConcurrentTo) != 1 || request.ConcurrentTo[0] != response.RecordID {
+		t.Errorf("request concurrent to %v, want %s", request.ConcurrentTo, response.RecordID)
+	}
+	if metadata.RefersTo != response.RecordID {
+		t.Errorf("metadata refers to %q, want %s", metadata.RefersTo, response.RecordID)
+	}
+	if !strings.HasPrefix(blocks[0], "GET /index.html?q=a%20b&lang=en HTTP/1.1\r\n") {
+		t.Errorf("request starts with %q", blocks[0][:40])
+	}
+	for _, field := range []string{":authority", "User-Agent"} {
+		if strings.Contains(blocks[0], field) {
+			t.Errorf("request has field %s:\n%s", field, blocks[0])
+		}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(blocks[1])), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "<p>Hello, world!</p>\r\n" {
+		t.Errorf("response payload %q", body)
+	}
+	if resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != int64(len(body)) {
+		t.Errorf("response Content-Encoding %q, Content-Length %d", resp.Header.Get("Content-Encoding"), resp.ContentLength)
+	}
+	for _, line := range []string{"fetchTimeMs: 43", FieldTime + ": 42.5", FieldSSL + ": 6", FieldBlocked + ": -1", FieldPageref + ": page_1"} {
+		if !strings.Contains(blocks[2], line+"\r\n") {
+			t.Errorf("metadata lacks %q:\n%s", line, blocks[2])
+		}
+	}
+
+	if !strings.HasPrefix(blocks[3], "POST /form HTTP/1.1\r\n") || !strings.HasSuffix(blocks[3], "\r\n\r\nname=J%C3%BCrgen&age=42") {
+		t.Errorf("form request:\n%s", blocks[3])
+	}
+	if !strings.HasSuffix(blocks[4], "\r\n\r\nGIF8") {
+		t.Errorf("redirect response:\n%s", blocks[4])
+	}
+}
+
+func TestImportTruncated(t *testing.T) {
+	h := testHAR()
+	h.Log.Entries = h.Log.Entries[:1]
+	h.Log.Entries[0].Response.Content.Text = ""
+	var buf bytes.Buffer
+	if err := Import(warc.NewWriter(&buf), h); err != nil {
+		t.Fatal(err)
+	}
+	r, err := warc.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	response, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Truncated != warc.TruncatedUnspecified {
+		t.Errorf("response without its payload truncated %q", response.Truncated)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	h := testHAR()
+	h.Log.Entries[1].Response.Content.Text = "not base64!"
+	var buf bytes.Buffer
+	err := Import(warc.NewWriter(&buf), h)
+	if err == nil || !strings.Contains(err.Error(), "entry 1") {
+		t.Errorf("got %v, want an error for entry 1", err)
+	}
+}
@@ -0,0 +1,458 @@
+// Package charset decodes HTML documents to UTF-8, finding their character encoding as
+// browsers do: from a byte order mark, the Content-Type, or a meta element.
+package charset
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/zenless-lab/gwarc/internal/htmltok"
+)
+
+// Encodings supported, by name
+const (
+	UTF8        = "utf-8"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Windows1252 = "windows-1252"
+	IBM866      = "ibm866"
+	ISO88592    = "iso-8859-2"
+	ISO88593    = "iso-8859-3"
+	ISO88594    = "iso-8859-4"
+	ISO88595    = "iso-8859-5"
+	ISO88596    = "iso-8859-6"
+	ISO88597    = "iso-8859-7"
+	ISO88598    = "iso-8859-8"
+	ISO885910   = "iso-8859-10"
+	ISO885913   = "iso-8859-13"
+	ISO885914   = "iso-8859-14"
+	ISO885915   = "iso-8859-15"
+	ISO885916   = "iso-8859-16"
+	KOI8R       = "koi8-r"
+	KOI8U       = "koi8-u"
+	Windows874  = "windows-874"
+	Windows1250 = "windows-1250"
+	Windows1251 = "windows-1251"
+	Windows1253 = "windows-1253"
+	Windows1254 = "windows-1254"
+	Windows1255 = "windows-1255"
+	Windows1256 = "windows-1256"
+	Windows1257 = "windows-1257"
+	Windows1258 = "windows-1258"
+)
+
+// Encodings known but not supported, by name: the multi-byte encodings of Chinese, Japanese
+// and Korean, and those of older Macintosh systems
+const (
+	GBK          = "gbk"
+	GB18030      = "gb18030"
+	Big5         = "big5"
+	EUCJP        = "euc-jp"
+	ISO2022JP    = "iso-2022-jp"
+	ShiftJIS     = "shift_jis"
+	EUCKR        = "euc-kr"
+	Macintosh    = "macintosh"
+	XMacCyrillic = "x-mac-cyrillic"
+	// Replacement stands for encodings that browsers refuse to decode, such as ISO-2022-KR
+	Replacement = "replacement"
+)
+
+// ErrUnsupported is returned when decoding from an encoding that is known but not supported.
+var ErrUnsupported = errors.New("unsupported encoding")
+
+// labels maps the labels of the known encodings to their names, as in the WHATWG Encoding
+// Standard. As in browsers, ISO-8859-1 and ASCII are read as windows-1252, a superset of
+// both, ISO-8859-9 as windows-1254 and ISO-8859-11 as windows-874.
+var labels = map[string]string{
+	"utf-8":             UTF8,
+	"utf8":              UTF8,
+	"unicode-1-1-utf-8": UTF8,
+	"utf-16":            UTF16LE,
+	"utf-16le":          UTF16LE,
+	"unicode":           UTF16LE,
+	"utf-16be":          UTF16BE,
+	"windows-1252":      Windows1252,
+	"cp1252":            Windows1252,
+	"x-cp1252":          Windows1252,
+	"iso-8859-1":        Windows1252,
+	"iso8859-1":         Windows1252,
+	"iso_8859-1":        Windows1252,
+	"latin1":            Windows1252,
+	"l1":                Windows1252,
+	"ascii":             Windows1252,
+	"us-ascii":          Windows1252,
+	"ansi_x3.4-1968":    Windows1252,
+	"cp819":             Windows1252,
+	"csisolatin1":       Windows1252,
+	"ibm819":            Windows1252,
+	"iso-ir-100":        Windows1252,
+	"iso88591":          Windows1252,
+	"iso_8859-1:1987":   Windows1252,
+
+	"866":      IBM866,
+	"cp866":    IBM866,
+	"csibm866": IBM866,
+	"ibm866":   IBM866,
+
+	"csisolatin2":     ISO88592,
+	"iso-8859-2":      ISO88592,
+	"iso-ir-101":      ISO88592,
+	"iso8859-2":       ISO88592,
+	"iso88592":        ISO88592,
+	"iso_8859-2":      ISO88592,
+	"iso_8859-2:1987": ISO88592,
+	"l2":              ISO88592,
+	"latin2":          ISO88592,
+
+	"csisolatin3":     ISO88593,
+	"iso-8859-3":      ISO88593,
+	"iso-ir-109":      ISO88593,
+	"iso8859-3":       ISO88593,
+	"iso88593":        ISO88593,
+	"iso_8859-3":      ISO88593,
+	"iso_8859-3:1988": ISO88593,
+	"l3":              ISO88593,
+	"latin3":          ISO88593,
+
+	"csisolatin4":     ISO88594,
+	"iso-8859-4":      ISO88594,
+	"iso-ir-110":      ISO88594,
+	"iso8859-4":       ISO88594,
+	"iso88594":        ISO88594,
+	"iso_8859-4":      ISO88594,
+	"iso_8859-4:1988": ISO88594,
+	"l4":              ISO88594,
+	"latin4":          ISO88594,
+
+	"csisolatincyrillic": ISO88595,
+	"cyrillic":           ISO88595,
+	"iso-8859-5":         ISO88595,
+	"iso-ir-144":         ISO88595,
+	"iso8859-5":          ISO88595,
+	"iso88595":           ISO88595,
+	"iso_8859-5":         ISO88595,
+	"iso_8859-5:1988":    ISO88595,
+
+	"arabic":           ISO88596,
+	"asmo-708":         ISO88596,
+	"csiso88596e":      ISO88596,
+	"csiso88596i":      ISO88596,
+	"csisolatinarabic": ISO88596,
+	"ecma-114":         ISO88596,
+	"iso-8859-6":       ISO88596,
+	"iso-8859-6-e":     ISO88596,
+	"iso-8859-6-i":     ISO88596,
+	"iso-ir-127":       ISO88596,
+	"iso8859-6":        ISO88596,
+	"iso88596":         ISO88596,
+	"iso_8859-6":       ISO88596,
+	"iso_8859-6:1987":  ISO88596,
+
+	"csisolatingreek": ISO88597,
+	"ecma-118":        ISO88597,
+	"elot_928":        ISO88597,
+	"greek":           ISO88597,
+	"greek8":          ISO88597,
+	"iso-8859-7":      ISO88597,
+	"iso-ir-126":      ISO88597,
+	"iso8859-7":       ISO88597,
+	"iso88597":        ISO88597,
+	"iso_8859-7":      ISO88597,
+	"iso_8859-7:1987": ISO88597,
+	"sun_eu_greek":    ISO88597,
+
+	"csiso88598e":      ISO88598,
+	"csisolatinhebrew": ISO88598,
+	"hebrew":           ISO88598,
+	"iso-8859-8":       ISO88598,
+	"iso-8859-8-e":     ISO88598,
+	"iso-ir-138":       ISO88598,
+	"iso8859-8":        ISO88598,
+	"iso88598":         ISO88598,
+	"iso_8859-8":       ISO88598,
+	"iso_8859-8:1988":  ISO88598,
+	"visual":           ISO88598,
+	"csiso88598i":      ISO88598,
+	"iso-8859-8-i":     ISO88598,
+	"logical":          ISO88598,
+
+	"csisolatin6": ISO885910,
+	"iso-8859-10": ISO885910,
+	"iso-ir-157":  ISO885910,
+	"iso8859-10":  ISO885910,
+	"iso885910":   ISO885910,
+	"l6":          ISO885910,
+	"latin6":      ISO885910,
+
+	"iso-8859-13": ISO885913,
+	"iso8859-13":  ISO885913,
+	"iso885913":   ISO885913,
+
+	"iso-8859-14": ISO885914,
+	"iso8859-14":  ISO885914,
+	"iso885914":   ISO885914,
+
+	"csisolatin9": ISO885915,
+	"iso-8859-15": ISO885915,
+	"iso8859-15":  ISO885915,
+	"iso885915":   ISO885915,
+	"iso_8859-15": ISO885915,
+	"l9":          ISO885915,
+
+	"iso-8859-16": ISO885916,
+
+	"cskoi8r": KOI8R,
+	"koi":     KOI8R,
+	"koi8":    KOI8R,
+	"koi8-r":  KOI8R,
+	"koi8_r":  KOI8R,
+	"koi8-ru": KOI8U,
+	"koi8-u":  KOI8U,
+
+	"dos-874":     Windows874,
+	"iso-8859-11": Windows874,
+	"iso8859-11":  Windows874,
+	"iso885911":   Windows874,
+	"tis-620":     Windows874,
+	"windows-874": Windows874,
+
+	"cp1250":       Windows1250,
+	"windows-1250": Windows1250,
+	"x-cp1250":     Windows1250,
+	"cp1251":       Windows1251,
+	"windows-1251": Windows1251,
+	"x-cp1251":     Windows1251,
+	"cp1253":       Windows1253,
+	"windows-1253": Windows1253,
+	"x-cp1253":     Windows1253,
+
+	"cp1254":          Windows1254,
+	"csisolatin5":     Windows1254,
+	"iso-8859-9":      Windows1254,
+	"iso-ir-148":      Windows1254,
+	"iso8859-9":       Windows1254,
+	"iso88599":        Windows1254,
+	"iso_8859-9":      Windows1254,
+	"iso_8859-9:1989": Windows1254,
+	"l5":              Windows1254,
+	"latin5":          Windows1254,
+	"windows-1254":    Windows1254,
+	"x-cp1254":        Windows1254,
+
+	"cp1255":       Windows1255,
+	"windows-1255": Windows1255,
+	"x-cp1255":     Windows1255,
+	"cp1256":       Windows1256,
+	"windows-1256": Windows1256,
+	"x-cp1256":     Windows1256,
+	"cp1257":       Windows1257,
+	"windows-1257": Windows1257,
+	"x-cp1257":     Windows1257,
+	"cp1258":       Windows1258,
+	"windows-1258": Windows1258,
+	"x-cp1258":     Windows1258,
+
+	"chinese":         GBK,
+	"csgb2312":        GBK,
+	"csiso58gb231280": GBK,
+	"gb2312":          GBK,
+	"gb_2312":         GBK,
+	"gb_2312-80":      GBK,
+	"gbk":             GBK,
+	"iso-ir-58":       GBK,
+	"x-gbk":           GBK,
+	"gb18030":         GB18030,
+
+	"big5":       Big5,
+	"big5-hkscs": Big5,
+	"cn-big5":    Big5,
+	"csbig5":     Big5,
+	"x-x-big5":   Big5,
+
+	"cseucpkdfmtjapanese": EUCJP,
+	"euc-jp":              EUCJP,
+	"x-euc-jp":            EUCJP,
+	"csiso2022jp":         ISO2022JP,
+	"iso-2022-jp":         ISO2022JP,
+	"csshiftjis":          ShiftJIS,
+	"ms932":               ShiftJIS,
+	"ms_kanji":            ShiftJIS,
+	"shift-jis":           ShiftJIS,
+	"shift_jis":           ShiftJIS,
+	"sjis":                ShiftJIS,
+	"windows-31j":         ShiftJIS,
+	"x-sjis":              ShiftJIS,
+
+	"cseuckr":        EUCKR,
+	"csksc56011987":  EUCKR,
+	"euc-kr":         EUCKR,
+	"iso-ir-149":     EUCKR,
+	"korean":         EUCKR,
+	"ks_c_5601-1987": EUCKR,
+	"ks_c_5601-1989": EUCKR,
+	"ksc5601":        EUCKR,
+	"ksc_5601":       EUCKR,
+	"windows-949":    EUCKR,
+
+	"csmacintosh":     Macintosh,
+	"mac":             Macintosh,
+	"macintosh":       Macintosh,
+	"x-mac-roman":     Macintosh,
+	"x-mac-cyrillic":  XMacCyrillic,
+	"x-mac-ukrainian": XMacCyrillic,
+	"csiso2022kr":     Replacement,
+	"hz-gb-2312":      Replacement,
+	"iso-2022-cn":     Replacement,
+	"iso-2022-cn-ext": Replacement,
+	"iso-2022-kr":     Replacement,
+}
+
+// Lookup returns the name of the encoding with the given label, if known. Known encodings
+// may not be supported, as Supported reports.
+func Lookup(label string) (string, bool) {
+	name, ok := labels[strings.ToLower(strings.Trim(label, " \t\n\r\f\"'"))]
+	return name, ok
+}
+
+// Supported reports whether Decode can decode from the named encoding.
+func Supported(name string) bool {
+	switch name {
+	case UTF8, UTF16LE, UTF16BE, Windows1252:
+		return true
+	}
+	return singleByte[name] != nil
+}
+
+// ASCIICompatible reports whether the named encoding writes ASCII characters as ASCII does,
+// and no ASCII bytes but for them, so that the markup of a document in it can be read from
+// its bytes as they are. All known encodings are but UTF-16, ISO-2022-JP and replacement.
+func ASCIICompatible(name string) bool {
+	switch name {
+	case UTF16LE, UTF16BE, ISO2022JP, Replacement:
+		return false
+	}
+	return true
+}
+
+// Detect returns the name of the encoding of the HTML document data, served with
+// contentType: given by a byte order mark, the charset parameter of contentType, or a meta
+// element in the first 1024 bytes, in that order. Failing all, or when they give unknown
+// labels, it is UTF-8 if data is valid UTF-8 and windows-1252 otherwise. The encoding found
+// may be one Decode does not support.
+func Detect(data []byte, contentType string) string {
+	switch {
+	case len(data) >= 3 && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF:
+		return UTF8
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE:
+		return UTF16LE
+	case len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF:
+		return UTF16BE
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if name, ok := Lookup(params["charset"]); ok {
+			return name
+		}
+	}
+	if name, ok := prescan(data); ok {
+		return name
+	}
+	if utf8.Valid(data) {
+		return UTF8
+	}
+	return Windows1252
+}
+
+// prescan looks for the encoding declared by a meta element at the start of data.
+func prescan(data []byte) (string, bool) {
+	if len(data) > 1024 {
+		data = data[:1024]
+	}
+	z := htmltok.New(string(data))
+	for {
+		token, ok := z.Next()
+		if !ok {
+			return "", false
+		}
+		if token.Kind != htmltok.StartTag || token.Name != "meta" {
+			continue
+		}
+		label, found := token.Attr("charset")
+		if !found {
+			if equiv, _ := token.Attr("http-equiv"); strings.EqualFold(equiv, "content-type") {
+				content, _ := token.Attr("content")
+				if _, params, err := mime.ParseMediaType(content); err == nil {
+					label, found = params["charset"], true
+				}
+			}
+		}
+		if name, ok := Lookup(label); found && ok {
+			// A document declaring UTF-16 in ASCII is not UTF-16
+			if name == UTF16LE || name == UTF16BE {
+				name = UTF8
+			}
+			return name, true
+		}
+	}
+}
+
+// Decode returns data, in the named encoding, as UTF-8. Invalid sequences become U+FFFD.
+// Decoding from an encoding not supported fails with ErrUnsupported, rather than giving text
+// decoded wrongly.
+func Decode(data []byte, name string) (string, error) {
+	if !Supported(name) {
+		return "", fmt.Errorf("charset: %s: %w", name, ErrUnsupported)
+	}
+	if table := singleByte[name]; table != nil {
+		var b strings.Builder
+		b.Grow(len(data))
+		for _, c := range data {
+			if c < 0x80 {
+				b.WriteByte(c)
+			} else {
+				b.WriteRune(table[c-0x80])
+			}
+		}
+		return b.String(), nil
+	}
+	switch name {
+	case UTF16LE, UTF16BE:
+		if len(data) >= 2 && (data[0] == 0xFF && data[1] == 0xFE || data[0] == 0xFE && data[1] == 0xFF) {
+			data = data[2:]
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if name == UTF16LE {
+				units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+			} else {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	case Windows1252:
+		var b strings.Builder
+		b.Grow(len(data))
+		for _, c := range data {
+			switch {
+			case c < 0x80:
+				b.WriteByte(c)
+			case c < 0xA0:
+				b.WriteRune(windows1252[c-0x80])
+			default:
+				b.WriteRune(rune(c))
+			}
+		}
+		return b.String(), nil
+	}
+	s := strings.TrimPrefix(string(data), "\uFEFF")
+	return strings.ToValidUTF8(s, "\uFFFD"), nil
+}
+
+// windows1252 maps the bytes from 0x80 to 0x9F, where windows-1252 differs from ISO-8859-1.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
@@ -0,0 +1,72 @@
+package charset
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+		want        string
+	}{
+		{"bom over content type", "\xEF\xBB\xBFcafé", "text/html; charset=iso-8859-1", UTF8},
+		{"utf-16 bom", "\xFF\xFEa\x00", "", UTF16LE},
+		{"content type", "caf\xE9", "text/html; charset=ISO-8859-1", Windows1252},
+		{"meta charset", `<head><meta charset="latin1">caf` + "\xE9", "text/html", Windows1252},
+		{"meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=utf-8">`, "", UTF8},
+		{"meta utf-16", `<meta charset="utf-16">`, "", UTF8},
+		{"unsupported", "caf\xC3\xA9", "text/html; charset=Shift_JIS", ShiftJIS},
+		{"meta unsupported", `<meta charset="gb2312">`, "", GBK},
+		{"unknown", "caf\xC3\xA9", "text/html; charset=x-unknown", UTF8},
+		{"single byte", "\xE8esk\xE1", "text/html; charset=latin2", ISO88592},
+		{"invalid utf-8", "caf\xE9", "", Windows1252},
+	}
+	for _, test := range tests {
+		if got := Detect([]byte(test.data), test.contentType); got != test.want {
+			t.Errorf("%s: Detect = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		data string
+		name string
+		want string
+	}{
+		{"caf\xC3\xA9", UTF8, "café"},
+		{"\xEF\xBB\xBFcaf\xC3\xA9\xFF", UTF8, "café�"},
+		{"caf\xE9 \x80\x93", Windows1252, "café €“"},
+		{"\xFF\xFEc\x00\xE9\x00", UTF16LE, "cé"},
+		{"\x00c\x00\xE9", UTF16BE, "cé"},
+		{"\xE8esk\xE1", ISO88592, "česká"},
+		{"\xF2\xD5\xD3\xD8", KOI8R, "Русь"},
+		{"\xD0\xF3\xF1\xFC", Windows1251, "Русь"},
+		{"\xAE\xBE", KOI8U, "ўЎ"},
+		{"\x81\xA5", Windows1257, "\u0081\uFFFD"},
+	}
+	for _, test := range tests {
+		if got, err := Decode([]byte(test.data), test.name); err != nil || got != test.want {
+			t.Errorf("Decode(%q, %s) = %q, %v, want %q", test.data, test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	for _, name := range []string{ShiftJIS, GBK, EUCKR, Replacement, "x-unknown"} {
+		if got, err := Decode([]byte("\x93\xFA\x96\x7B"), name); !errors.Is(err, ErrUnsupported) || got != "" {
+			t.Errorf("Decode(%s) = %q, %v, want ErrUnsupported", name, got, err)
+		}
+	}
+}
+
+func TestASCIICompatible(t *testing.T) {
+	for name, want := range map[string]bool{ShiftJIS: true, GBK: true, EUCKR: true, ISO88592: true, UTF16LE: false, ISO2022JP: false} {
+		if got := ASCIICompatible(name); got != want {
+			t.Errorf("ASCIICompatible(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
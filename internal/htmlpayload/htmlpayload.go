@@ -0,0 +1,79 @@
+// Package htmlpayload reads the HTML documents held by WARC records, decoded from their
+// content codings and charset, for the packages deriving data from them.
+package htmlpayload
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/charset"
+	"github.com/zenless-lab/gwarc/internal/mediatype"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// MaxSize is the number of bytes of an HTML payload read
+const MaxSize = 5 << 20
+
+// Document is an HTML document read from a record.
+type Document struct {
+	// Data is the document, up to MaxSize bytes of it
+	Data []byte
+	// Charset is the name of its encoding, as found by charset.Detect
+	Charset string
+}
+
+// Record returns the HTML document of a response or resource record, or nil for any other
+// record and for documents in a content coding not supported.
+func Record(record *warc.Record) *Document {
+	switch {
+	case record.Type == warc.WARCTypeResponse && strings.HasPrefix(record.ContentType, "application/http"):
+		resp, err := http.ReadResponse(bufio.NewReader(record.Block), nil)
+		if err != nil {
+			// Not an HTTP response, and so no HTML
+			return nil
+		}
+		return Response(resp)
+	case record.Type == warc.WARCTypeResource:
+		if !IsHTML(record.ContentType) {
+			return nil
+		}
+		return read(record.Block, record.ContentType)
+	}
+	return nil
+}
+
+// Response returns the HTML document of an HTTP response, or nil as Record does.
+func Response(resp *http.Response) *Document {
+	contentType := resp.Header.Get("Content-Type")
+	if !IsHTML(contentType) {
+		return nil
+	}
+	body, err := warc.DecodeContent(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		// Still encoded, the payload is no HTML to read
+		return nil
+	}
+	return read(body, contentType)
+}
+
+// read reads the HTML document body, served with contentType.
+func read(body io.Reader, contentType string) *Document {
+	// A payload cut short is still worth reading
+	data, _ := io.ReadAll(io.LimitReader(body, MaxSize))
+	return &Document{Data: data, Charset: charset.Detect(data, contentType)}
+}
+
+// HTML returns the document as UTF-8. Documents in a charset not supported, such as
+// Shift_JIS or GBK, fail with an error wrapping charset.ErrUnsupported, as text decoded
+// wrongly would be garbage.
+func (d *Document) HTML() (string, error) {
+	return charset.Decode(d.Data, d.Charset)
+}
+
+// IsHTML reports whether contentType is that of an HTML document.
+func IsHTML(contentType string) bool {
+	mediaType := mediatype.Of(contentType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
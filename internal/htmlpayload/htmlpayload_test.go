@@ -0,0 +1,61 @@
+package htmlpayload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zenless-lab/gwarc/internal/charset"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+func TestDocumentHTML(t *testing.T) {
+	doc := &Document{Data: []byte("<p>\x93\xFA\x96\x7B</p>"), Charset: charset.ShiftJIS}
+	if html, err := doc.HTML(); !errors.Is(err, charset.ErrUnsupported) || html != "" {
+		t.Errorf("HTML() = %q, %v, want ErrUnsupported", html, err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("<p>caf\xE9</p>"))
+	zw.Close()
+	tests := []struct {
+		name        string
+		recordType  warc.WARCRecordType
+		contentType string
+		block       string
+		want        string
+		ok          bool
+	}{
+		{"response", warc.WARCTypeResponse, warc.ContentTypeHTTPResponse,
+			"HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=latin1\r\nContent-Encoding: gzip\r\n\r\n" + gz.String(), "<p>café</p>", true},
+		{"xhtml response", warc.WARCTypeResponse, warc.ContentTypeHTTPResponse,
+			"HTTP/1.1 200 OK\r\nContent-Type: application/xhtml+xml\r\n\r\n<p>café</p>", "<p>café</p>", true},
+		{"resource", warc.WARCTypeResource, "text/html; charset=koi8-r", "<p>\xF2\xD5\xD3\xD8</p>", "<p>Русь</p>", true},
+		{"image", warc.WARCTypeResponse, warc.ContentTypeHTTPResponse,
+			"HTTP/1.1 200 OK\r\nContent-Type: image/png\r\n\r\n\x89PNG", "", false},
+		{"unsupported coding", warc.WARCTypeResponse, warc.ContentTypeHTTPResponse,
+			"HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Encoding: br\r\n\r\n\x0b\x02\x80", "", false},
+		{"unsupported charset", warc.WARCTypeResource, "text/html; charset=shift_jis", "<p>\x93\xFA\x96\x7B</p>", "", true},
+		{"not http", warc.WARCTypeResponse, warc.ContentTypeHTTPResponse, "<p>café</p>", "", false},
+		{"request", warc.WARCTypeRequest, warc.ContentTypeHTTPResponse, "GET / HTTP/1.1\r\n\r\n", "", false},
+	}
+	for _, test := range tests {
+		record := &warc.Record{
+			WARCRecord: warc.WARCRecord{Type: test.recordType, ContentType: test.contentType},
+			Block:      strings.NewReader(test.block),
+		}
+		var got string
+		doc := Record(record)
+		if doc != nil {
+			got, _ = doc.HTML()
+		}
+		if got != test.want || (doc != nil) != test.ok {
+			t.Errorf("%s: Record() = %+v, HTML %q, want %q, %v", test.name, doc, got, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package htmltok splits HTML into tokens: text, tags with their attributes, comments and
+// doctypes. It follows the tokenization rules of HTML closely enough to pull text and links
+// out of real pages, not to build their DOM.
+package htmltok
+
+import (
+	"html"
+	"strings"
+)
+
+// Kind is the kind of a Token.
+type Kind int
+
+const (
+	// Text is a run of text
+	Text Kind = iota
+	// StartTag is a start tag, such as <a href="/">
+	StartTag
+	// EndTag is an end tag, such as </a>
+	EndTag
+	// Comment is a comment, or markup read as one, such as <?xml ...>
+	Comment
+	// Doctype is a <!DOCTYPE ...> declaration
+	Doctype
+)
+
+// Attr is an attribute of a start tag.
+type Attr struct {
+	// Name is in lower case
+	Name string
+	// Value has its character references decoded
+	Value string
+}
+
+// Token is a piece of HTML.
+type Token struct {
+	Kind Kind
+	// Name is the lower case name of a tag
+	Name string
+	// Attrs are the attributes of a start tag, in order, the first of any duplicates kept
+	Attrs []Attr
+	// Text is the text, with character references decoded except in script and style
+	// elements, or the content of a comment or doctype
+	Text string
+	// SelfClosing is set for start tags ending with "/>"
+	SelfClosing bool
+}
+
+// Attr returns the value of the named attribute, given in lower case.
+func (t *Token) Attr(name string) (string, bool) {
+	for _, a := range t.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// rawText lists the elements whose content is text up to their end tag, and whether its
+// character references are decoded.
+var rawText = map[string]bool{
+	"script":    false,
+	"style":     false,
+	"xmp":       false,
+	"noembed":   false,
+	"noframes":  false,
+	"plaintext": false,
+	"title":     true,
+	"textarea":  true,
+}
+
+// Tokenizer reads the tokens of a document.
+type Tokenizer struct {
+	s   string
+	pos int
+	// raw is the element whose content is read as text next, if any
+	raw string
+}
+
+// New returns a Tokenizer of the document s.
+func New(s string) *Tokenizer {
+	return &Tokenizer{s: s}
+}
+
+// Next returns the next token, or false at the end of the document.
+func (z *Tokenizer) Next() (Token, bool) {
+	if z.pos >= len(z.s) {
+		return Token{}, false
+	}
+	if z.raw != "" {
+		name := z.raw
+		z.raw = ""
+		if text := z.rawText(name); text != "" {
+			if rawText[name] {
+				text = html.UnescapeString(text)
+			}
+			return Token{Kind: Text, Text: text}, true
+		}
+	}
+
+	start := z.pos
+	for {
+		i := strings.IndexByte(z.s[z.pos:], '<')
+		if i < 0 {
+			z.pos = len(z.s)
+			break
+		}
+		z.pos += i
+		if z.pos > start && z.startsMarkup() {
+			break
+		}
+		if z.pos == start {
+			if token, ok := z.markup(); ok {
+				return token, true
+			}
+		}
+		// A '<' starting no markup is text
+		z.pos++
+	}
+	return Token{Kind: Text, Text: html.UnescapeString(z.s[start:z.pos])}, true
+}
+
+// startsMarkup reports whether the '<' at z.pos starts a tag, comment or doctype.
+func (z *Tokenizer) startsMarkup() bool {
+	rest := z.s[z.pos+1:]
+	if rest == "" {
+		return false
+	}
+	switch c := rest[0]; {
+	case c == '!' || c == '?':
+		return true
+	case c == '/':
+		return len(rest) > 1 && isLetter(rest[1])
+	default:
+		return isLetter(c)
+	}
+}
+
+// markup reads the tag, comment or doctype at z.pos, if any.
+func (z *Tokenizer) markup() (Token, bool) {
+	if !z.startsMarkup() {
+		return Token{}, false
+	}
+	rest := z.s[z.pos+1:]
+	switch rest[0] {
+	case '!':
+		if strings.HasPrefix(rest, "!--") {
+			body := rest[3:]
+			end := strings.Index(body, "-->")
+			if end < 0 {
+				z.pos = len(z.s)
+				return Token{Kind: Comment, Text: body}, true
+			}
+			z.pos += 4 + end + 3
+			return Token{Kind: Comment, Text: body[:end]}, true
+		}
+		text := z.bogus(2)
+		if len(text) >= 7 && strings.EqualFold(text[:7], "doctype") {
+			return Token{Kind: Doctype, Text: strings.TrimSpace(text[7:])}, true
+		}
+		return Token{Kind: Comment, Text: text}, true
+	case '?':
+		return Token{Kind: Comment, Text: z.bogus(1)}, true
+	case '/':
+		z.pos += 2
+		name := z.tagName()
+		if end := strings.IndexByte(z.s[z.pos:], '>'); end >= 0 {
+			z.pos += end + 1
+		} else {
+			z.pos = len(z.s)
+		}
+		return Token{Kind: EndTag, Name: name}, true
+	}
+	z.pos++
+	token := Token{Kind: StartTag, Name: z.tagName()}
+	z.attributes(&token)
+	if _, ok := rawText[token.Name]; ok && !token.SelfClosing {
+		z.raw = token.Name
+	}
+	return token, true
+}
+
+// bogus reads the text from n bytes after z.pos to the next '>'.
+func (z *Tokenizer) bogus(n int) string {
+	body := z.s[z.pos+n:]
+	end := strings.IndexByte(body, '>')
+	if end < 0 {
+		z.pos = len(z.s)
+		return body
+	}
+	z.pos += n + end + 1
+	return body[:end]
+}
+
+func (z *Tokenizer) tagName() string {
+	start := z.pos
+	for z.pos < len(z.s) && !isSpace(z.s[z.pos]) && z.s[z.pos] != '/' && z.s[z.pos] != '>' {
+		z.pos++
+	}
+	return strings.ToLower(z.s[start:z.pos])
+}
+
+// attributes reads the attributes of a start tag up to its end.
+func (z *Tokenizer) attributes(token *Token) {
+	for z.pos < len(z.s) {
+		c := z.s[z.pos]
+		switch {
+		case c == '>':
+			z.pos++
+			return
+		case c == '/':
+			z.pos++
+			if z.pos < len(z.s) && z.s[z.pos] == '>' {
+				token.SelfClosing = true
+				z.pos++
+				return
+			}
+			continue
+		case isSpace(c):
+			z.pos++
+			continue
+		}
+
+		start := z.pos
+		// A leading '=' is part of the name
+		z.pos++
+		for z.pos < len(z.s) && !isSpace(z.s[z.pos]) && !strings.ContainsRune("/>=", rune(z.s[z.pos])) {
+			z.pos++
+		}
+		name := strings.ToLower(z.s[start:z.pos])
+		z.skipSpace()
+		var value string
+		if z.pos < len(z.s) && z.s[z.pos] == '=' {
+			z.pos++
+			z.skipSpace()
+			value = html.UnescapeString(z.attributeValue())
+		}
+		if _, duplicate := token.Attr(name); !duplicate {
+			token.Attrs = append(token.Attrs, Attr{Name: name, Value: value})
+		}
+	}
+}
+
+func (z *Tokenizer) attributeValue() string {
+	if z.pos >= len(z.s) {
+		return ""
+	}
+	if quote := z.s[z.pos]; quote == '"' || quote == '\'' {
+		body := z.s[z.pos+1:]
+		end := strings.IndexByte(body, quote)
+		if end < 0 {
+			z.pos = len(z.s)
+			return body
+		}
+		z.pos += end + 2
+		return body[:end]
+	}
+	start := z.pos
+	for z.pos < len(z.s) && !isSpace(z.s[z.pos]) && z.s[z.pos] != '>' {
+		z.pos++
+	}
+	return z.s[start:z.pos]
+}
+
+// rawText reads the content of the raw text element name, up to its end tag.
+func (z *Tokenizer) rawText(name string) string {
+	start := z.pos
+	if name == "plaintext" {
+		z.pos = len(z.s)
+		return z.s[start:]
+	}
+	for {
+		i := strings.Index(z.s[z.pos:], "</")
+		if i < 0 {
+			z.pos = len(z.s)
+			return z.s[start:]
+		}
+		z.pos += i
+		end := z.pos + 2 + len(name)
+		if end <= len(z.s) && strings.EqualFold(z.s[z.pos+2:end], name) &&
+			(end == len(z.s) || isSpace(z.s[end]) || z.s[end] == '/' || z.s[end] == '>') {
+			return z.s[start:z.pos]
+		}
+		z.pos += 2
+	}
+}
+
+func (z *Tokenizer) skipSpace() {
+	for z.pos < len(z.s) && isSpace(z.s[z.pos]) {
+		z.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
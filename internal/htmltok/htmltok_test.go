@@ -0,0 +1,100 @@
+package htmltok
+
+import (
+	"reflect"
+	"testing"
+)
+
+func tokens(s string) []Token {
+	var tokens []Token
+	z := New(s)
+	for {
+		token, ok := z.Next()
+		if !ok {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Token
+	}{
+		{
+			`<!DOCTYPE html><P Class=x id="a&amp;b" hidden>Fish &amp; chips</p>`,
+			[]Token{
+				{Kind: Doctype, Text: "html"},
+				{Kind: StartTag, Name: "p", Attrs: []Attr{{"class", "x"}, {"id", "a&b"}, {"hidden", ""}}},
+				{Kind: Text, Text: "Fish & chips"},
+				{Kind: EndTag, Name: "p"},
+			},
+		},
+		{
+			`<img src='a.png' alt=x/><br/>`,
+			[]Token{
+				{Kind: StartTag, Name: "img", Attrs: []Attr{{"src", "a.png"}, {"alt", "x/"}}},
+				{Kind: StartTag, Name: "br", SelfClosing: true},
+			},
+		},
+		{
+			`<script>if (a < b && c) { x = "</p>" }</script><style>p{}</STYLE >`,
+			[]Token{
+				{Kind: StartTag, Name: "script"},
+				{Kind: Text, Text: `if (a < b && c) { x = "</p>" }`},
+				{Kind: EndTag, Name: "script"},
+				{Kind: StartTag, Name: "style"},
+				{Kind: Text, Text: "p{}"},
+				{Kind: EndTag, Name: "style"},
+			},
+		},
+		{
+			`<title>A &lt;b&gt; title</title>`,
+			[]Token{
+				{Kind: StartTag, Name: "title"},
+				{Kind: Text, Text: "A <b> title"},
+				{Kind: EndTag, Name: "title"},
+			},
+		},
+		{
+			`a < b <!-- c --> d <? e ?><a href="x" href="y">`,
+			[]Token{
+				{Kind: Text, Text: "a < b "},
+				{Kind: Comment, Text: " c "},
+				{Kind: Text, Text: " d "},
+				{Kind: Comment, Text: "? e ?"},
+				{Kind: StartTag, Name: "a", Attrs: []Attr{{"href", "x"}}},
+			},
+		},
+		{
+			`<a href="unterminated`,
+			[]Token{{Kind: StartTag, Name: "a", Attrs: []Attr{{"href", "unterminated"}}}},
+		},
+		{
+			`<script>never closed`,
+			[]Token{{Kind: StartTag, Name: "script"}, {Kind: Text, Text: "never closed"}},
+		},
+		{"<", []Token{{Kind: Text, Text: "<"}}},
+	}
+	for _, test := range tests {
+		if got := tokens(test.input); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.input, got, test.want)
+		}
+	}
+}
+
+func FuzzTokenizer(f *testing.F) {
+	f.Add(`<html><head><title>x</title><script>a</script></head><body><a href=b>c</a><!-- d --></body>`)
+	f.Fuzz(func(t *testing.T, s string) {
+		z := New(s)
+		for i := 0; ; i++ {
+			if i > len(s)+1 {
+				t.Fatal("more tokens than bytes")
+			}
+			if _, ok := z.Next(); !ok {
+				break
+			}
+		}
+	})
+}
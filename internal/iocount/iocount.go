@@ -0,0 +1,42 @@
+// Package iocount counts the bytes read through readers, to find the offsets and sizes of
+// what they read.
+package iocount
+
+import "io"
+
+// Reader counts the bytes read through it. It implements io.ByteReader, through that of the
+// reader it wraps when it has one, so that a gzip.Reader reading a buffered reader through
+// it reads no further than the end of each member.
+type Reader struct {
+	r io.Reader
+	n int64
+}
+
+// NewReader returns a Reader reading r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// N returns the number of bytes read.
+func (c *Reader) N() int64 {
+	return c.n
+}
+
+func (c *Reader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *Reader) ReadByte() (byte, error) {
+	if br, ok := c.r.(io.ByteReader); ok {
+		b, err := br.ReadByte()
+		if err == nil {
+			c.n++
+		}
+		return b, err
+	}
+	var p [1]byte
+	_, err := io.ReadFull(c, p[:])
+	return p[0], err
+}
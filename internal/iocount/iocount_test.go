@@ -0,0 +1,50 @@
+package iocount
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	for _, r := range []io.Reader{strings.NewReader("hello, world"), bufio.NewReader(strings.NewReader("hello, world"))} {
+		c := NewReader(r)
+		if b, err := c.ReadByte(); err != nil || b != 'h' {
+			t.Errorf("%T: ReadByte() = %q, %v", r, b, err)
+		}
+		if data, err := io.ReadAll(c); err != nil || string(data) != "ello, world" || c.N() != 12 {
+			t.Errorf("%T: read %q, %v, N() = %d", r, data, err, c.N())
+		}
+		if _, err := c.ReadByte(); err != io.EOF || c.N() != 12 {
+			t.Errorf("%T: ReadByte() at the end = %v, N() = %d", r, err, c.N())
+		}
+	}
+}
+
+func TestReaderGzipMember(t *testing.T) {
+	var buf bytes.Buffer
+	var size int
+	for _, s := range []string{"one", "two"} {
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		if size == 0 {
+			size = buf.Len()
+		}
+	}
+	c := NewReader(bufio.NewReader(&buf))
+	zr, err := gzip.NewReader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.Multistream(false)
+	if data, err := io.ReadAll(zr); err != nil || string(data) != "one" {
+		t.Fatalf("first member = %q, %v", data, err)
+	}
+	if c.N() != int64(size) {
+		t.Errorf("N() = %d after a member of %d bytes", c.N(), size)
+	}
+}
@@ -0,0 +1,17 @@
+// Package mediatype reads the media type of Content-Type values as archives hold them,
+// malformed ones included.
+package mediatype
+
+import (
+	"mime"
+	"strings"
+)
+
+// Of returns the media type of contentType, in lower case and without parameters. A value
+// that does not parse gives what comes before its first ";".
+func Of(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+}
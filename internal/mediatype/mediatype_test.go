@@ -0,0 +1,18 @@
+package mediatype
+
+import "testing"
+
+func TestOf(t *testing.T) {
+	tests := map[string]string{
+		"":                                 "",
+		"text/html":                        "text/html",
+		"Text/HTML; charset=UTF-8":         "text/html",
+		"text/html; charset":               "text/html",
+		" application/pdf ;; name=\"a b\"": "application/pdf",
+	}
+	for contentType, want := range tests {
+		if got := Of(contentType); got != want {
+			t.Errorf("Of(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+// backwardReader reads a bitstream written forward by a bitWriter and read from its end,
+// as FSE and Huffman streams are. The last byte of the stream holds a marker bit above
+// the last bits written.
+type backwardReader struct {
+	data []byte
+	// off is the number of bytes of data not yet loaded into bits
+	off  int
+	bits uint64
+	// n is the number of unread low bits of bits
+	n uint
+}
+
+func newBackwardReader(data []byte) (*backwardReader, error) {
+	if len(data) == 0 {
+		return nil, corrupt("empty bitstream")
+	}
+	last := data[len(data)-1]
+	if last == 0 {
+		return nil, corrupt("bitstream without end marker")
+	}
+	return &backwardReader{
+		data: data,
+		off:  len(data) - 1,
+		bits: uint64(last),
+		n:    uint(7 - bits.LeadingZeros8(last)),
+	}, nil
+}
+
+// fill loads bytes until at least 56 bits are available, or the stream is loaded.
+func (r *backwardReader) fill() {
+	for r.n <= 56 && r.off > 0 {
+		r.off--
+		r.bits = r.bits<<8 | uint64(r.data[r.off])
+		r.n += 8
+	}
+}
+
+// remaining returns the number of bits left to read.
+func (r *backwardReader) remaining() int {
+	return int(r.n) + 8*r.off
+}
+
+// read returns the next n bits, n being at most 32.
+func (r *backwardReader) read(n uint) (uint32, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if r.n < n {
+		r.fill()
+		if r.n < n {
+			return 0, corrupt("bitstream overflow")
+		}
+	}
+	r.n -= n
+	return uint32(r.bits>>r.n) & (1<<n - 1), nil
+}
+
+// peek returns the next n bits without reading them, padded with zeros past the end
+// of the stream.
+func (r *backwardReader) peek(n uint) uint32 {
+	if r.n < n {
+		r.fill()
+		if r.n < n {
+			return uint32(r.bits<<(n-r.n)) & (1<<n - 1)
+		}
+	}
+	return uint32(r.bits>>(r.n-n)) & (1<<n - 1)
+}
+
+// skip drops n bits returned by peek.
+func (r *backwardReader) skip(n uint) error {
+	if r.n < n {
+		return corrupt("bitstream overflow")
+	}
+	r.n -= n
+	return nil
+}
+
+// forwardReader reads the bits of a byte slice from its start, lowest bits first, as
+// FSE table descriptions are written.
+type forwardReader struct {
+	data []byte
+	pos  uint
+}
+
+// peek returns the next n bits, n being at most 24, padded with zeros past the end of data.
+func (r *forwardReader) peek(n uint) uint32 {
+	var v uint32
+	start := int(r.pos / 8)
+	for i := 0; i < 4 && start+i < len(r.data); i++ {
+		v |= uint32(r.data[start+i]) << (8 * i)
+	}
+	return (v >> (r.pos % 8)) & (1<<n - 1)
+}
+
+func (r *forwardReader) skip(n uint) {
+	r.pos += n
+}
+
+// consumed returns the number of bytes read, counting a partly read byte.
+func (r *forwardReader) consumed() int {
+	return int((r.pos + 7) / 8)
+}
+
+// bitWriter writes a bitstream read backward by a backwardReader, or forward by a
+// forwardReader.
+type bitWriter struct {
+	out  []byte
+	bits uint64
+	n    uint
+}
+
+// write writes the low n bits of v, n being at most 32.
+func (w *bitWriter) write(v uint32, n uint) {
+	w.bits |= uint64(v&(1<<n-1)) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+// close writes the end marker of a backward stream and returns the stream.
+func (w *bitWriter) close() []byte {
+	w.write(1, 1)
+	return w.flush()
+}
+
+// flush writes the last partial byte, padded with zeros, and returns the stream.
+func (w *bitWriter) flush() []byte {
+	if w.n > 0 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits, w.n = 0, 0
+	}
+	return w.out
+}
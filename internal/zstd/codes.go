@@ -0,0 +1,97 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+// Largest symbols and accuracy logs of the FSE tables of sequences
+const (
+	maxLiteralLengthSymbol = 35
+	maxMatchLengthSymbol   = 52
+	maxOffsetSymbol        = 31
+	maxLiteralLengthLog    = 9
+	maxMatchLengthLog      = 9
+	maxOffsetLog           = 8
+)
+
+// code is the baseline and number of extra bits of a literal or match length code.
+type code struct {
+	baseline uint32
+	extra    uint8
+}
+
+var literalLengthCodes = func() []code {
+	codes := make([]code, 0, maxLiteralLengthSymbol+1)
+	for i := uint32(0); i < 16; i++ {
+		codes = append(codes, code{i, 0})
+	}
+	return append(codes,
+		code{16, 1}, code{18, 1}, code{20, 1}, code{22, 1}, code{24, 2}, code{28, 2},
+		code{32, 3}, code{40, 3}, code{48, 4}, code{64, 6}, code{128, 7}, code{256, 8},
+		code{512, 9}, code{1024, 10}, code{2048, 11}, code{4096, 12}, code{8192, 13},
+		code{16384, 14}, code{32768, 15}, code{65536, 16})
+}()
+
+var matchLengthCodes = func() []code {
+	codes := make([]code, 0, maxMatchLengthSymbol+1)
+	for i := uint32(3); i < 35; i++ {
+		codes = append(codes, code{i, 0})
+	}
+	return append(codes,
+		code{35, 1}, code{37, 1}, code{39, 1}, code{41, 1}, code{43, 2}, code{47, 2},
+		code{51, 3}, code{59, 3}, code{67, 4}, code{83, 4}, code{99, 5}, code{131, 7},
+		code{259, 8}, code{515, 9}, code{1027, 10}, code{2051, 11}, code{4099, 12},
+		code{8195, 13}, code{16387, 14}, code{32771, 15}, code{65539, 16})
+}()
+
+// Default distributions of the predefined mode
+var (
+	literalLengthDefault = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	matchLengthDefault = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	offsetDefault = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+var (
+	literalLengthPredefined = mustBuildDecoding(literalLengthDefault, 6)
+	matchLengthPredefined   = mustBuildDecoding(matchLengthDefault, 6)
+	offsetPredefined        = mustBuildDecoding(offsetDefault, 5)
+
+	literalLengthEncoder = buildEncoding(literalLengthDefault, 6)
+	matchLengthEncoder   = buildEncoding(matchLengthDefault, 6)
+	offsetEncoder        = buildEncoding(offsetDefault, 5)
+)
+
+func mustBuildDecoding(norm []int16, log uint8) *fseTable {
+	table, err := buildDecoding(norm, log)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+// lengthCode returns the code of a literal or match length, and its extra bits.
+func lengthCode(codes []code, length uint32) (uint8, uint32) {
+	i := len(codes) - 1
+	for codes[i].baseline > length {
+		i--
+	}
+	return uint8(i), length - codes[i].baseline
+}
+
+// offsetCode returns the code of an offset value, and its extra bits.
+func offsetCode(value uint32) (uint8, uint32) {
+	c := uint8(31 - bits.LeadingZeros32(value))
+	return c, value - 1<<c
+}
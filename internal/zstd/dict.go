@@ -0,0 +1,84 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// dictMagic starts a dictionary in the zstd format
+const dictMagic = 0xEC30A437
+
+// Dict is a dictionary shared by the frames compressed with it: either a zstd dictionary,
+// as trained by "zstd --train", with an ID and entropy tables, or raw content.
+type Dict struct {
+	// ID identifies the dictionary in the frames compressed with it; 0 for raw content
+	ID uint32
+
+	data     []byte
+	content  []byte
+	huffman  *huffTable
+	tables   [3]*fseTable
+	offsets  [3]uint32
+	hashOnce sync.Once
+	table    []uint32
+	chain    []uint32
+}
+
+// ParseDict parses a dictionary. Data starting with the dictionary magic number is a zstd
+// dictionary; any other data is used as raw content.
+func ParseDict(data []byte) (*Dict, error) {
+	d := &Dict{data: data, content: data, offsets: [3]uint32{1, 4, 8}}
+	if len(data) < 8 || binary.LittleEndian.Uint32(data) != dictMagic {
+		return d, nil
+	}
+	d.ID = binary.LittleEndian.Uint32(data[4:])
+	rest := data[8:]
+	var n int
+	var err error
+	if d.huffman, n, err = readHuffman(rest); err != nil {
+		return nil, err
+	}
+	rest = rest[n:]
+	limits := [3]struct {
+		maxSymbol int
+		maxLog    uint8
+	}{{maxOffsetSymbol, maxOffsetLog}, {maxMatchLengthSymbol, maxMatchLengthLog}, {maxLiteralLengthSymbol, maxLiteralLengthLog}}
+	// The tables are stored for offsets, match lengths and literal lengths, in that order
+	order := [3]int{tableOffset, tableMatchLength, tableLiteralLength}
+	for i, limit := range limits {
+		if d.tables[order[i]], n, err = readTable(rest, limit.maxSymbol, limit.maxLog); err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+	}
+	if len(rest) < 12 {
+		return nil, corrupt("truncated dictionary")
+	}
+	for i := range d.offsets {
+		d.offsets[i] = binary.LittleEndian.Uint32(rest[4*i:])
+		if d.offsets[i] == 0 {
+			return nil, corrupt("invalid dictionary repeat offset")
+		}
+	}
+	d.content = rest[12:]
+	return d, nil
+}
+
+// Bytes returns the dictionary as parsed.
+func (d *Dict) Bytes() []byte {
+	return d.data
+}
+
+// hashTables returns the hash chains of the dictionary content, computed once.
+func (d *Dict) hashTables() (table, chain []uint32) {
+	d.hashOnce.Do(func() {
+		d.table = make([]uint32, 1<<hashLog)
+		d.chain = make([]uint32, 1<<chainLog)
+		for i := 0; i+minMatch <= len(d.content); i++ {
+			h := hash4(d.content[i:])
+			d.chain[i&(1<<chainLog-1)] = d.table[h]
+			d.table[h] = uint32(i)
+		}
+	})
+	return d.table, d.chain
+}
@@ -0,0 +1,334 @@
+package zstd
+
+import (
+	"math"
+	"math/bits"
+)
+
+// fseEntry is a state of an FSE decoding table: the symbol it decodes, and the next state,
+// base plus the next nbBits bits of the stream.
+type fseEntry struct {
+	symbol uint8
+	nbBits uint8
+	base   uint16
+}
+
+// fseTable is an FSE decoding table of 1<<log states.
+type fseTable struct {
+	log     uint8
+	entries []fseEntry
+}
+
+// readNormalized reads an FSE table description, returning the normalized counts of
+// symbols 0 to maxSymbol, the accuracy log, and the number of bytes read.
+func readNormalized(data []byte, maxSymbol int, maxLog uint8) ([]int16, uint8, int, error) {
+	r := &forwardReader{data: data}
+	log := uint8(r.peek(4)) + 5
+	r.skip(4)
+	if log > maxLog {
+		return nil, 0, 0, corrupt("FSE accuracy log %d above %d", log, maxLog)
+	}
+
+	norm := make([]int16, maxSymbol+1)
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := uint(log) + 1
+	symbol := 0
+	previous0 := false
+	for remaining > 1 && symbol <= maxSymbol {
+		if previous0 {
+			// Repeat flags of 2 bits tell how many more symbols have probability 0
+			zeros := symbol
+			for r.peek(2) == 3 {
+				zeros += 3
+				r.skip(2)
+			}
+			zeros += int(r.peek(2))
+			r.skip(2)
+			if zeros > maxSymbol {
+				return nil, 0, 0, corrupt("FSE symbol overflow")
+			}
+			symbol = zeros
+			previous0 = false
+			continue
+		}
+
+		max := 2*threshold - 1 - remaining
+		v := int(r.peek(nbBits))
+		count := v & (threshold - 1)
+		if count < max {
+			r.skip(nbBits - 1)
+		} else {
+			count = v & (2*threshold - 1)
+			if count >= threshold {
+				count -= max
+			}
+			r.skip(nbBits)
+		}
+		count--
+		if count >= 0 {
+			remaining -= count
+		} else {
+			remaining--
+		}
+		norm[symbol] = int16(count)
+		symbol++
+		previous0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || r.consumed() > len(data) {
+		return nil, 0, 0, corrupt("invalid FSE table description")
+	}
+	return norm, log, r.consumed(), nil
+}
+
+// spread returns the symbol of each state of the table of a normalized distribution. Symbols
+// with a probability "less than 1" take the last states.
+func spread(norm []int16, log uint8) ([]uint8, error) {
+	size := 1 << log
+	symbols := make([]uint8, size)
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			symbols[high] = uint8(s)
+			high--
+		}
+	}
+	pos := 0
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			symbols[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, corrupt("invalid FSE distribution")
+	}
+	return symbols, nil
+}
+
+// buildDecoding returns the decoding table of a normalized distribution.
+func buildDecoding(norm []int16, log uint8) (*fseTable, error) {
+	symbols, err := spread(norm, log)
+	if err != nil {
+		return nil, err
+	}
+	size := 1 << log
+	next := make([]uint16, len(norm))
+	for s, n := range norm {
+		if n == -1 {
+			next[s] = 1
+		} else {
+			next[s] = uint16(n)
+		}
+	}
+	table := &fseTable{log: log, entries: make([]fseEntry, size)}
+	for i, s := range symbols {
+		state := next[s]
+		next[s]++
+		nbBits := log - uint8(15-bits.LeadingZeros16(state))
+		table.entries[i] = fseEntry{symbol: s, nbBits: nbBits, base: state<<nbBits - uint16(size)}
+	}
+	return table, nil
+}
+
+// readTable reads an FSE table description and returns its decoding table.
+func readTable(data []byte, maxSymbol int, maxLog uint8) (*fseTable, int, error) {
+	norm, log, n, err := readNormalized(data, maxSymbol, maxLog)
+	if err != nil {
+		return nil, 0, err
+	}
+	table, err := buildDecoding(norm, log)
+	return table, n, err
+}
+
+// rleTable returns the table of a symbol repeated for every sequence.
+func rleTable(symbol uint8) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+// fseEncoder encodes symbols with the FSE distribution it was built from.
+type fseEncoder struct {
+	log        uint8
+	stateTable []uint16
+	symbols    []symbolTransform
+}
+
+type symbolTransform struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// buildEncoding returns the encoder of a normalized distribution.
+func buildEncoding(norm []int16, log uint8) *fseEncoder {
+	symbols, err := spread(norm, log)
+	if err != nil {
+		panic(err)
+	}
+	size := 1 << log
+	cumul := make([]int, len(norm)+1)
+	for s, n := range norm {
+		if n == -1 {
+			n = 1
+		}
+		cumul[s+1] = cumul[s] + int(n)
+	}
+	enc := &fseEncoder{log: log, stateTable: make([]uint16, size), symbols: make([]symbolTransform, len(norm))}
+	for u, s := range symbols {
+		enc.stateTable[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+
+	total := 0
+	for s, n := range norm {
+		switch n {
+		case 0:
+			enc.symbols[s].deltaNbBits = uint32(log+1)<<16 - uint32(size)
+		case -1, 1:
+			enc.symbols[s] = symbolTransform{deltaNbBits: uint32(log)<<16 - uint32(size), deltaFindState: int32(total - 1)}
+			total++
+		default:
+			maxBitsOut := uint32(log) - uint32(31-bits.LeadingZeros32(uint32(n-1)))
+			minStatePlus := uint32(n) << maxBitsOut
+			enc.symbols[s] = symbolTransform{deltaNbBits: maxBitsOut<<16 - minStatePlus, deltaFindState: int32(total - int(n))}
+			total += int(n)
+		}
+	}
+	return enc
+}
+
+// fseState is the state of an fseEncoder encoding a stream.
+type fseState struct {
+	enc   *fseEncoder
+	state uint32
+}
+
+// init starts encoding with the last symbol of the stream, which is decoded first.
+func (s *fseState) init(enc *fseEncoder, symbol uint8) {
+	t := enc.symbols[symbol]
+	nbBits := (t.deltaNbBits + 1<<15) >> 16
+	value := nbBits<<16 - t.deltaNbBits
+	s.enc = enc
+	s.state = uint32(enc.stateTable[int32(value>>nbBits)+t.deltaFindState])
+}
+
+// encode writes the bits moving to the state of the previous symbol of the stream.
+func (s *fseState) encode(w *bitWriter, symbol uint8) {
+	t := s.enc.symbols[symbol]
+	nbBits := (s.state + t.deltaNbBits) >> 16
+	w.write(s.state, uint(nbBits))
+	s.state = uint32(s.enc.stateTable[int32(s.state>>nbBits)+t.deltaFindState])
+}
+
+// flush writes the state the decoder starts from.
+func (s *fseState) flush(w *bitWriter) {
+	w.write(s.state, uint(s.enc.log))
+}
+
+// normalize returns the distribution of symbol counts over 1<<log states, or false if the
+// states are too few for the symbols.
+func normalize(counts []int, total int, log uint8) ([]int16, bool) {
+	scale := 1 << log
+	norm := make([]int16, len(counts))
+	largest, sum := -1, 0
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		n := (c*scale + total/2) / total
+		if n == 0 {
+			norm[s] = -1
+			sum++
+		} else {
+			norm[s] = int16(n)
+			sum += n
+		}
+		if largest < 0 || c > counts[largest] {
+			largest = s
+		}
+	}
+	if largest < 0 || int(norm[largest])+scale-sum < 1 {
+		return nil, false
+	}
+	norm[largest] += int16(scale - sum)
+	return norm, true
+}
+
+// cost returns the approximate number of bits coding the symbol counts with a normalized
+// distribution takes, or -1 if it gives a counted symbol probability 0.
+func cost(counts []int, norm []int16, log uint8) int {
+	bits := 0.0
+	for s, c := range counts {
+		if c == 0 {
+			continue
+		}
+		n := int16(0)
+		if s < len(norm) {
+			n = norm[s]
+		}
+		if n == 0 {
+			return -1
+		}
+		if n < 0 {
+			n = 1
+		}
+		bits += float64(c) * (float64(log) - math.Log2(float64(n)))
+	}
+	return int(bits)
+}
+
+// writeNormalized appends the table description of a normalized distribution to dst.
+func writeNormalized(dst []byte, norm []int16, log uint8) []byte {
+	w := &bitWriter{out: dst}
+	w.write(uint32(log-5), 4)
+	remaining := 1<<log + 1
+	threshold := 1 << log
+	nbBits := uint(log) + 1
+	previous0 := false
+	for symbol := 0; symbol < len(norm) && remaining > 1; {
+		if previous0 {
+			start := symbol
+			for symbol < len(norm) && norm[symbol] == 0 {
+				symbol++
+			}
+			for ; symbol >= start+24; start += 24 {
+				w.write(0xffff, 16)
+			}
+			for ; symbol >= start+3; start += 3 {
+				w.write(3, 2)
+			}
+			w.write(uint32(symbol-start), 2)
+		}
+		count := int(norm[symbol])
+		symbol++
+		max := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		count++
+		if count >= threshold {
+			count += max
+		}
+		if count < max {
+			w.write(uint32(count), nbBits-1)
+		} else {
+			w.write(uint32(count), nbBits)
+		}
+		previous0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	return w.flush()
+}
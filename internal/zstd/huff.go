@@ -0,0 +1,275 @@
+package zstd
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// maxHuffmanBits is the longest Huffman code of literals
+const maxHuffmanBits = 11
+
+// huffTable is a Huffman decoding table indexed by the next maxBits bits of a stream.
+// Each entry holds a symbol in its high byte and the length of its code in its low byte.
+type huffTable struct {
+	maxBits uint8
+	entries []uint16
+}
+
+// readHuffman reads a Huffman tree description, returning its table and the number of
+// bytes read.
+func readHuffman(data []byte) (*huffTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, corrupt("missing Huffman tree description")
+	}
+	header := int(data[0])
+	var weights []uint8
+	n := 1
+	if header < 128 {
+		// Weights compressed with FSE, decoded by two interleaved states
+		if 1+header > len(data) {
+			return nil, 0, corrupt("truncated Huffman tree description")
+		}
+		compressed := data[1 : 1+header]
+		table, m, err := readTable(compressed, 255, 6)
+		if err != nil {
+			return nil, 0, err
+		}
+		br, err := newBackwardReader(compressed[m:])
+		if err != nil {
+			return nil, 0, err
+		}
+		var states [2]uint32
+		for i := range states {
+			if states[i], err = br.read(uint(table.log)); err != nil {
+				return nil, 0, err
+			}
+		}
+		for i := 0; ; i ^= 1 {
+			if len(weights) > 254 {
+				return nil, 0, corrupt("too many Huffman weights")
+			}
+			entry := table.entries[states[i]]
+			weights = append(weights, entry.symbol)
+			if br.remaining() < int(entry.nbBits) {
+				weights = append(weights, table.entries[states[i^1]].symbol)
+				break
+			}
+			v, _ := br.read(uint(entry.nbBits))
+			states[i] = uint32(entry.base) + v
+		}
+		n += header
+	} else {
+		// Weights of 4 bits
+		count := header - 127
+		if 1+(count+1)/2 > len(data) {
+			return nil, 0, corrupt("truncated Huffman tree description")
+		}
+		for i := 0; i < count; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, b>>4)
+			} else {
+				weights = append(weights, b&0xf)
+			}
+		}
+		n += (count + 1) / 2
+	}
+	table, err := buildHuffman(weights)
+	return table, n, err
+}
+
+// buildHuffman returns the table of the weights of all symbols but the last, whose weight
+// completes the code.
+func buildHuffman(weights []uint8) (*huffTable, error) {
+	if len(weights) > 255 {
+		return nil, corrupt("too many Huffman weights")
+	}
+	var total uint32
+	for _, w := range weights {
+		if w > maxHuffmanBits+1 {
+			return nil, corrupt("invalid Huffman weight %d", w)
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return nil, corrupt("invalid Huffman weights")
+	}
+	maxBits := uint8(bits.Len32(total))
+	left := uint32(1)<<maxBits - total
+	if maxBits > maxHuffmanBits || left&(left-1) != 0 {
+		return nil, corrupt("invalid Huffman weights")
+	}
+	weights = append(weights, uint8(bits.Len32(left)))
+
+	// Codes are assigned by increasing weight, then symbol
+	var start [maxHuffmanBits + 2]uint32
+	for _, w := range weights {
+		if w > 0 {
+			start[w] += 1 << (w - 1)
+		}
+	}
+	next := uint32(0)
+	for w := range start {
+		count := start[w]
+		start[w] = next
+		next += count
+	}
+	table := &huffTable{maxBits: maxBits, entries: make([]uint16, 1<<maxBits)}
+	for s, w := range weights {
+		if w == 0 {
+			continue
+		}
+		entry := uint16(s)<<8 | uint16(maxBits+1-w)
+		for i := uint32(0); i < 1<<(w-1); i++ {
+			table.entries[start[w]+i] = entry
+		}
+		start[w] += 1 << (w - 1)
+	}
+	return table, nil
+}
+
+// decode decodes n symbols from a Huffman stream, appending them to dst.
+func (t *huffTable) decode(dst, stream []byte, n int) ([]byte, error) {
+	br, err := newBackwardReader(stream)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		entry := t.entries[br.peek(uint(t.maxBits))]
+		if err := br.skip(uint(entry & 0xff)); err != nil {
+			return nil, err
+		}
+		dst = append(dst, byte(entry>>8))
+	}
+	if br.remaining() != 0 {
+		return nil, corrupt("Huffman stream not fully read")
+	}
+	return dst, nil
+}
+
+// huffEncoder encodes literals with a Huffman code.
+type huffEncoder struct {
+	maxBits uint8
+	codes   [256]uint16
+	lengths [256]uint8
+	// lastSymbol is the largest symbol coded
+	lastSymbol int
+}
+
+// buildHuffmanEncoder returns a code of at most maxHuffmanBits bits for the symbol counts,
+// which must count at least two distinct symbols.
+func buildHuffmanEncoder(counts *[256]int) *huffEncoder {
+	type node struct {
+		count  int
+		parent int
+	}
+	var symbols []int
+	for s, c := range counts {
+		if c > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	scaled := *counts
+	enc := &huffEncoder{lastSymbol: symbols[len(symbols)-1]}
+	for {
+		sort.SliceStable(symbols, func(i, j int) bool { return scaled[symbols[i]] < scaled[symbols[j]] })
+		// Leaves come first, then internal nodes in the order they are created, which is
+		// also by increasing count, so the two smallest nodes are always at the heads of
+		// the two queues.
+		nodes := make([]node, 0, 2*len(symbols))
+		for _, s := range symbols {
+			nodes = append(nodes, node{count: scaled[s]})
+		}
+		leaf, internal := 0, len(symbols)
+		pick := func() int {
+			if leaf < len(symbols) && (internal >= len(nodes) || nodes[leaf].count <= nodes[internal].count) {
+				leaf++
+				return leaf - 1
+			}
+			internal++
+			return internal - 1
+		}
+		for i := 0; i < len(symbols)-1; i++ {
+			a, b := pick(), pick()
+			nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, parent: -1})
+			nodes[a].parent, nodes[b].parent = len(nodes)-1, len(nodes)-1
+		}
+		depths := make([]uint8, len(nodes))
+		for i := len(nodes) - 2; i >= 0; i-- {
+			depths[i] = depths[nodes[i].parent] + 1
+		}
+		maxBits := uint8(0)
+		for i := range symbols {
+			if depths[i] > maxBits {
+				maxBits = depths[i]
+			}
+		}
+		if maxBits <= maxHuffmanBits {
+			enc.maxBits = maxBits
+			for i, s := range symbols {
+				enc.lengths[s] = depths[i]
+			}
+			break
+		}
+		// Flatten the distribution until the code is short enough
+		for _, s := range symbols {
+			scaled[s] = (scaled[s] + 1) / 2
+		}
+	}
+
+	// Canonical codes, assigned as buildHuffman does
+	var start [maxHuffmanBits + 2]uint32
+	for s := 0; s <= enc.lastSymbol; s++ {
+		if l := enc.lengths[s]; l > 0 {
+			start[enc.maxBits+1-l] += 1 << (enc.maxBits - l)
+		}
+	}
+	next := uint32(0)
+	for w := range start {
+		count := start[w]
+		start[w] = next
+		next += count
+	}
+	for s := 0; s <= enc.lastSymbol; s++ {
+		if l := enc.lengths[s]; l > 0 {
+			w := enc.maxBits + 1 - l
+			enc.codes[s] = uint16(start[w] >> (w - 1))
+			start[w] += 1 << (w - 1)
+		}
+	}
+	return enc
+}
+
+// writeDescription appends the tree description of the code, with weights of 4 bits. It
+// requires lastSymbol to be below 128.
+func (e *huffEncoder) writeDescription(dst []byte) []byte {
+	count := e.lastSymbol
+	dst = append(dst, byte(127+count))
+	for i := 0; i < count; i += 2 {
+		b := e.weight(i) << 4
+		if i+1 < count {
+			b |= e.weight(i + 1)
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+func (e *huffEncoder) weight(symbol int) byte {
+	if e.lengths[symbol] == 0 {
+		return 0
+	}
+	return e.maxBits + 1 - e.lengths[symbol]
+}
+
+// encode appends the Huffman stream of literals to dst.
+func (e *huffEncoder) encode(dst, literals []byte) []byte {
+	w := &bitWriter{out: dst}
+	for i := len(literals) - 1; i >= 0; i-- {
+		s := literals[i]
+		w.write(uint32(e.codes[s]), uint(e.lengths[s]))
+	}
+	return w.close()
+}
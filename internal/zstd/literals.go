@@ -0,0 +1,221 @@
+package zstd
+
+import (
+	"encoding/binary"
+)
+
+// Literals block types
+const (
+	literalsRaw = iota
+	literalsRLE
+	literalsCompressed
+	literalsTreeless
+)
+
+// readLiterals decodes the literals section at the start of a compressed block into
+// d.literals, returning the size of the section.
+func (d *decoder) readLiterals(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, corrupt("missing literals section")
+	}
+	kind := data[0] & 3
+	format := data[0] >> 2 & 3
+	d.literals = d.literals[:0]
+
+	if kind == literalsRaw || kind == literalsRLE {
+		var size, n int
+		switch format {
+		case 0, 2:
+			size, n = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return 0, corrupt("truncated literals header")
+			}
+			size, n = int(data[0]>>4)|int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return 0, corrupt("truncated literals header")
+			}
+			size, n = int(data[0]>>4)|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if size > maxBlockSize {
+			return 0, corrupt("literals size %d above block size", size)
+		}
+		if kind == literalsRaw {
+			if n+size > len(data) {
+				return 0, corrupt("truncated literals")
+			}
+			d.literals = append(d.literals, data[n:n+size]...)
+			return n + size, nil
+		}
+		if n >= len(data) {
+			return 0, corrupt("truncated literals")
+		}
+		for i := 0; i < size; i++ {
+			d.literals = append(d.literals, data[n])
+		}
+		return n + 1, nil
+	}
+
+	var regenerated, compressed, n int
+	streams := 4
+	switch format {
+	case 0, 1:
+		if len(data) < 3 {
+			return 0, corrupt("truncated literals header")
+		}
+		h := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		regenerated, compressed, n = int(h>>4&0x3ff), int(h>>14&0x3ff), 3
+		if format == 0 {
+			streams = 1
+		}
+	case 2:
+		if len(data) < 4 {
+			return 0, corrupt("truncated literals header")
+		}
+		h := binary.LittleEndian.Uint32(data)
+		regenerated, compressed, n = int(h>>4&0x3fff), int(h>>18), 4
+	case 3:
+		if len(data) < 5 {
+			return 0, corrupt("truncated literals header")
+		}
+		h := binary.LittleEndian.Uint32(data)
+		regenerated, compressed, n = int(h>>4&0x3ffff), int(h>>22)|int(data[4])<<10, 5
+	}
+	if regenerated > maxBlockSize {
+		return 0, corrupt("literals size %d above block size", regenerated)
+	}
+	if n+compressed > len(data) {
+		return 0, corrupt("truncated literals")
+	}
+	streamData := data[n : n+compressed]
+
+	if kind == literalsCompressed {
+		table, m, err := readHuffman(streamData)
+		if err != nil {
+			return 0, err
+		}
+		d.huffman = table
+		streamData = streamData[m:]
+	} else if d.huffman == nil {
+		return 0, corrupt("treeless literals without a previous Huffman table")
+	}
+
+	var err error
+	if streams == 1 {
+		d.literals, err = d.huffman.decode(d.literals, streamData, regenerated)
+		return n + compressed, err
+	}
+	if len(streamData) < 6 {
+		return 0, corrupt("truncated literals jump table")
+	}
+	sizes := [4]int{
+		int(binary.LittleEndian.Uint16(streamData)),
+		int(binary.LittleEndian.Uint16(streamData[2:])),
+		int(binary.LittleEndian.Uint16(streamData[4:])),
+	}
+	sizes[3] = len(streamData) - 6 - sizes[0] - sizes[1] - sizes[2]
+	if sizes[3] < 0 {
+		return 0, corrupt("invalid literals jump table")
+	}
+	streamData = streamData[6:]
+	segment := (regenerated + 3) / 4
+	for i, size := range sizes {
+		count := segment
+		if i == 3 {
+			count = regenerated - 3*segment
+		}
+		if count < 0 {
+			return 0, corrupt("invalid literals size")
+		}
+		if d.literals, err = d.huffman.decode(d.literals, streamData[:size], count); err != nil {
+			return 0, err
+		}
+		streamData = streamData[size:]
+	}
+	return n + compressed, nil
+}
+
+// writeLiterals appends the literals section of literals to dst, compressing them with a
+// Huffman code when they are all below 128, as text mostly is, and that saves space.
+func writeLiterals(dst, literals []byte) []byte {
+	var counts [256]int
+	distinct := 0
+	for _, b := range literals {
+		if counts[b] == 0 {
+			distinct++
+		}
+		counts[b]++
+	}
+	if distinct == 1 && len(literals) > 1 {
+		return append(writeLiteralsHeader(dst, literalsRLE, len(literals)), literals[0])
+	}
+	ascii := true
+	for _, c := range counts[128:] {
+		ascii = ascii && c == 0
+	}
+	raw := len(literals) + 3
+	if distinct > 1 && len(literals) >= 32 && ascii {
+		if section := compressLiterals(dst, literals, &counts); len(section)-len(dst) < raw {
+			return section
+		}
+	}
+	return append(writeLiteralsHeader(dst, literalsRaw, len(literals)), literals...)
+}
+
+func writeLiteralsHeader(dst []byte, kind byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(dst, kind|byte(size)<<3)
+	case size < 4096:
+		return append(dst, kind|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(dst, kind|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// compressLiterals appends a compressed literals section to dst.
+func compressLiterals(dst, literals []byte, counts *[256]int) []byte {
+	enc := buildHuffmanEncoder(counts)
+	body := enc.writeDescription(nil)
+	streams := 1
+	if len(literals) < 1024 {
+		body = enc.encode(body, literals)
+	}
+	if len(literals) >= 1024 || len(body) >= 1024 {
+		// Four streams, after a jump table giving the size of the first three
+		streams = 4
+		body = enc.writeDescription(body[:0])
+		jump := len(body)
+		body = append(body, make([]byte, 6)...)
+		segment := (len(literals) + 3) / 4
+		for i := 0; i < 4; i++ {
+			start := len(body)
+			end := (i + 1) * segment
+			if end > len(literals) {
+				end = len(literals)
+			}
+			body = enc.encode(body, literals[i*segment:end])
+			if i < 3 {
+				binary.LittleEndian.PutUint16(body[jump+2*i:], uint16(len(body)-start))
+			}
+		}
+	}
+
+	regenerated, compressed := uint64(len(literals)), uint64(len(body))
+	switch {
+	case streams == 1:
+		h := literalsCompressed | regenerated<<4 | compressed<<14
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16))
+	case regenerated < 1024 && compressed < 1024:
+		h := literalsCompressed | 1<<2 | regenerated<<4 | compressed<<14
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16))
+	case regenerated < 16384 && compressed < 16384:
+		h := literalsCompressed | 2<<2 | regenerated<<4 | compressed<<18
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16), byte(h>>24))
+	default:
+		h := literalsCompressed | 3<<2 | regenerated<<4 | compressed<<22
+		dst = append(dst, byte(h), byte(h>>8), byte(h>>16), byte(h>>24), byte(h>>32))
+	}
+	return append(dst, body...)
+}
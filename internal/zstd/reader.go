@@ -0,0 +1,531 @@
+// Package zstd reads and writes Zstandard frames (RFC 8878), with dictionaries, for the
+// .warc.zst files of the WARC zstd specification.
+//
+// It is written here rather than imported so that gwarc stays a module without
+// dependencies: the rest of the module needs nothing beyond the standard library, and
+// github.com/klauspost/compress would be its only requirement, pulled into every program
+// reading WARC files for a single format. It covers what WARC files use, not all the
+// library does: the Reader decodes any conforming frame, checked against files of the
+// reference implementation in testdata, while the Writer only uses a fast single level
+// of compression.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Magic numbers of frames
+const (
+	frameMagic = 0xFD2FB528
+	// Skippable frames have magic numbers from skippableMagic to skippableMagic+15
+	skippableMagic = 0x184D2A50
+	skippableMask  = 0xFFFFFFF0
+)
+
+const (
+	// maxBlockSize is the largest size of the content of a block
+	maxBlockSize = 128 << 10
+	// MaxWindowSize is the largest window a Reader accepts, as the reference decoder does
+	// by default
+	MaxWindowSize = 1 << 27
+)
+
+// Indexes of the FSE tables of sequences
+const (
+	tableLiteralLength = iota
+	tableOffset
+	tableMatchLength
+)
+
+// ErrCorrupt is wrapped by the errors returned on invalid compressed data.
+var ErrCorrupt = errors.New("zstd: corrupt input")
+
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrCorrupt}, args...)...)
+}
+
+// decoder holds the state carried from block to block of a frame.
+type decoder struct {
+	dict *Dict
+	// hist is the content decoded, of which at least the last window bytes are kept for
+	// matches; dropped is the number of bytes dropped from its start
+	hist    []byte
+	dropped int64
+	window  int
+
+	literals []byte
+	huffman  *huffTable
+	tables   [3]*fseTable
+	offsets  [3]uint32
+	block    []byte
+}
+
+// Reader decompresses a single frame, skipping any skippable frames before it, and returns
+// io.EOF at its end. Reset moves on to the next frame. A Reader reads no further than the
+// end of its frame.
+type Reader struct {
+	r    io.Reader
+	dict *Dict
+	d    decoder
+
+	started  bool
+	last     bool
+	checksum bool
+	// size is the content size given by the frame header, or -1
+	size     int64
+	produced int64
+	// out is the offset in d.hist of the content not yet returned by Read
+	out  int
+	hash *xxhash64
+	err  error
+	buf  [8]byte
+}
+
+// NewReader returns a Reader decompressing a frame read from r, with dict if not nil.
+func NewReader(r io.Reader, dict *Dict) *Reader {
+	z := &Reader{dict: dict, hash: newXXHash64()}
+	z.Reset(r)
+	return z
+}
+
+// Reset discards the state of the Reader, which then reads a new frame from r.
+func (z *Reader) Reset(r io.Reader) {
+	z.r = r
+	z.started, z.last, z.checksum = false, false, false
+	z.size, z.produced, z.out, z.err = -1, 0, 0, nil
+	z.d.hist, z.d.dropped = z.d.hist[:0], 0
+	z.hash.reset()
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	for z.out == len(z.d.hist) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.step()
+	}
+	n := copy(p, z.d.hist[z.out:])
+	z.out += n
+	return n, nil
+}
+
+// step reads the frame header, a block, or the end of the frame.
+func (z *Reader) step() error {
+	if !z.started {
+		return z.readFrameHeader()
+	}
+	if z.last {
+		if z.size >= 0 && z.produced != z.size {
+			return corrupt("frame content is %d bytes, want %d", z.produced, z.size)
+		}
+		if z.checksum {
+			if err := z.readFull(z.buf[:4]); err != nil {
+				return err
+			}
+			if binary.LittleEndian.Uint32(z.buf[:4]) != uint32(z.hash.Sum64()) {
+				return corrupt("frame checksum mismatch")
+			}
+		}
+		return io.EOF
+	}
+
+	// Drop content read and out of the window, when that is at least half of what is kept
+	drop := len(z.d.hist) - z.d.window
+	if drop > z.out {
+		drop = z.out
+	}
+	if drop > len(z.d.hist)/2 && drop > maxBlockSize {
+		n := copy(z.d.hist, z.d.hist[drop:])
+		z.d.hist = z.d.hist[:n]
+		z.d.dropped += int64(drop)
+		z.out -= drop
+	}
+
+	start := len(z.d.hist)
+	if err := z.readBlock(); err != nil {
+		return err
+	}
+	z.hash.Write(z.d.hist[start:])
+	z.produced += int64(len(z.d.hist) - start)
+	if z.size >= 0 && z.produced > z.size {
+		return corrupt("frame content above %d bytes", z.size)
+	}
+	return nil
+}
+
+// readFull reads exactly len(p) bytes, reporting a truncated frame as io.ErrUnexpectedEOF.
+func (z *Reader) readFull(p []byte) error {
+	if _, err := io.ReadFull(z.r, p); err != nil {
+		if err == io.EOF && z.started {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (z *Reader) readFrameHeader() error {
+	for {
+		if err := z.readFull(z.buf[:4]); err != nil {
+			return err
+		}
+		magic := binary.LittleEndian.Uint32(z.buf[:4])
+		if magic == frameMagic {
+			break
+		}
+		if magic&skippableMask != skippableMagic {
+			return fmt.Errorf("zstd: invalid magic number %#x", magic)
+		}
+		if err := z.readFull(z.buf[:4]); err != nil {
+			return noEOF(err)
+		}
+		if _, err := io.CopyN(io.Discard, z.r, int64(binary.LittleEndian.Uint32(z.buf[:4]))); err != nil {
+			return noEOF(err)
+		}
+	}
+	z.started = true
+
+	if err := z.readFull(z.buf[:1]); err != nil {
+		return err
+	}
+	descriptor := z.buf[0]
+	if descriptor&0x08 != 0 {
+		return corrupt("reserved bit set in frame header")
+	}
+	sizeFlag, single := descriptor>>6, descriptor&0x20 != 0
+	z.checksum = descriptor&0x04 != 0
+
+	window := int64(0)
+	if !single {
+		if err := z.readFull(z.buf[:1]); err != nil {
+			return err
+		}
+		log := 10 + uint(z.buf[0]>>3)
+		if log > 40 {
+			return fmt.Errorf("zstd: window of 2^%d bytes is too large", log)
+		}
+		base := int64(1) << log
+		window = base + base/8*int64(z.buf[0]&7)
+	}
+
+	var dictID uint32
+	if n := [4]int{0, 1, 2, 4}[descriptor&3]; n > 0 {
+		if err := z.readFull(z.buf[:n]); err != nil {
+			return err
+		}
+		for i := n - 1; i >= 0; i-- {
+			dictID = dictID<<8 | uint32(z.buf[i])
+		}
+	}
+
+	sizeBytes := [4]int{0, 2, 4, 8}[sizeFlag]
+	if sizeFlag == 0 && single {
+		sizeBytes = 1
+	}
+	if sizeBytes > 0 {
+		if err := z.readFull(z.buf[:sizeBytes]); err != nil {
+			return err
+		}
+		var size uint64
+		for i := sizeBytes - 1; i >= 0; i-- {
+			size = size<<8 | uint64(z.buf[i])
+		}
+		if sizeBytes == 2 {
+			size += 256
+		}
+		if size > 1<<62 {
+			return corrupt("invalid frame content size")
+		}
+		z.size = int64(size)
+	}
+	if single {
+		window = z.size
+	}
+	if window > MaxWindowSize {
+		return fmt.Errorf("zstd: window of %d bytes is above %d", window, MaxWindowSize)
+	}
+
+	dict := z.dict
+	if dictID != 0 && (dict == nil || dict.ID != dictID) {
+		return fmt.Errorf("zstd: frame needs dictionary %d", dictID)
+	}
+	z.d.reset(dict, int(window))
+	return nil
+}
+
+// noEOF reports the end of the input within a frame as io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// reset prepares the decoder for a frame.
+func (d *decoder) reset(dict *Dict, window int) {
+	d.dict, d.window = dict, window
+	d.huffman = nil
+	d.tables = [3]*fseTable{}
+	d.offsets = [3]uint32{1, 4, 8}
+	if dict != nil {
+		d.huffman, d.tables, d.offsets = dict.huffman, dict.tables, dict.offsets
+	}
+}
+
+// Block types
+const (
+	blockRaw = iota
+	blockRLE
+	blockCompressed
+)
+
+func (z *Reader) readBlock() error {
+	if err := z.readFull(z.buf[:3]); err != nil {
+		return err
+	}
+	header := uint32(z.buf[0]) | uint32(z.buf[1])<<8 | uint32(z.buf[2])<<16
+	z.last = header&1 != 0
+	size := int(header >> 3)
+	limit := maxBlockSize
+	if z.d.window < limit {
+		limit = z.d.window
+	}
+	if size > limit {
+		return corrupt("block of %d bytes above %d", size, limit)
+	}
+
+	d := &z.d
+	switch header >> 1 & 3 {
+	case blockRaw:
+		start := len(d.hist)
+		d.hist = grow(d.hist, size)
+		return z.readFull(d.hist[start:])
+	case blockRLE:
+		if err := z.readFull(z.buf[:1]); err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			d.hist = append(d.hist, z.buf[0])
+		}
+		return nil
+	case blockCompressed:
+		if cap(d.block) < size {
+			d.block = make([]byte, size)
+		}
+		d.block = d.block[:size]
+		if err := z.readFull(d.block); err != nil {
+			return err
+		}
+		return d.decodeBlock(d.block)
+	}
+	return corrupt("reserved block type")
+}
+
+// grow extends b by n bytes.
+func grow(b []byte, n int) []byte {
+	if len(b)+n > cap(b) {
+		grown := make([]byte, len(b), 2*cap(b)+n)
+		copy(grown, b)
+		b = grown
+	}
+	return b[:len(b)+n]
+}
+
+// decodeBlock decodes a compressed block, appending its content to d.hist.
+func (d *decoder) decodeBlock(data []byte) error {
+	n, err := d.readLiterals(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+	start := len(d.hist)
+
+	if len(data) == 0 {
+		return corrupt("missing sequences section")
+	}
+	count := int(data[0])
+	switch {
+	case count == 0:
+		d.hist = append(d.hist, d.literals...)
+		return nil
+	case count < 128:
+		data = data[1:]
+	case count < 255:
+		if len(data) < 2 {
+			return corrupt("truncated sequences header")
+		}
+		count = (count-128)<<8 + int(data[1])
+		data = data[2:]
+	default:
+		if len(data) < 3 {
+			return corrupt("truncated sequences header")
+		}
+		count = int(data[1]) + int(data[2])<<8 + 0x7F00
+		data = data[3:]
+	}
+	if len(data) == 0 {
+		return corrupt("truncated sequences header")
+	}
+	modes := data[0]
+	if modes&3 != 0 {
+		return corrupt("reserved bits set in sequences header")
+	}
+	data = data[1:]
+
+	kinds := [3]struct {
+		index      int
+		maxSymbol  int
+		maxLog     uint8
+		predefined *fseTable
+	}{
+		{tableLiteralLength, maxLiteralLengthSymbol, maxLiteralLengthLog, literalLengthPredefined},
+		{tableOffset, maxOffsetSymbol, maxOffsetLog, offsetPredefined},
+		{tableMatchLength, maxMatchLengthSymbol, maxMatchLengthLog, matchLengthPredefined},
+	}
+	for i, kind := range kinds {
+		switch modes >> (6 - 2*i) & 3 {
+		case 0:
+			d.tables[kind.index] = kind.predefined
+		case 1:
+			if len(data) == 0 || int(data[0]) > kind.maxSymbol {
+				return corrupt("invalid RLE sequence symbol")
+			}
+			d.tables[kind.index] = rleTable(data[0])
+			data = data[1:]
+		case 2:
+			table, n, err := readTable(data, kind.maxSymbol, kind.maxLog)
+			if err != nil {
+				return err
+			}
+			d.tables[kind.index] = table
+			data = data[n:]
+		case 3:
+			if d.tables[kind.index] == nil {
+				return corrupt("repeated sequence table without a previous table")
+			}
+		}
+	}
+
+	br, err := newBackwardReader(data)
+	if err != nil {
+		return err
+	}
+	var states [3]uint32
+	for i := range states {
+		if states[i], err = br.read(uint(d.tables[i].log)); err != nil {
+			return err
+		}
+	}
+	literals := d.literals
+	for i := 0; i < count; i++ {
+		ll := d.tables[tableLiteralLength].entries[states[tableLiteralLength]]
+		of := d.tables[tableOffset].entries[states[tableOffset]]
+		ml := d.tables[tableMatchLength].entries[states[tableMatchLength]]
+
+		extra, err := br.read(uint(of.symbol))
+		if err != nil {
+			return err
+		}
+		offset := uint32(1)<<of.symbol + extra
+		c := matchLengthCodes[ml.symbol]
+		if extra, err = br.read(uint(c.extra)); err != nil {
+			return err
+		}
+		matchLength := c.baseline + extra
+		c = literalLengthCodes[ll.symbol]
+		if extra, err = br.read(uint(c.extra)); err != nil {
+			return err
+		}
+		literalLength := c.baseline + extra
+
+		if i < count-1 {
+			for _, t := range [3]int{tableLiteralLength, tableMatchLength, tableOffset} {
+				entry := d.tables[t].entries[states[t]]
+				v, err := br.read(uint(entry.nbBits))
+				if err != nil {
+					return err
+				}
+				states[t] = uint32(entry.base) + v
+			}
+		}
+
+		// Offset values 1 to 3 repeat recent offsets
+		if offset > 3 {
+			offset -= 3
+			d.offsets = [3]uint32{offset, d.offsets[0], d.offsets[1]}
+		} else {
+			index := offset - 1
+			if literalLength == 0 {
+				index++
+			}
+			switch index {
+			case 0:
+				offset = d.offsets[0]
+			case 1:
+				offset = d.offsets[1]
+				d.offsets = [3]uint32{offset, d.offsets[0], d.offsets[2]}
+			case 2:
+				offset = d.offsets[2]
+				d.offsets = [3]uint32{offset, d.offsets[0], d.offsets[1]}
+			case 3:
+				offset = d.offsets[0] - 1
+				if offset == 0 {
+					return corrupt("zero offset")
+				}
+				d.offsets = [3]uint32{offset, d.offsets[0], d.offsets[1]}
+			}
+		}
+
+		if int(literalLength) > len(literals) {
+			return corrupt("sequence literal length above literals")
+		}
+		d.hist = append(d.hist, literals[:literalLength]...)
+		literals = literals[literalLength:]
+		if err := d.copyMatch(int(offset), int(matchLength)); err != nil {
+			return err
+		}
+		if len(d.hist)-start > maxBlockSize {
+			return corrupt("block content above %d bytes", maxBlockSize)
+		}
+	}
+	if br.remaining() != 0 {
+		return corrupt("sequences bitstream not fully read")
+	}
+	d.hist = append(d.hist, literals...)
+	if len(d.hist)-start > maxBlockSize {
+		return corrupt("block content above %d bytes", maxBlockSize)
+	}
+	return nil
+}
+
+// copyMatch appends length bytes found offset bytes back in the content, or in the
+// dictionary content before it.
+func (d *decoder) copyMatch(offset, length int) error {
+	if offset > len(d.hist) {
+		before := offset - len(d.hist)
+		if d.dropped > 0 || d.dict == nil || before > len(d.dict.content) {
+			return corrupt("match offset %d out of range", offset)
+		}
+		from := d.dict.content[len(d.dict.content)-before:]
+		if length < len(from) {
+			from = from[:length]
+		}
+		d.hist = append(d.hist, from...)
+		length -= len(from)
+		if length == 0 {
+			return nil
+		}
+	}
+	start := len(d.hist) - offset
+	if offset >= length {
+		d.hist = append(d.hist, d.hist[start:start+length]...)
+		return nil
+	}
+	for i := 0; i < length; i++ {
+		d.hist = append(d.hist, d.hist[start+i])
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func readFile(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testDict(t testing.TB) *Dict {
+	t.Helper()
+	dict, err := ParseDict(readFile(t, "dict"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dict
+}
+
+// The fixtures are compressed by the reference implementation.
+func TestReader(t *testing.T) {
+	text := readFile(t, "text.txt")
+	record := readFile(t, "record.txt")
+	dict := testDict(t)
+	for _, test := range []struct {
+		name string
+		dict *Dict
+		want []byte
+	}{
+		{"text.txt.1.zst", nil, text},
+		{"text.txt.19.zst", nil, text},
+		{"text.txt.nocheck.zst", nil, text},
+		{"record.txt.zst", dict, record},
+	} {
+		got, err := io.ReadAll(NewReader(bytes.NewReader(readFile(t, test.name)), test.dict))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: decompressed %d bytes, differing from the %d bytes expected", test.name, len(got), len(test.want))
+		}
+	}
+}
+
+func TestParseDict(t *testing.T) {
+	dict := testDict(t)
+	if dict.ID != 522756793 {
+		t.Errorf("ID = %d, want 522756793", dict.ID)
+	}
+	if !bytes.Equal(dict.Bytes(), readFile(t, "dict")) {
+		t.Error("Bytes differs from the dictionary parsed")
+	}
+	raw, err := ParseDict([]byte("raw content"))
+	if err != nil || raw.ID != 0 || string(raw.content) != "raw content" {
+		t.Errorf("raw dictionary: %v, ID %d, content %q", err, raw.ID, raw.content)
+	}
+	if _, err := ParseDict(readFile(t, "dict")[:20]); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("truncated dictionary: %v, want ErrCorrupt", err)
+	}
+}
+
+func TestReaderFrames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSkippable(&buf, 13, []byte("skipped")); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"first frame", "second frame"} {
+		w := NewWriter(&buf, nil)
+		io.WriteString(w, s)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	z := NewReader(r, nil)
+	for _, want := range []string{"first frame", "second frame"} {
+		got, err := io.ReadAll(z)
+		if err != nil || string(got) != want {
+			t.Fatalf("frame = %q, %v; want %q", got, err, want)
+		}
+		z.Reset(r)
+	}
+	if _, err := z.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("after the last frame: %v, want io.EOF", err)
+	}
+}
+
+func TestReaderCorrupt(t *testing.T) {
+	data := readFile(t, "text.txt.1.zst")
+	checksum := append([]byte(nil), data...)
+	checksum[len(checksum)-1] ^= 1
+	block := append([]byte(nil), data...)
+	block[len(block)/2] ^= 0x55
+	for _, test := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"checksum", checksum, ErrCorrupt},
+		{"block", block, ErrCorrupt},
+		{"truncated", data[:len(data)/2], io.ErrUnexpectedEOF},
+		{"magic", []byte("not a zstd frame"), nil},
+		{"dictionary", readFile(t, "record.txt.zst"), nil},
+	} {
+		_, err := io.ReadAll(NewReader(bytes.NewReader(test.data), nil))
+		if err == nil || test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	f.Add(readFile(f, "record.txt.zst"))
+	var buf bytes.Buffer
+	w := NewWriter(&buf, nil)
+	w.Write(readFile(f, "record.txt"))
+	w.Close()
+	f.Add(buf.Bytes())
+	dict := testDict(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, d := range []*Dict{nil, dict} {
+			z := NewReader(bytes.NewReader(data), d)
+			io.Copy(io.Discard, io.LimitReader(z, 1<<22))
+		}
+	})
+}
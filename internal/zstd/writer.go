@@ -0,0 +1,463 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+const (
+	// windowLog is the log of the window of the frames written; matches are found no
+	// further back
+	windowLog = 20
+	// hashLog is the log of the size of the table of the last position of each hash, and
+	// chainLog that of the table linking each position to the previous one of its hash
+	hashLog  = 16
+	chainLog = 17
+	// searchDepth is the number of positions of a hash compared when looking for a match
+	searchDepth = 16
+	minMatch    = 4
+)
+
+// hash4 hashes the 4 bytes at the start of b.
+func hash4(b []byte) uint32 {
+	return binary.LittleEndian.Uint32(b) * 2654435761 >> (32 - hashLog)
+}
+
+// sequence is a run of literals followed by a match, with its offset coded as an offset
+// value.
+type sequence struct {
+	literalLength uint32
+	matchLength   uint32
+	offsetValue   uint32
+}
+
+// Writer compresses data into a single frame with a checksum, finished by Close. Reset
+// starts a new frame. Matches are found with hash chains, in the window and in the
+// dictionary content, and chosen with one step of lazy matching; literals are Huffman
+// coded, and sequences coded with the predefined FSE tables or tables fitted to them.
+type Writer struct {
+	w    io.Writer
+	dict *Dict
+
+	// hist holds the dictionary content and the data written to the frame, from absolute
+	// position base on; data from pending on is not compressed yet
+	hist    []byte
+	base    int64
+	pending int64
+	table   []uint32
+	chain   []uint32
+	offsets [3]uint32
+	hash    *xxhash64
+	started bool
+	err     error
+
+	seqs     []sequence
+	literals []byte
+	out      []byte
+}
+
+// NewWriter returns a Writer writing a frame to w, compressed with dict if not nil.
+func NewWriter(w io.Writer, dict *Dict) *Writer {
+	z := &Writer{
+		dict:  dict,
+		hash:  newXXHash64(),
+		table: make([]uint32, 1<<hashLog),
+		chain: make([]uint32, 1<<chainLog),
+	}
+	z.Reset(w)
+	return z
+}
+
+// Reset discards the state of the Writer, which then writes a new frame to w.
+func (z *Writer) Reset(w io.Writer) {
+	z.w, z.err, z.started = w, nil, false
+	z.hash.reset()
+	z.hist = z.hist[:0]
+	z.base = 0
+	z.offsets = [3]uint32{1, 4, 8}
+	if z.dict != nil {
+		z.hist = append(z.hist, z.dict.content...)
+		table, chain := z.dict.hashTables()
+		copy(z.table, table)
+		copy(z.chain, chain)
+		z.offsets = z.dict.offsets
+	} else {
+		// Stale chain links are harmless, as every match found is checked
+		for i := range z.table {
+			z.table[i] = 0
+		}
+	}
+	z.pending = int64(len(z.hist))
+}
+
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	z.hash.Write(p)
+	n := len(p)
+	for len(p) > 0 {
+		room := maxBlockSize - int(int64(len(z.hist))+z.base-z.pending)
+		chunk := p
+		if len(chunk) > room {
+			chunk = chunk[:room]
+		}
+		z.hist = append(z.hist, chunk...)
+		p = p[len(chunk):]
+		if len(p) > 0 {
+			// A full block is pending, and more data follows it
+			if z.err = z.writeBlock(false); z.err != nil {
+				return 0, z.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the last block and the checksum of the frame. It does not close the
+// underlying writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.err = z.writeBlock(true); z.err != nil {
+		return z.err
+	}
+	var checksum [4]byte
+	binary.LittleEndian.PutUint32(checksum[:], uint32(z.hash.Sum64()))
+	_, z.err = z.w.Write(checksum[:])
+	if z.err == nil {
+		z.err = errors.New("zstd: writer is closed")
+		return nil
+	}
+	return z.err
+}
+
+// writeFrameHeader appends the frame header to z.out.
+func (z *Writer) writeFrameHeader() {
+	var id uint32
+	if z.dict != nil {
+		id = z.dict.ID
+	}
+	idFlag, idSize := byte(0), 0
+	switch {
+	case id == 0:
+	case id < 1<<8:
+		idFlag, idSize = 1, 1
+	case id < 1<<16:
+		idFlag, idSize = 2, 2
+	default:
+		idFlag, idSize = 3, 4
+	}
+	z.out = append(z.out, 0x28, 0xB5, 0x2F, 0xFD)
+	// A checksum, and no content size
+	z.out = append(z.out, 0x04|idFlag, (windowLog-10)<<3)
+	for i := 0; i < idSize; i++ {
+		z.out = append(z.out, byte(id>>(8*i)))
+	}
+}
+
+// writeBlock compresses the pending data into a block.
+func (z *Writer) writeBlock(last bool) error {
+	z.out = z.out[:0]
+	if !z.started {
+		z.writeFrameHeader()
+		z.started = true
+	}
+	from, to := int(z.pending-z.base), len(z.hist)
+	data := z.hist[from:to]
+
+	header := len(z.out)
+	z.out = append(z.out, 0, 0, 0)
+	kind := blockCompressed
+	offsets := z.offsets
+	z.out = z.compressBlock(z.out, from, to)
+	size := len(z.out) - header - 3
+	if size >= len(data) {
+		// The decoder does not see the sequences of a raw block
+		kind, size = blockRaw, len(data)
+		z.out = append(z.out[:header+3], data...)
+		z.offsets = offsets
+	}
+	h := uint32(size)<<3 | uint32(kind)<<1
+	if last {
+		h |= 1
+	}
+	z.out[header], z.out[header+1], z.out[header+2] = byte(h), byte(h>>8), byte(h>>16)
+	if _, err := z.w.Write(z.out); err != nil {
+		return err
+	}
+
+	z.pending = z.base + int64(to)
+	// Keep a window of history; the dictionary content only while it is in the window
+	if drop := len(z.hist) - (1<<windowLog - maxBlockSize); drop > len(z.hist)/2 {
+		n := copy(z.hist, z.hist[drop:])
+		z.hist = z.hist[:n]
+		z.base += int64(drop)
+	}
+	return nil
+}
+
+// insert adds position i of z.hist to the hash chains.
+func (z *Writer) insert(i int) {
+	pos := uint32(z.base + int64(i))
+	h := hash4(z.hist[i:])
+	z.chain[pos&(1<<chainLog-1)] = z.table[h]
+	z.table[h] = pos
+}
+
+// search returns the distance and length of the longest match at position i of z.hist,
+// ending by to, or a length of 0.
+func (z *Writer) search(i, to, literalStart int) (int, int) {
+	hist := z.hist
+	distance, length := 0, 0
+	// The last offset is the cheapest to code, after literals
+	if r := int(z.offsets[0]); i > literalStart && r <= i && r <= 1<<windowLog {
+		if l := matchLength(hist, i, i-r, to); l >= minMatch {
+			distance, length = r, l
+		}
+	}
+
+	pos := uint32(z.base + int64(i))
+	candidate := z.table[hash4(hist[i:])]
+	last := 0
+	for depth := 0; depth < searchDepth && i+length < to; depth++ {
+		d := int(pos - candidate)
+		if d <= last || d > 1<<windowLog || d > i {
+			break
+		}
+		last = d
+		if hist[i-d+length] == hist[i+length] {
+			if l := matchLength(hist, i, i-d, to); l > length && l >= minMatch {
+				distance, length = d, l
+			}
+		}
+		if d >= 1<<chainLog {
+			break
+		}
+		candidate = z.chain[candidate&(1<<chainLog-1)]
+	}
+	return distance, length
+}
+
+// matchLength returns the length of the common prefix of hist[a:to] and hist[b:].
+func matchLength(hist []byte, a, b, to int) int {
+	n := 0
+	for a+n+8 <= to {
+		if x := binary.LittleEndian.Uint64(hist[a+n:]) ^ binary.LittleEndian.Uint64(hist[b+n:]); x != 0 {
+			return n + bits.TrailingZeros64(x)/8
+		}
+		n += 8
+	}
+	for a+n < to && hist[a+n] == hist[b+n] {
+		n++
+	}
+	return n
+}
+
+// compressBlock appends the literals and sequences sections of z.hist[from:to] to dst.
+func (z *Writer) compressBlock(dst []byte, from, to int) []byte {
+	z.seqs, z.literals = z.seqs[:0], z.literals[:0]
+	hist := z.hist
+	literalStart := from
+	inserted := from
+	for i := from; i+minMatch <= to; {
+		distance, length := z.search(i, to, literalStart)
+		z.insert(i)
+		inserted = i + 1
+		if length == 0 {
+			// Skip faster through data without matches
+			i += 1 + (i-literalStart)>>6
+			continue
+		}
+		if i+1+minMatch <= to {
+			// A longer match at the next position is worth a literal
+			d, l := z.search(i+1, to, literalStart)
+			z.insert(i + 1)
+			inserted = i + 2
+			if l > length {
+				i, distance, length = i+1, d, l
+			}
+		}
+		for i > literalStart && i-distance > 0 && hist[i-1] == hist[i-1-distance] {
+			i--
+			length++
+		}
+
+		z.literals = append(z.literals, hist[literalStart:i]...)
+		z.seqs = append(z.seqs, sequence{
+			literalLength: uint32(i - literalStart),
+			matchLength:   uint32(length),
+			offsetValue:   z.offsetValue(uint32(distance), i > literalStart),
+		})
+		i += length
+		literalStart = i
+		for ; inserted < i && inserted+minMatch <= to; inserted++ {
+			z.insert(inserted)
+		}
+	}
+	z.literals = append(z.literals, hist[literalStart:to]...)
+
+	dst = writeLiterals(dst, z.literals)
+	return writeSequences(dst, z.seqs)
+}
+
+// offsetValue returns the offset value coding distance, using the repeated offsets as the
+// decoder does, and updates them.
+func (z *Writer) offsetValue(distance uint32, literals bool) uint32 {
+	r := z.offsets
+	if literals {
+		switch distance {
+		case r[0]:
+			return 1
+		case r[1]:
+			z.offsets = [3]uint32{distance, r[0], r[2]}
+			return 2
+		case r[2]:
+			z.offsets = [3]uint32{distance, r[0], r[1]}
+			return 3
+		}
+	} else {
+		// Without literals, the offset values are shifted by one
+		switch distance {
+		case r[1]:
+			z.offsets = [3]uint32{distance, r[0], r[2]}
+			return 1
+		case r[2]:
+			z.offsets = [3]uint32{distance, r[0], r[1]}
+			return 2
+		case r[0] - 1:
+			z.offsets = [3]uint32{distance, r[0], r[1]}
+			return 3
+		}
+	}
+	z.offsets = [3]uint32{distance, r[0], r[1]}
+	return distance + 3
+}
+
+// seqTable describes how one of the three symbols of sequences is coded.
+type seqTable struct {
+	codes      []code
+	maxSymbol  int
+	maxLog     uint8
+	defaults   []int16
+	defaultLog uint8
+	encoder    *fseEncoder
+}
+
+var seqTables = [3]seqTable{
+	{literalLengthCodes, maxLiteralLengthSymbol, maxLiteralLengthLog, literalLengthDefault, 6, literalLengthEncoder},
+	{nil, maxOffsetSymbol, maxOffsetLog, offsetDefault, 5, offsetEncoder},
+	{matchLengthCodes, maxMatchLengthSymbol, maxMatchLengthLog, matchLengthDefault, 6, matchLengthEncoder},
+}
+
+// chooseEncoder returns the mode and encoder coding the counted symbols in the fewest
+// bits, the predefined distribution or one fitted to them, appending the description of
+// a fitted one to dst.
+func (t *seqTable) chooseEncoder(dst []byte, counts []int, total int) (byte, *fseEncoder, []byte) {
+	predefined := cost(counts, t.defaults, t.defaultLog)
+
+	distinct := 0
+	for _, c := range counts {
+		if c > 0 {
+			distinct++
+		}
+	}
+	log := uint8(bits.Len(uint(total)))
+	if min := uint8(bits.Len(uint(distinct))) + 1; log < min {
+		log = min
+	}
+	if log < 5 {
+		log = 5
+	}
+	if log > t.maxLog {
+		log = t.maxLog
+	}
+	norm, ok := normalize(counts, total, log)
+	if ok {
+		description := writeNormalized(nil, norm, log)
+		if fitted := cost(counts, norm, log) + 8*len(description); predefined < 0 || fitted < predefined {
+			return 2, buildEncoding(norm, log), append(dst, description...)
+		}
+	}
+	return 0, t.encoder, dst
+}
+
+// writeSequences appends the sequences section of seqs to dst.
+func writeSequences(dst []byte, seqs []sequence) []byte {
+	switch n := len(seqs); {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7F00:
+		dst = append(dst, byte(n>>8+128), byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7F00), byte((n-0x7F00)>>8))
+	}
+	if len(seqs) == 0 {
+		return dst
+	}
+
+	// Symbols and extra bits of literal lengths, offsets and match lengths
+	symbols := make([][3]uint8, len(seqs))
+	extra := make([][3]uint32, len(seqs))
+	var counts [3][]int
+	for i := range counts {
+		counts[i] = make([]int, seqTables[i].maxSymbol+1)
+	}
+	for i, s := range seqs {
+		symbols[i][0], extra[i][0] = lengthCode(literalLengthCodes, s.literalLength)
+		symbols[i][1], extra[i][1] = offsetCode(s.offsetValue)
+		symbols[i][2], extra[i][2] = lengthCode(matchLengthCodes, s.matchLength)
+		for k := range counts {
+			counts[k][symbols[i][k]]++
+		}
+	}
+
+	modes := len(dst)
+	dst = append(dst, 0)
+	var encoders [3]*fseEncoder
+	for k := range seqTables {
+		var mode byte
+		mode, encoders[k], dst = seqTables[k].chooseEncoder(dst, counts[k], len(seqs))
+		dst[modes] |= mode << (6 - 2*k)
+	}
+
+	extraBits := func(k int, symbol uint8) uint {
+		if seqTables[k].codes == nil {
+			return uint(symbol)
+		}
+		return uint(seqTables[k].codes[symbol].extra)
+	}
+	w := &bitWriter{out: dst}
+	var states [3]fseState
+	lastSeq := len(seqs) - 1
+	for _, k := range [3]int{tableMatchLength, tableOffset, tableLiteralLength} {
+		states[k].init(encoders[k], symbols[lastSeq][k])
+	}
+	for i := lastSeq; i >= 0; i-- {
+		if i < lastSeq {
+			for _, k := range [3]int{tableOffset, tableMatchLength, tableLiteralLength} {
+				states[k].encode(w, symbols[i][k])
+			}
+		}
+		for _, k := range [3]int{tableLiteralLength, tableMatchLength, tableOffset} {
+			w.write(extra[i][k], extraBits(k, symbols[i][k]))
+		}
+	}
+	for _, k := range [3]int{tableMatchLength, tableOffset, tableLiteralLength} {
+		states[k].flush(w)
+	}
+	return w.close()
+}
+
+// WriteSkippable writes a skippable frame holding data, with the magic number
+// 0x184D2A50+n, n being at most 15.
+func WriteSkippable(w io.Writer, n int, data []byte) error {
+	header := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint32(header, skippableMagic+uint32(n&15))
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	_, err := w.Write(append(header, data...))
+	return err
+}
@@ -0,0 +1,122 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte, dict *Dict) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, dict)
+	// Uneven writes, across blocks
+	for len(data) > 0 {
+		n := 70000
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testInputs(t *testing.T) map[string][]byte {
+	random := make([]byte, 300000)
+	rand.New(rand.NewSource(1)).Read(random)
+	binary := make([]byte, 200000)
+	rng := rand.New(rand.NewSource(2))
+	for i := range binary {
+		binary[i] = byte(rng.Intn(4)) * 60
+	}
+	return map[string][]byte{
+		"empty":    nil,
+		"byte":     []byte("a"),
+		"run":      bytes.Repeat([]byte("a"), 300000),
+		"text":     readFile(t, "text.txt"),
+		"record":   readFile(t, "record.txt"),
+		"repeated": []byte(strings.Repeat("abcdefgh12345678", 20000)),
+		"random":   random,
+		"binary":   binary,
+		"mixed":    append(append([]byte(nil), random[:50000]...), readFile(t, "text.txt")...),
+	}
+}
+
+func TestWriter(t *testing.T) {
+	dicts := map[string]*Dict{"none": nil, "zstd": testDict(t)}
+	dicts["raw"], _ = ParseDict(readFile(t, "text.txt")[:20000])
+	for name, data := range testInputs(t) {
+		for dictName, dict := range dicts {
+			compressed := compress(t, data, dict)
+			got, err := io.ReadAll(NewReader(bytes.NewReader(compressed), dict))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s, %s dictionary: round trip gave %d bytes, %v; want %d bytes", name, dictName, len(got), err, len(data))
+			}
+		}
+	}
+}
+
+func TestWriterRatio(t *testing.T) {
+	text := readFile(t, "text.txt")
+	if n := len(compress(t, text, nil)); n > len(text)/4 {
+		t.Errorf("text compressed to %d bytes of %d", n, len(text))
+	}
+	record := readFile(t, "record.txt")
+	plain, withDict := len(compress(t, record, nil)), len(compress(t, record, testDict(t)))
+	if withDict >= plain {
+		t.Errorf("record compressed to %d bytes with the dictionary, %d without", withDict, plain)
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	var first, second bytes.Buffer
+	w := NewWriter(&first, testDict(t))
+	io.WriteString(w, "first frame")
+	w.Close()
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+	w.Reset(&second)
+	io.WriteString(w, "second frame")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(NewReader(&second, testDict(t)))
+	if err != nil || string(got) != "second frame" {
+		t.Errorf("second frame = %q, %v", got, err)
+	}
+}
+
+// TestWriterReference checks the frames written decompress with the reference
+// implementation, when it is installed.
+func TestWriterReference(t *testing.T) {
+	path, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd not installed")
+	}
+	for name, data := range testInputs(t) {
+		for _, dict := range []bool{false, true} {
+			args := []string{"-d", "-c"}
+			var d *Dict
+			if dict {
+				args = append(args, "-D", "testdata/dict")
+				d = testDict(t)
+			}
+			cmd := exec.Command(path, args...)
+			cmd.Stdin = bytes.NewReader(compress(t, data, d))
+			got, err := cmd.Output()
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s, dictionary %v: zstd gave %d bytes, %v; want %d bytes", name, dict, len(got), err, len(data))
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH64 primes
+const (
+	prime64_1 = 11400714785074694791
+	prime64_2 = 14029467366897019727
+	prime64_3 = 1609587929392839161
+	prime64_4 = 9650029242287828579
+	prime64_5 = 2870177450012600261
+)
+
+// xxhash64 computes the XXH64 hash, with seed 0, of the content of a frame, whose low 32
+// bits are its checksum.
+type xxhash64 struct {
+	v     [4]uint64
+	buf   [32]byte
+	nbuf  int
+	total uint64
+}
+
+func newXXHash64() *xxhash64 {
+	h := &xxhash64{}
+	h.reset()
+	return h
+}
+
+func (h *xxhash64) reset() {
+	p1, p2 := uint64(prime64_1), uint64(prime64_2)
+	h.v = [4]uint64{p1 + p2, p2, 0, -p1}
+	h.nbuf = 0
+	h.total = 0
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * prime64_2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime64_1
+}
+
+func xxhMerge(acc, v uint64) uint64 {
+	acc ^= xxhRound(0, v)
+	return acc*prime64_1 + prime64_4
+}
+
+func (h *xxhash64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+	if h.nbuf > 0 {
+		c := copy(h.buf[h.nbuf:], p)
+		h.nbuf += c
+		p = p[c:]
+		if h.nbuf < 32 {
+			return n, nil
+		}
+		h.stripes(h.buf[:])
+		h.nbuf = 0
+	}
+	full := len(p) &^ 31
+	h.stripes(p[:full])
+	h.nbuf = copy(h.buf[:], p[full:])
+	return n, nil
+}
+
+// stripes consumes stripes of 32 bytes.
+func (h *xxhash64) stripes(p []byte) {
+	for ; len(p) >= 32; p = p[32:] {
+		for i := range h.v {
+			h.v[i] = xxhRound(h.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+		}
+	}
+}
+
+func (h *xxhash64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = xxhMerge(acc, v)
+		}
+	} else {
+		acc = h.v[2] + prime64_5
+	}
+	acc += h.total
+
+	p := h.buf[:h.nbuf]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*prime64_1 + prime64_4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * prime64_1
+		acc = bits.RotateLeft64(acc, 23)*prime64_2 + prime64_3
+		p = p[4:]
+	}
+	for _, b := range p {
+		acc ^= uint64(b) * prime64_5
+		acc = bits.RotateLeft64(acc, 11) * prime64_1
+	}
+
+	acc ^= acc >> 33
+	acc *= prime64_2
+	acc ^= acc >> 29
+	acc *= prime64_3
+	acc ^= acc >> 32
+	return acc
+}
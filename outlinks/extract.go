@@ -0,0 +1,236 @@
+package outlinks
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/htmltok"
+)
+
+// Link types
+const (
+	// TypeAnchor is a hyperlink of an a or area element
+	TypeAnchor = "a"
+	// TypeLink is the resource of a link element, such as a stylesheet or icon
+	TypeLink = "link"
+	// TypeImage is the source of an img element
+	TypeImage = "img"
+	// TypeScript is the source of a script element
+	TypeScript = "script"
+	// TypeFrame is the source of an iframe or frame element
+	TypeFrame = "iframe"
+	// TypeForm is the action of a form element
+	TypeForm = "form"
+	// TypeSrcset is a candidate of the srcset of an img or source element
+	TypeSrcset = "srcset"
+	// TypeCSS is a url() or @import of a style element or attribute
+	TypeCSS = "css"
+	// TypeRefresh is the target of a meta refresh
+	TypeRefresh = "refresh"
+)
+
+// Link is a link of an HTML document.
+type Link struct {
+	// URL is the absolute URL linked to, without fragment
+	URL string
+	// Type is one of the link types
+	Type string
+	// Context is where the link was found: the element and attribute, as "img/@src", or
+	// "style" for the text of a style element
+	Context string
+}
+
+// Hop returns the Heritrix hop type of the link: R for a redirect, L for a navigation link
+// and E for an embedded resource.
+func (l Link) Hop() string {
+	switch l.Type {
+	case TypeRefresh:
+		return "R"
+	case TypeAnchor, TypeForm:
+		return "L"
+	}
+	return "E"
+}
+
+// attrTypes gives the link type of the URL attributes of elements.
+var attrTypes = map[string]map[string]string{
+	"a":      {"href": TypeAnchor},
+	"area":   {"href": TypeAnchor},
+	"link":   {"href": TypeLink},
+	"img":    {"src": TypeImage, "srcset": TypeSrcset},
+	"source": {"srcset": TypeSrcset},
+	"script": {"src": TypeScript},
+	"iframe": {"src": TypeFrame},
+	"frame":  {"src": TypeFrame},
+	"form":   {"action": TypeForm},
+}
+
+// cssURL matches the url() and @import references of a style sheet.
+var cssURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// Extract returns the links of an HTML document retrieved from baseURL, resolved against it
+// or the document's base element, in document order. Links to other schemes than http and
+// https are left out, as are repeats of a link of the same type.
+func Extract(document, baseURL string) []Link {
+	var found []Link
+	var base string
+	add := func(ref, typ, context string) {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			found = append(found, Link{URL: ref, Type: typ, Context: context})
+		}
+	}
+	z := htmltok.New(document)
+	inStyle := false
+	for {
+		token, ok := z.Next()
+		if !ok {
+			break
+		}
+		switch token.Kind {
+		case htmltok.Text:
+			if inStyle {
+				for _, ref := range cssRefs(token.Text) {
+					add(ref, TypeCSS, "style")
+				}
+			}
+		case htmltok.EndTag:
+			if token.Name == "style" {
+				inStyle = false
+			}
+		case htmltok.StartTag:
+			switch token.Name {
+			case "style":
+				inStyle = !token.SelfClosing
+			case "base":
+				if href, ok := token.Attr("href"); ok && base == "" {
+					base = strings.TrimSpace(href)
+				}
+			case "meta":
+				if equiv, _ := token.Attr("http-equiv"); strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+					content, _ := token.Attr("content")
+					add(refreshURL(content), TypeRefresh, "meta/@content")
+				}
+			}
+			for _, a := range token.Attrs {
+				switch typ := attrTypes[token.Name][a.Name]; {
+				case typ == TypeSrcset:
+					for _, ref := range srcsetURLs(a.Value) {
+						add(ref, typ, token.Name+"/@"+a.Name)
+					}
+				case typ != "":
+					add(a.Value, typ, token.Name+"/@"+a.Name)
+				case a.Name == "style":
+					for _, ref := range cssRefs(a.Value) {
+						add(ref, TypeCSS, token.Name+"/@style")
+					}
+				}
+			}
+		}
+	}
+
+	// The base element applies to the whole document, links before it included
+	resolved, err := url.Parse(baseURL)
+	if err != nil {
+		resolved = &url.URL{}
+	}
+	if b, err := resolved.Parse(base); base != "" && err == nil {
+		resolved = b
+	}
+	links := make([]Link, 0, len(found))
+	seen := make(map[Link]bool, len(found))
+	for _, link := range found {
+		u, err := resolved.Parse(link.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		u.Fragment, u.RawFragment = "", ""
+		link.URL = u.String()
+		key := Link{URL: link.URL, Type: link.Type}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// cssRefs returns the URLs referenced by a style sheet.
+func cssRefs(css string) []string {
+	var refs []string
+	for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+		for _, ref := range m[1:] {
+			if ref != "" {
+				refs = append(refs, ref)
+				break
+			}
+		}
+	}
+	return refs
+}
+
+// srcsetURLs returns the URLs of the candidates of a srcset attribute.
+func srcsetURLs(srcset string) []string {
+	var urls []string
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\f\r,")
+		if s == "" {
+			return urls
+		}
+		end := strings.IndexAny(s, " \t\n\f\r")
+		if end < 0 {
+			end = len(s)
+		}
+		ref := s[:end]
+		s = s[end:]
+		if strings.HasSuffix(ref, ",") {
+			// A candidate without descriptors
+			ref = strings.TrimRight(ref, ",")
+		} else {
+			// Skip the descriptors, up to a comma outside parentheses
+			depth, i := 0, 0
+		descriptors:
+			for ; i < len(s); i++ {
+				switch s[i] {
+				case '(':
+					depth++
+				case ')':
+					if depth > 0 {
+						depth--
+					}
+				case ',':
+					if depth == 0 {
+						break descriptors
+					}
+				}
+			}
+			s = s[i:]
+		}
+		urls = append(urls, ref)
+	}
+}
+
+// refreshURL returns the URL of the content of a meta refresh, as "5; url=page.html", or ""
+// if it only reloads the document.
+func refreshURL(content string) string {
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		return ""
+	}
+	s := strings.TrimSpace(content[i+1:])
+	if len(s) >= 3 && strings.EqualFold(s[:3], "url") {
+		if rest := strings.TrimSpace(s[3:]); strings.HasPrefix(rest, "=") {
+			s = strings.TrimSpace(rest[1:])
+		}
+	}
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		if end := strings.IndexByte(s[1:], s[0]); end >= 0 {
+			s = s[1 : end+1]
+		} else {
+			s = s[1:]
+		}
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package outlinks_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/outlinks"
+)
+
+func TestExtract(t *testing.T) {
+	document := `<!DOCTYPE html>
+<html><head>
+<meta http-equiv="Refresh" content="5; URL='/next.html'">
+<link rel="stylesheet" href="style.css">
+<base href="http://example.com/dir/">
+<style>body { background: url("bg.png") } @import 'print.css';</style>
+<script src="/app.js"></script>
+<script>var s = "<a href='not-a-link'>";</script>
+</head><body>
+<a href="page.html#top">Page</a>
+<a href="page.html">Page again</a>
+<a href="mailto:me@example.com">Mail</a>
+<a href="javascript:void(0)">Nothing</a>
+<img src="a.png" srcset="a-1x.png 1x, a-2x.png 2x,a,3x.png 3x">
+<picture><source srcset="b.webp"></picture>
+<iframe src="//other.example/frame"></iframe>
+<form action="/search?q=a&amp;b=c"></form>
+<div style="background-image: url(div.png)"></div>
+</body></html>`
+	want := []Link{
+		{"http://example.com/next.html", TypeRefresh, "meta/@content"},
+		{"http://example.com/dir/style.css", TypeLink, "link/@href"},
+		{"http://example.com/dir/bg.png", TypeCSS, "style"},
+		{"http://example.com/dir/print.css", TypeCSS, "style"},
+		{"http://example.com/app.js", TypeScript, "script/@src"},
+		{"http://example.com/dir/page.html", TypeAnchor, "a/@href"},
+		{"http://example.com/dir/a.png", TypeImage, "img/@src"},
+		{"http://example.com/dir/a-1x.png", TypeSrcset, "img/@srcset"},
+		{"http://example.com/dir/a-2x.png", TypeSrcset, "img/@srcset"},
+		{"http://example.com/dir/a,3x.png", TypeSrcset, "img/@srcset"},
+		{"http://example.com/dir/b.webp", TypeSrcset, "source/@srcset"},
+		{"http://other.example/frame", TypeFrame, "iframe/@src"},
+		{"http://example.com/search?q=a&b=c", TypeForm, "form/@action"},
+		{"http://example.com/dir/div.png", TypeCSS, "div/@style"},
+	}
+	got := Extract(document, "http://example.com/index.html")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract() =")
+		for _, link := range got {
+			t.Errorf("\t%+v", link)
+		}
+		t.Errorf("want")
+		for _, link := range want {
+			t.Errorf("\t%+v", link)
+		}
+	}
+}
+
+func TestExtractRefresh(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []Link
+	}{
+		{"0;url=http://example.org/", []Link{{"http://example.org/", TypeRefresh, "meta/@content"}}},
+		{"3, other.html", []Link{{"http://example.com/other.html", TypeRefresh, "meta/@content"}}},
+		{`1; url = "quoted.html"`, []Link{{"http://example.com/quoted.html", TypeRefresh, "meta/@content"}}},
+		{"30", []Link{}},
+	}
+	for _, tt := range tests {
+		got := Extract(`<meta http-equiv="refresh" content='`+tt.content+`'>`, "http://example.com/")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("refresh %q: got %+v, want %+v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestLinkHop(t *testing.T) {
+	for typ, want := range map[string]string{
+		TypeAnchor: "L", TypeForm: "L", TypeRefresh: "R", TypeImage: "E", TypeCSS: "E", TypeFrame: "E",
+	} {
+		if got := (Link{Type: typ}).Hop(); got != want {
+			t.Errorf("Hop() of a %s link = %q, want %q", typ, got, want)
+		}
+	}
+}
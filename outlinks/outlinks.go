@@ -0,0 +1,150 @@
+// Package outlinks extracts the links of the HTML documents of WARC files, to build link
+// graphs: as edge lists in CSV or JSON Lines, or as the outlinks of metadata records.
+package outlinks
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/zenless-lab/gwarc/internal/charset"
+	"github.com/zenless-lab/gwarc/internal/htmlpayload"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// MaxHTMLSize is the number of bytes of an HTML payload searched for links
+const MaxHTMLSize = htmlpayload.MaxSize
+
+// TimestampFormat is the layout of the capture timestamps of edges, as in CDX files
+const TimestampFormat = "20060102150405"
+
+// Format selects the format of an edge list.
+type Format int
+
+const (
+	// FormatCSV writes edges as CSV, after a header row naming the columns
+	FormatCSV Format = iota
+	// FormatJSONL writes edges as JSON Lines, one object each
+	FormatJSONL
+)
+
+// Edge is a link of the link graph of an archive.
+type Edge struct {
+	// Source is the target URI of the record linking
+	Source string `json:"source"`
+	// Target is the URL linked to
+	Target string `json:"target"`
+	// Type is the link type
+	Type string `json:"type"`
+	// Timestamp is the date of the record linking, as TimestampFormat
+	Timestamp string `json:"timestamp"`
+}
+
+// RecordLinks returns the links of the HTML payload of a response or resource record, or
+// nil for any other record. The payload is decoded from its content codings and charset,
+// and its links resolved against the target URI of the record. Payloads in a charset not
+// supported but ASCII-compatible, such as Shift_JIS or GBK, are searched as they are, links
+// being ASCII or percent-encoded; those in other charsets, such as ISO-2022-JP, are skipped.
+func RecordLinks(record *warc.Record) ([]Link, error) {
+	doc := htmlpayload.Record(record)
+	if doc == nil {
+		return nil, nil
+	}
+	html, err := doc.HTML()
+	if err != nil {
+		if !charset.ASCIICompatible(doc.Charset) {
+			return nil, nil
+		}
+		// The markup and links, ASCII or percent-encoded, read the same in the bytes of a
+		// charset that keeps ASCII as it is
+		html = string(doc.Data)
+	}
+	return Extract(html, record.TargetURI), nil
+}
+
+// WriteEdges reads records from r and writes the edges of the links of their HTML payloads
+// to w, in the given format.
+func WriteEdges(w io.Writer, r warc.RecordReader, format Format) error {
+	var write func(Edge) error
+	var flush func() error
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"source", "target", "type", "timestamp"}); err != nil {
+			return err
+		}
+		write = func(e Edge) error {
+			return cw.Write([]string{e.Source, e.Target, e.Type, e.Timestamp})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		write = func(e Edge) error {
+			return enc.Encode(e)
+		}
+		flush = bw.Flush
+	default:
+		return fmt.Errorf("unknown edge list format %d", format)
+	}
+
+	err := eachLinks(r, func(record *warc.Record, links []Link) error {
+		timestamp := record.Date.UTC().Format(TimestampFormat)
+		for _, link := range links {
+			if err := write(Edge{Source: record.TargetURI, Target: link.URL, Type: link.Type, Timestamp: timestamp}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// WriteMetadata reads records from r and writes to w a metadata record for every HTML
+// payload with links, concurrent to the record holding it, with its links in Outlinks.
+// The records written are meant to be added to the archive read.
+func WriteMetadata(w warc.RecordWriter, r warc.RecordReader) error {
+	return eachLinks(r, func(record *warc.Record, links []Link) error {
+		metadata := &warc.MetadataRecord{WARCRecord: warc.WARCRecord{
+			Date:         record.Date,
+			TargetURI:    record.TargetURI,
+			ConcurrentTo: []string{record.RecordID},
+		}}
+		for _, link := range links {
+			metadata.Outlinks = append(metadata.Outlinks, link.URL+" "+link.Hop()+" "+link.Context)
+		}
+		return w.WriteRecords(metadata)
+	})
+}
+
+// eachLinks calls fn with every record read from r with links, and its links.
+func eachLinks(r warc.RecordReader, fn func(*warc.Record, []Link) error) error {
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		links, err := RecordLinks(record)
+		if err != nil {
+			return fmt.Errorf("record %s: %w", record.RecordID, err)
+		}
+		if len(links) == 0 {
+			continue
+		}
+		if err := fn(record, links); err != nil {
+			return err
+		}
+	}
+}
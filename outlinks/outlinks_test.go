@@ -0,0 +1,177 @@
+package outlinks_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/outlinks"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// testArchive returns a WARC file of an HTML response, compressed, an image response and
+// an HTML resource.
+func testArchive(t *testing.T) (*bytes.Buffer, []*warc.WARCRecord) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<a href="/about">About</a><img src="logo.png">`))
+	zw.Close()
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	records := []*warc.WARCRecord{
+		{
+			RecordID:    warc.NewRecordID(),
+			Type:        warc.WARCTypeResponse,
+			Date:        date,
+			TargetURI:   "http://example.com/",
+			ContentType: warc.ContentTypeHTTPResponse,
+			Content: append([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Encoding: gzip\r\n\r\n"),
+				gz.Bytes()...),
+		},
+		{
+			RecordID:    warc.NewRecordID(),
+			Type:        warc.WARCTypeResponse,
+			Date:        date,
+			TargetURI:   "http://example.com/logo.png",
+			ContentType: warc.ContentTypeHTTPResponse,
+			Content:     []byte("HTTP/1.1 200 OK\r\nContent-Type: image/png\r\n\r\n\x89PNG"),
+		},
+		{
+			RecordID:    warc.NewRecordID(),
+			Type:        warc.WARCTypeResource,
+			Date:        date.Add(time.Hour),
+			TargetURI:   "http://example.com/docs/index.html",
+			ContentType: "text/html",
+			Content:     []byte(`<a href="../">Home, "quoted"</a><form action="search"></form>`),
+		},
+	}
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf)
+	for _, record := range records {
+		if err := w.WriteRecords(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf, records
+}
+
+func TestWriteEdges(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatCSV, "source,target,type,timestamp\n" +
+			"http://example.com/,http://example.com/about,a,20240101100000\n" +
+			"http://example.com/,http://example.com/logo.png,img,20240101100000\n" +
+			"http://example.com/docs/index.html,http://example.com/,a,20240101110000\n" +
+			"http://example.com/docs/index.html,http://example.com/docs/search,form,20240101110000\n"},
+		{FormatJSONL, `{"source":"http://example.com/","target":"http://example.com/about","type":"a","timestamp":"20240101100000"}` + "\n" +
+			`{"source":"http://example.com/","target":"http://example.com/logo.png","type":"img","timestamp":"20240101100000"}` + "\n" +
+			`{"source":"http://example.com/docs/index.html","target":"http://example.com/","type":"a","timestamp":"20240101110000"}` + "\n" +
+			`{"source":"http://example.com/docs/index.html","target":"http://example.com/docs/search","type":"form","timestamp":"20240101110000"}` + "\n"},
+	}
+	for _, tt := range tests {
+		archive, _ := testArchive(t)
+		r, err := warc.NewReader(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := WriteEdges(&out, r, tt.format); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("format %d:\n%s\nwant\n%s", tt.format, out.String(), tt.want)
+		}
+	}
+
+	archive, _ := testArchive(t)
+	r, err := warc.NewReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteEdges(io.Discard, r, Format(-1)); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	archive, records := testArchive(t)
+	r, err := warc.NewReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := WriteMetadata(warc.NewWriter(&out), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		source   *warc.WARCRecord
+		outlinks []string
+	}{
+		{records[0], []string{"http://example.com/about L a/@href", "http://example.com/logo.png E img/@src"}},
+		{records[2], []string{"http://example.com/ L a/@href", "http://example.com/docs/search L form/@action"}},
+	}
+	metadata, err := warc.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		record, err := metadata.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("got %d metadata records, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(want) {
+			t.Fatalf("unexpected record %d for %s", i, record.TargetURI)
+		}
+		if record.Type != warc.WARCTypeMetadata || record.TargetURI != want[i].source.TargetURI ||
+			len(record.ConcurrentTo) != 1 || record.ConcurrentTo[0] != want[i].source.RecordID {
+			t.Errorf("record %d: %s for %s concurrent to %v", i, record.Type, record.TargetURI, record.ConcurrentTo)
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantBlock := "outlink: " + strings.Join(want[i].outlinks, "\r\noutlink: ") + "\r\n"; string(block) != wantBlock {
+			t.Errorf("record %d: block %q, want %q", i, block, wantBlock)
+		}
+	}
+}
+
+func TestRecordLinksUnsupportedCharset(t *testing.T) {
+	// 日本 and 語 in Shift_JIS, the second byte of 本 being "{"
+	page := "<p>\x93\xFA\x96\x7B</p><a href=\"/ja/%E8%AA%9E\">\x8C\xEA</a><a href='news.html'>\x93\xFA</a>"
+	tests := []struct {
+		contentType string
+		want        []string
+	}{
+		{"text/html; charset=Shift_JIS", []string{"http://example.jp/ja/%E8%AA%9E", "http://example.jp/docs/news.html"}},
+		{"text/html; charset=iso-2022-jp", nil},
+	}
+	for _, test := range tests {
+		record := &warc.Record{
+			WARCRecord: warc.WARCRecord{Type: warc.WARCTypeResource, TargetURI: "http://example.jp/docs/", ContentType: test.contentType},
+			Block:      strings.NewReader(page),
+		}
+		links, err := RecordLinks(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, link := range links {
+			got = append(got, link.URL)
+		}
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%s: links %v, want %v", test.contentType, got, test.want)
+		}
+	}
+}
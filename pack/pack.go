@@ -0,0 +1,161 @@
+// Package pack writes local files, such as generated reports and datasets that were never
+// served over HTTP, to WARC files as resource records.
+package pack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// sniffLen is the number of bytes sniffed for the content type of a file
+const sniffLen = 512
+
+// Packer writes the files of a directory tree as resource records: one for every regular
+// file, in lexical order, with the file's content as block. Each run starts with a
+// warcinfo record describing it, which the resource records name in WARC-Warcinfo-ID.
+type Packer struct {
+	// BaseURI is the URI the slash-separated path of every file is resolved against to give
+	// the WARC-Target-URI of its record, as "file:///srv/reports/" or
+	// "https://example.com/data/". PackDir defaults to the file: URI of its directory, and
+	// PackFS to "file:///".
+	BaseURI string
+	// Operator and Description go in the warcinfo record of every run
+	Operator    string
+	Description string
+
+	w warc.RecordWriter
+}
+
+// NewPacker returns a Packer writing records to w.
+func NewPacker(w warc.RecordWriter) *Packer {
+	return &Packer{w: w}
+}
+
+// PackDir writes the files under the directory dir.
+func (p *Packer) PackDir(dir string) error {
+	base := p.BaseURI
+	if base == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+		if !strings.HasPrefix(u.Path, "/") {
+			// A Windows path, as C:/reports
+			u.Path = "/" + u.Path
+		}
+		base = u.String() + "/"
+	}
+	return p.pack(os.DirFS(dir), base)
+}
+
+// PackFS writes the files of fsys.
+func (p *Packer) PackFS(fsys fs.FS) error {
+	base := p.BaseURI
+	if base == "" {
+		base = "file:///"
+	}
+	return p.pack(fsys, base)
+}
+
+// pack writes a warcinfo record for the run, then the files of fsys.
+func (p *Packer) pack(fsys fs.FS, baseURI string) error {
+	base, err := url.Parse(baseURI)
+	if err != nil {
+		return fmt.Errorf("base URI: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") && base.Opaque == "" {
+		// The files are under the base, not beside it
+		base.Path += "/"
+	}
+
+	info := &warc.WarcInfoRecord{WARCRecord: warc.WARCRecord{RecordID: warc.NewRecordID()}}
+	info.Software = "gwarc"
+	info.Operator = p.Operator
+	info.Hostname, _ = os.Hostname()
+	content := "format: WARC File Format 1.1\r\n" +
+		"conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n" +
+		"source: " + base.String() + "\r\n"
+	if p.Description != "" {
+		content += "description: " + p.Description + "\r\n"
+	}
+	info.Content = []byte(content)
+	if err := p.w.WriteRecords(info); err != nil {
+		return err
+	}
+
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Directories are walked, and other files than regular ones, such as symbolic
+		// links, skipped
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		target := base.ResolveReference(&url.URL{Path: name})
+		if err := p.packFile(fsys, name, target.String(), info.RecordID); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// packFile writes the resource record of the file name of fsys.
+func (p *Packer) packFile(fsys fs.FS, name, targetURI, warcinfoID string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	block := bufio.NewReaderSize(f, sniffLen)
+	head, err := block.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	record := &warc.Record{
+		WARCRecord: warc.WARCRecord{
+			Type:          warc.WARCTypeResource,
+			Date:          time.Now().UTC(),
+			TargetURI:     targetURI,
+			ContentType:   contentType(name, head),
+			ContentLength: uint64(stat.Size()),
+			WarcinfoID:    warcinfoID,
+		},
+		Block: block,
+	}
+	digested, release, err := warc.DigestRecord(record)
+	if err != nil {
+		return err
+	}
+	defer release()
+	// The payload of a resource record is its whole block
+	digested.PayloadDigest = digested.BlockDigest
+	return p.w.WriteRecords(digested)
+}
+
+// contentType returns the content type of the file name, from its extension, or sniffed
+// from head, its first bytes.
+func contentType(name string, head []byte) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(head)
+}
@@ -0,0 +1,123 @@
+package pack_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/zenless-lab/gwarc/pack"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// readAll returns the records of a WARC file, with their blocks.
+func readAll(t *testing.T, data []byte) ([]*warc.Record, []string) {
+	t.Helper()
+	r, err := warc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.VerifyDigests = true
+	var records []*warc.Record
+	var blocks []string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, blocks
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+		blocks = append(blocks, string(block))
+	}
+}
+
+func TestPackFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"reports/2024 Q1.xml": {Data: []byte("<r>1</r>")},
+		"reports/summary":     {Data: []byte("%PDF-1.7\n")},
+		"README":              {Data: []byte("Generated reports")},
+		"empty.json":          {Data: nil},
+	}
+	var buf bytes.Buffer
+	p := NewPacker(warc.NewWriter(&buf))
+	p.BaseURI = "https://example.com/data"
+	p.Operator = "Archives <archives@example.com>"
+	p.Description = "Quarterly reports"
+	if err := p.PackFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	records, blocks := readAll(t, buf.Bytes())
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+	info := records[0]
+	if info.Type != warc.WARCTypeWarcinfo {
+		t.Fatalf("first record is %s", info.Type)
+	}
+	for _, line := range []string{"software: gwarc", "operator: Archives <archives@example.com>",
+		"description: Quarterly reports", "source: https://example.com/data/"} {
+		if !strings.Contains(blocks[0], line+"\r\n") {
+			t.Errorf("warcinfo lacks %q:\n%s", line, blocks[0])
+		}
+	}
+
+	want := []struct {
+		uri, contentType, block string
+	}{
+		{"https://example.com/data/README", "text/plain; charset=utf-8", "Generated reports"},
+		{"https://example.com/data/empty.json", "application/json", ""},
+		{"https://example.com/data/reports/2024%20Q1.xml", "text/xml; charset=utf-8", "<r>1</r>"},
+		{"https://example.com/data/reports/summary", "application/pdf", "%PDF-1.7\n"},
+	}
+	for i, w := range want {
+		record, block := records[i+1], blocks[i+1]
+		if record.Type != warc.WARCTypeResource || record.TargetURI != w.uri || record.WarcinfoID != info.RecordID {
+			t.Errorf("record %d: %s %s in %s", i+1, record.Type, record.TargetURI, record.WarcinfoID)
+		}
+		if record.ContentType != w.contentType {
+			t.Errorf("%s: content type %q, want %q", w.uri, record.ContentType, w.contentType)
+		}
+		if block != w.block {
+			t.Errorf("%s: block %q, want %q", w.uri, block, w.block)
+		}
+		digest := warc.Digest([]byte(w.block))
+		if record.BlockDigest != digest || record.PayloadDigest != digest {
+			t.Errorf("%s: digests %s, %s, want %s", w.uri, record.BlockDigest, record.PayloadDigest, digest)
+		}
+	}
+}
+
+func TestPackDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "data.bin"), []byte{0, 1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := NewPacker(warc.NewWriter(&buf)).PackDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := readAll(t, buf.Bytes())
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	resource := records[1]
+	if !strings.HasPrefix(resource.TargetURI, "file:///") || !strings.HasSuffix(resource.TargetURI, "/sub/data.bin") {
+		t.Errorf("target URI %q", resource.TargetURI)
+	}
+	if resource.ContentType != "application/octet-stream" || resource.ContentLength != 4 {
+		t.Errorf("content type %q, length %d", resource.ContentType, resource.ContentLength)
+	}
+}
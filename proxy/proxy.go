@@ -0,0 +1,207 @@
+// Package proxy implements a forward HTTP proxy that records the traffic passing
+// through it as WARC request and response records. HTTPS is recorded by terminating
+// CONNECT tunnels with certificates issued on the fly by a local CA.
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Proxy is an http.Handler serving as a recording forward proxy.
+type Proxy struct {
+	// Transport sends requests upstream and records each exchange
+	Transport *warc.Transport
+	// CA issues certificates for intercepted CONNECT tunnels; CONNECT is refused if nil
+	CA *CA
+	// ErrorLog receives errors serving clients; the log package's standard logger is used if nil
+	ErrorLog *log.Logger
+}
+
+// New returns a Proxy recording traffic into w and intercepting TLS with ca.
+func New(w warc.RecordWriter, ca *CA) *Proxy {
+	return &Proxy{Transport: warc.NewTransport(w), CA: ca}
+}
+
+// ServeHTTP forwards a proxy request, or intercepts the tunnel of a CONNECT request.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "proxy requests need an absolute URI", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := p.roundTrip(r)
+	if err != nil {
+		p.logf("proxy: %s %s: %v", r.Method, r.URL, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	header := resp.Header.Clone()
+	removeHopHeaders(header)
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		p.logf("proxy: %s %s: %v", r.Method, r.URL, err)
+	}
+}
+
+// serveConnect terminates the TLS tunnel requested by r and forwards the requests sent through it.
+func (p *Proxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	if p.CA == nil {
+		http.Error(w, "TLS interception is not configured", http.StatusMethodNotAllowed)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be hijacked", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		p.logf("proxy: CONNECT %s: %v", r.Host, err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	tlsConn := tls.Server(&bufferedConn{Conn: conn, r: rw.Reader}, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = host
+			}
+			return p.CA.certificate(name)
+		},
+		NextProtos: []string{"http/1.1"},
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		p.logf("proxy: CONNECT %s: TLS handshake: %v", r.Host, err)
+		return
+	}
+
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			if err != io.EOF {
+				p.logf("proxy: CONNECT %s: %v", r.Host, err)
+			}
+			return
+		}
+		req.URL.Scheme = "https"
+		req.URL.Host = r.Host
+		req.RemoteAddr = r.RemoteAddr
+		req = req.WithContext(r.Context())
+
+		resp, err := p.roundTrip(req)
+		if err != nil {
+			p.logf("proxy: %s %s: %v", req.Method, req.URL, err)
+			resp = errorResponse(req, err)
+		}
+
+		// Answer the client in HTTP/1.1 without touching the recorded response
+		out := *resp
+		out.Proto, out.ProtoMajor, out.ProtoMinor = "HTTP/1.1", 1, 1
+		out.Header = resp.Header.Clone()
+		removeHopHeaders(out.Header)
+		err = out.Write(tlsConn)
+		resp.Body.Close()
+		if err != nil || req.Close || resp.Close {
+			return
+		}
+	}
+}
+
+// roundTrip sends a request received by the proxy upstream through the recording transport.
+func (p *Proxy) roundTrip(r *http.Request) (*http.Response, error) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Close = false
+	removeHopHeaders(out.Header)
+	return p.Transport.RoundTrip(out)
+}
+
+// logf reports an error serving a client.
+func (p *Proxy) logf(format string, args ...any) {
+	if p.ErrorLog != nil {
+		p.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// errorResponse is sent to a client whose request could not be forwarded.
+func errorResponse(req *http.Request, err error) *http.Response {
+	body := err.Error()
+	return &http.Response{
+		Status:        "502 Bad Gateway",
+		StatusCode:    http.StatusBadGateway,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// hopHeaders are the header fields that apply to a single connection only.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// bufferedConn is a hijacked connection whose first bytes may already sit in a bufio.Reader.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(p)
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// syncBuffer is a bytes.Buffer safe for the proxy and the test to share.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records waits until want records have been written and returns them.
+func (b *syncBuffer) records(t *testing.T, want int) []warc.WARCRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		data := append([]byte(nil), b.buf.Bytes()...)
+		b.mu.Unlock()
+
+		var records []warc.WARCRecord
+		reader := warc.NewWARCFromBytes(data)
+		for {
+			record, _, err := reader.Next()
+			if err != nil {
+				break
+			}
+			if r, ok := record.(warc.WARCRecord); ok {
+				records = append(records, r)
+			}
+		}
+		if len(records) >= want || time.Now().After(deadline) {
+			return records
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "hello from "+r.URL.Path)
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	ca, err := NewCA("gwarc test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		wantTLS bool
+	}{
+		{name: "http", url: plain.URL + "/plain"},
+		{name: "https", url: secure.URL + "/secure", wantTLS: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out syncBuffer
+			p := New(warc.NewWriter(&out), ca)
+			p.Transport.Transport = secure.Client().Transport
+			p.Transport.RecordTLSInfo = true
+			proxyServer := httptest.NewServer(p)
+			defer proxyServer.Close()
+
+			proxyURL, _ := url.Parse(proxyServer.URL)
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.Certificate())
+			transport := &http.Transport{
+				Proxy:           http.ProxyURL(proxyURL),
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			}
+			defer transport.CloseIdleConnections()
+			client := &http.Client{Transport: transport}
+
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			path := strings.TrimPrefix(tt.url, plain.URL)
+			path = strings.TrimPrefix(path, secure.URL)
+			if string(body) != "hello from "+path {
+				t.Fatalf("body = %q", body)
+			}
+			if tt.wantTLS && resp.TLS.PeerCertificates[0].Issuer.CommonName != "gwarc test CA" {
+				t.Errorf("certificate issued by %v", resp.TLS.PeerCertificates[0].Issuer)
+			}
+
+			records := out.records(t, 2)
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want 2", len(records))
+			}
+			request, response := records[0], records[1]
+			if request.Type != warc.WARCTypeRequest || response.Type != warc.WARCTypeResponse {
+				t.Fatalf("types = %v, %v", request.Type, response.Type)
+			}
+			if response.TargetURI != tt.url {
+				t.Errorf("TargetURI = %v, want %v", response.TargetURI, tt.url)
+			}
+			if response.IPAddress != "127.0.0.1" {
+				t.Errorf("IPAddress = %v, want 127.0.0.1", response.IPAddress)
+			}
+			if !bytes.Contains(response.Content, []byte("hello from "+path)) {
+				t.Errorf("response block = %q", response.Content)
+			}
+			if bytes.Contains(request.Content, []byte("Proxy-Connection")) {
+				t.Errorf("request block has hop-by-hop headers: %q", request.Content)
+			}
+
+			protocols := response.Extensions[warc.FieldProtocol]
+			cipher := response.Extensions[warc.FieldCipherSuite]
+			if tt.wantTLS {
+				if len(protocols) != 2 || protocols[1] != "tls/1.3" || len(cipher) != 1 || cipher[0] == "" {
+					t.Errorf("TLS extensions = %v, %v", protocols, cipher)
+				}
+			} else if protocols != nil || cipher != nil {
+				t.Errorf("TLS extensions on plain HTTP = %v, %v", protocols, cipher)
+			}
+		})
+	}
+}
+
+func TestLoadCA(t *testing.T) {
+	ca, err := NewCA("gwarc test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := ca.PEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCA(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Certificate().Equal(ca.Certificate()) {
+		t.Errorf("loaded certificate differs")
+	}
+
+	leaf, err := loaded.certificate("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(leaf.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err != nil {
+		t.Errorf("issued certificate does not verify: %v", err)
+	}
+
+	if _, err := LoadCA(certPEM, []byte("invalid")); err == nil {
+		t.Errorf("LoadCA() with invalid key succeeded")
+	}
+}
@@ -0,0 +1,312 @@
+package surt
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// url is a URL split in the parts canonicalization works on. Opaque URLs, as
+// "mailto:me@example.com" and "dns:example.com", have their scheme and opaque part only.
+type url struct {
+	scheme   string
+	opaque   string
+	userinfo string
+	host     string
+	port     string
+	path     string
+	query    string
+	hasQuery bool
+}
+
+// String returns the URL as a string.
+func (u *url) String() string {
+	if u.opaque != "" {
+		return u.scheme + ":" + u.opaque
+	}
+	var b strings.Builder
+	b.WriteString(u.scheme + "://")
+	if u.userinfo != "" {
+		b.WriteString(u.userinfo + "@")
+	}
+	b.WriteString(u.host)
+	if u.port != "" {
+		b.WriteString(":" + u.port)
+	}
+	b.WriteString(u.path)
+	if u.hasQuery {
+		b.WriteString("?" + u.query)
+	}
+	return b.String()
+}
+
+// schemePrefix matches the scheme of a URL with an authority
+var schemePrefix = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// opaquePrefix matches the scheme of an opaque URL
+var opaquePrefix = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// parse applies the canonicalization of Google Safe Browsing to rawURL, which every Options
+// starts with, and splits the result:
+//
+//   - surrounding white space, tabs, CR and LF are removed, and so is the fragment;
+//   - a URL without scheme is taken to be an http URL;
+//   - host, path and query are unescaped repeatedly, then their control characters,
+//     spaces, non-ASCII bytes, "#" and "%" escaped;
+//   - the host is lower case, without leading, trailing or repeated dots, its IPv4
+//     address, if given as a single number, in dotted form, and its labels that are not
+//     ASCII in IDNA form;
+//   - the path has its dot segments resolved and runs of slashes collapsed, and is "/"
+//     if empty.
+func parse(rawURL string) *url {
+	s := strings.TrimSpace(rawURL)
+	s = strings.NewReplacer("\t", "", "\r", "", "\n", "").Replace(s)
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+	u := &url{}
+	if m := schemePrefix.FindString(s); m != "" {
+		u.scheme = strings.ToLower(m[:len(m)-3])
+		s = s[len(m):]
+	} else if m := opaquePrefix.FindString(s); m != "" {
+		u.scheme = strings.ToLower(m[:len(m)-1])
+		u.opaque = s[len(m):]
+		return u
+	} else {
+		u.scheme = "http"
+	}
+
+	authority := s
+	rest := ""
+	if i := strings.IndexAny(s, "/?"); i >= 0 {
+		authority, rest = s[:i], s[i:]
+	}
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		u.userinfo, authority = authority[:i], authority[i+1:]
+	}
+	u.host = authority
+	if i := strings.LastIndexByte(authority, ':'); i >= 0 && !strings.Contains(authority[i:], "]") {
+		u.host, u.port = authority[:i], authority[i+1:]
+	}
+	path := rest
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		path, u.query, u.hasQuery = rest[:i], rest[i+1:], true
+	}
+
+	u.host = canonicalHost(u.host)
+	u.path = escape(resolvePath(unescapeRepeatedly(path)))
+	u.query = escape(unescapeRepeatedly(u.query))
+	return u
+}
+
+// canonicalHost returns host in canonical form.
+func canonicalHost(host string) string {
+	host = lowerASCII(unescapeRepeatedly(host))
+	host = strings.Trim(host, ".")
+	for strings.Contains(host, "..") {
+		host = strings.ReplaceAll(host, "..", ".")
+	}
+	if n, err := strconv.ParseUint(host, 10, 32); err == nil {
+		host = net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).String()
+	}
+	return escape(toASCII(host))
+}
+
+// lowerASCII returns s with its ASCII letters in lower case, leaving other bytes, which may
+// not be UTF-8, as they are.
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// resolvePath returns path with its dot segments resolved and runs of slashes collapsed.
+func resolvePath(path string) string {
+	var segments []string
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch part {
+		case "", ".":
+			if last {
+				segments = append(segments, "")
+			}
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+			if last {
+				segments = append(segments, "")
+			}
+		default:
+			segments = append(segments, part)
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// unescapeRepeatedly decodes the percent escapes of s until none is left.
+func unescapeRepeatedly(s string) string {
+	for {
+		unescaped := unescape(s)
+		if unescaped == s {
+			return s
+		}
+		s = unescaped
+	}
+}
+
+// unescape decodes the valid percent escapes of s, leaving the others as they are.
+func unescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			n, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			b = append(b, byte(n))
+			i += 2
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// escape percent-encodes the control characters, spaces, non-ASCII bytes, "#" and "%" of s.
+func escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 0x20 || c >= 0x7f || c == '#' || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// wwwPrefix matches the "www" label, numbered or not, leading hosts
+var wwwPrefix = regexp.MustCompile(`^www\d*\.`)
+
+// querySessionIDs match the session IDs of query strings, between the parts kept
+var querySessionIDs = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^(.*)(?:jsessionid=[0-9a-z]{32})(?:&(.*))?$`),
+	regexp.MustCompile(`(?i)^(.*)(?:phpsessid=[0-9a-z]{32})(?:&(.*))?$`),
+	regexp.MustCompile(`(?i)^(.*)(?:sid=[0-9a-z]{32})(?:&(.*))?$`),
+	regexp.MustCompile(`(?i)^(.*)(?:aspsessionid[a-z]{8}=[a-z]{24})(?:&(.*))?$`),
+	regexp.MustCompile(`(?i)^(.*)(?:cfid=[^&]+&cftoken=[^&]+)(?:&(.*))?$`),
+}
+
+// pathSessionIDs match the ASP.NET session IDs of paths, between the parts kept
+var pathSessionIDs = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^(.*/)(?:\((?:[a-z]\([0-9a-z]{24}\))+\)/)([^?]+\.aspx.*)$`),
+	regexp.MustCompile(`(?i)^(.*/)(?:\([0-9a-z]{24}\)/)([^?]+\.aspx.*)$`),
+}
+
+// stripSessionIDs removes the session IDs s matches with patterns.
+func stripSessionIDs(s string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		if m := re.FindStringSubmatch(s); m != nil {
+			s = m[1] + m[2]
+		}
+	}
+	return s
+}
+
+// sortQuery sorts the arguments of query by name, then value, arguments without value
+// first.
+func sortQuery(query string) string {
+	if len(query) <= 1 {
+		return query
+	}
+	type arg struct {
+		name, value string
+		hasValue    bool
+	}
+	var args []arg
+	for _, a := range strings.Split(query, "&") {
+		name, value, hasValue := strings.Cut(a, "=")
+		args = append(args, arg{name, value, hasValue})
+	}
+	sort.SliceStable(args, func(i, j int) bool {
+		a, b := args[i], args[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.hasValue != b.hasValue {
+			return !a.hasValue
+		}
+		return a.value < b.value
+	})
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = a.name
+		if a.hasValue {
+			parts[i] += "=" + a.value
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// defaultPorts gives the default port of schemes
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// canonicalize applies the rules of o to u.
+func (o *Options) canonicalize(u *url) {
+	if u.opaque != "" {
+		return
+	}
+	if o.StripWWW {
+		u.host = wwwPrefix.ReplaceAllString(u.host, "")
+	}
+	if o.StripUserinfo {
+		u.userinfo = ""
+	}
+	if o.StripDefaultPort && u.port == defaultPorts[u.scheme] {
+		u.port = ""
+	}
+	if o.LowercasePath {
+		u.path = strings.ToLower(u.path)
+	}
+	if o.StripSessionIDs {
+		u.path = stripSessionIDs(u.path, pathSessionIDs)
+	}
+	if o.StripTrailingSlash && len(u.path) > 1 {
+		u.path = strings.TrimSuffix(u.path, "/")
+	}
+	if o.LowercaseQuery {
+		u.query = strings.ToLower(u.query)
+	}
+	if o.StripSessionIDs {
+		u.query = stripSessionIDs(u.query, querySessionIDs)
+	}
+	if o.FixupQuery {
+		u.query = strings.TrimRight(u.query, "&")
+	}
+	if (o.StripEmptyQuery || o.FixupQuery) && u.query == "" {
+		u.hasQuery = false
+	}
+	if o.SortQuery {
+		u.query = sortQuery(u.query)
+	}
+}
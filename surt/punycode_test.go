@@ -0,0 +1,39 @@
+package surt
+
+import "testing"
+
+func TestPunycode(t *testing.T) {
+	// Samples of RFC 3492, section 7.1, and of RFC 3490
+	tests := []struct {
+		in, want string
+	}{
+		{"bücher", "bcher-kva"},
+		{"3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+		{"ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+		{"そのスピードで", "d9juau41awczczp"},
+		{"他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+	}
+	for _, tt := range tests {
+		if got := punycode(tt.in); got != tt.want {
+			t.Errorf("punycode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"example.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"BÜCHER.example", "xn--bcher-kva.example"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		{"\x80.com", "\x80.com"},
+	}
+	for _, tt := range tests {
+		if got := toASCII(tt.host); got != tt.want {
+			t.Errorf("toASCII(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package surt canonicalizes URLs and turns them into SURTs, Sort-friendly URI Reordering
+// Transforms, the keys under which CDX indexes sort captures: "http://www.example.com/a?b"
+// becomes "com,example)/a?b", so that the captures of a domain and its subdomains sort
+// together.
+//
+// URLs are first canonicalized as Google Safe Browsing does, then as the rules of an
+// Options say. PyWB gives the rules of pywb and OpenWayback indexes, which Canonicalize and
+// SURT apply; AggressiveIA those of Heritrix's AggressiveIA canonicalizer.
+package surt
+
+import (
+	"net"
+	"strings"
+)
+
+// Options are the canonicalization rules applied to URLs, on top of those of Google Safe
+// Browsing, and the form of their SURTs.
+type Options struct {
+	// StripWWW removes a leading "www" label, numbered or not, as "www2."
+	StripWWW bool
+	// StripUserinfo removes the user name and password
+	StripUserinfo bool
+	// StripDefaultPort removes the port when it is the default port of the scheme
+	StripDefaultPort bool
+	// LowercasePath and LowercaseQuery lower the case of the path and query
+	LowercasePath  bool
+	LowercaseQuery bool
+	// SortQuery sorts the query arguments by name, then value
+	SortQuery bool
+	// StripSessionIDs removes the session IDs of Java, PHP, ASP, ASP.NET and ColdFusion
+	// from the query and path
+	StripSessionIDs bool
+	// StripTrailingSlash removes the slash ending a path other than "/"
+	StripTrailingSlash bool
+	// StripEmptyQuery removes the "?" of an empty query
+	StripEmptyQuery bool
+	// FixupQuery removes the "&" ending a query, then the query if it is empty
+	FixupQuery bool
+
+	// WithScheme starts SURTs with the scheme, as "http://(com,example,)/"
+	WithScheme bool
+	// TrailingComma ends the host of SURTs with a comma, as "com,example,)/"
+	TrailingComma bool
+	// ReverseIPAddr reverses the numbers of IPv4 hosts in SURTs, as "254,1,168,192)/",
+	// like the labels of other hosts
+	ReverseIPAddr bool
+}
+
+// PyWB are the rules of pywb and OpenWayback indexes
+var PyWB = Options{
+	StripWWW:           true,
+	StripUserinfo:      true,
+	StripDefaultPort:   true,
+	LowercasePath:      true,
+	LowercaseQuery:     true,
+	SortQuery:          true,
+	StripSessionIDs:    true,
+	StripTrailingSlash: true,
+	StripEmptyQuery:    true,
+	ReverseIPAddr:      true,
+}
+
+// AggressiveIA are the rules of Heritrix's AggressiveIA canonicalizer, with SURTs in the
+// form Heritrix writes them
+var AggressiveIA = Options{
+	StripWWW:         true,
+	StripUserinfo:    true,
+	StripDefaultPort: true,
+	LowercasePath:    true,
+	LowercaseQuery:   true,
+	StripSessionIDs:  true,
+	StripEmptyQuery:  true,
+	FixupQuery:       true,
+	WithScheme:       true,
+	TrailingComma:    true,
+}
+
+// Canonicalize returns uri canonicalized by the rules of PyWB.
+func Canonicalize(uri string) string {
+	return PyWB.Canonicalize(uri)
+}
+
+// SURT returns the SURT of uri by the rules of PyWB.
+func SURT(uri string) string {
+	return PyWB.SURT(uri)
+}
+
+// Canonicalize returns uri canonicalized by the rules of o. Opaque URLs, as
+// "dns:example.com", are returned as they are.
+func (o Options) Canonicalize(uri string) string {
+	u := parse(uri)
+	o.canonicalize(u)
+	return u.String()
+}
+
+// SURT returns the SURT of uri canonicalized by the rules of o: the labels of its host
+// reversed and separated by commas, its port, ")", then its path and query, as
+// "com,example:8080)/path?query". IPv4 hosts are reversed only with ReverseIPAddr, and
+// IPv6 hosts never. URLs without host, as "file:///a" and "dns:example.com", are returned
+// canonicalized. An empty uri has the SURT "-".
+func (o Options) SURT(uri string) string {
+	if strings.TrimSpace(uri) == "" {
+		return "-"
+	}
+	u := parse(uri)
+	o.canonicalize(u)
+	if u.opaque != "" || u.host == "" {
+		return u.String()
+	}
+
+	var b strings.Builder
+	if o.WithScheme {
+		b.WriteString(u.scheme + "://(")
+	}
+	if ip := net.ParseIP(u.host); ip != nil && !(o.ReverseIPAddr && ip.To4() != nil) {
+		b.WriteString(u.host)
+	} else {
+		labels := strings.Split(u.host, ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			b.WriteString(labels[i])
+			if i > 0 {
+				b.WriteByte(',')
+			}
+		}
+	}
+	if o.TrailingComma {
+		b.WriteByte(',')
+	}
+	if u.port != "" {
+		b.WriteString(":" + u.port)
+	}
+	if u.userinfo != "" {
+		b.WriteString("@" + u.userinfo)
+	}
+	b.WriteString(")" + u.path)
+	if u.hasQuery {
+		b.WriteString("?" + u.query)
+	}
+	return b.String()
+}
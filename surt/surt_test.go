@@ -0,0 +1,59 @@
+package surt
+
+import "testing"
+
+func TestSURT(t *testing.T) {
+	// Vectors of the surt Python package
+	tests := []struct {
+		in, want string
+	}{
+		{"", "-"},
+		{"filedesc:foo.arc.gz", "filedesc:foo.arc.gz"},
+		{"filedesc:/foo.arc.gz", "filedesc:/foo.arc.gz"},
+		{"warcinfo:foo.warc.gz", "warcinfo:foo.warc.gz"},
+		{"dns:alexa.com", "dns:alexa.com"},
+		{"dns:archive.org", "dns:archive.org"},
+		{"http://www.archive.org/", "org,archive)/"},
+		{"http://archive.org/", "org,archive)/"},
+		{"http://archive.org/goo/", "org,archive)/goo"},
+		{"http://archive.org/goo/?", "org,archive)/goo"},
+		{"http://archive.org/goo/?b&a", "org,archive)/goo?a&b"},
+		{"http://archive.org/goo/?a=2&b&a=1", "org,archive)/goo?a=1&a=2&b"},
+		{"http://192.168.1.254/info/", "254,1,168,192)/info"},
+		{"http://www.example.com:8080/", "com,example:8080)/"},
+		{"https://www.example.com:443/", "com,example)/"},
+		{"whois://whois.isoc.org.il/shaveh.co.il", "il,org,isoc,whois)/shaveh.co.il"},
+		{"http://bücher.example/", "example,xn--bcher-kva)/"},
+		{"http://www.rte.ie/tv/", "ie,rte)/tv"},
+		{"http://archive.org/index.php?PHPSESSID=0123456789abcdefghijklemopqrstuv&action=profile;u=4221", "org,archive)/index.php?action=profile;u=4221"},
+		{"http://visit.webhosting.yahoo.com/visit.gif?&r=http%3A//web.archive.org/web/20090517140029/http%3A//anthonystewarthead.electric-chi.com/&b=Netscape%205.0%20%28Windows%3B%20en-US%29&s=1366x768&o=Win32&c=24&j=true&v=1.2",
+			"com,yahoo,webhosting,visit)/visit.gif?&b=netscape%205.0%20(windows;%20en-us)&c=24&j=true&o=win32&r=http://web.archive.org/web/20090517140029/http://anthonystewarthead.electric-chi.com/&s=1366x768&v=1.2"},
+		{"file:///reports/Q1.pdf", "file:///reports/q1.pdf"},
+	}
+	for _, tt := range tests {
+		if got := SURT(tt.in); got != tt.want {
+			t.Errorf("SURT(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSURTOptions(t *testing.T) {
+	tests := []struct {
+		options  Options
+		in, want string
+	}{
+		{Options{TrailingComma: true}, "http://archive.org/goo/?a=2&b&a=1", "org,archive,)/goo/?a=2&b&a=1"},
+		{Options{WithScheme: true}, "http://example.com/", "http://(com,example)/"},
+		{Options{WithScheme: true, TrailingComma: true}, "https://www.example.com/", "https://(com,example,www,)/"},
+		{Options{}, "http://user@example.com:81/", "com,example:81@user)/"},
+		{AggressiveIA, "http://www.archive.org/Goo/?a=2&b&a=1&", "http://(org,archive,)/goo/?a=2&b&a=1"},
+		{AggressiveIA, "dns:archive.org", "dns:archive.org"},
+		{Options{}, "http://192.168.1.254/info/", "192.168.1.254)/info/"},
+		{Options{ReverseIPAddr: true}, "http://[::1]:8080/", "[::1]:8080)/"},
+	}
+	for _, tt := range tests {
+		if got := tt.options.SURT(tt.in); got != tt.want {
+			t.Errorf("%+v.SURT(%q) = %q, want %q", tt.options, tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package wacz
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/zenless-lab/gwarc/cdx"
+)
+
+// indexWARC reads the WARC file name from r, returning the index entries of its captures and
+// the HTML pages among them.
+func indexWARC(name string, r io.Reader) ([]cdx.CDXRecord, []Page, error) {
+	entries, err := cdx.Index(name, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pages []Page
+	for _, entry := range entries {
+		// Only responses have a status, revisits being of the media type warc/revisit
+		if entry.StatusCode == http.StatusOK && entry.MIMEType == "text/html" {
+			pages = append(pages, Page{URL: entry.OriginalURL, TS: entry.Date})
+		}
+	}
+	return entries, pages, nil
+}
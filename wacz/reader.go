@@ -0,0 +1,241 @@
+package wacz
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/cdx"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// Reader reads a WACZ package, giving access to its WARC files through the warc package
+// and to its index through the cdx package.
+type Reader struct {
+	// DataPackage is the manifest of the package
+	DataPackage DataPackage
+
+	ra     io.ReaderAt
+	zr     *zip.Reader
+	closer io.Closer
+	files  map[string]*zip.File
+}
+
+// Open opens the WACZ file name.
+func Open(name string) (*Reader, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r, err := NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.closer = file
+	return r, nil
+}
+
+// NewReader returns a Reader reading the WACZ package r of the given size.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	reader := &Reader{ra: r, zr: zr, files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		reader.files[f.Name] = f
+	}
+
+	data, err := reader.readFile(DataPackagePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reader.DataPackage); err != nil {
+		return nil, fmt.Errorf("wacz: invalid %s: %w", DataPackagePath, err)
+	}
+	return reader, nil
+}
+
+// Close closes the file of a Reader created by Open.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// WARCs returns the names of the WARC files of the package, sorted.
+func (r *Reader) WARCs() []string {
+	var names []string
+	for name := range r.files {
+		if rest := strings.TrimPrefix(name, ArchiveDir); rest != name && rest != "" && !strings.Contains(rest, "/") {
+			names = append(names, rest)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenFile opens the file of the package at the given path, such as "pages/pages.jsonl".
+func (r *Reader) OpenFile(path string) (io.ReadCloser, error) {
+	f, ok := r.files[path]
+	if !ok {
+		return nil, fmt.Errorf("wacz: no file %s: %w", path, os.ErrNotExist)
+	}
+	return f.Open()
+}
+
+// OpenWARC returns a warc.Reader reading the records of the named WARC file.
+func (r *Reader) OpenWARC(name string) (*warc.Reader, error) {
+	rc, err := r.OpenFile(ArchiveDir + name)
+	if err != nil {
+		return nil, err
+	}
+	return warc.NewReader(rc)
+}
+
+// ReadRecord returns the record at offset in the named WARC file, as found in the index.
+// Its Block is valid until the next call to ReadRecord.
+func (r *Reader) ReadRecord(name string, offset int64) (*warc.Record, error) {
+	f, ok := r.files[ArchiveDir+name]
+	if !ok {
+		return nil, fmt.Errorf("wacz: no WARC file %s: %w", name, os.ErrNotExist)
+	}
+	if f.Method != zip.Store {
+		return nil, fmt.Errorf("wacz: WARC file %s is compressed in the package and cannot be read by offset", name)
+	}
+	start, err := f.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || offset >= int64(f.UncompressedSize64) {
+		return nil, fmt.Errorf("wacz: offset %d is outside WARC file %s", offset, name)
+	}
+	size := int64(f.UncompressedSize64)
+	record, err := warc.ReadRecordAt(io.NewSectionReader(r.ra, start, size), size, offset)
+	if err != nil {
+		return nil, err
+	}
+	record.File = name
+	return record, nil
+}
+
+// Index returns the captures listed in the index files of the package, sorted. Indexes
+// are read from the CDXJ files of IndexDir, plain or gzip compressed, as in
+// CompressedIndexPath, and from its classic CDX files. The keys of CDXJ records that map to
+// no CDX field are kept in their Extras.
+func (r *Reader) Index() ([]cdx.CDXJRecord, error) {
+	var paths []string
+	for path := range r.files {
+		if rest := strings.TrimPrefix(path, IndexDir); rest != path && !strings.Contains(rest, "/") &&
+			(strings.HasSuffix(rest, ".cdxj") || strings.HasSuffix(rest, ".cdx") || strings.HasSuffix(rest, ".cdx.gz")) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("wacz: no index in %s: %w", IndexDir, os.ErrNotExist)
+	}
+	sort.Strings(paths)
+
+	var index []cdx.CDXJRecord
+	for _, path := range paths {
+		records, err := r.readIndex(path)
+		if err != nil {
+			return nil, fmt.Errorf("wacz: %s: %w", path, err)
+		}
+		index = append(index, records...)
+	}
+	if len(paths) > 1 {
+		sort.SliceStable(index, func(i, j int) bool {
+			if index[i].MassagedURL != index[j].MassagedURL {
+				return index[i].MassagedURL < index[j].MassagedURL
+			}
+			return index[i].Date.Before(index[j].Date)
+		})
+	}
+	return index, nil
+}
+
+// readIndex reads the index file at path.
+func (r *Reader) readIndex(path string) ([]cdx.CDXJRecord, error) {
+	data, err := r.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		// The blocks are gzip members, read on as one stream
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(data, " "), []byte("CDX ")) {
+		var file cdx.CDXFile
+		if err := cdx.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+		records := make([]cdx.CDXJRecord, len(file.Records))
+		for i, record := range file.Records {
+			records[i].CDXRecord = record
+		}
+		return records, nil
+	}
+	return cdx.UnmarshalCDXJ(data)
+}
+
+// Pages returns the pages listed in pages.jsonl.
+func (r *Reader) Pages() ([]Page, error) {
+	rc, err := r.OpenFile(PagesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var pages []Page
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		var entry struct {
+			Page
+			Format string `json:"format"`
+		}
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("wacz: %s line %d: %w", PagesPath, line, err)
+		}
+		if entry.Format != "" {
+			// The header line
+			continue
+		}
+		pages = append(pages, entry.Page)
+	}
+	return pages, scanner.Err()
+}
+
+// readFile reads a whole file of the package.
+func (r *Reader) readFile(path string) ([]byte, error) {
+	rc, err := r.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
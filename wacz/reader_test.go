@@ -0,0 +1,199 @@
+package wacz_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zenless-lab/gwarc/wacz"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+func TestReader(t *testing.T) {
+	warcs := map[string][]byte{
+		"one.warc.gz": buildWARC(t, true, "text/html", "http://example.com/b", "http://example.com/a"),
+		"two.warc":    buildWARC(t, false, "text/html", "http://example.com/c"),
+	}
+	path := filepath.Join(t.TempDir(), "test.wacz")
+	if err := os.WriteFile(path, buildWACZ(t, warcs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := wacz.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if names := r.WARCs(); len(names) != 2 || names[0] != "one.warc.gz" || names[1] != "two.warc" {
+		t.Errorf("WARCs() = %v", names)
+	}
+
+	index, err := r.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantURLs := []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"}
+	if len(index) != len(wantURLs) {
+		t.Fatalf("Index() has %d records, want %d", len(index), len(wantURLs))
+	}
+	for i, entry := range index {
+		if entry.OriginalURL != wantURLs[i] || entry.StatusCode != 200 || entry.MIMEType != "text/html" || entry.CompressedSize == 0 {
+			t.Errorf("index entry %d = %+v", i, entry)
+		}
+		record, err := r.ReadRecord(entry.Filename, entry.CompressedArcOffset)
+		if err != nil {
+			t.Fatalf("ReadRecord(%s, %d): %v", entry.Filename, entry.CompressedArcOffset, err)
+		}
+		if record.TargetURI != entry.OriginalURL || record.File != entry.Filename {
+			t.Errorf("ReadRecord(%s, %d) = %s in %s, want %s", entry.Filename, entry.CompressedArcOffset, record.TargetURI, record.File, entry.OriginalURL)
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil || !bytes.HasSuffix(block, []byte("<html>"+entry.OriginalURL+"</html>")) {
+			t.Errorf("record %s block = %q, %v", record.TargetURI, block, err)
+		}
+	}
+
+	reader, err := r.OpenWARC("two.warc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []warc.WARCRecordType
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, record.Type)
+	}
+	if len(types) != 2 || types[0] != warc.WARCTypeWarcinfo || types[1] != warc.WARCTypeResponse {
+		t.Errorf("OpenWARC() records = %v", types)
+	}
+
+	pages, err := r.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 || pages[0].URL != "http://example.com/b" || !pages[0].TS.Equal(captureDate) {
+		t.Errorf("Pages() = %+v", pages)
+	}
+
+	if _, err := r.ReadRecord("missing.warc", 0); err == nil {
+		t.Errorf("ReadRecord() of a missing file = nil, want an error")
+	}
+}
+
+func TestReaderPages(t *testing.T) {
+	warcs := map[string][]byte{"two.warc": buildWARC(t, false, "text/html", "http://example.com/")}
+	data := buildWACZ(t, warcs, wacz.Page{ID: "1", URL: "http://example.com/", TS: captureDate, Title: "Example"})
+	r, err := wacz.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := r.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].ID != "1" || pages[0].Title != "Example" {
+		t.Errorf("Pages() = %+v", pages)
+	}
+}
+
+// repackIndex returns the WACZ package data with its CDXJ index replaced by files made
+// from the index lines by convert.
+func repackIndex(t *testing.T, data []byte, convert func(lines []string) map[string][]byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, _ := io.ReadAll(rc)
+		rc.Close()
+		files := map[string][]byte{f.Name: contents}
+		if f.Name == wacz.IndexPath {
+			files = convert(strings.SplitAfter(strings.TrimSuffix(string(contents), "\n"), "\n"))
+		}
+		for name, contents := range files {
+			out, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.Write(contents)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReaderIndexVariants(t *testing.T) {
+	warcs := map[string][]byte{
+		"one.warc.gz": buildWARC(t, true, "text/html", "http://example.com/b", "http://example.com/a"),
+		"two.warc":    buildWARC(t, false, "text/html", "http://example.com/c"),
+	}
+	data := buildWACZ(t, warcs)
+	variants := map[string]func(lines []string) map[string][]byte{
+		"compressed": func(lines []string) map[string][]byte {
+			// One gzip member per block of lines, as in ZipNum indexes
+			var index, idx bytes.Buffer
+			for i, line := range lines {
+				offset := index.Len()
+				gz := gzip.NewWriter(&index)
+				io.WriteString(gz, line)
+				gz.Close()
+				key := strings.SplitN(line, " ", 3)
+				fmt.Fprintf(&idx, "%s %s\t{\"offset\": %d, \"length\": %d, \"lineno\": %d}\n", key[0], key[1], offset, index.Len()-offset, i+1)
+			}
+			return map[string][]byte{wacz.CompressedIndexPath: index.Bytes(), wacz.IDXPath: idx.Bytes()}
+		},
+		"split": func(lines []string) map[string][]byte {
+			return map[string][]byte{
+				"indexes/b.cdxj": []byte(lines[0] + lines[2]),
+				"indexes/a.cdxj": []byte(lines[1]),
+			}
+		},
+	}
+	for name, convert := range variants {
+		t.Run(name, func(t *testing.T) {
+			repacked := repackIndex(t, data, convert)
+			r, err := wacz.NewReader(bytes.NewReader(repacked), int64(len(repacked)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			index, err := r.Index()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantURLs := []string{"http://example.com/a", "http://example.com/b", "http://example.com/c"}
+			if len(index) != len(wantURLs) {
+				t.Fatalf("Index() has %d records, want %d", len(index), len(wantURLs))
+			}
+			for i, entry := range index {
+				if entry.OriginalURL != wantURLs[i] {
+					t.Errorf("index entry %d = %s, want %s", i, entry.OriginalURL, wantURLs[i])
+				}
+				record, err := r.ReadRecord(entry.Filename, entry.CompressedArcOffset)
+				if err != nil || record.TargetURI != entry.OriginalURL {
+					t.Errorf("ReadRecord(%s, %d) = %v, %v", entry.Filename, entry.CompressedArcOffset, record, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package wacz
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DigestPath is the path of the file holding the digest of datapackage.json and its signature
+const DigestPath = "datapackage-digest.json"
+
+// DigestFile is the datapackage-digest.json file of a WACZ package.
+type DigestFile struct {
+	// Path is the path of the file digested, datapackage.json
+	Path string `json:"path"`
+	// Hash is the SHA-256 digest of the file, as "sha256:" followed by its hex encoding
+	Hash string `json:"hash"`
+	// SignedData holds the signature of Hash, if the package is signed
+	SignedData *SignedData `json:"signedData,omitempty"`
+}
+
+// SignedData is the signature of a WACZ package made with a local key, as described by the
+// WACZ signing and verification specification for anonymous signing.
+type SignedData struct {
+	// Hash is the signed hash, that of datapackage.json
+	Hash string `json:"hash"`
+	// Signature is the base64 encoded signature of Hash: an Ed25519 signature, or an ECDSA
+	// signature of its SHA-256 digest in the IEEE P1363 encoding of WebCrypto, r and s in
+	// fixed width, or in ASN.1
+	Signature string `json:"signature"`
+	// PublicKey is the base64 encoded DER (PKIX) public key verifying Signature
+	PublicKey string `json:"publicKey"`
+	// Created is the time of signing
+	Created time.Time `json:"created"`
+	// Software names the program that signed the package
+	Software string `json:"software,omitempty"`
+}
+
+// Sign signs hash with signer, which must hold an Ed25519 or ECDSA private key. ECDSA
+// signatures are written in the IEEE P1363 encoding, so that tools verifying them with
+// WebCrypto can.
+func Sign(signer crypto.Signer, hash string) (*SignedData, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	var signature []byte
+	switch key := signer.Public().(type) {
+	case ed25519.PublicKey:
+		signature, err = signer.Sign(rand.Reader, []byte(hash), crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256([]byte(hash))
+		if signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+			signature, err = ecdsaP1363(key, signature)
+		}
+	default:
+		return nil, fmt.Errorf("wacz: unsupported key type %T", signer.Public())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SignedData{
+		Hash:      hash,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		Created:   time.Now().UTC(),
+	}, nil
+}
+
+// Verify checks the signature of d against its public key, returning the key.
+func (d *SignedData) Verify() (crypto.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(d.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(d.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	valid := false
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, []byte(d.Hash), signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256([]byte(d.Hash))
+		if size := curveSize(key); len(signature) == 2*size {
+			r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(key, digest[:], r, s)
+		}
+		if !valid {
+			valid = ecdsa.VerifyASN1(key, digest[:], signature)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+	if !valid {
+		return publicKey, errors.New("signature does not match")
+	}
+	return publicKey, nil
+}
+
+// ecdsaP1363 returns the IEEE P1363 encoding of an ASN.1 encoded ECDSA signature made with
+// the private key of key.
+func ecdsaP1363(key *ecdsa.PublicKey, signature []byte) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("wacz: invalid ECDSA signature")
+	}
+	size := curveSize(key)
+	p1363 := make([]byte, 2*size)
+	sig.R.FillBytes(p1363[:size])
+	sig.S.FillBytes(p1363[size:])
+	return p1363, nil
+}
+
+// curveSize returns the size in bytes of the integers of the curve of key.
+func curveSize(key *ecdsa.PublicKey) int {
+	return (key.Curve.Params().BitSize + 7) / 8
+}
+
+// FileCheck is the verification of one file of a WACZ package against datapackage.json.
+type FileCheck struct {
+	Path string
+	// WantHash and WantBytes are the digest and size recorded in datapackage.json
+	WantHash  string
+	WantBytes int64
+	// Hash and Bytes are the digest and size of the file in the package
+	Hash  string
+	Bytes int64
+	// Err is why the file could not be checked, such as its absence from the package
+	Err error
+}
+
+// OK reports whether the file matches its record in datapackage.json.
+func (c *FileCheck) OK() bool {
+	return c.Err == nil && strings.EqualFold(c.Hash, c.WantHash) && c.Bytes == c.WantBytes
+}
+
+// Report is the result of verifying a WACZ package.
+type Report struct {
+	// Files checks each file listed in datapackage.json
+	Files []FileCheck
+	// Unlisted names the files of the package missing from datapackage.json
+	Unlisted []string
+
+	// Digested reports whether the package has a datapackage-digest.json, and DigestOK
+	// whether the digest it holds matches datapackage.json
+	Digested bool
+	DigestOK bool
+	// DigestErr is why the digest could not be checked, such as an algorithm not
+	// supported, or nil
+	DigestErr error
+
+	// Signed reports whether datapackage-digest.json holds a signature, and SignatureErr
+	// why the signature is invalid, or nil
+	Signed       bool
+	SignatureErr error
+	// PublicKey is the key the package was signed with
+	PublicKey crypto.PublicKey
+	// Trusted reports whether the signature is valid and PublicKey one of the trusted keys
+	// given to Verify. A signature checked against no keys is never trusted, as anyone
+	// altering a package can sign it again with a key of their own.
+	Trusted bool
+
+	// keysGiven reports whether Verify was given trusted keys
+	keysGiven bool
+}
+
+// OK reports whether every file matches datapackage.json, no file is unlisted, the digest
+// of datapackage.json matches, and the package is signed with a trusted key if it is
+// signed or if Verify was given trusted keys. An unsigned package verified without keys
+// is only known to be consistent, not to be unaltered.
+func (r *Report) OK() bool {
+	for i := range r.Files {
+		if !r.Files[i].OK() {
+			return false
+		}
+	}
+	if len(r.Unlisted) > 0 || (r.Digested && !r.DigestOK) {
+		return false
+	}
+	if r.Signed || r.keysGiven {
+		return r.Trusted
+	}
+	return true
+}
+
+// Verify checks every file of the package against the hashes of datapackage.json, and
+// datapackage.json against datapackage-digest.json and its signature, if any. The package is
+// trusted only when signed with one of the trusted keys given, as a valid signature alone
+// proves nothing of its origin. Verify needs no network access. Its error reports failures
+// to read the package, not mismatches, which are in the Report.
+func (r *Reader) Verify(trusted ...crypto.PublicKey) (*Report, error) {
+	report := &Report{keysGiven: len(trusted) > 0}
+	listed := map[string]bool{DataPackagePath: true, DigestPath: true}
+	for _, resource := range r.DataPackage.Resources {
+		listed[resource.Path] = true
+		check := FileCheck{Path: resource.Path, WantHash: resource.Hash, WantBytes: resource.Bytes}
+		check.Hash, check.Bytes, check.Err = r.hashFile(resource.Path, resource.Hash)
+		report.Files = append(report.Files, check)
+	}
+	for name, f := range r.files {
+		if !listed[name] && !f.FileInfo().IsDir() {
+			report.Unlisted = append(report.Unlisted, name)
+		}
+	}
+	sort.Strings(report.Unlisted)
+
+	data, err := r.readFile(DigestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Digested = true
+	var digest DigestFile
+	if err := json.Unmarshal(data, &digest); err != nil {
+		return nil, fmt.Errorf("wacz: invalid %s: %w", DigestPath, err)
+	}
+	hash, _, err := r.hashFile(DataPackagePath, digest.Hash)
+	switch {
+	case errors.Is(err, errUnsupportedHash):
+		report.DigestErr = err
+	case err != nil:
+		return nil, err
+	default:
+		report.DigestOK = digest.Path == DataPackagePath && strings.EqualFold(hash, digest.Hash)
+	}
+
+	if digest.SignedData == nil {
+		return report, nil
+	}
+	report.Signed = true
+	if digest.SignedData.Hash != digest.Hash {
+		report.SignatureErr = errors.New("signed hash differs from the digest of datapackage.json")
+		return report, nil
+	}
+	report.PublicKey, report.SignatureErr = digest.SignedData.Verify()
+	if report.SignatureErr == nil {
+		for _, key := range trusted {
+			if equal, ok := key.(interface{ Equal(crypto.PublicKey) bool }); ok && equal.Equal(report.PublicKey) {
+				report.Trusted = true
+			}
+		}
+	}
+	return report, nil
+}
+
+// errUnsupportedHash is wrapped by the errors of hashFile for digests of an algorithm other
+// than SHA-256
+var errUnsupportedHash = errors.New("unsupported hash algorithm")
+
+// hashFile returns the digest and size of a file of the package, with the algorithm of want.
+func (r *Reader) hashFile(path, want string) (string, int64, error) {
+	algorithm, _, _ := strings.Cut(want, ":")
+	if !strings.EqualFold(algorithm, "sha256") {
+		return "", 0, fmt.Errorf("%w %q", errUnsupportedHash, algorithm)
+	}
+	rc, err := r.OpenFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return "", n, err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// digestFile returns the datapackage-digest.json of a datapackage.json, signed by signer if set.
+func digestFile(dataPackage []byte, signer crypto.Signer, software string) ([]byte, error) {
+	sum := sha256.Sum256(dataPackage)
+	digest := DigestFile{Path: DataPackagePath, Hash: "sha256:" + hex.EncodeToString(sum[:])}
+	if signer != nil {
+		signed, err := Sign(signer, digest.Hash)
+		if err != nil {
+			return nil, err
+		}
+		signed.Software = software
+		digest.SignedData = signed
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(digest); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
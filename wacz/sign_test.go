@@ -0,0 +1,275 @@
+package wacz_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/zenless-lab/gwarc/wacz"
+)
+
+// buildSignedWACZ returns a WACZ package signed by signer.
+func buildSignedWACZ(t *testing.T, signer crypto.Signer) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := wacz.NewWriter(&buf)
+	w.Created = captureDate
+	w.Signer = signer
+	if err := w.AddWARC("one.warc", bytes.NewReader(buildWARC(t, false, "text/html", "http://example.com/"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rewriteZip returns a copy of a zip file with its files passed through edit, and extra
+// files added.
+func rewriteZip(t *testing.T, data []byte, edit func(name string, content []byte) []byte, extra ...string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(edit(f.Name, content))
+	}
+	for _, name := range extra {
+		out, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write([]byte(name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func verify(t *testing.T, data []byte, trusted ...crypto.PublicKey) *wacz.Report {
+	t.Helper()
+	r, err := wacz.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := r.Verify(trusted...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestVerify(t *testing.T) {
+	report := verify(t, buildSignedWACZ(t, nil))
+	if !report.OK() || !report.Digested || !report.DigestOK || report.Signed {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Files) != 3 {
+		t.Fatalf("Files = %+v, want 3", report.Files)
+	}
+	for _, check := range report.Files {
+		if !check.OK() || check.Hash != check.WantHash || check.Bytes == 0 {
+			t.Errorf("file %+v", check)
+		}
+	}
+}
+
+func TestVerifySigned(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signer := range []crypto.Signer{edKey, p256, p384} {
+		data := buildSignedWACZ(t, signer)
+		// A valid signature by a key not known to be trusted proves nothing
+		report := verify(t, data)
+		if report.OK() || !report.Signed || report.SignatureErr != nil || report.Trusted {
+			t.Errorf("%T: report = %+v", signer, report)
+		}
+		if key, ok := report.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !key.Equal(signer.Public()) {
+			t.Errorf("%T: PublicKey = %v", signer, report.PublicKey)
+		}
+
+		if report := verify(t, data, p384.Public(), signer.Public()); !report.OK() || !report.Trusted {
+			t.Errorf("%T: report with trusted keys = %+v", signer, report)
+		}
+		other := p256.Public()
+		if signer == p256 {
+			other = edKey.Public()
+		}
+		if report := verify(t, data, other); report.OK() || report.Trusted || report.SignatureErr != nil {
+			t.Errorf("%T: report with untrusted key = %+v", signer, report)
+		}
+	}
+}
+
+func TestSignP1363(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed, err := wacz.Sign(key, "sha256:0123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, _ := base64.StdEncoding.DecodeString(signed.Signature)
+		size := (curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			t.Errorf("%s: signature has %d bytes, want %d", curve.Params().Name, len(signature), 2*size)
+		}
+		// As WebCrypto checks it
+		digest := sha256.Sum256([]byte(signed.Hash))
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+			t.Errorf("%s: signature is not r and s", curve.Params().Name)
+		}
+		if _, err := signed.Verify(); err != nil {
+			t.Errorf("%s: Verify() = %v", curve.Params().Name, err)
+		}
+
+		// Signatures in ASN.1 are still accepted
+		asn1Signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed.Signature = base64.StdEncoding.EncodeToString(asn1Signature)
+		if _, err := signed.Verify(); err != nil {
+			t.Errorf("%s: Verify() of an ASN.1 signature = %v", curve.Params().Name, err)
+		}
+	}
+}
+
+func TestVerifyUnsigned(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buildSignedWACZ(t, nil)
+	if report := verify(t, data); !report.OK() {
+		t.Errorf("report = %+v", report)
+	}
+	if report := verify(t, data, key.Public()); report.OK() || report.Trusted {
+		t.Errorf("report with trusted keys = %+v", report)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buildSignedWACZ(t, key)
+
+	// A capture altered after packaging
+	report := verify(t, rewriteZip(t, data, func(name string, content []byte) []byte {
+		if name == wacz.ArchiveDir+"one.warc" {
+			return bytes.Replace(content, []byte("example.com"), []byte("example.org"), -1)
+		}
+		return content
+	}))
+	if report.OK() || !report.DigestOK || report.SignatureErr != nil {
+		t.Errorf("altered WARC: report = %+v", report)
+	}
+	for _, check := range report.Files {
+		if check.OK() != (check.Path != wacz.ArchiveDir+"one.warc") {
+			t.Errorf("altered WARC: file %+v", check)
+		}
+	}
+
+	// A file added and one removed
+	report = verify(t, rewriteZip(t, data, func(name string, content []byte) []byte {
+		if name == wacz.PagesPath {
+			return nil
+		}
+		return content
+	}, "extra.txt"))
+	if report.OK() || len(report.Unlisted) != 1 || report.Unlisted[0] != "extra.txt" {
+		t.Errorf("extra file: report = %+v", report)
+	}
+	for _, check := range report.Files {
+		if check.OK() == (check.Path == wacz.PagesPath) {
+			t.Errorf("emptied file: file %+v", check)
+		}
+	}
+
+	// datapackage.json rewritten to match the altered capture
+	report = verify(t, rewriteZip(t, data, func(name string, content []byte) []byte {
+		if name == wacz.DataPackagePath {
+			return bytes.Replace(content, []byte(`"created"`), []byte(`"title": "Forged", "created"`), 1)
+		}
+		return content
+	}))
+	if report.OK() || report.DigestOK {
+		t.Errorf("altered datapackage.json: report = %+v", report)
+	}
+
+	// The digest recomputed, but not the signature
+	report = verify(t, rewriteZip(t, data, func(name string, content []byte) []byte {
+		if name == wacz.DigestPath {
+			var digest wacz.DigestFile
+			if err := json.Unmarshal(content, &digest); err != nil {
+				t.Fatal(err)
+			}
+			digest.SignedData.Hash = "sha256:" + digest.SignedData.Hash[len("sha256:")+1:] + "0"
+			digest.Hash = digest.SignedData.Hash
+			content, _ = json.Marshal(digest)
+		}
+		return content
+	}))
+	if report.OK() || report.DigestOK || report.SignatureErr == nil {
+		t.Errorf("forged signature: report = %+v", report)
+	}
+
+	// A digest of an algorithm not supported
+	report = verify(t, rewriteZip(t, data, func(name string, content []byte) []byte {
+		if name == wacz.DigestPath {
+			var digest wacz.DigestFile
+			if err := json.Unmarshal(content, &digest); err != nil {
+				t.Fatal(err)
+			}
+			digest.Hash = "md5:d41d8cd98f00b204e9800998ecf8427e"
+			content, _ = json.Marshal(digest)
+		}
+		return content
+	}))
+	if report.OK() || !report.Digested || report.DigestOK || report.DigestErr == nil {
+		t.Errorf("unsupported digest: report = %+v", report)
+	}
+}
@@ -0,0 +1,59 @@
+package wacz
+
+import (
+	"time"
+)
+
+// Version is the version of the WACZ specification written by Writer
+const Version = "1.1.1"
+
+// Paths of the files of a WACZ package
+const (
+	ArchiveDir = "archive/"
+	IndexDir   = "indexes/"
+	// IndexPath is the CDXJ index written by Writer
+	IndexPath = IndexDir + "index.cdxj"
+	// CompressedIndexPath and IDXPath are the gzip compressed CDXJ index of large packages,
+	// in blocks of lines compressed on their own, and the index of its blocks
+	CompressedIndexPath = IndexDir + "index.cdx.gz"
+	IDXPath             = IndexDir + "index.idx"
+	PagesPath           = "pages/pages.jsonl"
+	DataPackagePath     = "datapackage.json"
+)
+
+// PagesFormat is the format named in the header line of pages.jsonl
+const PagesFormat = "json-pages-1.0"
+
+// DataPackage is the datapackage.json manifest of a WACZ package.
+type DataPackage struct {
+	Profile      string     `json:"profile"`
+	WACZVersion  string     `json:"wacz_version"`
+	Title        string     `json:"title,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Created      time.Time  `json:"created"`
+	Software     string     `json:"software,omitempty"`
+	MainPageURL  string     `json:"mainPageURL,omitempty"`
+	MainPageDate *time.Time `json:"mainPageDate,omitempty"`
+	Resources    []Resource `json:"resources"`
+}
+
+// Resource describes a file of a WACZ package in its DataPackage.
+type Resource struct {
+	// Name is the base name of the file
+	Name string `json:"name"`
+	// Path is the path of the file within the package
+	Path string `json:"path"`
+	// Hash is the SHA-256 digest of the file, as "sha256:" followed by its hex encoding
+	Hash string `json:"hash"`
+	// Bytes is the size of the file
+	Bytes int64 `json:"bytes"`
+}
+
+// Page is an entry of pages.jsonl, a page a replay tool can start from.
+type Page struct {
+	ID    string    `json:"id,omitempty"`
+	URL   string    `json:"url"`
+	TS    time.Time `json:"ts"`
+	Title string    `json:"title,omitempty"`
+	Text  string    `json:"text,omitempty"`
+}
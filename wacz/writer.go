@@ -0,0 +1,204 @@
+package wacz
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zenless-lab/gwarc/cdx"
+	"github.com/zenless-lab/gwarc/internal/iocount"
+)
+
+// Writer writes a WACZ package: the WARC files added to it, stored uncompressed so that
+// their records can be read by offset, a CDXJ index of their captures, a list of pages and the
+// datapackage.json manifest with the SHA-256 digest of every file.
+type Writer struct {
+	// Title and Description describe the collection in datapackage.json
+	Title       string
+	Description string
+	// Software names the program writing the package; "gwarc" if empty
+	Software string
+	// Created is the creation time recorded in datapackage.json; the time of Close if zero
+	Created time.Time
+	// MainPageURL and MainPageDate name the page replay starts from; the first page if empty
+	MainPageURL  string
+	MainPageDate time.Time
+	// Signer, if set, signs datapackage-digest.json with its Ed25519 or ECDSA key
+	Signer crypto.Signer
+
+	zw        *zip.Writer
+	resources []Resource
+	index     []cdx.CDXRecord
+	pages     []Page
+	found     []Page
+	closed    bool
+}
+
+// NewWriter returns a Writer writing a WACZ package to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// AddWARC adds the WARC file name, plain or gzip compressed, read from r to the package,
+// and its response, revisit and resource records to the index.
+func (w *Writer) AddWARC(name string, r io.Reader) error {
+	if w.closed {
+		return fmt.Errorf("wacz: writer is closed")
+	}
+	if name != path.Base(name) || !strings.Contains(name, ".warc") {
+		return fmt.Errorf("wacz: invalid WARC file name %q", name)
+	}
+	for _, resource := range w.resources {
+		if resource.Name == name {
+			return fmt.Errorf("wacz: WARC file %q added twice", name)
+		}
+	}
+
+	out, digest, err := w.create(ArchiveDir + name)
+	if err != nil {
+		return err
+	}
+	counter := iocount.NewReader(io.TeeReader(r, out))
+	entries, pages, err := indexWARC(name, counter)
+	if err != nil {
+		return fmt.Errorf("wacz: %s: %w", name, err)
+	}
+	w.index = append(w.index, entries...)
+	w.found = append(w.found, pages...)
+	w.addResource(ArchiveDir+name, digest, counter.N())
+	return nil
+}
+
+// AddPage adds a page to pages.jsonl. When no page is added, the pages are the HTML
+// documents captured with status 200 in the WARC files.
+func (w *Writer) AddPage(page Page) {
+	w.pages = append(w.pages, page)
+}
+
+// Close writes the index, the pages, datapackage.json and datapackage-digest.json, and
+// finishes the package. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	cdx.SortRecords(w.index)
+	index := make([]cdx.CDXJRecord, len(w.index))
+	for i, entry := range w.index {
+		index[i].CDXRecord = entry
+	}
+	data, err := cdx.MarshalCDXJ(index)
+	if err != nil {
+		return err
+	}
+	if err := w.addFile(IndexPath, data); err != nil {
+		return err
+	}
+
+	pages := w.pages
+	if len(pages) == 0 {
+		pages = w.found
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(map[string]string{"format": PagesFormat, "id": "pages", "title": "All Pages"}); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := enc.Encode(page); err != nil {
+			return err
+		}
+	}
+	if err := w.addFile(PagesPath, buf.Bytes()); err != nil {
+		return err
+	}
+
+	pkg := DataPackage{
+		Profile:     "data-package",
+		WACZVersion: Version,
+		Title:       w.Title,
+		Description: w.Description,
+		Created:     w.Created,
+		Software:    w.Software,
+		MainPageURL: w.MainPageURL,
+		Resources:   w.resources,
+	}
+	if pkg.Created.IsZero() {
+		pkg.Created = time.Now().UTC()
+	}
+	if pkg.Software == "" {
+		pkg.Software = "gwarc"
+	}
+	mainPageDate := w.MainPageDate
+	if pkg.MainPageURL == "" && len(pages) > 0 {
+		pkg.MainPageURL, mainPageDate = pages[0].URL, pages[0].TS
+	}
+	if !mainPageDate.IsZero() {
+		pkg.MainPageDate = &mainPageDate
+	}
+	if data, err = json.MarshalIndent(pkg, "", "  "); err != nil {
+		return err
+	}
+	out, err := w.zw.CreateHeader(&zip.FileHeader{Name: DataPackagePath, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+
+	digest, err := digestFile(data, w.Signer, pkg.Software)
+	if err != nil {
+		return err
+	}
+	if out, err = w.zw.CreateHeader(&zip.FileHeader{Name: DigestPath, Method: zip.Deflate, Modified: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := out.Write(digest); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// create starts a stored file of the package, returning a writer to it and the digest
+// of what is written.
+func (w *Writer) create(name string) (io.Writer, hash.Hash, error) {
+	out, err := w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return nil, nil, err
+	}
+	digest := sha256.New()
+	return io.MultiWriter(out, digest), digest, nil
+}
+
+// addFile adds a file listed in datapackage.json to the package.
+func (w *Writer) addFile(name string, data []byte) error {
+	out, digest, err := w.create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	w.addResource(name, digest, int64(len(data)))
+	return nil
+}
+
+func (w *Writer) addResource(name string, digest hash.Hash, size int64) {
+	w.resources = append(w.resources, Resource{
+		Name:  path.Base(name),
+		Path:  name,
+		Hash:  "sha256:" + hex.EncodeToString(digest.Sum(nil)),
+		Bytes: size,
+	})
+}
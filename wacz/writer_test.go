@@ -0,0 +1,137 @@
+package wacz_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/wacz"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+var captureDate = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// buildWARC returns a WARC file holding a warcinfo record and a response for each URL.
+func buildWARC(t *testing.T, compressed bool, contentType string, urls ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf)
+	if compressed {
+		w = warc.NewGzipWriter(&buf)
+	}
+	info := &warc.WarcInfoRecord{WARCRecord: warc.WARCRecord{Date: captureDate}}
+	info.Software = "gwarc"
+	records := []any{info}
+	for i, url := range urls {
+		records = append(records, &warc.WARCRecord{
+			Type:          warc.WARCTypeResponse,
+			Date:          captureDate.Add(time.Duration(i) * time.Second),
+			TargetURI:     url,
+			ContentType:   "application/http; msgtype=response",
+			PayloadDigest: warc.Digest([]byte("<html>" + url + "</html>")),
+			Content:       []byte("HTTP/1.1 200 OK\r\nContent-Type: " + contentType + "\r\n\r\n<html>" + url + "</html>"),
+		})
+	}
+	if err := w.WriteRecords(records...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildWACZ returns a WACZ package of the given WARC files.
+func buildWACZ(t *testing.T, warcs map[string][]byte, pages ...wacz.Page) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := wacz.NewWriter(&buf)
+	w.Title = "Test collection"
+	w.Created = captureDate
+	for _, name := range []string{"one.warc.gz", "two.warc"} {
+		if data, ok := warcs[name]; ok {
+			if err := w.AddWARC(name, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, page := range pages {
+		w.AddPage(page)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	warcs := map[string][]byte{
+		"one.warc.gz": buildWARC(t, true, "text/html; charset=utf-8", "http://www.example.com/", "http://example.com/b"),
+		"two.warc":    buildWARC(t, false, "image/png", "http://example.com/a.png"),
+	}
+	data := buildWACZ(t, warcs)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string][]byte)
+	for _, f := range zr.File {
+		if f.Name == "archive/one.warc.gz" || f.Name == "archive/two.warc" {
+			if f.Method != zip.Store {
+				t.Errorf("%s is compressed in the package", f.Name)
+			}
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	for _, path := range []string{"archive/one.warc.gz", "archive/two.warc", wacz.IndexPath, wacz.PagesPath, wacz.DataPackagePath, wacz.DigestPath} {
+		if _, ok := contents[path]; !ok {
+			t.Errorf("package has no %s", path)
+		}
+	}
+	if index := string(contents[wacz.IndexPath]); !strings.HasPrefix(index, "com,example)/ 20240101") ||
+		!strings.Contains(index, ` {"url": "http://example.com/a.png", "mime": "image/png", "status": "200"`) {
+		t.Errorf("index is not CDXJ:\n%s", index)
+	}
+
+	r, err := wacz.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := r.DataPackage
+	if pkg.Profile != "data-package" || pkg.WACZVersion != wacz.Version || pkg.Title != "Test collection" || !pkg.Created.Equal(captureDate) {
+		t.Errorf("DataPackage = %+v", pkg)
+	}
+	if pkg.MainPageURL != "http://www.example.com/" || pkg.MainPageDate == nil || !pkg.MainPageDate.Equal(captureDate) {
+		t.Errorf("main page = %s at %v", pkg.MainPageURL, pkg.MainPageDate)
+	}
+	if len(pkg.Resources) != 4 {
+		t.Fatalf("Resources = %+v, want 4", pkg.Resources)
+	}
+	for _, resource := range pkg.Resources {
+		sum := sha256.Sum256(contents[resource.Path])
+		if resource.Hash != "sha256:"+hex.EncodeToString(sum[:]) || resource.Bytes != int64(len(contents[resource.Path])) {
+			t.Errorf("resource %s: hash %s, %d bytes", resource.Path, resource.Hash, resource.Bytes)
+		}
+	}
+	if !bytes.Equal(contents["archive/one.warc.gz"], warcs["one.warc.gz"]) {
+		t.Errorf("archive/one.warc.gz differs from the WARC file added")
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := wacz.NewWriter(io.Discard)
+	if err := w.AddWARC("dir/one.warc", bytes.NewReader(nil)); err == nil {
+		t.Errorf("AddWARC() of a path = nil, want an error")
+	}
+	if err := w.AddWARC("one.warc", bytes.NewReader([]byte("not a WARC file"))); err == nil {
+		t.Errorf("AddWARC() of an invalid file = nil, want an error")
+	}
+}
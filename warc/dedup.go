@@ -0,0 +1,223 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ProfileIdenticalPayloadDigest1_0 is the WARC 1.0 revisit profile for payloads archived before
+	ProfileIdenticalPayloadDigest1_0 = "http://netpreserve.org/warc/1.0/revisit/identical-payload-digest"
+	// ProfileIdenticalPayloadDigest1_1 is the WARC 1.1 revisit profile for payloads archived before
+	ProfileIdenticalPayloadDigest1_1 = "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"
+)
+
+// DigestEntry identifies the record a payload was first archived in.
+type DigestEntry struct {
+	// RecordID is the WARC-Record-ID of the original record
+	RecordID string
+	// TargetURI is the WARC-Target-URI of the original record
+	TargetURI string
+	// Date is the WARC-Date of the original record
+	Date time.Time
+}
+
+// DigestStore remembers the payload digests of archived records, so that a Writer can
+// replace later captures of the same payload with revisit records.
+type DigestStore interface {
+	// Lookup returns the entry stored for a payload digest, if any
+	Lookup(digest string) (DigestEntry, bool, error)
+	// Store records the entry for a payload digest
+	Store(digest string, entry DigestEntry) error
+}
+
+// MemoryDigestStore is a DigestStore held in memory. It is safe for concurrent use.
+type MemoryDigestStore struct {
+	mu      sync.RWMutex
+	entries map[string]DigestEntry
+}
+
+// NewMemoryDigestStore returns an empty MemoryDigestStore.
+func NewMemoryDigestStore() *MemoryDigestStore {
+	return &MemoryDigestStore{entries: make(map[string]DigestEntry)}
+}
+
+// Lookup returns the entry stored for digest, if any.
+func (s *MemoryDigestStore) Lookup(digest string) (DigestEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[digest]
+	return entry, ok, nil
+}
+
+// Store records entry for digest.
+func (s *MemoryDigestStore) Store(digest string, entry DigestEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[digest] = entry
+	return nil
+}
+
+// FileDigestStore is a DigestStore persisted in an append-only file of tab-separated
+// lines. The file is loaded into memory when opened. It is safe for concurrent use.
+type FileDigestStore struct {
+	memory *MemoryDigestStore
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileDigestStore opens the digest store at path, creating it if needed. A last line
+// without line feed, left by a crash while appending it, is removed from the file.
+func OpenFileDigestStore(path string) (*FileDigestStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	memory := NewMemoryDigestStore()
+	br := bufio.NewReader(file)
+	var offset int64
+	for line := 1; ; line++ {
+		text, err := br.ReadString('\n')
+		if err == io.EOF {
+			if text != "" {
+				// A line cut short by a crash while appending: drop it, so that the next
+				// entry starts on a line of its own
+				if err := file.Truncate(offset); err != nil {
+					file.Close()
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		offset += int64(len(text))
+		fields := strings.Split(strings.TrimSuffix(text, "\n"), "\t")
+		if len(fields) != 4 {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: invalid digest entry", path, line)
+		}
+		date, err := time.Parse(time.RFC3339Nano, fields[2])
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: invalid date: %v", path, line, err)
+		}
+		memory.entries[fields[0]] = DigestEntry{RecordID: fields[1], Date: date, TargetURI: fields[3]}
+	}
+
+	return &FileDigestStore{memory: memory, file: file}, nil
+}
+
+// Lookup returns the entry stored for digest, if any.
+func (s *FileDigestStore) Lookup(digest string) (DigestEntry, bool, error) {
+	return s.memory.Lookup(digest)
+}
+
+// Store records entry for digest and appends it to the file.
+func (s *FileDigestStore) Store(digest string, entry DigestEntry) error {
+	for _, field := range []string{digest, entry.RecordID, entry.TargetURI} {
+		if strings.ContainsAny(field, "\t\n") {
+			return fmt.Errorf("digest entry field %q contains a tab or newline", field)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line := digest + "\t" + entry.RecordID + "\t" + entry.Date.Format(time.RFC3339Nano) + "\t" + entry.TargetURI + "\n"
+	if _, err := io.WriteString(s.file, line); err != nil {
+		return err
+	}
+	return s.memory.Store(digest, entry)
+}
+
+// Close closes the file backing the store.
+func (s *FileDigestStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// emptyPayloadDigest is the payload digest of records without payload
+var emptyPayloadDigest = Digest(nil)
+
+// deduplicate returns the record to write in place of record: a revisit record when
+// its payload has been archived before, otherwise record itself. Empty payloads are not
+// deduplicated, as a revisit would save nothing. The returned func
+// remembers the payload once the record has been written.
+func (w *Writer) deduplicate(record *Record) (*Record, func() error, error) {
+	noop := func() error { return nil }
+	if w.DigestStore == nil || record.Type != WARCTypeResponse || record.PayloadDigest == "" || record.Truncated != "" ||
+		strings.EqualFold(record.PayloadDigest, emptyPayloadDigest) {
+		return record, noop, nil
+	}
+
+	original, found, err := w.DigestStore.Lookup(record.PayloadDigest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		store := func() error {
+			return w.DigestStore.Store(record.PayloadDigest, DigestEntry{
+				RecordID:  record.RecordID,
+				TargetURI: record.TargetURI,
+				Date:      record.Date,
+			})
+		}
+		return record, store, nil
+	}
+
+	var head []byte
+	if strings.HasPrefix(record.ContentType, "application/http") {
+		if head, err = readHTTPHead(bufio.NewReader(io.LimitReader(record.Block, int64(record.ContentLength)))); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	revisit := &Record{WARCRecord: record.WARCRecord, Block: bytes.NewReader(head)}
+	revisit.Type = WARCTypeRevisit
+	revisit.ContentLength = uint64(len(head))
+	revisit.BlockDigest = Digest(head)
+	revisit.RefersTo = original.RecordID
+	if revisit.Version == WARCVariant1_0 {
+		revisit.Profile = ProfileIdenticalPayloadDigest1_0
+	} else {
+		revisit.Profile = ProfileIdenticalPayloadDigest1_1
+		revisit.RefersToTargetURI = original.TargetURI
+		revisit.RefersToDate = original.Date
+	}
+	return revisit, noop, nil
+}
+
+// readHTTPHead reads the status line and header fields of an HTTP message,
+// up to and including the blank line that ends them. The rest of the message is left in br.
+func readHTTPHead(br *bufio.Reader) ([]byte, error) {
+	var head bytes.Buffer
+	partial := false
+	for {
+		line, err := br.ReadSlice('\n')
+		head.Write(line)
+		switch {
+		case err == bufio.ErrBufferFull:
+			partial = true
+			continue
+		case err == io.EOF:
+			return head.Bytes(), nil
+		case err != nil:
+			return nil, err
+		}
+		if !partial && len(bytes.TrimRight(line, "\r\n")) == 0 {
+			return head.Bytes(), nil
+		}
+		partial = false
+	}
+}
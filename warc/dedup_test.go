@@ -0,0 +1,182 @@
+package warc_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func httpResponseRecord(uri string, date time.Time, payload string) *WARCRecord {
+	return &WARCRecord{
+		Version:       WARCVariant1_1,
+		Type:          WARCTypeResponse,
+		Date:          date,
+		TargetURI:     uri,
+		ContentType:   ContentTypeHTTPResponse,
+		PayloadDigest: Digest([]byte(payload)),
+		Content:       []byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n" + payload),
+	}
+}
+
+func TestWriterDeduplication(t *testing.T) {
+	first := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	stores := map[string]func(t *testing.T) DigestStore{
+		"memory": func(t *testing.T) DigestStore {
+			return NewMemoryDigestStore()
+		},
+		"file": func(t *testing.T) DigestStore {
+			store, err := OpenFileDigestStore(filepath.Join(t.TempDir(), "digests.tsv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.DigestStore = newStore(t)
+
+			original := httpResponseRecord("http://example.com/a", first, "same payload")
+			duplicate := httpResponseRecord("http://example.com/b", second, "same payload")
+			different := httpResponseRecord("http://example.com/c", second, "other payload")
+			for _, record := range []*WARCRecord{original, duplicate, different} {
+				if err := w.WriteRecord(record); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			records := readRecords(t, buf.Bytes())
+			if len(records) != 3 {
+				t.Fatalf("got %d records, want 3", len(records))
+			}
+			if records[0].Type != WARCTypeResponse || records[2].Type != WARCTypeResponse {
+				t.Errorf("types = %v, %v, want response", records[0].Type, records[2].Type)
+			}
+
+			revisit := records[1]
+			if revisit.Type != WARCTypeRevisit {
+				t.Fatalf("Type = %v, want revisit", revisit.Type)
+			}
+			if revisit.Profile != ProfileIdenticalPayloadDigest1_1 {
+				t.Errorf("Profile = %v", revisit.Profile)
+			}
+			if revisit.RefersTo != records[0].RecordID {
+				t.Errorf("RefersTo = %v, want %v", revisit.RefersTo, records[0].RecordID)
+			}
+			if revisit.RefersToTargetURI != "http://example.com/a" {
+				t.Errorf("RefersToTargetURI = %v", revisit.RefersToTargetURI)
+			}
+			if !revisit.RefersToDate.Equal(first) {
+				t.Errorf("RefersToDate = %v, want %v", revisit.RefersToDate, first)
+			}
+			if revisit.TargetURI != "http://example.com/b" {
+				t.Errorf("TargetURI = %v", revisit.TargetURI)
+			}
+			wantBlock := "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n"
+			if string(revisit.Content) != wantBlock {
+				t.Errorf("block = %q, want %q", revisit.Content, wantBlock)
+			}
+			if revisit.BlockDigest != Digest([]byte(wantBlock)) {
+				t.Errorf("BlockDigest = %v", revisit.BlockDigest)
+			}
+			if revisit.PayloadDigest != Digest([]byte("same payload")) {
+				t.Errorf("PayloadDigest = %v", revisit.PayloadDigest)
+			}
+		})
+	}
+}
+
+func TestFileDigestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.tsv")
+	store, err := OpenFileDigestStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := DigestEntry{
+		RecordID:  "<urn:uuid:1>",
+		TargetURI: "http://example.com/",
+		Date:      time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+	if err := store.Store("sha1:AAAA", entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenFileDigestStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, found, err := reopened.Lookup("sha1:AAAA")
+	if err != nil || !found {
+		t.Fatalf("Lookup() = %v, %v, %v", got, found, err)
+	}
+	if got.RecordID != entry.RecordID || got.TargetURI != entry.TargetURI || !got.Date.Equal(entry.Date) {
+		t.Errorf("Lookup() = %+v, want %+v", got, entry)
+	}
+	if _, found, _ := reopened.Lookup("sha1:BBBB"); found {
+		t.Errorf("Lookup() found an unknown digest")
+	}
+}
+
+func TestFileDigestStorePartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.tsv")
+	data := "sha1:AAAA\t<urn:uuid:1>\t2024-01-01T10:00:00Z\thttp://example.com/a\n" +
+		"sha1:BBBB\t<urn:uuid:2>\t2024-01-01T"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenFileDigestStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Lookup("sha1:AAAA"); !found {
+		t.Errorf("Lookup() did not find the complete entry")
+	}
+	if _, found, _ := store.Lookup("sha1:BBBB"); found {
+		t.Errorf("Lookup() found the partial entry")
+	}
+	entry := DigestEntry{RecordID: "<urn:uuid:3>", TargetURI: "http://example.com/c", Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if err := store.Store("sha1:CCCC", entry); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	reopened, err := OpenFileDigestStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got, found, _ := reopened.Lookup("sha1:CCCC"); !found || got.RecordID != entry.RecordID {
+		t.Errorf("Lookup() = %+v, %v after appending past a partial line", got, found)
+	}
+}
+
+func TestWriterDeduplicationEmptyPayload(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.DigestStore = NewMemoryDigestStore()
+	for _, uri := range []string{"http://example.com/a", "http://example.com/b"} {
+		if err := w.WriteRecord(httpResponseRecord(uri, date, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, record := range readRecords(t, buf.Bytes()) {
+		if record.Type != WARCTypeResponse {
+			t.Errorf("%s written as %s, want response", record.TargetURI, record.Type)
+		}
+	}
+}
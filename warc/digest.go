@@ -0,0 +1,208 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DigestAlgorithm is the labelled algorithm used for block and payload digests
+const DigestAlgorithm = "sha1"
+
+// NewRecordID returns a new random "<urn:uuid:...>" record identifier
+func NewRecordID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Sprintf("warc: failed to generate record id: %v", err))
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// Digest returns the labelled digest of data, e.g. "sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ"
+func Digest(data []byte) string {
+	h := newDigest()
+	h.Write(data)
+	return formatDigest(h)
+}
+
+func newDigest() hash.Hash {
+	return sha1.New()
+}
+
+func formatDigest(h hash.Hash) string {
+	return DigestAlgorithm + ":" + base32.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// payloadHash hashes the payload of an HTTP message as the bytes following its head are
+// written to it. The payload is the body with its chunked transfer coding removed, as
+// net/http hands it over, so that records of the same payload get the same digest however
+// it was framed. A chunked body cut short is hashed as far as it goes; one whose framing
+// is invalid is hashed as it is.
+type payloadHash struct {
+	raw, decoded hash.Hash
+	chunked      bool
+	invalid      bool
+
+	state chunkState
+	line  []byte
+	left  uint64
+}
+
+// chunkState is the part of a chunked body a payloadHash is reading
+type chunkState int
+
+const (
+	chunkSize chunkState = iota
+	chunkData
+	chunkEnd
+	chunkTrailer
+)
+
+// maxChunkLine bounds the chunk-size line, extensions included
+const maxChunkLine = 4096
+
+// newPayloadHash returns a payloadHash for a body, chunked or not.
+func newPayloadHash(chunked bool) *payloadHash {
+	return &payloadHash{raw: newDigest(), decoded: newDigest(), chunked: chunked}
+}
+
+func (h *payloadHash) Write(p []byte) (int, error) {
+	h.raw.Write(p)
+	if !h.chunked || h.invalid {
+		return len(p), nil
+	}
+	for rest := p; len(rest) > 0 && !h.invalid; {
+		switch h.state {
+		case chunkSize:
+			i := bytes.IndexByte(rest, '\n')
+			if i < 0 {
+				i = len(rest)
+			}
+			h.line = append(h.line, rest[:i]...)
+			if size, _, _ := bytes.Cut(h.line, []byte(";")); len(h.line) > maxChunkLine ||
+				len(bytes.Trim(size, "0123456789abcdefABCDEF \t\r")) > 0 {
+				h.invalid = true
+			} else if i < len(rest) {
+				h.startChunk()
+				i++
+			}
+			rest = rest[i:]
+		case chunkData:
+			n := uint64(len(rest))
+			if n > h.left {
+				n = h.left
+			}
+			h.decoded.Write(rest[:n])
+			rest = rest[n:]
+			if h.left -= n; h.left == 0 {
+				h.state = chunkEnd
+			}
+		case chunkEnd:
+			switch rest[0] {
+			case '\r':
+			case '\n':
+				h.state = chunkSize
+			default:
+				h.invalid = true
+			}
+			rest = rest[1:]
+		case chunkTrailer:
+			rest = nil
+		}
+	}
+	return len(p), nil
+}
+
+// startChunk parses the chunk-size line read.
+func (h *payloadHash) startChunk() {
+	size, _, _ := strings.Cut(string(bytes.TrimRight(h.line, "\r")), ";")
+	h.line = h.line[:0]
+	n, err := strconv.ParseUint(strings.TrimSpace(size), 16, 64)
+	switch {
+	case err != nil:
+		h.invalid = true
+	case n == 0:
+		h.state = chunkTrailer
+	default:
+		h.state, h.left = chunkData, n
+	}
+}
+
+// digest returns the labelled digest of the payload written.
+func (h *payloadHash) digest() string {
+	if h.chunked && !h.invalid {
+		return formatDigest(h.decoded)
+	}
+	return formatDigest(h.raw)
+}
+
+// DigestPayload returns the labelled digest of the payload of an HTTP message, read from
+// body, the bytes following its head. The chunked transfer coding of a body is removed
+// as it is for the payload digests of the records this package writes.
+func DigestPayload(body io.Reader, chunked bool) (string, error) {
+	h := newPayloadHash(chunked)
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return h.digest(), nil
+}
+
+// isChunked reports whether the HTTP message whose head is given has a chunked body.
+func isChunked(head []byte) bool {
+	lines := strings.Split(string(head), "\n")
+	for _, line := range lines[1:] {
+		name, value, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "Transfer-Encoding") {
+			continue
+		}
+		codings := strings.Split(value, ",")
+		return strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+	}
+	return false
+}
+
+// DigestRecord reads the block of record and returns a copy of it with BlockDigest set, and
+// PayloadDigest too for application/http records, whose payload is what follows the HTTP
+// header, with its chunked transfer coding removed. The block is buffered in memory up to
+// DefaultMaxMemory bytes and in a temporary file beyond; the copy's Block replays it until
+// the returned func releases it.
+func DigestRecord(record *Record) (*Record, func(), error) {
+	block := bufio.NewReader(io.LimitReader(record.Block, int64(record.ContentLength)))
+	spool := newSpool(DefaultMaxMemory, "")
+	blockHash := newDigest()
+	var head []byte
+	if strings.HasPrefix(record.ContentType, "application/http") {
+		var err error
+		if head, err = readHTTPHead(block); err != nil {
+			return nil, nil, err
+		}
+		spool.Write(head)
+		blockHash.Write(head)
+	}
+	payloadHash := newPayloadHash(isChunked(head))
+	if _, err := io.Copy(io.MultiWriter(spool, blockHash, payloadHash), block); err != nil {
+		spool.Close()
+		return nil, nil, err
+	}
+	if uint64(spool.Len()) != record.ContentLength {
+		spool.Close()
+		return nil, nil, fmt.Errorf("record %s block is %d bytes, want %d", record.RecordID, spool.Len(), record.ContentLength)
+	}
+
+	digested := *record
+	digested.BlockDigest = formatDigest(blockHash)
+	if strings.HasPrefix(record.ContentType, "application/http") {
+		digested.PayloadDigest = payloadHash.digest()
+	}
+	digested.Block = spool.Reader()
+	return &digested, func() { spool.Close() }, nil
+}
@@ -0,0 +1,64 @@
+package warc_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestDigestRecord(t *testing.T) {
+	head := "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n"
+	chunked := "HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip, chunked\r\n\r\n"
+	tests := []struct {
+		name        string
+		contentType string
+		block       string
+		payload     string
+	}{
+		{"http", "application/http; msgtype=response", head + "Hello", "Hello"},
+		{"http without payload", "application/http; msgtype=response", head, ""},
+		{"chunked", "application/http; msgtype=response", chunked + "5;ext=1\r\nHello\r\n7\r\n, world\r\n0\r\nExpires: 0\r\n\r\n", "Hello, world"},
+		{"chunked with bare line feeds", "application/http; msgtype=response", chunked + "5\nHello\n0\n\n", "Hello"},
+		{"chunked cut short", "application/http; msgtype=response", chunked + "c\r\nHello", "Hello"},
+		{"invalid chunked", "application/http; msgtype=response", chunked + "Hello", "Hello"},
+		{"large", "text/plain", strings.Repeat("a", DefaultMaxMemory+1), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &Record{
+				WARCRecord: WARCRecord{ContentType: tt.contentType, ContentLength: uint64(len(tt.block))},
+				Block:      strings.NewReader(tt.block),
+			}
+			digested, release, err := DigestRecord(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer release()
+			if digested.BlockDigest != Digest([]byte(tt.block)) {
+				t.Errorf("BlockDigest = %s, want %s", digested.BlockDigest, Digest([]byte(tt.block)))
+			}
+			wantPayload := ""
+			if strings.HasPrefix(tt.contentType, "application/http") {
+				wantPayload = Digest([]byte(tt.payload))
+			}
+			if digested.PayloadDigest != wantPayload {
+				t.Errorf("PayloadDigest = %s, want %s", digested.PayloadDigest, wantPayload)
+			}
+			block, err := io.ReadAll(digested.Block)
+			if err != nil || string(block) != tt.block {
+				t.Errorf("Block replays %d bytes, %v; want %d bytes", len(block), err, len(tt.block))
+			}
+			if record.BlockDigest != "" {
+				t.Errorf("DigestRecord() changed the original record")
+			}
+		})
+	}
+
+	short := &Record{WARCRecord: WARCRecord{ContentLength: 10}, Block: strings.NewReader("Hello")}
+	if _, _, err := DigestRecord(short); err == nil {
+		t.Errorf("DigestRecord() of a short block = nil, want an error")
+	}
+}
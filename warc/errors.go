@@ -0,0 +1,92 @@
+package warc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCategory classifies the errors reported by a ParseError.
+type ErrorCategory int
+
+const (
+	// CategorySyntax is malformed input, such as a header line without a colon or a block
+	// shorter than its Content-Length
+	CategorySyntax ErrorCategory = iota
+	// CategoryLimits is input exceeding Limits
+	CategoryLimits
+	// CategoryDigest is a block not matching its WARC-Block-Digest
+	CategoryDigest
+	// CategoryVersion is a record of an unsupported WARC version
+	CategoryVersion
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case CategorySyntax:
+		return "syntax"
+	case CategoryLimits:
+		return "limits"
+	case CategoryDigest:
+		return "digest"
+	case CategoryVersion:
+		return "version"
+	}
+	return fmt.Sprintf("ErrorCategory(%d)", int(c))
+}
+
+// ParseError reports a record that could not be parsed. It is returned by Unmarshal, Valid
+// and Reader, and wraps the underlying error, such as a *LimitError or io.ErrUnexpectedEOF.
+type ParseError struct {
+	Category ErrorCategory
+	// Offset is the offset of the record in its input; Unmarshal and Valid parse a
+	// record at offset 0
+	Offset int64
+	// Line is the line of the header at fault, counting the version line as 1, or 0
+	// when the error is not in a header line
+	Line int
+	// RecordID is the WARC-Record-ID of the record, when known
+	RecordID string
+	// Field is the name of the header field at fault, if any
+	Field string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	b.WriteString("record ")
+	if e.RecordID != "" {
+		b.WriteString(e.RecordID + " ")
+	}
+	fmt.Fprintf(&b, "at offset %d", e.Offset)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ", line %d", e.Line)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, ", %s", e.Field)
+	}
+	fmt.Fprintf(&b, ": %s error: %v", e.Category, e.Err)
+	return b.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// headerError returns a *ParseError for err, met while reading header line h.line. Errors
+// wrapping a *LimitError are in CategoryLimits.
+func (h *headerReader) headerError(category ErrorCategory, err error) *ParseError {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		category = CategoryLimits
+	}
+	return &ParseError{Category: category, Offset: h.offset, Line: h.line, RecordID: h.recordID, Err: err}
+}
+
+// fieldError returns a *ParseError for err, met in the value of the named header field.
+func (h *headerReader) fieldError(category ErrorCategory, field string, err error) *ParseError {
+	e := h.headerError(category, err)
+	e.Line = 0
+	e.Field = field
+	return e
+}
@@ -0,0 +1,123 @@
+package warc_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestParseErrors(t *testing.T) {
+	valid := rawRecord("1.1", []string{"WARC-Type: resource", "WARC-Record-ID: <urn:uuid:1>"}, "Hello")
+	tests := []struct {
+		name  string
+		input string
+		want  ParseError
+	}{
+		{
+			name:  "unsupported version",
+			input: rawRecord("2.0", []string{"WARC-Type: resource"}, ""),
+			want:  ParseError{Category: CategoryVersion, Line: 1},
+		},
+		{
+			name:  "invalid header line",
+			input: rawRecord("1.1", []string{"WARC-Record-ID: <urn:uuid:1>", "WARC-Type resource"}, ""),
+			want:  ParseError{Category: CategorySyntax, Line: 3, RecordID: "<urn:uuid:1>"},
+		},
+		{
+			name:  "invalid Content-Length",
+			input: rawRecord("1.1", []string{"WARC-Record-ID: <urn:uuid:1>", "Content-Length: -1"}, ""),
+			want:  ParseError{Category: CategorySyntax, RecordID: "<urn:uuid:1>", Field: "Content-Length"},
+		},
+		{
+			name:  "missing block",
+			input: strings.TrimSuffix(rawRecord("1.1", []string{"WARC-Record-ID: <urn:uuid:1>", "Content-Length: 100"}, ""), "\r\n\r\n"),
+			want:  ParseError{Category: CategorySyntax, RecordID: "<urn:uuid:1>", Field: "Content-Length"},
+		},
+		{
+			name:  "too many fields",
+			input: rawRecord("1.1", append([]string{"WARC-Record-ID: <urn:uuid:1>"}, strings.Split(strings.Repeat("X: y\n", 1100), "\n")[:1100]...), ""),
+			want:  ParseError{Category: CategoryLimits, Line: 1026, RecordID: "<urn:uuid:1>"},
+		},
+	}
+
+	check := func(t *testing.T, what string, err error, want ParseError) {
+		t.Helper()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%s error = %v, want a *ParseError", what, err)
+		}
+		if parseErr.Category != want.Category || parseErr.Offset != want.Offset || parseErr.Line != want.Line ||
+			parseErr.RecordID != want.RecordID || parseErr.Field != want.Field {
+			t.Errorf("%s error = %+v, want %+v", what, *parseErr, want)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record WARCRecord
+			check(t, "Unmarshal()", Unmarshal([]byte(tt.input), &record), tt.want)
+			check(t, "Valid()", Valid([]byte(tt.input)), tt.want)
+
+			// The reader finds the same error in a record following a valid one
+			reader, err := NewReader(strings.NewReader(valid + tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := reader.Next(); err != nil {
+				t.Fatal(err)
+			}
+			record2, err := reader.Next()
+			if err == nil {
+				_, err = io.ReadAll(record2.Block)
+			}
+			want := tt.want
+			want.Offset = int64(len(valid))
+			check(t, "Reader", err, want)
+		})
+	}
+	// Fields of the block are checked as those of the header
+	metadata := rawRecord("1.1", []string{"WARC-Type: metadata", "WARC-Record-ID: <urn:uuid:1>",
+		"Content-Type: application/warc-fields"}, "fetchTimeMs: soon\r\n")
+	var record MetadataRecord
+	check(t, "Unmarshal() of metadata", Unmarshal([]byte(metadata), &record),
+		ParseError{Category: CategorySyntax, RecordID: "<urn:uuid:1>", Field: "fetchTimeMs"})
+}
+
+func TestReaderVerifyDigests(t *testing.T) {
+	var buf bytes.Buffer
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := NewWriter(&buf).WriteRecord(httpResponseRecord("http://example.com/", date, "Hello")); err != nil {
+		t.Fatal(err)
+	}
+	corrupted := bytes.Replace(buf.Bytes(), []byte("Hello"), []byte("Jello"), 1)
+
+	for _, verify := range []bool{false, true} {
+		reader, err := NewReader(bytes.NewReader(corrupted))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader.VerifyDigests = verify
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.ReadAll(record.Block)
+		var parseErr *ParseError
+		if got := errors.As(err, &parseErr) && parseErr.Category == CategoryDigest && parseErr.Field == "WARC-Block-Digest"; got != verify {
+			t.Errorf("VerifyDigests = %v: ReadAll() error = %v", verify, err)
+		}
+	}
+}
+
+func TestParseErrorString(t *testing.T) {
+	err := &ParseError{Category: CategoryLimits, Offset: 12, Line: 3, RecordID: "<urn:uuid:1>", Err: &LimitError{Limit: "MaxHeaderFields", Max: 2}}
+	want := "record <urn:uuid:1> at offset 12, line 3: limits error: MaxHeaderFields of 2 exceeded"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
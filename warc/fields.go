@@ -0,0 +1,91 @@
+package warc
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ContentTypeWARCFields is the content type of blocks made of named fields, as in warcinfo and metadata records
+const ContentTypeWARCFields = "application/warc-fields"
+
+// Field is a named field of a record header.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Fields returns the named fields of the record header, in order: as they appeared for a
+// record read by Reader, and as they would be written otherwise.
+func (r *Record) Fields() []Field {
+	var fields []Field
+	for _, f := range recordFields(r) {
+		fields = append(fields, Field{f.name, f.value})
+	}
+	return fields
+}
+
+// warcField is a single "name: value" line of an application/warc-fields block.
+type warcField struct {
+	name  string
+	value string
+}
+
+// mergeFields updates the fields block content with fields. Lines naming one of fields
+// take its value, or are dropped when the value is empty; fields not yet present are
+// appended. All other lines are kept as they are.
+func mergeFields(content []byte, fields []warcField) []byte {
+	pending := make(map[string]string, len(fields))
+	for _, f := range fields {
+		pending[f.name] = f.value
+	}
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		name, _, found := strings.Cut(string(line), ":")
+		name = strings.TrimSpace(name)
+		value, known := pending[name]
+		if !found || !known {
+			buf.Write(line)
+			if line[len(line)-1] != '\n' {
+				buf.WriteString("\r\n")
+			}
+			continue
+		}
+		delete(pending, name)
+		if value != "" {
+			buf.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := pending[f.name]; ok && value != "" {
+			buf.WriteString(f.name + ": " + value + "\r\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// replaceField replaces the lines of the fields block content naming name, a field that
+// may be repeated, with one line for each of values, appended after all other lines.
+func replaceField(content []byte, name string, values []string) []byte {
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if field, _, found := strings.Cut(string(line), ":"); found && strings.TrimSpace(field) == name {
+			continue
+		}
+		buf.Write(line)
+		if line[len(line)-1] != '\n' {
+			buf.WriteString("\r\n")
+		}
+	}
+	for _, value := range values {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	return buf.Bytes()
+}
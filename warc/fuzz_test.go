@@ -0,0 +1,98 @@
+package warc_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+// fuzzLimits keeps the memory a fuzzed input may claim small.
+var fuzzLimits = Limits{
+	MaxHeaderLineLength: 1 << 10,
+	MaxHeaderFields:     64,
+	MaxRecordSize:       1 << 20,
+	MaxMemory:           1 << 20,
+}
+
+// addSeeds adds records written by gwarc, plain and compressed, and edge cases to the corpus of f.
+func addSeeds(f *testing.F) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.MaxRecordSize = 8
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	info := &WarcInfoRecord{WARCRecord: WARCRecord{Date: date}}
+	info.Software = "gwarc"
+	if err := w.WriteRecords(info, httpResponseRecord("http://example.com/", date, "a segmented payload")); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(buf.Bytes())
+	gz.Close()
+	f.Add(compressed.Bytes())
+
+	var zstdCompressed bytes.Buffer
+	zw := NewZstdWriter(&zstdCompressed, []byte("WARC/1.1\r\nWARC-Type: "))
+	if err := zw.WriteRecords(info, httpResponseRecord("http://example.com/", date, "a payload")); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(zstdCompressed.Bytes())
+
+	f.Add([]byte(rawRecord("1.0", []string{"WARC-Type: resource", "X-Folded: a", " b"}, "Hello")))
+	f.Add([]byte(rawRecord("1.1", []string{"Content-Length: 99999999999999999999"}, "")))
+	f.Add([]byte(rawRecord("1.1", []string{"Content-Length: -5"}, "")))
+	f.Add([]byte("WARC/1.1\r\n"))
+	f.Add([]byte{})
+}
+
+func FuzzReader(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, reassemble := range []bool{false, true} {
+			reader, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				return
+			}
+			reader.Reassemble = reassemble
+			reader.Limits = fuzzLimits
+			for i := 0; i < 100; i++ {
+				record, err := reader.Next()
+				if err != nil {
+					break
+				}
+				if record.ContentLength > uint64(fuzzLimits.MaxRecordSize) {
+					t.Fatalf("record of %d bytes accepted", record.ContentLength)
+				}
+				if _, err := io.Copy(io.Discard, record.Block); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var record WARCRecord
+		UnmarshalWithLimits(data, &record, fuzzLimits)
+		var info WarcInfoRecord
+		UnmarshalWithLimits(data, &info, fuzzLimits)
+		var metadata MetadataRecord
+		UnmarshalWithLimits(data, &metadata, fuzzLimits)
+		Valid(data)
+	})
+}
+
+func FuzzValidateReader(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ValidateReader(bytes.NewReader(data))
+	})
+}
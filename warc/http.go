@@ -0,0 +1,321 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenless-lab/gwarc/internal/zstd"
+)
+
+const (
+	// ContentTypeHTTPRequest is the content type of request records holding an HTTP message
+	ContentTypeHTTPRequest = "application/http; msgtype=request"
+	// ContentTypeHTTPResponse is the content type of response records holding an HTTP message
+	ContentTypeHTTPResponse = "application/http; msgtype=response"
+)
+
+// httpMessage is the serialized head of an HTTP message and the framing of its body.
+type httpMessage struct {
+	head    []byte
+	chunked bool
+	trailer http.Header
+}
+
+// size returns the length of the message when it carries a body of bodyLen bytes.
+func (m httpMessage) size(bodyLen int64) int64 {
+	n := int64(len(m.head)) + bodyLen
+	if m.chunked {
+		n += int64(len(m.chunkPrefix(bodyLen))) + int64(len(m.chunkSuffix(bodyLen)))
+	}
+	return n
+}
+
+// reader returns the message with a body of bodyLen bytes read from body.
+func (m httpMessage) reader(body io.Reader, bodyLen int64) io.Reader {
+	if !m.chunked {
+		return io.MultiReader(bytes.NewReader(m.head), io.LimitReader(body, bodyLen))
+	}
+	return io.MultiReader(
+		bytes.NewReader(m.head),
+		strings.NewReader(m.chunkPrefix(bodyLen)),
+		io.LimitReader(body, bodyLen),
+		strings.NewReader(m.chunkSuffix(bodyLen)),
+	)
+}
+
+// chunkPrefix returns the chunk-size line a chunked body of bodyLen bytes is sent in.
+func (m httpMessage) chunkPrefix(bodyLen int64) string {
+	if bodyLen == 0 {
+		return ""
+	}
+	return strconv.FormatInt(bodyLen, 16) + "\r\n"
+}
+
+// chunkSuffix returns the end of the chunk, the last-chunk and the trailer section.
+func (m httpMessage) chunkSuffix(bodyLen int64) string {
+	var b strings.Builder
+	if bodyLen > 0 {
+		b.WriteString("\r\n")
+	}
+	b.WriteString("0\r\n")
+	m.trailer.Write(&b)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// block returns the whole message with body as its payload.
+func (m httpMessage) block(body []byte) []byte {
+	block, _ := io.ReadAll(m.reader(bytes.NewReader(body), int64(len(body))))
+	return block
+}
+
+// requestMessage serializes the head of req as it goes out on the wire.
+// header overrides req.Header when the header fields actually sent are known.
+func requestMessage(req *http.Request, header http.Header, bodyLen int64) httpMessage {
+	var buf bytes.Buffer
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	proto := "HTTP/1.1"
+	if req.ProtoMajor == 1 && req.Proto != "" {
+		proto = req.Proto
+	}
+	fmt.Fprintf(&buf, "%s %s %s\r\n", method, req.URL.RequestURI(), proto)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&buf, "Host: %s\r\n", host)
+
+	chunked := len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked"
+	if header == nil {
+		header = req.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		// Mirror the fields net/http adds to client requests
+		if _, ok := header["User-Agent"]; !ok && req.RequestURI == "" {
+			header.Set("User-Agent", "Go-http-client/1.1")
+		}
+		if header.Get("Content-Length") == "" && !chunked {
+			switch {
+			case req.ContentLength > 0:
+				header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+			case bodyLen > 0:
+				chunked = true
+			case method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch:
+				header.Set("Content-Length", "0")
+			}
+		}
+	} else if header.Get("Content-Length") == "" && bodyLen > 0 {
+		chunked = true
+	}
+	header.Del("Host")
+	header.Del("Transfer-Encoding")
+	if chunked {
+		header.Set("Transfer-Encoding", "chunked")
+	}
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	return httpMessage{head: buf.Bytes(), chunked: chunked}
+}
+
+// responseMessage serializes the head of resp as it came off the wire.
+func responseMessage(resp *http.Response) httpMessage {
+	var buf bytes.Buffer
+
+	major, minor := resp.ProtoMajor, resp.ProtoMinor
+	if major == 0 {
+		major, minor = 1, 1
+	}
+	status := strconv.Itoa(resp.StatusCode)
+	if text := strings.TrimPrefix(resp.Status, status+" "); text != "" && text != resp.Status {
+		status += " " + text
+	} else if text := http.StatusText(resp.StatusCode); text != "" {
+		status += " " + text
+	}
+	fmt.Fprintf(&buf, "HTTP/%d.%d %s\r\n", major, minor, status)
+
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	chunked := len(resp.TransferEncoding) > 0 && resp.TransferEncoding[0] == "chunked"
+	header.Del("Transfer-Encoding")
+	if chunked {
+		// net/http moves Transfer-Encoding out of the header map
+		header.Set("Transfer-Encoding", "chunked")
+	} else if resp.ContentLength >= 0 && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+
+	return httpMessage{head: buf.Bytes(), chunked: chunked, trailer: resp.Trailer}
+}
+
+// requestTargetURI returns the absolute URI req was sent to.
+func requestTargetURI(req *http.Request) string {
+	if req.URL.IsAbs() {
+		return req.URL.String()
+	}
+	u := *req.URL
+	u.Scheme = "http"
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	return u.String()
+}
+
+// readBody reads an HTTP body in full and returns a replacement that replays it.
+func readBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	if body == nil || body == http.NoBody {
+		return nil, body, nil
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// NewRequestRecord creates a request record holding req.
+// The body is taken from req.GetBody when set; otherwise it is read in full and
+// replaced with an in-memory copy, so req can still be sent.
+func NewRequestRecord(req *http.Request) (*WARCRecord, error) {
+	var body []byte
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		if body, _, err = readBody(rc); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+	} else {
+		data, replay, err := readBody(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		if replay != nil && replay != http.NoBody {
+			body = data
+			req.Body = replay
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+		}
+	}
+
+	content := requestMessage(req, nil, int64(len(body))).block(body)
+	return &WARCRecord{
+		Version:       WARCVariant1_1,
+		RecordID:      NewRecordID(),
+		ContentLength: uint64(len(content)),
+		Date:          time.Now().UTC(),
+		Type:          WARCTypeRequest,
+		ContentType:   ContentTypeHTTPRequest,
+		BlockDigest:   Digest(content),
+		PayloadDigest: Digest(body),
+		TargetURI:     requestTargetURI(req),
+		Content:       content,
+	}, nil
+}
+
+// NewResponseRecord creates a response record holding resp.
+// The response body is read in full and replaced with an in-memory copy, so the caller can still consume it.
+func NewResponseRecord(resp *http.Response) (*WARCRecord, error) {
+	body, replay, err := readBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if replay != nil {
+		resp.Body = replay
+	}
+
+	content := responseMessage(resp).block(body)
+	record := &WARCRecord{
+		Version:       WARCVariant1_1,
+		RecordID:      NewRecordID(),
+		ContentLength: uint64(len(content)),
+		Date:          time.Now().UTC(),
+		Type:          WARCTypeResponse,
+		ContentType:   ContentTypeHTTPResponse,
+		BlockDigest:   Digest(content),
+		PayloadDigest: Digest(body),
+		Content:       content,
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		record.TargetURI = requestTargetURI(resp.Request)
+	}
+	return record, nil
+}
+
+// NewHTTPRecords creates a linked request and response record pair for one HTTP exchange.
+// ipAddress is the address of the server that answered; it is omitted when empty.
+func NewHTTPRecords(req *http.Request, resp *http.Response, ipAddress string) (request, response *WARCRecord, err error) {
+	if request, err = NewRequestRecord(req); err != nil {
+		return nil, nil, err
+	}
+	if response, err = NewResponseRecord(resp); err != nil {
+		return nil, nil, err
+	}
+
+	response.Date = request.Date
+	response.TargetURI = request.TargetURI
+	request.ConcurrentTo = []string{response.RecordID}
+	response.ConcurrentTo = []string{request.RecordID}
+	request.IPAddress = ipAddress
+	response.IPAddress = ipAddress
+	return request, response, nil
+}
+
+// DecodeContent returns a reader of body with the content codings listed in contentEncoding,
+// the value of a Content-Encoding header, removed in reverse order of application. It
+// supports gzip, deflate, whether zlib wrapped or raw as some servers send it, and zstd.
+func DecodeContent(body io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gzip content: %w", err)
+			}
+			body = gz
+		case "deflate":
+			br := bufio.NewReader(body)
+			if header, err := br.Peek(2); err == nil && header[0]&0x0f == 8 && (uint(header[0])<<8|uint(header[1]))%31 == 0 {
+				zr, err := zlib.NewReader(br)
+				if err != nil {
+					return nil, fmt.Errorf("invalid deflate content: %w", err)
+				}
+				body = zr
+			} else {
+				body = flate.NewReader(br)
+			}
+		case "zstd":
+			body = zstd.NewReader(body, nil)
+		default:
+			return nil, fmt.Errorf("unsupported content coding %q", coding)
+		}
+	}
+	return body, nil
+}
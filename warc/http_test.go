@@ -0,0 +1,190 @@
+package warc_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestNewHTTPRecords(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/chunked" {
+			io.WriteString(w, "part one, ")
+			w.(http.Flusher).Flush()
+		}
+		w.Write(append([]byte("echo: "), body...))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		wantChunked bool
+	}{
+		{name: "content length", path: "/plain", body: "hello"},
+		{name: "chunked", path: "/chunked", body: "hello", wantChunked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reqRecord, err := NewRequestRecord(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request, response, err := NewHTTPRecords(req, resp, "127.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The caller still sees the whole body
+			callerBody, _ := io.ReadAll(resp.Body)
+			if !strings.HasSuffix(string(callerBody), "echo: hello") {
+				t.Errorf("caller body = %q", callerBody)
+			}
+
+			if !bytes.Equal(reqRecord.Content, request.Content) {
+				t.Errorf("request record is not repeatable:\n%s\n%s", reqRecord.Content, request.Content)
+			}
+			if request.Type != WARCTypeRequest || response.Type != WARCTypeResponse {
+				t.Errorf("Type = %v/%v", request.Type, response.Type)
+			}
+			if len(request.ConcurrentTo) != 1 || request.ConcurrentTo[0] != response.RecordID {
+				t.Errorf("request ConcurrentTo = %v, want %v", request.ConcurrentTo, response.RecordID)
+			}
+			if len(response.ConcurrentTo) != 1 || response.ConcurrentTo[0] != request.RecordID {
+				t.Errorf("response ConcurrentTo = %v, want %v", response.ConcurrentTo, request.RecordID)
+			}
+			if response.TargetURI != server.URL+tt.path || request.TargetURI != response.TargetURI {
+				t.Errorf("TargetURI = %v/%v", request.TargetURI, response.TargetURI)
+			}
+			if response.IPAddress != "127.0.0.1" {
+				t.Errorf("IPAddress = %v", response.IPAddress)
+			}
+			if response.ContentType != ContentTypeHTTPResponse {
+				t.Errorf("ContentType = %v", response.ContentType)
+			}
+			if response.BlockDigest != Digest(response.Content) {
+				t.Errorf("BlockDigest = %v, want %v", response.BlockDigest, Digest(response.Content))
+			}
+			if response.ContentLength != uint64(len(response.Content)) {
+				t.Errorf("ContentLength = %v, want %v", response.ContentLength, len(response.Content))
+			}
+
+			parsedReq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request.Content)))
+			if err != nil {
+				t.Fatalf("request block is not an HTTP request: %v", err)
+			}
+			reqBody, _ := io.ReadAll(parsedReq.Body)
+			if string(reqBody) != tt.body {
+				t.Errorf("request block body = %q, want %q", reqBody, tt.body)
+			}
+			if request.PayloadDigest != Digest([]byte(tt.body)) {
+				t.Errorf("request PayloadDigest = %v", request.PayloadDigest)
+			}
+
+			parsedResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response.Content)), nil)
+			if err != nil {
+				t.Fatalf("response block is not an HTTP response: %v", err)
+			}
+			respBody, _ := io.ReadAll(parsedResp.Body)
+			if !bytes.Equal(respBody, callerBody) {
+				t.Errorf("response block body = %q, want %q", respBody, callerBody)
+			}
+			if response.PayloadDigest != Digest(callerBody) {
+				t.Errorf("response PayloadDigest = %v, want %v", response.PayloadDigest, Digest(callerBody))
+			}
+			if chunked := len(parsedResp.TransferEncoding) > 0; chunked != tt.wantChunked {
+				t.Errorf("chunked = %v, want %v", chunked, tt.wantChunked)
+			}
+			if parsedResp.Header.Get("Content-Type") != "text/plain" {
+				t.Errorf("Content-Type = %v", parsedResp.Header.Get("Content-Type"))
+			}
+
+			if _, err := Marshal(response); err != nil {
+				t.Errorf("Marshal() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewRecordID(t *testing.T) {
+	a, b := NewRecordID(), NewRecordID()
+	if a == b {
+		t.Errorf("NewRecordID() returned %v twice", a)
+	}
+	if !strings.HasPrefix(a, "<urn:uuid:") || !strings.HasSuffix(a, ">") || len(a) != 47 {
+		t.Errorf("NewRecordID() = %v", a)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	if got, want := Digest([]byte("Hello, World!")), "sha1:BIFJ6KTHOKKCKV5LKNK5O2XUIL4PMXQB"; got != want {
+		t.Errorf("Digest() = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeContent(t *testing.T) {
+	const payload = "a payload encoded twice over"
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	io.WriteString(gz, payload)
+	gz.Close()
+	encode := func(w io.WriteCloser, buf *bytes.Buffer, data []byte) []byte {
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+	var zlibbed, raw bytes.Buffer
+	flateWriter, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+	}{
+		{"identity", []byte(payload), ""},
+		{"gzip", gzipped.Bytes(), "gzip"},
+		{"zlib deflate", encode(zlib.NewWriter(&zlibbed), &zlibbed, []byte(payload)), "deflate"},
+		{"raw deflate", encode(flateWriter, &raw, []byte(payload)), "deflate"},
+	}
+	var twice bytes.Buffer
+	tests = append(tests, struct {
+		name     string
+		body     []byte
+		encoding string
+	}{"gzip then deflate", encode(zlib.NewWriter(&twice), &twice, gzipped.Bytes()), "gzip, deflate"})
+
+	for _, test := range tests {
+		r, err := DecodeContent(bytes.NewReader(test.body), test.encoding)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got, err := io.ReadAll(r); err != nil || string(got) != payload {
+			t.Errorf("%s: decoded %q, %v", test.name, got, err)
+		}
+	}
+	if _, err := DecodeContent(strings.NewReader(""), "br"); err == nil {
+		t.Error("unsupported coding accepted")
+	}
+}
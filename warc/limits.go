@@ -0,0 +1,113 @@
+package warc
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// Limits bounds the resources spent parsing records, so that input from untrusted
+// sources cannot exhaust memory. A zero field takes its value from DefaultLimits.
+type Limits struct {
+	// MaxHeaderLineLength is the longest header line accepted, in bytes including its line ending
+	MaxHeaderLineLength int
+	// MaxHeaderFields is the largest number of named fields accepted in one header
+	MaxHeaderFields int
+	// MaxRecordSize is the largest Content-Length accepted
+	MaxRecordSize int64
+	// MaxMemory is the most memory held for records at once: their headers, blocks read into
+	// memory by Unmarshal and Valid, and records a Reader holds back while reassembling segments
+	MaxMemory int64
+}
+
+// DefaultLimits are the limits applied by Unmarshal, Valid and Readers whose Limits are not set.
+var DefaultLimits = Limits{
+	MaxHeaderLineLength: 64 << 10,
+	MaxHeaderFields:     1024,
+	MaxRecordSize:       1 << 40,
+	MaxMemory:           256 << 20,
+}
+
+// withDefaults returns l with its zero fields taken from DefaultLimits.
+func (l Limits) withDefaults() Limits {
+	if l.MaxHeaderLineLength <= 0 {
+		l.MaxHeaderLineLength = DefaultLimits.MaxHeaderLineLength
+	}
+	if l.MaxHeaderFields <= 0 {
+		l.MaxHeaderFields = DefaultLimits.MaxHeaderFields
+	}
+	if l.MaxRecordSize <= 0 {
+		l.MaxRecordSize = DefaultLimits.MaxRecordSize
+	}
+	if l.MaxMemory <= 0 {
+		l.MaxMemory = DefaultLimits.MaxMemory
+	}
+	return l
+}
+
+// LimitError reports input exceeding one of the Limits.
+type LimitError struct {
+	// Limit names the field of Limits that was exceeded, such as "MaxHeaderFields"
+	Limit string
+	// Max is the value of the limit
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d exceeded", e.Limit, e.Max)
+}
+
+// headerReader reads the lines of record headers within Limits, keeping track of
+// where it is for errors.
+type headerReader struct {
+	br     *bufio.Reader
+	limits Limits
+	fields int
+	size   int64
+
+	offset   int64
+	line     int
+	recordID string
+}
+
+// readLine reads a line, up to and including its LF. At the end of the input it returns
+// what was read with the error, as bufio.Reader.ReadString does.
+func (h *headerReader) readLine() (string, error) {
+	h.line++
+	var line []byte
+	for {
+		chunk, err := h.br.ReadSlice('\n')
+		if len(line)+len(chunk) > h.limits.MaxHeaderLineLength {
+			return "", &LimitError{Limit: "MaxHeaderLineLength", Max: int64(h.limits.MaxHeaderLineLength)}
+		}
+		h.size += int64(len(chunk))
+		if h.size > h.limits.MaxMemory {
+			return "", &LimitError{Limit: "MaxMemory", Max: h.limits.MaxMemory}
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return string(line), err
+	}
+}
+
+// addField counts a named field of the header.
+func (h *headerReader) addField() error {
+	h.fields++
+	if h.fields > h.limits.MaxHeaderFields {
+		return &LimitError{Limit: "MaxHeaderFields", Max: int64(h.limits.MaxHeaderFields)}
+	}
+	return nil
+}
+
+// checkLength checks a Content-Length against MaxRecordSize and, when the block is to be
+// held in memory along with the header read so far, against MaxMemory.
+func (h *headerReader) checkLength(length int64, inMemory bool) error {
+	if length > h.limits.MaxRecordSize {
+		return &LimitError{Limit: "MaxRecordSize", Max: h.limits.MaxRecordSize}
+	}
+	if inMemory && h.size+length > h.limits.MaxMemory {
+		return &LimitError{Limit: "MaxMemory", Max: h.limits.MaxMemory}
+	}
+	return nil
+}
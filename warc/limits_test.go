@@ -0,0 +1,208 @@
+package warc_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestReaderLimits(t *testing.T) {
+	resource := []string{
+		"WARC-Type: resource",
+		"WARC-Record-ID: <urn:uuid:1>",
+		"WARC-Date: 2024-01-01T10:00:00Z",
+		"WARC-Target-URI: http://example.com/",
+	}
+	many := append([]string(nil), resource...)
+	for i := 0; i < 20; i++ {
+		many = append(many, "X-Field: value")
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		limits Limits
+		want   string
+	}{
+		{
+			name:   "long header line",
+			input:  rawRecord("1.1", append(append([]string(nil), resource...), "X-Long: "+strings.Repeat("a", 200)), "Hello"),
+			limits: Limits{MaxHeaderLineLength: 100},
+			want:   "MaxHeaderLineLength",
+		},
+		{
+			name:   "long line without end",
+			input:  "WARC/1.1\r\n" + strings.Repeat("a", 1<<16),
+			limits: Limits{MaxHeaderLineLength: 100},
+			want:   "MaxHeaderLineLength",
+		},
+		{
+			name:   "many header fields",
+			input:  rawRecord("1.1", many, "Hello"),
+			limits: Limits{MaxHeaderFields: 10},
+			want:   "MaxHeaderFields",
+		},
+		{
+			name:   "large header",
+			input:  rawRecord("1.1", many, "Hello"),
+			limits: Limits{MaxMemory: 200},
+			want:   "MaxMemory",
+		},
+		{
+			name:   "large record",
+			input:  rawRecord("1.1", resource, strings.Repeat("a", 100)),
+			limits: Limits{MaxRecordSize: 99},
+			want:   "MaxRecordSize",
+		},
+		{
+			name:  "within limits",
+			input: rawRecord("1.1", many, strings.Repeat("a", 100)),
+			limits: Limits{
+				MaxHeaderLineLength: 100,
+				MaxHeaderFields:     len(many) + 1,
+				MaxRecordSize:       100,
+				MaxMemory:           1000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader.Limits = tt.limits
+			_, err = reader.Next()
+			var limitErr *LimitError
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Next() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &limitErr) || limitErr.Limit != tt.want {
+				t.Errorf("Next() = %v, want a *LimitError on %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderReassembleLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.MaxRecordSize = 10
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	segmented := httpResponseRecord("http://example.com/a", date, strings.Repeat("a", 40))
+	if err := w.WriteRecord(segmented); err != nil {
+		t.Fatal(err)
+	}
+	// Records between the first segment and its continuations must be held in memory
+	var records bytes.Buffer
+	w = NewWriter(&records)
+	for i := 0; i < 10; i++ {
+		if err := w.WriteRecord(httpResponseRecord("http://example.com/b", date, strings.Repeat("b", 200))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := bytes.Index(buf.Bytes()[1:], []byte("WARC/1.1")) + 1
+	input := string(buf.Bytes()[:first]) + records.String() + string(buf.Bytes()[first:])
+
+	reader, err := NewReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Reassemble = true
+	reader.Limits = Limits{MaxMemory: 1000}
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(record.Block)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxMemory" {
+		t.Errorf("reading a reassembled block = %v, want a *LimitError on MaxMemory", err)
+	}
+}
+
+func TestUnmarshalLimits(t *testing.T) {
+	huge := rawRecord("1.1", []string{"WARC-Type: resource", "Content-Length: 1000000000000"}, "")
+	hugest := rawRecord("1.1", []string{"WARC-Type: resource", "Content-Length: 9223372036854775807"}, "")
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	before := stats.TotalAlloc
+	var record WARCRecord
+	if err := Unmarshal([]byte(huge), &record); err == nil {
+		t.Errorf("Unmarshal() of a missing 1 TB block = nil, want an error")
+	}
+	if err := Valid([]byte(hugest)); err == nil {
+		t.Errorf("Valid() of a missing 8 EB block = nil, want an error")
+	}
+	runtime.ReadMemStats(&stats)
+	if allocated := stats.TotalAlloc - before; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for a missing block", allocated)
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		limits Limits
+		want   string
+	}{
+		{
+			name:   "long header line",
+			input:  rawRecord("1.1", []string{"WARC-Type: " + strings.Repeat("a", 100)}, ""),
+			limits: Limits{MaxHeaderLineLength: 50},
+			want:   "MaxHeaderLineLength",
+		},
+		{
+			name:   "many header fields",
+			input:  rawRecord("1.1", []string{"A: 1", "B: 2", "C: 3"}, ""),
+			limits: Limits{MaxHeaderFields: 2},
+			want:   "MaxHeaderFields",
+		},
+		{
+			name:   "large record",
+			input:  rawRecord("1.1", []string{"WARC-Type: resource"}, "Hello"),
+			limits: Limits{MaxRecordSize: 4},
+			want:   "MaxRecordSize",
+		},
+		{
+			name:   "block beyond memory",
+			input:  rawRecord("1.1", []string{"WARC-Type: resource"}, strings.Repeat("a", 100)),
+			limits: Limits{MaxMemory: 100},
+			want:   "MaxMemory",
+		},
+		{
+			name:   "negative length",
+			input:  rawRecord("1.1", []string{"Content-Length: -1"}, ""),
+			limits: Limits{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := map[string]any{
+				"record":   &WARCRecord{},
+				"warcinfo": &WarcInfoRecord{},
+				"metadata": &MetadataRecord{},
+			}
+			for kind, record := range records {
+				err := UnmarshalWithLimits([]byte(tt.input), record, tt.limits)
+				if err == nil {
+					t.Fatalf("UnmarshalWithLimits() of a %s = nil, want an error", kind)
+				}
+				var limitErr *LimitError
+				if tt.want != "" && (!errors.As(err, &limitErr) || limitErr.Limit != tt.want) {
+					t.Errorf("UnmarshalWithLimits() of a %s = %v, want a *LimitError on %s", kind, err, tt.want)
+				}
+			}
+		})
+	}
+}
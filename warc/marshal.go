@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -29,15 +30,29 @@ func Marshal(v any) ([]byte, error) {
 		}
 	}
 
+	var content []byte
+	if contentField := val.FieldByName("Content"); contentField.IsValid() {
+		content = contentField.Bytes()
+	}
+
 	var buf bytes.Buffer
+	if err := writeHeader(&buf, val, int64(len(content))); err != nil {
+		return nil, err
+	}
+	buf.Write(content)
+
+	return buf.Bytes(), nil
+}
 
+// writeHeader writes the version line and the named fields of the record held in val,
+// followed by the blank line that separates the header from a block of contentLength bytes.
+func writeHeader(buf *bytes.Buffer, val reflect.Value, contentLength int64) error {
 	versionField := val.FieldByName("Version")
 	if !versionField.IsValid() {
-		return nil, fmt.Errorf("Version field is required")
+		return fmt.Errorf("Version field is required")
 	}
-	fmt.Fprintf(&buf, "WARC/%s\r\n", versionField.Interface())
+	fmt.Fprintf(buf, "WARC/%s\r\n", versionField.Interface())
 
-	headers := make(map[string]string)
 	typ := val.Type()
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Field(i)
@@ -50,7 +65,11 @@ func Marshal(v any) ([]byte, error) {
 
 		tagParts := parseTag(tag)
 		headerName := tagParts.name
-		if headerName == "" {
+		// Content-Length is always derived from the block actually written
+		if headerName == "" || headerName == "Content-Length" {
+			continue
+		}
+		if tagParts.omitempty && field.IsZero() {
 			continue
 		}
 
@@ -59,27 +78,25 @@ func Marshal(v any) ([]byte, error) {
 			continue
 		}
 
-		headers[headerName] = value
+		fmt.Fprintf(buf, "%s: %s\r\n", headerName, value)
 	}
 
-	for name, value := range headers {
-		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
-	}
-
-	contentField := val.FieldByName("Content")
-	if contentField.IsValid() {
-		content := contentField.Bytes()
-		headers["Content-Length"] = strconv.Itoa(len(content))
-		fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(content))
-
-		buf.WriteString("\r\n")
-
-		buf.Write(content)
-	} else {
-		buf.WriteString("Content-Length: 0\r\n\r\n")
+	if field := val.FieldByName("Extensions"); field.IsValid() && field.Type() == reflect.TypeOf(map[string][]string(nil)) {
+		extensions := field.Interface().(map[string][]string)
+		names := make([]string, 0, len(extensions))
+		for name := range extensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, value := range extensions[name] {
+				fmt.Fprintf(buf, "%s: %s\r\n", name, value)
+			}
+		}
 	}
 
-	return buf.Bytes(), nil
+	fmt.Fprintf(buf, "Content-Length: %d\r\n\r\n", contentLength)
+	return nil
 }
 
 type tagInfo struct {
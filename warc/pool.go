@@ -0,0 +1,66 @@
+package warc
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// RecordWriter writes groups of records, keeping the records of each group adjacent.
+// It is implemented by Writer and WriterPool.
+type RecordWriter interface {
+	WriteRecords(records ...any) error
+}
+
+// WriterPool spreads records over several Writers, so that goroutines writing at the same
+// time rarely wait for one another. Writers are picked round-robin, and all the records of
+// one call go to the same Writer. It is safe for concurrent use.
+type WriterPool struct {
+	writers []*Writer
+	next    uint32
+}
+
+// NewWriterPool returns a WriterPool over writers.
+func NewWriterPool(writers ...*Writer) *WriterPool {
+	return &WriterPool{writers: writers}
+}
+
+// NewRotatingWriterPool returns a WriterPool over n rotating Writers in dir, the i-th of
+// them naming its files prefix-i-00000.warc and so on. Writers can be configured further
+// through Writers before the first write.
+func NewRotatingWriterPool(dir, prefix string, n int, maxFileSize int64, compression Compression) *WriterPool {
+	writers := make([]*Writer, n)
+	for i := range writers {
+		writers[i] = NewRotatingWriter(dir, fmt.Sprintf("%s-%d", prefix, i), maxFileSize, compression)
+	}
+	return NewWriterPool(writers...)
+}
+
+// Writers returns the Writers of the pool.
+func (p *WriterPool) Writers() []*Writer {
+	return p.writers
+}
+
+// WriteRecord writes a single record to the next Writer.
+func (p *WriterPool) WriteRecord(v any) error {
+	return p.WriteRecords(v)
+}
+
+// WriteRecords writes records back to back to the next Writer.
+func (p *WriterPool) WriteRecords(records ...any) error {
+	if len(p.writers) == 0 {
+		return fmt.Errorf("writer pool is empty")
+	}
+	i := atomic.AddUint32(&p.next, 1) - 1
+	return p.writers[i%uint32(len(p.writers))].WriteRecords(records...)
+}
+
+// Close closes the Writers of the pool, returning the first error.
+func (p *WriterPool) Close() error {
+	var first error
+	for _, w := range p.writers {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
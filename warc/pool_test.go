@@ -0,0 +1,146 @@
+package warc_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+// writeGroups writes linked request, response and metadata groups from many goroutines.
+func writeGroups(t *testing.T, w RecordWriter, goroutines, groups int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < groups; i++ {
+				uri := fmt.Sprintf("http://example.com/%d/%d", g, i)
+				request := &WARCRecord{Type: WARCTypeRequest, RecordID: NewRecordID(), TargetURI: uri, Content: []byte("GET")}
+				response := &WARCRecord{Type: WARCTypeResponse, RecordID: NewRecordID(), TargetURI: uri, Content: []byte("200 OK")}
+				metadata := &WARCRecord{Type: WARCTypeMetadata, TargetURI: uri, ConcurrentTo: []string{response.RecordID}}
+				request.ConcurrentTo = []string{response.RecordID}
+				response.ConcurrentTo = []string{request.RecordID}
+				if err := w.WriteRecords(request, response, metadata); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// checkGroups reads the files written by writeGroups and checks that every group is
+// complete and adjacent. It returns the number of groups found.
+func checkGroups(t *testing.T, paths []string) int {
+	t.Helper()
+	count := 0
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var group []*Record
+		for {
+			record, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Type == WARCTypeWarcinfo {
+				continue
+			}
+			copied := *record
+			group = append(group, &copied)
+			if len(group) < 3 {
+				continue
+			}
+			request, response, metadata := group[0], group[1], group[2]
+			if request.Type != WARCTypeRequest || response.Type != WARCTypeResponse || metadata.Type != WARCTypeMetadata {
+				t.Fatalf("%s: group types = %v, %v, %v", path, request.Type, response.Type, metadata.Type)
+			}
+			if request.TargetURI != response.TargetURI || response.TargetURI != metadata.TargetURI {
+				t.Fatalf("%s: records of different groups interleave: %v, %v, %v", path, request.TargetURI, response.TargetURI, metadata.TargetURI)
+			}
+			if request.ConcurrentTo[0] != response.RecordID || metadata.ConcurrentTo[0] != response.RecordID {
+				t.Fatalf("%s: group records are not linked", path)
+			}
+			group = group[:0]
+			count++
+		}
+		file.Close()
+		if len(group) != 0 {
+			t.Fatalf("%s ends with an incomplete group", path)
+		}
+	}
+	return count
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.warc")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeGroups(t, NewWriter(file), 20, 25)
+	file.Close()
+
+	if got := checkGroups(t, []string{path}); got != 500 {
+		t.Errorf("got %d groups, want 500", got)
+	}
+}
+
+func TestWriterPool(t *testing.T) {
+	dir := t.TempDir()
+	pool := NewRotatingWriterPool(dir, "pool", 4, 4096, CompressionGzip)
+	for _, w := range pool.Writers() {
+		info := &WarcInfoRecord{}
+		info.Software = "gwarc"
+		w.Warcinfo = info
+	}
+	writeGroups(t, pool, 20, 25)
+	if err := pool.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range pool.Writers() {
+		paths, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("pool-%d-*.warc.gz", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) < 2 {
+			t.Errorf("writer %d wrote %d files, want it to rotate", i, len(paths))
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := checkGroups(t, paths); got != 500 {
+		t.Errorf("got %d groups, want 500", got)
+	}
+}
+
+func TestWriterPoolEmpty(t *testing.T) {
+	if err := NewWriterPool().WriteRecord(&WARCRecord{Type: WARCTypeResource}); err == nil {
+		t.Errorf("WriteRecord() error = nil, want an error for an empty pool")
+	}
+}
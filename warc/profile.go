@@ -0,0 +1,180 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ValidationProfile is a named set of rules for records. Profiles are applied by
+// ValidateReader and ValidateRecord, and can include one another.
+type ValidationProfile struct {
+	// Name identifies the profile in the registry and in findings
+	Name string
+	// Include lists profiles whose rules apply as well
+	Include []*ValidationProfile
+	// RequiredFields lists, per record type, header fields records must have
+	RequiredFields map[WARCRecordType][]string
+	// RequiredBlockFields lists, per record type, the named fields an application/warc-fields
+	// block must have, such as "software" in warcinfo records
+	RequiredBlockFields map[WARCRecordType][]string
+	// Check, if set, applies further rules to a record. The Content of records with an
+	// application/warc-fields block holds the block.
+	Check func(record *Record) []Finding
+}
+
+// SpecMinimumProfile checks records against the WARC specification of their version only,
+// as described by ValidateRecord. It is the profile used when none is given.
+var SpecMinimumProfile = &ValidationProfile{
+	Name: "spec-minimum",
+	Check: func(record *Record) []Finding {
+		return checkRecord(record).findings
+	},
+}
+
+// HeritrixProfile requires the warcinfo and metadata fields written by the Heritrix crawler.
+var HeritrixProfile = &ValidationProfile{
+	Name:    "heritrix",
+	Include: []*ValidationProfile{SpecMinimumProfile},
+	RequiredBlockFields: map[WARCRecordType][]string{
+		WARCTypeWarcinfo: {"software", "hostname", "ip", "format", "conformsTo", "http-header-user-agent"},
+		WARCTypeMetadata: {"hopsFromSeed", "fetchTimeMs"},
+	},
+}
+
+// AllFieldsProfile is a strict profile requiring every field of WarcInfoRecord and
+// MetadataRecord, describing crawler, operator and crawl context in full.
+var AllFieldsProfile = &ValidationProfile{
+	Name:    "all-fields",
+	Include: []*ValidationProfile{SpecMinimumProfile},
+	RequiredBlockFields: map[WARCRecordType][]string{
+		WARCTypeWarcinfo: {"operator", "software", "robots", "hostname", "ip", "http-header-user-agent", "http-header-from"},
+		WARCTypeMetadata: {"via", "hopsFromSeed", "fetchTimeMs"},
+	},
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]*ValidationProfile{}
+)
+
+func init() {
+	for _, p := range []*ValidationProfile{SpecMinimumProfile, HeritrixProfile, AllFieldsProfile} {
+		profiles[p.Name] = p
+	}
+}
+
+// RegisterProfile makes a profile available to LookupProfile under its name.
+// It fails if the name is empty or already taken.
+func RegisterProfile(p *ValidationProfile) error {
+	if p == nil || p.Name == "" {
+		return fmt.Errorf("validation profile has no name")
+	}
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if _, ok := profiles[p.Name]; ok {
+		return fmt.Errorf("validation profile %q is already registered", p.Name)
+	}
+	profiles[p.Name] = p
+	return nil
+}
+
+// LookupProfile returns the registered profile with the given name.
+func LookupProfile(name string) (*ValidationProfile, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// ProfileNames returns the names of the registered profiles, sorted.
+func ProfileNames() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandProfiles returns profiles with the profiles they include, each once,
+// included profiles first. No profiles stands for SpecMinimumProfile.
+func expandProfiles(list []*ValidationProfile) []*ValidationProfile {
+	if len(list) == 0 {
+		list = []*ValidationProfile{SpecMinimumProfile}
+	}
+	var expanded []*ValidationProfile
+	seen := make(map[*ValidationProfile]bool)
+	var visit func(p *ValidationProfile)
+	visit = func(p *ValidationProfile) {
+		if p == nil || seen[p] {
+			return
+		}
+		seen[p] = true
+		for _, included := range p.Include {
+			visit(included)
+		}
+		expanded = append(expanded, p)
+	}
+	for _, p := range list {
+		visit(p)
+	}
+	return expanded
+}
+
+// checkProfiles applies the rules of profiles, already expanded, to record.
+func checkProfiles(record *Record, profiles []*ValidationProfile) []Finding {
+	var findings []Finding
+	for _, p := range profiles {
+		c := &checker{offset: record.Offset, id: record.RecordID}
+		fields := recordFields(record)
+		for _, name := range p.RequiredFields[record.Type] {
+			if !hasField(fields, name) {
+				c.errorf(name, "required by the %s profile", p.Name)
+			}
+		}
+		if required := p.RequiredBlockFields[record.Type]; len(required) > 0 {
+			present := blockFieldNames(record.Content)
+			for _, name := range required {
+				if !present[name] {
+					c.errorf(name, "block field required by the %s profile", p.Name)
+				}
+			}
+		}
+		if p.Check != nil {
+			c.findings = append(c.findings, p.Check(record)...)
+		}
+		for i := range c.findings {
+			if c.findings[i].Profile == "" {
+				c.findings[i].Profile = p.Name
+			}
+		}
+		findings = append(findings, c.findings...)
+	}
+	return findings
+}
+
+func hasField(fields []warcField, name string) bool {
+	for _, field := range fields {
+		if strings.EqualFold(field.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockFieldNames returns the names of the fields with a value in an application/warc-fields block.
+func blockFieldNames(block []byte) map[string]bool {
+	names := make(map[string]bool)
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		name, value, found := bytes.Cut(line, []byte(":"))
+		if found && len(bytes.TrimSpace(value)) > 0 {
+			names[string(bytes.TrimSpace(name))] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,147 @@
+package warc_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func minimalWarcinfo() *WarcInfoRecord {
+	info := &WarcInfoRecord{WARCRecord: WARCRecord{
+		Version:  WARCVariant1_1,
+		RecordID: "<urn:uuid:1>",
+		Date:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Type:     WARCTypeWarcinfo,
+	}}
+	info.Software = "other-tool/1.0"
+	return info
+}
+
+func findingFields(findings []Finding) string {
+	var fields []string
+	for _, f := range findings {
+		fields = append(fields, f.Profile+":"+f.Field)
+	}
+	return strings.Join(fields, " ")
+}
+
+func TestOptionalBlockFields(t *testing.T) {
+	info := minimalWarcinfo()
+	if err := info.Validate(); err != nil {
+		t.Errorf("WarcInfoRecord.Validate() = %v, want nil", err)
+	}
+	if _, err := Marshal(info); err != nil {
+		t.Errorf("Marshal(warcinfo) = %v, want nil", err)
+	}
+
+	metadata := &MetadataRecord{WARCRecord: info.WARCRecord}
+	metadata.Type = WARCTypeMetadata
+	if err := metadata.Validate(); err != nil {
+		t.Errorf("MetadataRecord.Validate() = %v, want nil", err)
+	}
+	if _, err := Marshal(metadata); err != nil {
+		t.Errorf("Marshal(metadata) = %v, want nil", err)
+	}
+}
+
+func TestValidateRecordProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles []*ValidationProfile
+		want     string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "spec-minimum",
+			profiles: []*ValidationProfile{SpecMinimumProfile},
+		},
+		{
+			name:     "heritrix",
+			profiles: []*ValidationProfile{HeritrixProfile},
+			want:     "heritrix:hostname heritrix:ip heritrix:format heritrix:conformsTo heritrix:http-header-user-agent",
+		},
+		{
+			name:     "all-fields",
+			profiles: []*ValidationProfile{AllFieldsProfile},
+			want:     "all-fields:operator all-fields:robots all-fields:hostname all-fields:ip all-fields:http-header-user-agent all-fields:http-header-from",
+		},
+		{
+			name: "composed",
+			profiles: []*ValidationProfile{{
+				Name:           "composed",
+				Include:        []*ValidationProfile{HeritrixProfile, AllFieldsProfile},
+				RequiredFields: map[WARCRecordType][]string{WARCTypeWarcinfo: {"WARC-Filename"}},
+				Check: func(record *Record) []Finding {
+					if !bytes.Contains(record.Content, []byte("software: gwarc")) {
+						return []Finding{{Severity: SeverityWarning, Field: "software", Message: "not written by gwarc"}}
+					}
+					return nil
+				},
+			}},
+			want: "heritrix:hostname heritrix:ip heritrix:format heritrix:conformsTo heritrix:http-header-user-agent " +
+				"all-fields:operator all-fields:robots all-fields:hostname all-fields:ip all-fields:http-header-user-agent all-fields:http-header-from " +
+				"composed:WARC-Filename composed:software",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findingFields(ValidateRecord(minimalWarcinfo(), tt.profiles...))
+			if got != tt.want {
+				t.Errorf("ValidateRecord() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReaderProfiles(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	metadata := &MetadataRecord{WARCRecord: WARCRecord{TargetURI: "http://example.com/"}}
+	metadata.Via = "http://example.com/seed"
+	if err := w.WriteRecords(minimalWarcinfo(), metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	findings := ValidateReader(bytes.NewReader(buf.Bytes()), AllFieldsProfile)
+	want := "all-fields:operator all-fields:robots all-fields:hostname all-fields:ip all-fields:http-header-user-agent all-fields:http-header-from " +
+		"all-fields:hopsFromSeed all-fields:fetchTimeMs"
+	if got := findingFields(findings); got != want {
+		t.Errorf("ValidateReader() = %q, want %q", got, want)
+	}
+	if findings[len(findings)-1].Offset == 0 {
+		t.Errorf("metadata findings have offset 0")
+	}
+}
+
+func TestRegisterProfile(t *testing.T) {
+	for _, name := range []string{"spec-minimum", "heritrix", "all-fields"} {
+		if p, ok := LookupProfile(name); !ok || p.Name != name {
+			t.Errorf("LookupProfile(%q) = %v, %v", name, p, ok)
+		}
+	}
+
+	custom := &ValidationProfile{Name: "test-register", Include: []*ValidationProfile{SpecMinimumProfile}}
+	if err := RegisterProfile(custom); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := LookupProfile("test-register"); !ok || p != custom {
+		t.Errorf("LookupProfile() = %v, %v, want the registered profile", p, ok)
+	}
+	if err := RegisterProfile(&ValidationProfile{Name: "test-register"}); err == nil {
+		t.Errorf("RegisterProfile() of a taken name = nil, want an error")
+	}
+	if err := RegisterProfile(&ValidationProfile{}); err == nil {
+		t.Errorf("RegisterProfile() without a name = nil, want an error")
+	}
+
+	names := strings.Join(ProfileNames(), " ")
+	if !strings.Contains(names, "heritrix") || !strings.Contains(names, "test-register") {
+		t.Errorf("ProfileNames() = %v", names)
+	}
+}
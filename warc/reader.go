@@ -0,0 +1,366 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/iocount"
+	"github.com/zenless-lab/gwarc/internal/zstd"
+)
+
+// RecordReader reads records one at a time, returning io.EOF at the end of its input.
+// It is implemented by Reader and by readers of other archive formats exposing their
+// records as WARC records, so that code indexing or analysing records works on all of them.
+type RecordReader interface {
+	Next() (*Record, error)
+}
+
+// Reader reads WARC records one at a time from a stream, without holding their blocks in
+// memory. Gzip and zstd compressed input is detected automatically; with one gzip member
+// or zstd frame per record, as in .warc.gz and .warc.zst files, the offset of every record
+// is the offset of its member or frame. The dictionary starting .warc.zst input is used to
+// decompress its frames.
+type Reader struct {
+	// Reassemble joins segmented records: a first segment is returned as one logical record
+	// whose Block streams the blocks of its continuation records, which are not returned
+	// on their own. The ContentLength and SegmentTotalLength of a logical record are zero
+	// until its Block has been read to the end, and it has no BlockDigest.
+	Reassemble bool
+
+	// Limits bounds the header of each record, its Content-Length, and the records held
+	// in memory while reassembling. Exceeding them fails Next with a *LimitError.
+	Limits Limits
+
+	// VerifyDigests checks the block of each record with a SHA-1 WARC-Block-Digest against
+	// it once the block has been read to the end; a mismatch fails the final Read with a
+	// *ParseError in CategoryDigest.
+	VerifyDigests bool
+
+	open     func() (io.Reader, string, error)
+	closer   io.Closer
+	filename string
+
+	buf          *bufio.Reader
+	src          *iocount.Reader
+	br           *bufio.Reader
+	gz           *gzip.Reader
+	zst          *zstd.Reader
+	zstdDict     []byte
+	memberOffset int64
+
+	block    *io.LimitedReader
+	returned io.Reader
+	pending  []*Record
+	// pendingSize is the number of bytes of blocks held in pending
+	pendingSize int64
+
+	// offset is the offset of the record being read, and separator the bytes
+	// skipped before it, so that a Validator can check both
+	offset    int64
+	separator []byte
+}
+
+// NewReader returns a Reader reading records from r. When r is a file, its records are
+// marked with the file's name.
+func NewReader(r io.Reader) (*Reader, error) {
+	var name string
+	if file, ok := r.(*os.File); ok {
+		name = filepath.Base(file.Name())
+	}
+	used := false
+	reader := &Reader{open: func() (io.Reader, string, error) {
+		if used {
+			return nil, "", io.EOF
+		}
+		used = true
+		return r, name, nil
+	}}
+	if err := reader.nextSource(); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// OpenReader returns a Reader reading records from the named files in turn, as one stream.
+// It is meant for sets of rotated files, whose segmented records may span files.
+func OpenReader(paths ...string) (*Reader, error) {
+	reader := &Reader{}
+	reader.open = func() (io.Reader, string, error) {
+		if len(paths) == 0 {
+			return nil, "", io.EOF
+		}
+		file, err := os.Open(paths[0])
+		if err != nil {
+			return nil, "", err
+		}
+		name := filepath.Base(paths[0])
+		paths = paths[1:]
+		reader.closer = file
+		return file, name, nil
+	}
+	if err := reader.nextSource(); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Close closes the file a Reader created by OpenReader is reading.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	return err
+}
+
+// nextSource moves on to the next input, returning io.EOF when there is none.
+func (r *Reader) nextSource() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	in, name, err := r.open()
+	if err != nil {
+		return err
+	}
+	r.filename = name
+	r.buf = bufio.NewReader(in)
+	r.src = iocount.NewReader(r.buf)
+	r.gz, r.zst, r.zstdDict = nil, nil, nil
+	r.memberOffset = 0
+
+	magic, _ := r.buf.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		if r.gz, err = gzip.NewReader(r.src); err != nil {
+			return err
+		}
+		r.gz.Multistream(false)
+		r.br = bufio.NewReader(r.gz)
+	case isZstd(magic):
+		return r.startZstd()
+	default:
+		r.br = bufio.NewReader(r.src)
+	}
+	return nil
+}
+
+// Next returns the next record. Its Block is valid until the following call to Next.
+// At the end of the input Next returns io.EOF.
+func (r *Reader) Next() (*Record, error) {
+	if r.returned != nil {
+		if _, err := io.Copy(io.Discard, r.returned); err != nil {
+			return nil, err
+		}
+		r.returned = nil
+	}
+
+	var record *Record
+	if len(r.pending) > 0 {
+		record, r.pending = r.pending[0], r.pending[1:]
+		r.pendingSize -= int64(record.ContentLength)
+	} else {
+		var err error
+		if record, err = r.readRecord(); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Reassemble && record.SegmentNumber == 1 && record.Type != WARCTypeContinuation {
+		record = r.reassemble(record)
+	}
+	r.returned = record.Block
+	return record, nil
+}
+
+// readRecord reads the next record as it appears in the input.
+func (r *Reader) readRecord() (*Record, error) {
+	if r.block != nil {
+		if _, err := io.Copy(io.Discard, r.block); err != nil {
+			return nil, err
+		}
+		r.block = nil
+	}
+	r.separator = r.separator[:0]
+	if err := r.skipSeparator(); err != nil {
+		return nil, err
+	}
+
+	offset := r.src.N() - int64(r.br.Buffered())
+	if r.gz != nil || r.zst != nil {
+		offset = r.memberOffset
+	}
+	r.offset = offset
+
+	record := &Record{Offset: offset, File: r.filename}
+	header := &headerReader{br: r.br, limits: r.Limits.withDefaults(), offset: offset}
+	fields, err := readHeader(header, &record.WARCRecord)
+	if err != nil {
+		return nil, err
+	}
+	record.fields = fields
+	headers := make(map[string]string, len(fields))
+	occurrences := make(map[string][]string, len(fields))
+	for _, field := range fields {
+		headers[field.name] = field.value
+		occurrences[field.name] = append(occurrences[field.name], field.value)
+	}
+	if _, ok := headers["Content-Length"]; !ok {
+		return nil, header.fieldError(CategorySyntax, "Content-Length", errors.New("missing Content-Length header"))
+	}
+	if err := decodeHeader(reflect.ValueOf(&record.WARCRecord).Elem(), headers, occurrences); err != nil {
+		return nil, &ParseError{Category: CategorySyntax, Offset: offset, RecordID: record.RecordID, Err: err}
+	}
+
+	length, err := strconv.ParseUint(headers["Content-Length"], 10, 64)
+	if err != nil {
+		return nil, header.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("invalid Content-Length: %q", headers["Content-Length"]))
+	}
+	if length > uint64(header.limits.MaxRecordSize) {
+		return nil, header.fieldError(CategoryLimits, "Content-Length", &LimitError{Limit: "MaxRecordSize", Max: header.limits.MaxRecordSize})
+	}
+	record.ContentLength = length
+
+	r.block = &io.LimitedReader{R: r.br, N: int64(length)}
+	block := &blockReader{r: r.block, offset: offset, recordID: record.RecordID}
+	if algorithm, _, _ := strings.Cut(record.BlockDigest, ":"); r.VerifyDigests && strings.EqualFold(algorithm, DigestAlgorithm) {
+		block.want = record.BlockDigest
+		block.digest = newDigest()
+	}
+	record.Block = block
+	return record, nil
+}
+
+// skipSeparator skips the blank lines ending the previous record, moving on to the next
+// gzip member, zstd frame or input as needed.
+func (r *Reader) skipSeparator() error {
+	for {
+		b, err := r.br.ReadByte()
+		if err == io.EOF {
+			if r.gz != nil {
+				if _, err := r.buf.Peek(1); err == nil {
+					r.memberOffset = r.src.N()
+					if err := r.gz.Reset(r.src); err != nil {
+						return err
+					}
+					r.gz.Multistream(false)
+					r.br.Reset(r.gz)
+					continue
+				}
+			}
+			if r.zst != nil {
+				if _, err := r.buf.Peek(1); err == nil {
+					r.memberOffset = r.src.N()
+					r.zst.Reset(r.src)
+					r.br.Reset(r.zst)
+					continue
+				}
+			}
+			if err := r.nextSource(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if b != '\r' && b != '\n' {
+			return r.br.UnreadByte()
+		}
+		if len(r.separator) < 8 {
+			r.separator = append(r.separator, b)
+		}
+	}
+}
+
+// readHeader reads the version line and named fields of a record, in order. Its errors
+// are *ParseErrors.
+func readHeader(h *headerReader, record *WARCRecord) ([]warcField, error) {
+	versionLine, err := h.readLine()
+	if err != nil {
+		return nil, h.headerError(CategorySyntax, fmt.Errorf("failed to read version: %w", err))
+	}
+	version := WARCVariant(strings.TrimSpace(strings.TrimPrefix(versionLine, "WARC/")))
+	if version != WARCVariant1_0 && version != WARCVariant1_1 {
+		return nil, h.headerError(CategoryVersion, fmt.Errorf("unsupported WARC version: %s", strings.TrimSpace(versionLine)))
+	}
+	record.Version = version
+
+	var fields []warcField
+	for {
+		line, err := h.readLine()
+		if err != nil {
+			return nil, h.headerError(CategorySyntax, fmt.Errorf("failed to read header: %w", err))
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			// Continuation of a folded field value
+			fields[len(fields)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, h.headerError(CategorySyntax, fmt.Errorf("invalid header format: %s", strings.TrimSpace(line)))
+		}
+		if err := h.addField(); err != nil {
+			return nil, h.headerError(CategoryLimits, err)
+		}
+		field := warcField{strings.TrimSpace(name), strings.TrimSpace(value)}
+		if field.name == "WARC-Record-ID" {
+			h.recordID = field.value
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// blockReader hides the LimitedReader behind a record's Block. A short block fails with a
+// *ParseError wrapping io.ErrUnexpectedEOF, and, when digest is set, a block not matching
+// the digest it should have with a *ParseError in CategoryDigest.
+type blockReader struct {
+	r        *io.LimitedReader
+	offset   int64
+	recordID string
+	digest   hash.Hash
+	want     string
+}
+
+func (b *blockReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if b.digest != nil {
+		b.digest.Write(p[:n])
+	}
+	if err == io.EOF && b.r.N > 0 {
+		err = &ParseError{Category: CategorySyntax, Offset: b.offset, RecordID: b.recordID, Field: "Content-Length", Err: io.ErrUnexpectedEOF}
+	} else if err == io.EOF && b.digest != nil {
+		if got := formatDigest(b.digest); !strings.EqualFold(got, b.want) {
+			err = &ParseError{Category: CategoryDigest, Offset: b.offset, RecordID: b.recordID, Field: "WARC-Block-Digest",
+				Err: fmt.Errorf("block digest is %s, want %s", got, b.want)}
+		}
+		b.digest = nil
+	}
+	return n, err
+}
+
+// bufferRecord reads the block of record into memory, so that it outlives the next read.
+func bufferRecord(record *Record) error {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, record.Block); err != nil {
+		return err
+	}
+	record.Block = bytes.NewReader(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,143 @@
+package warc_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestReader(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	records := []*WARCRecord{
+		{Type: WARCTypeResource, RecordID: "<urn:uuid:1>", Date: date, TargetURI: "http://example.com/", Content: []byte("one")},
+		{Type: WARCTypeResource, RecordID: "<urn:uuid:2>", Date: date, ConcurrentTo: []string{"<urn:uuid:1>"}, Content: bytes.Repeat([]byte("x"), 100000)},
+		{Type: WARCTypeMetadata, RecordID: "<urn:uuid:3>", Date: date, Extensions: map[string][]string{"X-Custom": {"a", "b"}}},
+	}
+
+	writers := map[string]func(io.Writer) *Writer{
+		"plain": NewWriter,
+		"gzip":  NewGzipWriter,
+	}
+	for name, newWriter := range writers {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newWriter(&buf)
+			var offsets []int64
+			for _, record := range records {
+				offsets = append(offsets, w.Offset())
+				if err := w.WriteRecord(record); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range records {
+				got, err := r.Next()
+				if err != nil {
+					t.Fatalf("Next() record %d: %v", i, err)
+				}
+				if got.Offset != offsets[i] {
+					t.Errorf("record %d: Offset = %d, want %d", i, got.Offset, offsets[i])
+				}
+				if got.RecordID != want.RecordID || got.Type != want.Type || !got.Date.Equal(want.Date) || got.TargetURI != want.TargetURI {
+					t.Errorf("record %d: header = %+v", i, got.WARCRecord)
+				}
+				if got.ContentLength != uint64(len(want.Content)) {
+					t.Errorf("record %d: ContentLength = %d, want %d", i, got.ContentLength, len(want.Content))
+				}
+				if strings.Join(got.ConcurrentTo, " ") != strings.Join(want.ConcurrentTo, " ") {
+					t.Errorf("record %d: ConcurrentTo = %v, want %v", i, got.ConcurrentTo, want.ConcurrentTo)
+				}
+				if len(want.Extensions) > 0 && strings.Join(got.Extensions["X-Custom"], " ") != "a b" {
+					t.Errorf("record %d: Extensions = %v", i, got.Extensions)
+				}
+				if i == 0 {
+					// The first block is left unread, so that Next has to skip it
+					continue
+				}
+				block, err := io.ReadAll(got.Block)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(block, want.Content) {
+					t.Errorf("record %d: block is %d bytes, want %d", i, len(block), len(want.Content))
+				}
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("Next() at end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unsupported version", "WARC/2.0\r\nContent-Length: 0\r\n\r\n"},
+		{"missing Content-Length", "WARC/1.1\r\nWARC-Type: resource\r\n\r\n"},
+		{"invalid Content-Length", "WARC/1.1\r\nContent-Length: x\r\n\r\n"},
+		{"invalid header", "WARC/1.1\r\nno colon\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Next(); err == nil {
+				t.Errorf("Next() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestReaderShortBlock(t *testing.T) {
+	r, err := NewReader(strings.NewReader("WARC/1.1\r\nWARC-Type: resource\r\nContent-Length: 10\r\n\r\nHello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(record.Block); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAll() error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestRecordFields(t *testing.T) {
+	raw := "WARC/1.1\r\nWARC-Type: resource\r\nWARC-Record-ID: <urn:uuid:1>\r\nX-Custom:  spaced \r\n" +
+		"WARC-Date: 2024-01-01T10:00:00Z\r\nContent-Length: 0\r\n\r\n\r\n\r\n"
+	r, err := NewReader(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Field{
+		{"WARC-Type", "resource"}, {"WARC-Record-ID", "<urn:uuid:1>"}, {"X-Custom", "spaced"},
+		{"WARC-Date", "2024-01-01T10:00:00Z"}, {"Content-Length", "0"},
+	}
+	if got := record.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields() = %v, want %v", got, want)
+	}
+
+	// Fields of a record not read are those it would be written with
+	written := &Record{WARCRecord: WARCRecord{Version: WARCVariant1_1, Type: WARCTypeResource, RecordID: "<urn:uuid:2>"}}
+	if got := written.Fields(); len(got) != 4 || got[0] != (Field{"WARC-Record-ID", "<urn:uuid:2>"}) || got[3] != (Field{"Content-Length", "0"}) {
+		t.Errorf("Fields() of a record to write = %v", got)
+	}
+}
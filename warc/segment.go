@@ -0,0 +1,153 @@
+package warc
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeSegments writes a record whose block exceeds MaxRecordSize as a first segment and
+// continuation records, as described in section 6 of the WARC 1.1 specification. The first
+// segment keeps the header of the record; each segment has the digest of its own block, and
+// the last one carries the total length of the logical block.
+func (w *Writer) writeSegments(record *Record) error {
+	total := record.ContentLength
+	remaining := total
+	for number := 1; remaining > 0; number++ {
+		size := uint64(w.MaxRecordSize)
+		if remaining < size {
+			size = remaining
+		}
+		remaining -= size
+
+		spool := newSpool(DefaultMaxMemory, "")
+		digest := newDigest()
+		n, err := io.CopyN(io.MultiWriter(spool, digest), record.Block, int64(size))
+		if err != nil {
+			spool.Close()
+			if err == io.EOF {
+				return fmt.Errorf("record %s block is %d bytes, want %d", record.RecordID, total-remaining-size+uint64(n), total)
+			}
+			return err
+		}
+
+		segment := &Record{WARCRecord: record.WARCRecord}
+		if number > 1 {
+			if err := w.rotate(true); err != nil {
+				spool.Close()
+				return err
+			}
+			segment.WARCRecord = WARCRecord{
+				Version:         record.Version,
+				RecordID:        NewRecordID(),
+				Date:            record.Date,
+				Type:            WARCTypeContinuation,
+				TargetURI:       record.TargetURI,
+				WarcinfoID:      record.WarcinfoID,
+				SegmentOriginID: record.RecordID,
+			}
+			if remaining == 0 {
+				segment.SegmentTotalLength = total
+			}
+		}
+		segment.SegmentNumber = number
+		segment.ContentLength = size
+		segment.BlockDigest = formatDigest(digest)
+
+		segment.Block = spool.Reader()
+		err = w.writeRecord(segment)
+		spool.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reassemble returns the logical record a first segment starts. Its Block reads through
+// the continuation records that follow; records found between them are kept in memory
+// and returned by later calls to Next.
+func (r *Reader) reassemble(first *Record) *Record {
+	logical := &Record{WARCRecord: first.WARCRecord, Offset: first.Offset, File: first.File}
+	logical.BlockDigest = ""
+	logical.ContentLength = 0
+	logical.Block = &segmentedBlock{r: r, logical: logical, origin: first.RecordID, current: first.Block, length: first.ContentLength, number: 1}
+	return logical
+}
+
+// segmentedBlock streams the blocks of a first segment and its continuation records.
+type segmentedBlock struct {
+	r       *Reader
+	logical *Record
+	origin  string
+	current io.Reader
+	length  uint64
+	number  int
+	err     error
+}
+
+func (b *segmentedBlock) Read(p []byte) (int, error) {
+	for b.err == nil {
+		n, err := b.current.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+		if b.logical.SegmentTotalLength != 0 {
+			b.err = io.EOF
+			break
+		}
+		b.err = b.nextSegment()
+	}
+	return 0, b.err
+}
+
+// nextSegment moves on to the next continuation record of the logical record. When the last
+// one is reached, the length of the logical record becomes known.
+func (b *segmentedBlock) nextSegment() error {
+	for {
+		record, err := b.r.readRecord()
+		if err == io.EOF {
+			return b.error(CategorySyntax, "", fmt.Errorf("missing continuation records: %w", io.ErrUnexpectedEOF))
+		}
+		if err != nil {
+			return err
+		}
+		if record.Type != WARCTypeContinuation || record.SegmentOriginID != b.origin {
+			if max := b.r.Limits.withDefaults().MaxMemory; b.r.pendingSize+int64(record.ContentLength) > max {
+				return b.error(CategoryLimits, "", &LimitError{Limit: "MaxMemory", Max: max})
+			}
+			if err := bufferRecord(record); err != nil {
+				return err
+			}
+			b.r.pending = append(b.r.pending, record)
+			b.r.pendingSize += int64(record.ContentLength)
+			continue
+		}
+
+		if record.SegmentNumber != b.number+1 {
+			err := b.error(CategorySyntax, "WARC-Segment-Number", fmt.Errorf("found segment %d, want %d", record.SegmentNumber, b.number+1))
+			err.Offset = record.Offset
+			return err
+		}
+		b.number++
+		b.current = record.Block
+		b.length += record.ContentLength
+		if record.SegmentTotalLength != 0 {
+			if record.SegmentTotalLength != b.length {
+				err := b.error(CategorySyntax, "WARC-Segment-Total-Length", fmt.Errorf("segments hold %d bytes, WARC-Segment-Total-Length is %d", b.length, record.SegmentTotalLength))
+				err.Offset = record.Offset
+				return err
+			}
+			b.logical.ContentLength = b.length
+			b.logical.SegmentTotalLength = b.length
+		}
+		return nil
+	}
+}
+
+// error returns a *ParseError about the logical record, at the offset of its first segment.
+func (b *segmentedBlock) error(category ErrorCategory, field string, err error) *ParseError {
+	return &ParseError{Category: category, Offset: b.logical.Offset, RecordID: b.origin, Field: field, Err: err}
+}
@@ -0,0 +1,200 @@
+package warc_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestWriterSegmentation(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 25)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.MaxRecordSize = 100
+	first := &WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:1>", TargetURI: "http://example.com/", Content: content}
+	if err := w.WriteRecords(first, &WARCRecord{Type: WARCTypeResource, Content: []byte("small")}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readRecords(t, buf.Bytes())
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want 4", len(records))
+	}
+	wantLengths := []uint64{100, 100, 50}
+	for i, record := range records[:3] {
+		if record.SegmentNumber != i+1 {
+			t.Errorf("record %d: SegmentNumber = %d, want %d", i, record.SegmentNumber, i+1)
+		}
+		if uint64(len(record.Content)) != wantLengths[i] {
+			t.Errorf("record %d: block is %d bytes, want %d", i, len(record.Content), wantLengths[i])
+		}
+		if record.BlockDigest != Digest(record.Content) {
+			t.Errorf("record %d: BlockDigest = %v, want %v", i, record.BlockDigest, Digest(record.Content))
+		}
+		if record.TargetURI != "http://example.com/" {
+			t.Errorf("record %d: TargetURI = %v", i, record.TargetURI)
+		}
+		if i == 0 {
+			if record.Type != WARCTypeResource || record.RecordID != "<urn:uuid:1>" {
+				t.Errorf("first segment = %v %v", record.Type, record.RecordID)
+			}
+			continue
+		}
+		if record.Type != WARCTypeContinuation || record.SegmentOriginID != "<urn:uuid:1>" {
+			t.Errorf("record %d = %v from %v, want continuation of <urn:uuid:1>", i, record.Type, record.SegmentOriginID)
+		}
+	}
+	if records[1].SegmentTotalLength != 0 || records[2].SegmentTotalLength != 250 {
+		t.Errorf("SegmentTotalLength = %d, %d, want 0, 250", records[1].SegmentTotalLength, records[2].SegmentTotalLength)
+	}
+	if records[3].SegmentNumber != 0 {
+		t.Errorf("small record was segmented")
+	}
+}
+
+func TestReaderReassemble(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.MaxRecordSize = 300
+	if err := w.WriteRecord(&WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:1>", Content: content}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRecord(&WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:2>", Content: []byte("after")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the record after the first segment, so that the reader has to hold it back
+	raw := readRecords(t, buf.Bytes())
+	var reordered bytes.Buffer
+	for _, record := range []WARCRecord{raw[0], raw[4], raw[1], raw[2], raw[3]} {
+		if err := NewWriter(&reordered).WriteRecord(&record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(&reordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Reassemble = true
+	logical, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logical.RecordID != "<urn:uuid:1>" || logical.ContentLength != 0 {
+		t.Errorf("logical record = %v with ContentLength %d before reading", logical.RecordID, logical.ContentLength)
+	}
+	block, err := io.ReadAll(logical.Block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(block, content) {
+		t.Errorf("block is %d bytes, want %d", len(block), len(content))
+	}
+	if logical.ContentLength != 1000 || logical.SegmentTotalLength != 1000 {
+		t.Errorf("ContentLength, SegmentTotalLength = %d, %d, want 1000", logical.ContentLength, logical.SegmentTotalLength)
+	}
+
+	next, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RecordID != "<urn:uuid:2>" {
+		t.Errorf("next record = %v, want <urn:uuid:2>", next.RecordID)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() at end = %v, want io.EOF", err)
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("abcdefghij"), 50)
+
+	w := NewRotatingWriter(dir, "test", 200, CompressionGzip)
+	w.MaxRecordSize = 150
+	info := &WarcInfoRecord{}
+	info.Software = "gwarc"
+	w.Warcinfo = info
+	if err := w.WriteRecord(&WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:1>", Content: content}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRecord(&WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:2>", Content: []byte("last")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "test-*.warc.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) < 2 {
+		t.Fatalf("got %d files, want the record spread over several", len(paths))
+	}
+
+	// Every file starts with its own warcinfo record
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record, err := r.Next()
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Type != WARCTypeWarcinfo || record.Filename != filepath.Base(path) || record.File != filepath.Base(path) {
+			t.Errorf("%s starts with %v %v, want its warcinfo", path, record.Type, record.Filename)
+		}
+	}
+
+	r, err := OpenReader(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Reassemble = true
+	var ids []string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Type == WARCTypeWarcinfo {
+			continue
+		}
+		if record.WarcinfoID == "" {
+			t.Errorf("record %v has no WARC-Warcinfo-ID", record.RecordID)
+		}
+		ids = append(ids, record.RecordID)
+		if record.RecordID != "<urn:uuid:1>" {
+			continue
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(block, content) {
+			t.Errorf("reassembled block is %d bytes, want %d", len(block), len(content))
+		}
+	}
+	if len(ids) != 2 || ids[0] != "<urn:uuid:1>" || ids[1] != "<urn:uuid:2>" {
+		t.Errorf("records = %v, want <urn:uuid:1> <urn:uuid:2>", ids)
+	}
+}
@@ -0,0 +1,94 @@
+package warc
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultMaxMemory is the number of bytes buffered in memory per body before spilling to a temporary file
+const DefaultMaxMemory = 1 << 20
+
+// spool buffers written data in memory up to a limit and spills the rest to a temporary file.
+type spool struct {
+	limit int64
+	dir   string
+	mem   bytes.Buffer
+	file  *os.File
+	size  int64
+}
+
+func newSpool(limit int64, dir string) *spool {
+	if limit <= 0 {
+		limit = DefaultMaxMemory
+	}
+	return &spool{limit: limit, dir: dir}
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && int64(s.mem.Len()+len(p)) > s.limit {
+		file, err := os.CreateTemp(s.dir, "gwarc-spool-*")
+		if err != nil {
+			return 0, err
+		}
+		if _, err := file.Write(s.mem.Bytes()); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return 0, err
+		}
+		s.file = file
+		s.mem = bytes.Buffer{}
+	}
+
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.mem.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+// Len returns the number of bytes written to the spool.
+func (s *spool) Len() int64 {
+	return s.size
+}
+
+// Reader returns a reader over everything written so far.
+func (s *spool) Reader() io.Reader {
+	if s.file != nil {
+		return io.NewSectionReader(s.file, 0, s.size)
+	}
+	return bytes.NewReader(s.mem.Bytes())
+}
+
+// Reset discards everything written so far.
+func (s *spool) Reset() error {
+	s.mem.Reset()
+	s.size = 0
+	if s.file != nil {
+		if err := s.file.Truncate(0); err != nil {
+			return err
+		}
+		_, err := s.file.Seek(0, io.SeekStart)
+		return err
+	}
+	return nil
+}
+
+// Close releases the spool and removes its temporary file.
+func (s *spool) Close() error {
+	s.mem = bytes.Buffer{}
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	err := s.file.Close()
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	s.file = nil
+	return err
+}
@@ -0,0 +1,401 @@
+package warc
+
+import (
+	"crypto/tls"
+	"hash"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Transport is an http.RoundTripper that archives each exchange it carries as a linked
+// pair of request and response records. Responses reach the caller unchanged; the records
+// are written once the response body has been read to the end or closed, or once a limit
+// on the recording has been reached. Bodies are buffered in memory up to MaxMemory bytes
+// and in a temporary file beyond that.
+type Transport struct {
+	// Transport sends the requests; a copy of http.DefaultTransport with DisableCompression
+	// set is used if nil. A transport that decompresses responses itself hands over bodies
+	// that are not those received, so an *http.Transport given here should have
+	// DisableCompression set too.
+	Transport http.RoundTripper
+	// Writer receives the records of every exchange, such as a Writer or a WriterPool
+	Writer RecordWriter
+	// WriteMetadata adds a metadata record with fetchTimeMs and via (the Referer) to each exchange
+	WriteMetadata bool
+	// MaxMemory is the number of bytes per body kept in memory; DefaultMaxMemory is used if zero
+	MaxMemory int64
+	// TempDir is where bodies larger than MaxMemory are spooled; os.TempDir() is used if empty
+	TempDir string
+	// MaxPayloadSize, if positive, limits the recorded response payload; the response is
+	// recorded with WARC-Truncated: length as soon as the limit is exceeded
+	MaxPayloadSize int64
+	// MaxFetchDuration, if positive, limits the time from sending the request to recording the
+	// exchange; a response still being read by then is recorded with WARC-Truncated: time
+	MaxFetchDuration time.Duration
+	// RecordTLSInfo adds WARC-Protocol and WARC-Cipher-Suite extension fields to the records of exchanges over TLS
+	RecordTLSInfo bool
+	// ErrorLog receives errors recording exchanges; the log package's standard logger is used if nil
+	ErrorLog *log.Logger
+}
+
+const (
+	// FieldProtocol names the extension field listing the protocols of an exchange, e.g. "h2" and "tls/1.3"
+	FieldProtocol = "WARC-Protocol"
+	// FieldCipherSuite names the extension field holding the TLS cipher suite of an exchange
+	FieldCipherSuite = "WARC-Cipher-Suite"
+)
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "tls/1.0",
+	tls.VersionTLS11: "tls/1.1",
+	tls.VersionTLS12: "tls/1.2",
+	tls.VersionTLS13: "tls/1.3",
+}
+
+// tlsExtensions returns the extension fields describing the connection resp arrived on.
+func tlsExtensions(resp *http.Response) map[string][]string {
+	if resp.TLS == nil {
+		return nil
+	}
+	protocol := "http/1.1"
+	if resp.ProtoMajor == 2 {
+		protocol = "h2"
+	}
+	protocols := []string{protocol}
+	if name, ok := tlsVersionNames[resp.TLS.Version]; ok {
+		protocols = append(protocols, name)
+	}
+	return map[string][]string{
+		FieldProtocol:    protocols,
+		FieldCipherSuite: {tls.CipherSuiteName(resp.TLS.CipherSuite)},
+	}
+}
+
+// defaultTransport sends the requests of Transports without one. Unlike
+// http.DefaultTransport, it asks for no content coding on its own and leaves the bodies
+// of responses as received, so that the caller's Accept-Encoding goes out unchanged and
+// responses are recorded as they came off the wire.
+var defaultTransport http.RoundTripper = newDefaultTransport()
+
+func newDefaultTransport() http.RoundTripper {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+	transport = transport.Clone()
+	transport.DisableCompression = true
+	return transport
+}
+
+// NewTransport returns a Transport archiving exchanges sent with a copy of
+// http.DefaultTransport into w.
+func NewTransport(w RecordWriter) *Transport {
+	return &Transport{Writer: w}
+}
+
+// RoundTrip sends req with the underlying transport and archives the exchange.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ex := &exchange{
+		t:          t,
+		req:        req,
+		start:      time.Now(),
+		reqBody:    newSpool(t.MaxMemory, t.TempDir),
+		reqDigest:  newDigest(),
+		respBody:   newSpool(t.MaxMemory, t.TempDir),
+		respDigest: newDigest(),
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				ex.mu.Lock()
+				ex.ipAddress = host
+				ex.mu.Unlock()
+			}
+		},
+		WroteHeaderField: func(key string, value []string) {
+			if len(key) > 0 && key[0] == ':' {
+				return // HTTP/2 pseudo-header
+			}
+			ex.mu.Lock()
+			if ex.sentHeader == nil {
+				ex.sentHeader = make(http.Header)
+			}
+			for _, v := range value {
+				ex.sentHeader.Add(key, v)
+			}
+			ex.mu.Unlock()
+		},
+	}
+	out := req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	if req.Body != nil && req.Body != http.NoBody {
+		out.Body = &teeBody{rc: req.Body, ex: ex}
+		if req.GetBody != nil {
+			out.GetBody = func() (io.ReadCloser, error) {
+				rc, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				ex.resetRequestBody()
+				return &teeBody{rc: rc, ex: ex}, nil
+			}
+		}
+	}
+
+	transport := t.Transport
+	if transport == nil {
+		transport = defaultTransport
+	}
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		ex.close()
+		return nil, err
+	}
+	if resp.Uncompressed {
+		// The coding and length received are gone, and the body is decoded
+		t.logf("warc: %s: response decompressed by the transport, recorded decoded", req.URL)
+	}
+
+	ex.mu.Lock()
+	ex.resp = resp
+	if t.MaxFetchDuration > 0 {
+		ex.timer = time.AfterFunc(t.MaxFetchDuration-time.Since(ex.start), func() {
+			ex.finish(TruncatedTime)
+		})
+	}
+	ex.mu.Unlock()
+	resp.Body = &recordingBody{rc: resp.Body, ex: ex}
+	return resp, nil
+}
+
+// logf reports an error recording an exchange.
+func (t *Transport) logf(format string, args ...any) {
+	if t.ErrorLog != nil {
+		t.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// exchange is the state of one archived request and response.
+type exchange struct {
+	t     *Transport
+	req   *http.Request
+	resp  *http.Response
+	start time.Time
+
+	mu         sync.Mutex
+	ipAddress  string
+	sentHeader http.Header
+	reqBody    *spool
+	reqDigest  hash.Hash
+	respBody   *spool
+	respDigest hash.Hash
+	timer      *time.Timer
+	err        error
+	done       bool
+}
+
+func (ex *exchange) writeRequestBody(p []byte) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if _, err := ex.reqBody.Write(p); err != nil && ex.err == nil {
+		ex.err = err
+	}
+	ex.reqDigest.Write(p)
+}
+
+func (ex *exchange) resetRequestBody() {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if err := ex.reqBody.Reset(); err != nil && ex.err == nil {
+		ex.err = err
+	}
+	ex.reqDigest.Reset()
+}
+
+// writeResponseBody records a piece of the response body. It reports whether the
+// piece exceeded MaxPayloadSize, in which case only the part within it is recorded.
+func (ex *exchange) writeResponseBody(p []byte) (full bool) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if ex.done {
+		return false
+	}
+	if max := ex.t.MaxPayloadSize; max > 0 && ex.respBody.Len()+int64(len(p)) > max {
+		p = p[:max-ex.respBody.Len()]
+		full = true
+	}
+	if _, err := ex.respBody.Write(p); err != nil && ex.err == nil {
+		ex.err = err
+	}
+	ex.respDigest.Write(p)
+	return full
+}
+
+// finish writes the records of the exchange once. truncated tells why the response body
+// was not read to the end, if it was not.
+func (ex *exchange) finish(truncated TruncatedReason) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	if ex.done {
+		return
+	}
+	ex.done = true
+	defer ex.close()
+
+	if ex.err != nil {
+		ex.t.logf("warc: failed to record %s: %v", ex.req.URL, ex.err)
+		return
+	}
+	if err := ex.write(truncated); err != nil {
+		ex.t.logf("warc: failed to record %s: %v", ex.req.URL, err)
+	}
+}
+
+// write builds the records of the exchange and hands them to the Writer as one group.
+func (ex *exchange) write(truncated TruncatedReason) error {
+	date := ex.start.UTC()
+	targetURI := requestTargetURI(ex.req)
+
+	reqMessage := requestMessage(ex.req, ex.sentHeader, ex.reqBody.Len())
+	request := &Record{
+		WARCRecord: WARCRecord{
+			Version:       WARCVariant1_1,
+			RecordID:      NewRecordID(),
+			ContentLength: uint64(reqMessage.size(ex.reqBody.Len())),
+			Date:          date,
+			Type:          WARCTypeRequest,
+			ContentType:   ContentTypeHTTPRequest,
+			PayloadDigest: formatDigest(ex.reqDigest),
+			IPAddress:     ex.ipAddress,
+			TargetURI:     targetURI,
+		},
+	}
+
+	respMessage := responseMessage(ex.resp)
+	response := &Record{
+		WARCRecord: WARCRecord{
+			Version:       WARCVariant1_1,
+			RecordID:      NewRecordID(),
+			ContentLength: uint64(respMessage.size(ex.respBody.Len())),
+			Date:          date,
+			Type:          WARCTypeResponse,
+			ContentType:   ContentTypeHTTPResponse,
+			PayloadDigest: formatDigest(ex.respDigest),
+			IPAddress:     ex.ipAddress,
+			TargetURI:     targetURI,
+			Truncated:     truncated,
+		},
+	}
+	request.ConcurrentTo = []string{response.RecordID}
+	response.ConcurrentTo = []string{request.RecordID}
+	if ex.t.RecordTLSInfo {
+		request.Extensions = tlsExtensions(ex.resp)
+		response.Extensions = tlsExtensions(ex.resp)
+	}
+
+	var err error
+	if request.BlockDigest, err = blockDigest(reqMessage.reader(ex.reqBody.Reader(), ex.reqBody.Len())); err != nil {
+		return err
+	}
+	if response.BlockDigest, err = blockDigest(respMessage.reader(ex.respBody.Reader(), ex.respBody.Len())); err != nil {
+		return err
+	}
+	request.Block = reqMessage.reader(ex.reqBody.Reader(), ex.reqBody.Len())
+	response.Block = respMessage.reader(ex.respBody.Reader(), ex.respBody.Len())
+
+	records := []any{request, response}
+	if ex.t.WriteMetadata {
+		elapsed := time.Since(ex.start)
+		metadata := &MetadataRecord{
+			WARCRecord: WARCRecord{
+				Version:      WARCVariant1_1,
+				Date:         date,
+				Type:         WARCTypeMetadata,
+				ConcurrentTo: []string{response.RecordID},
+				TargetURI:    targetURI,
+			},
+		}
+		metadata.Via = ex.req.Referer()
+		metadata.FetchTimeMs = uint64((elapsed + time.Millisecond - 1) / time.Millisecond)
+		records = append(records, metadata)
+	}
+
+	return ex.t.Writer.WriteRecords(records...)
+}
+
+// close stops the fetch timer and releases the buffered bodies.
+func (ex *exchange) close() {
+	if ex.timer != nil {
+		ex.timer.Stop()
+	}
+	ex.reqBody.Close()
+	ex.respBody.Close()
+}
+
+// blockDigest returns the labelled digest of everything read from r.
+func blockDigest(r io.Reader) (string, error) {
+	h := newDigest()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return formatDigest(h), nil
+}
+
+// teeBody copies a request body into the exchange as it is sent.
+type teeBody struct {
+	rc io.ReadCloser
+	ex *exchange
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	if n > 0 {
+		b.ex.writeRequestBody(p[:n])
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	return b.rc.Close()
+}
+
+// recordingBody copies a response body into the exchange as the caller reads it,
+// and writes the records when the body is exhausted or closed.
+type recordingBody struct {
+	rc io.ReadCloser
+	ex *exchange
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	if n > 0 && b.ex.writeResponseBody(p[:n]) {
+		b.ex.finish(TruncatedLength)
+	}
+	switch {
+	case err == io.EOF:
+		b.ex.finish("")
+	case err != nil:
+		b.ex.finish(truncationReason(err))
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.rc.Close()
+	b.ex.mu.Lock()
+	complete := b.ex.resp.ContentLength >= 0 && b.ex.respBody.Len() >= b.ex.resp.ContentLength
+	b.ex.mu.Unlock()
+	if complete {
+		b.ex.finish("")
+	} else {
+		b.ex.finish(TruncatedUnspecified)
+	}
+	return err
+}
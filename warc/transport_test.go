@@ -0,0 +1,278 @@
+package warc_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+// readRecords splits uncompressed WARC data into its records.
+func readRecords(t *testing.T, data []byte) []WARCRecord {
+	t.Helper()
+	var records []WARCRecord
+	for len(data) > 0 {
+		end := bytes.Index(data, []byte("\r\n\r\n"))
+		if end < 0 {
+			t.Fatalf("record header is not terminated: %q", data)
+		}
+		var length int
+		for _, line := range strings.Split(string(data[:end]), "\r\n") {
+			if value := strings.TrimPrefix(line, "Content-Length: "); value != line {
+				length, _ = strconv.Atoi(value)
+			}
+		}
+		size := end + 4 + length
+		var record WARCRecord
+		if err := Unmarshal(data[:size], &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+		data = data[size+4:]
+	}
+	return records
+}
+
+func TestTransport(t *testing.T) {
+	large := strings.Repeat("0123456789", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			// Stream the body in pieces
+			for i := 0; i < len(large); i += 4096 {
+				end := i + 4096
+				if end > len(large) {
+					end = len(large)
+				}
+				io.WriteString(w, large[i:end])
+				w.(http.Flusher).Flush()
+			}
+		default:
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(append([]byte("echo: "), body...))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantBody string
+	}{
+		{name: "get", method: http.MethodGet, path: "/large", wantBody: large},
+		{name: "post", method: http.MethodPost, path: "/echo", body: "hello", wantBody: "echo: hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			transport := NewTransport(NewWriter(&buf))
+			transport.Transport = server.Client().Transport
+			transport.WriteMetadata = true
+			transport.MaxMemory = 1024
+			transport.TempDir = t.TempDir()
+			client := &http.Client{Transport: transport}
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Referer", "http://example.com/seed")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if string(got) != tt.wantBody {
+				t.Fatalf("caller body has %d bytes, want %d", len(got), len(tt.wantBody))
+			}
+
+			records := readRecords(t, buf.Bytes())
+			if len(records) != 3 {
+				t.Fatalf("got %d records, want 3", len(records))
+			}
+			request, response, metadata := records[0], records[1], records[2]
+
+			if request.Type != WARCTypeRequest || response.Type != WARCTypeResponse || metadata.Type != WARCTypeMetadata {
+				t.Fatalf("types = %v, %v, %v", request.Type, response.Type, metadata.Type)
+			}
+			if request.TargetURI != server.URL+tt.path || response.TargetURI != request.TargetURI {
+				t.Errorf("TargetURI = %v, %v", request.TargetURI, response.TargetURI)
+			}
+			if response.IPAddress != "127.0.0.1" {
+				t.Errorf("IPAddress = %v, want 127.0.0.1", response.IPAddress)
+			}
+			if response.BlockDigest != Digest(response.Content) {
+				t.Errorf("BlockDigest = %v, want %v", response.BlockDigest, Digest(response.Content))
+			}
+			if response.PayloadDigest != Digest(got) {
+				t.Errorf("PayloadDigest = %v, want %v", response.PayloadDigest, Digest(got))
+			}
+			if response.Truncated != "" {
+				t.Errorf("Truncated = %v", response.Truncated)
+			}
+
+			parsedReq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request.Content)))
+			if err != nil {
+				t.Fatalf("request block is not an HTTP request: %v", err)
+			}
+			if reqBody, _ := io.ReadAll(parsedReq.Body); string(reqBody) != tt.body {
+				t.Errorf("request block body = %q, want %q", reqBody, tt.body)
+			}
+			if parsedReq.Header.Get("Referer") != "http://example.com/seed" {
+				t.Errorf("request block Referer = %q", parsedReq.Header.Get("Referer"))
+			}
+
+			parsedResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response.Content)), nil)
+			if err != nil {
+				t.Fatalf("response block is not an HTTP response: %v", err)
+			}
+			if respBody, _ := io.ReadAll(parsedResp.Body); string(respBody) != tt.wantBody {
+				t.Errorf("response block body has %d bytes, want %d", len(respBody), len(tt.wantBody))
+			}
+
+			var meta MetadataRecord
+			if err := Unmarshal(mustMarshal(t, metadata), &meta); err != nil {
+				t.Fatal(err)
+			}
+			if meta.Via != "http://example.com/seed" || meta.FetchTimeMs == 0 {
+				t.Errorf("metadata via, fetchTimeMs = %q, %d", meta.Via, meta.FetchTimeMs)
+			}
+			if len(meta.ConcurrentTo) != 1 || meta.ConcurrentTo[0] != response.RecordID {
+				t.Errorf("metadata ConcurrentTo = %v, want %v", meta.ConcurrentTo, response.RecordID)
+			}
+		})
+	}
+}
+
+func TestTransportEarlyClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 100000))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	transport := NewTransport(NewWriter(&buf))
+	transport.Transport = server.Client().Transport
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadFull(resp.Body, make([]byte, 10))
+	resp.Body.Close()
+
+	records := readRecords(t, buf.Bytes())
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[1].Truncated == "" {
+		t.Errorf("Truncated is empty for a partially read body")
+	}
+}
+
+func TestTransportCompression(t *testing.T) {
+	payload := strings.Repeat("hello world ", 100)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	io.WriteString(gz, payload)
+	gz.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(compressed.Bytes())
+			return
+		}
+		io.WriteString(w, payload)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           []byte
+	}{
+		{name: "identity", want: []byte(payload)},
+		{name: "gzip", acceptEncoding: "gzip", want: compressed.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The default transport, as the server needs no TLS
+			var buf bytes.Buffer
+			client := &http.Client{Transport: NewTransport(NewWriter(&buf))}
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("caller body = %q, want %q", got, tt.want)
+			}
+
+			records := readRecords(t, buf.Bytes())
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want 2", len(records))
+			}
+			request, response := records[0], records[1]
+			parsedReq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request.Content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsedReq.Header.Get("Accept-Encoding"); got != tt.acceptEncoding {
+				t.Errorf("recorded Accept-Encoding = %q, want %q", got, tt.acceptEncoding)
+			}
+			parsedResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response.Content)), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsedResp.Header.Get("Content-Encoding"); got != tt.acceptEncoding {
+				t.Errorf("recorded Content-Encoding = %q, want %q", got, tt.acceptEncoding)
+			}
+			if parsedResp.ContentLength != int64(len(tt.want)) {
+				t.Errorf("recorded Content-Length = %d, want %d", parsedResp.ContentLength, len(tt.want))
+			}
+			if body, _ := io.ReadAll(parsedResp.Body); !bytes.Equal(body, tt.want) {
+				t.Errorf("recorded body = %q, want %q", body, tt.want)
+			}
+			if response.PayloadDigest != Digest(tt.want) {
+				t.Errorf("PayloadDigest = %s, want %s", response.PayloadDigest, Digest(tt.want))
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,79 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// IsTruncated reports whether the record's content block was cut short, so that its
+// payload is incomplete. The reason is given by Truncated.
+func (w *WARCRecord) IsTruncated() bool {
+	return w.Truncated != ""
+}
+
+// truncate cuts the block of a record whose payload exceeds MaxPayloadSize and marks it
+// with WARC-Truncated: length. The payload of an HTTP record is what follows its header;
+// the header itself is always kept. Digests are computed again over the bytes kept, the
+// payload digest over the payload they hold once its chunked coding is removed. The
+// returned func releases the buffer holding the cut block.
+func (w *Writer) truncate(record *Record) (*Record, func(), error) {
+	noop := func() {}
+	if w.MaxPayloadSize <= 0 || record.ContentLength <= uint64(w.MaxPayloadSize) {
+		return record, noop, nil
+	}
+
+	block := bufio.NewReader(io.LimitReader(record.Block, int64(record.ContentLength)))
+	var head []byte
+	if strings.HasPrefix(record.ContentType, "application/http") {
+		var err error
+		if head, err = readHTTPHead(block); err != nil {
+			return nil, nil, err
+		}
+	}
+	if record.ContentLength-uint64(len(head)) <= uint64(w.MaxPayloadSize) {
+		kept := *record
+		kept.Block = io.MultiReader(bytes.NewReader(head), block)
+		return &kept, noop, nil
+	}
+
+	spool := newSpool(DefaultMaxMemory, "")
+	blockHash, payloadHash := newDigest(), newPayloadHash(isChunked(head))
+	spool.Write(head)
+	blockHash.Write(head)
+	if _, err := io.CopyN(io.MultiWriter(spool, blockHash, payloadHash), block, w.MaxPayloadSize); err != nil {
+		spool.Close()
+		return nil, nil, err
+	}
+
+	truncated := *record
+	truncated.ContentLength = uint64(spool.Len())
+	truncated.BlockDigest = formatDigest(blockHash)
+	if record.PayloadDigest != "" {
+		truncated.PayloadDigest = payloadHash.digest()
+	}
+	if truncated.Truncated == "" {
+		truncated.Truncated = TruncatedLength
+	}
+	truncated.Block = spool.Reader()
+	return &truncated, func() { spool.Close() }, nil
+}
+
+// truncationReason returns why reading a body failed with err.
+func truncationReason(err error) TruncatedReason {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return TruncatedTime
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return TruncatedTime
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, net.ErrClosed):
+		return TruncatedDisconnect
+	}
+	return TruncatedUnspecified
+}
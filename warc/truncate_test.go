@@ -0,0 +1,203 @@
+package warc_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+// syncBuffer is a bytes.Buffer safe for records written from timers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func TestWriterMaxPayloadSize(t *testing.T) {
+	head := "HTTP/1.1 200 OK\r\nContent-Length: 26\r\n\r\n"
+	chunkedHead := "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n"
+	tests := []struct {
+		name          string
+		record        *WARCRecord
+		wantBlock     string
+		wantPayload   string
+		wantTruncated TruncatedReason
+	}{
+		{
+			name:          "resource",
+			record:        &WARCRecord{Type: WARCTypeResource, Content: []byte("abcdefghijklmnopqrstuvwxyz")},
+			wantBlock:     "abcdefghij",
+			wantTruncated: TruncatedLength,
+		},
+		{
+			name: "http response keeps its header",
+			record: &WARCRecord{
+				Type:          WARCTypeResponse,
+				ContentType:   ContentTypeHTTPResponse,
+				PayloadDigest: Digest([]byte("abcdefghijklmnopqrstuvwxyz")),
+				Content:       []byte(head + "abcdefghijklmnopqrstuvwxyz"),
+			},
+			wantBlock:     head + "abcdefghij",
+			wantPayload:   "abcdefghij",
+			wantTruncated: TruncatedLength,
+		},
+		{
+			name: "chunked http response",
+			record: &WARCRecord{
+				Type:          WARCTypeResponse,
+				ContentType:   ContentTypeHTTPResponse,
+				PayloadDigest: Digest([]byte("abcdefghijklmnopqrstuvwxyz")),
+				Content:       []byte(chunkedHead + "1a\r\nabcdefghijklmnopqrstuvwxyz\r\n0\r\n\r\n"),
+			},
+			wantBlock:     chunkedHead + "1a\r\nabcdef",
+			wantPayload:   "abcdef",
+			wantTruncated: TruncatedLength,
+		},
+		{
+			name:      "within the limit",
+			record:    &WARCRecord{Type: WARCTypeResource, Content: []byte("abcdefghij")},
+			wantBlock: "abcdefghij",
+		},
+		{
+			name:          "keeps an earlier reason",
+			record:        &WARCRecord{Type: WARCTypeResource, Truncated: TruncatedDisconnect, Content: []byte("abcdefghijklmnopqrstuvwxyz")},
+			wantBlock:     "abcdefghij",
+			wantTruncated: TruncatedDisconnect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.MaxPayloadSize = 10
+			if err := w.WriteRecord(tt.record); err != nil {
+				t.Fatal(err)
+			}
+
+			records := readRecords(t, buf.Bytes())
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			got := records[0]
+			if string(got.Content) != tt.wantBlock {
+				t.Errorf("block = %q, want %q", got.Content, tt.wantBlock)
+			}
+			if got.BlockDigest != Digest([]byte(tt.wantBlock)) {
+				t.Errorf("BlockDigest = %v, want %v", got.BlockDigest, Digest([]byte(tt.wantBlock)))
+			}
+			if tt.wantPayload != "" && got.PayloadDigest != Digest([]byte(tt.wantPayload)) {
+				t.Errorf("PayloadDigest = %v, want %v", got.PayloadDigest, Digest([]byte(tt.wantPayload)))
+			}
+			if got.Truncated != tt.wantTruncated || got.IsTruncated() != (tt.wantTruncated != "") {
+				t.Errorf("Truncated = %q, IsTruncated() = %v, want %q", got.Truncated, got.IsTruncated(), tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestTransportLimits(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			w.(http.Flusher).Flush()
+			io.WriteString(w, body[:100])
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+			io.WriteString(w, body[100:])
+		default:
+			io.WriteString(w, body)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		configure     func(*Transport)
+		wantPayload   int
+		wantTruncated TruncatedReason
+	}{
+		{
+			name:          "payload size",
+			path:          "/",
+			configure:     func(t *Transport) { t.MaxPayloadSize = 10 },
+			wantPayload:   10,
+			wantTruncated: TruncatedLength,
+		},
+		{
+			name:          "fetch duration",
+			path:          "/slow",
+			configure:     func(t *Transport) { t.MaxFetchDuration = 50 * time.Millisecond },
+			wantPayload:   100,
+			wantTruncated: TruncatedTime,
+		},
+		{
+			name:        "within limits",
+			path:        "/",
+			configure:   func(t *Transport) { t.MaxPayloadSize = 1000; t.MaxFetchDuration = time.Minute },
+			wantPayload: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf syncBuffer
+			transport := NewTransport(NewWriter(&buf))
+			transport.Transport = server.Client().Transport
+			tt.configure(transport)
+			client := &http.Client{Transport: transport}
+
+			resp, err := client.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != body {
+				t.Errorf("caller body has %d bytes, want %d", len(got), len(body))
+			}
+
+			records := readRecords(t, buf.Bytes())
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want 2", len(records))
+			}
+			response := records[1]
+			if response.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %q, want %q", response.Truncated, tt.wantTruncated)
+			}
+			if response.BlockDigest != Digest(response.Content) {
+				t.Errorf("BlockDigest = %v, want %v", response.BlockDigest, Digest(response.Content))
+			}
+			wantPayload := []byte(body[:tt.wantPayload])
+			if !bytes.Contains(response.Content, wantPayload) || bytes.Contains(response.Content, []byte(strings.Repeat("x", tt.wantPayload+1))) {
+				t.Errorf("response block does not hold exactly the %d recorded bytes", tt.wantPayload)
+			}
+			if response.PayloadDigest != Digest(wantPayload) {
+				t.Errorf("PayloadDigest = %v, want %v", response.PayloadDigest, Digest(wantPayload))
+			}
+		})
+	}
+}
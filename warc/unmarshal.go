@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
@@ -12,24 +13,42 @@ import (
 )
 
 // Unmarshal parses WARC formatted data and stores the result in the value pointed to by v.
+// It applies DefaultLimits.
 func Unmarshal[T any](data []byte, v T) error {
+	return UnmarshalWithLimits(data, v, Limits{})
+}
+
+// limitedUnmarshaler is implemented by the records of this package that parse their block
+// after their header, so that UnmarshalWithLimits applies its limits to them.
+type limitedUnmarshaler interface {
+	unmarshalWARCRecord(data []byte, limits Limits) error
+}
+
+// UnmarshalWithLimits is like Unmarshal, but fails when data exceeds limits. Parse failures
+// are *ParseErrors, wrapping a *LimitError for limits exceeded.
+func UnmarshalWithLimits[T any](data []byte, v T, limits Limits) error {
+	// Records parsing their blocks apply limits to their header too
+	if unmarshaler, ok := any(v).(limitedUnmarshaler); ok {
+		return unmarshaler.unmarshalWARCRecord(data, limits)
+	}
 	// Check if v implements WARCRecordUnmarshaler
 	if unmarshaler, ok := any(v).(WARCRecordUnmarshaler); ok {
 		return unmarshaler.UnmarshalWARCRecord(data)
 	}
 
-	reader := bufio.NewReader(bytes.NewReader(data))
+	src := bytes.NewReader(data)
+	reader := &headerReader{br: bufio.NewReader(src), limits: limits.withDefaults()}
 
-	versionLine, err := reader.ReadString('\n')
+	versionLine, err := reader.readLine()
 	if err != nil {
-		return fmt.Errorf("failed to read version: %v", err)
+		return reader.headerError(CategorySyntax, fmt.Errorf("failed to read version: %w", err))
 	}
 
 	version := strings.TrimSpace(strings.TrimPrefix(versionLine, "WARC/"))
 
 	warcVersion := WARCVariant(version)
 	if warcVersion != WARCVariant1_0 && warcVersion != WARCVariant1_1 {
-		return fmt.Errorf("unsupported WARC version: %s", version)
+		return reader.headerError(CategoryVersion, fmt.Errorf("unsupported WARC version: %s", version))
 	}
 
 	val := reflect.ValueOf(v)
@@ -44,10 +63,11 @@ func Unmarshal[T any](data []byte, v T) error {
 	}
 
 	headers := make(map[string]string)
+	occurrences := make(map[string][]string)
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := reader.readLine()
 		if err != nil {
-			return fmt.Errorf("failed to read header: %v", err)
+			return reader.headerError(CategorySyntax, fmt.Errorf("failed to read header: %w", err))
 		}
 
 		line = strings.TrimSpace(line)
@@ -57,17 +77,35 @@ func Unmarshal[T any](data []byte, v T) error {
 
 		parts := strings.SplitN(line, ":", 2)
 		if len(parts) != 2 {
-			return fmt.Errorf("invalid header format: %s", line)
+			return reader.headerError(CategorySyntax, fmt.Errorf("invalid header format: %s", line))
 		}
 
-		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		if err := reader.addField(); err != nil {
+			return reader.headerError(CategoryLimits, err)
+		}
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "WARC-Record-ID" {
+			reader.recordID = value
+		}
+		headers[name] = value
+		occurrences[name] = append(occurrences[name], value)
 	}
 
 	contentLength, _ := strconv.ParseInt(headers["Content-Length"], 10, 64)
+	if contentLength < 0 {
+		return reader.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("invalid Content-Length value: %d", contentLength))
+	}
+	if err := reader.checkLength(contentLength, true); err != nil {
+		return reader.fieldError(CategoryLimits, "Content-Length", err)
+	}
+	if contentLength > int64(src.Len()+reader.br.Buffered()) {
+		// Allocate nothing for a block the data cannot hold
+		return reader.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("failed to read content: %w", io.ErrUnexpectedEOF))
+	}
 	content := make([]byte, contentLength)
-	_, err = reader.Read(content)
+	_, err = io.ReadFull(reader.br, content)
 	if err != nil {
-		return fmt.Errorf("failed to read content: %v", err)
+		return reader.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("failed to read content: %w", err))
 	}
 	contentField := elem.FieldByName("Content")
 	if contentField.IsValid() && contentField.CanSet() {
@@ -78,7 +116,15 @@ func Unmarshal[T any](data []byte, v T) error {
 		return errors.New("v must be a pointer")
 	}
 
+	return decodeHeader(elem, headers, occurrences)
+}
+
+// decodeHeader sets the tagged fields of elem from the named fields of a record. headers holds
+// the last value of each field and occurrences all of them. Fields without a tag go into the
+// Extensions map, when elem has one.
+func decodeHeader(elem reflect.Value, headers map[string]string, occurrences map[string][]string) error {
 	typ := elem.Type()
+	known := map[string]bool{"Content-Length": true}
 
 	for i := 0; i < elem.NumField(); i++ {
 		field := elem.Field(i)
@@ -91,17 +137,38 @@ func Unmarshal[T any](data []byte, v T) error {
 
 		tagParts := strings.Split(tag, ",")
 		headerName := tagParts[0]
+		known[headerName] = true
+		if headerName == "Content-Length" {
+			// Describes the block as read, not a value to keep
+			continue
+		}
 
 		value, exists := headers[headerName]
 		if !exists && len(tagParts) > 1 && tagParts[1] == "omitempty" {
 			continue
 		}
 
+		if field.Kind() == reflect.Slice {
+			setSliceField(field, occurrences[headerName])
+			continue
+		}
 		if err := setField(field, value); err != nil {
 			return fmt.Errorf("failed to set field %s: %v", fieldType.Name, err)
 		}
 	}
 
+	if extensions := elem.FieldByName("Extensions"); extensions.IsValid() && extensions.Type() == reflect.TypeOf(map[string][]string(nil)) {
+		values := make(map[string][]string)
+		for name, occurrence := range occurrences {
+			if !known[name] {
+				values[name] = occurrence
+			}
+		}
+		if len(values) > 0 {
+			extensions.Set(reflect.ValueOf(values))
+		}
+	}
+
 	return nil
 }
 
@@ -113,6 +180,10 @@ func setField(field reflect.Value, value string) error {
 		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
 			field.SetInt(v)
 		}
+	case reflect.Uint, reflect.Uint64:
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			field.SetUint(v)
+		}
 	case reflect.Struct:
 		if field.Type() == reflect.TypeOf(time.Time{}) {
 			if t, err := time.Parse(time.RFC3339, value); err == nil {
@@ -123,27 +194,45 @@ func setField(field reflect.Value, value string) error {
 	return nil
 }
 
-// Valid checks if the provided data is a valid WARC formatted data.
+// setSliceField sets a string slice field from every occurrence of a header.
+// Comma-separated values within one occurrence become separate elements.
+func setSliceField(field reflect.Value, occurrences []string) {
+	if field.Type().Elem().Kind() != reflect.String {
+		return
+	}
+	var values []string
+	for _, occurrence := range occurrences {
+		for _, value := range strings.Split(occurrence, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	field.Set(reflect.ValueOf(values).Convert(field.Type()))
+}
+
+// Valid checks if the provided data is a valid WARC formatted data. Its errors are
+// *ParseErrors, wrapping a *LimitError when data exceeds DefaultLimits.
 func Valid(data []byte) error {
-	reader := bufio.NewReader(bytes.NewReader(data))
+	reader := &headerReader{br: bufio.NewReader(bytes.NewReader(data)), limits: DefaultLimits}
 
-	versionLine, err := reader.ReadString('\n')
+	versionLine, err := reader.readLine()
 	if err != nil {
-		return fmt.Errorf("failed to read version: %v", err)
+		return reader.headerError(CategorySyntax, fmt.Errorf("failed to read version: %w", err))
 	}
 
 	version := strings.TrimSpace(strings.TrimPrefix(versionLine, "WARC/"))
 
 	warcVersion := WARCVariant(version)
 	if warcVersion != WARCVariant1_0 && warcVersion != WARCVariant1_1 {
-		return fmt.Errorf("unsupported WARC version: %s", version)
+		return reader.headerError(CategoryVersion, fmt.Errorf("unsupported WARC version: %s", version))
 	}
 
 	headers := make(map[string]string)
 	for {
-		line, err := reader.ReadString('\n')
+		line, err := reader.readLine()
 		if err != nil {
-			return fmt.Errorf("failed to read header: %v", err)
+			return reader.headerError(CategorySyntax, fmt.Errorf("failed to read header: %w", err))
 		}
 
 		line = strings.TrimSpace(line)
@@ -153,25 +242,38 @@ func Valid(data []byte) error {
 
 		parts := strings.SplitN(line, ":", 2)
 		if len(parts) != 2 {
-			return fmt.Errorf("invalid header format: %s", line)
+			return reader.headerError(CategorySyntax, fmt.Errorf("invalid header format: %s", line))
 		}
 
-		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		if err := reader.addField(); err != nil {
+			return reader.headerError(CategoryLimits, err)
+		}
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "WARC-Record-ID" {
+			reader.recordID = value
+		}
+		headers[name] = value
 	}
 
 	if _, exists := headers["Content-Length"]; !exists {
-		return errors.New("missing Content-Length header")
+		return reader.fieldError(CategorySyntax, "Content-Length", errors.New("missing Content-Length header"))
 	}
 
 	contentLength, err := strconv.ParseInt(headers["Content-Length"], 10, 64)
 	if err != nil {
-		return fmt.Errorf("invalid Content-Length value: %v", err)
+		return reader.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("invalid Content-Length value: %w", err))
 	}
 
-	content := make([]byte, contentLength)
-	_, err = reader.Read(content)
-	if err != nil {
-		return fmt.Errorf("failed to read content: %v", err)
+	if contentLength < 0 {
+		return reader.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("invalid Content-Length value: %d", contentLength))
+	}
+	if err := reader.checkLength(contentLength, false); err != nil {
+		return reader.fieldError(CategoryLimits, "Content-Length", err)
+	}
+	if contentLength > 0 {
+		if _, err := reader.br.Peek(1); err != nil {
+			return reader.fieldError(CategorySyntax, "Content-Length", fmt.Errorf("failed to read content: %w", err))
+		}
 	}
 
 	return nil
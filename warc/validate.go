@@ -0,0 +1,451 @@
+package warc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity tells how serious a Finding is.
+type Severity int
+
+const (
+	// SeverityWarning marks a departure from what the specification recommends
+	SeverityWarning Severity = iota
+	// SeverityError marks a violation of what the specification requires
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Finding is a problem found in a record by ValidateReader or ValidateRecord.
+type Finding struct {
+	// Severity tells whether the problem breaks a requirement or a recommendation
+	Severity Severity
+	// Offset is the offset of the record in its input
+	Offset int64
+	// RecordID is the WARC-Record-ID of the record, if known
+	RecordID string
+	// Field names the header field, or the field of an application/warc-fields block, at fault
+	Field string
+	// Profile names the validation profile whose rule the record breaks; it is empty for
+	// problems with the framing of the record
+	Profile string
+	// Message describes the problem
+	Message string
+}
+
+func (f Finding) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "offset %d: %s: ", f.Offset, f.Severity)
+	if f.RecordID != "" {
+		fmt.Fprintf(&b, "%s: ", f.RecordID)
+	}
+	if f.Field != "" {
+		fmt.Fprintf(&b, "%s: ", f.Field)
+	}
+	b.WriteString(f.Message)
+	return b.String()
+}
+
+// ValidateReader reads every record from r, plain or gzip compressed, and applies the
+// rules of profiles to it, or those of SpecMinimumProfile if none are given. It also checks
+// that every block is as long as its Content-Length and followed by two CRLFs, and that
+// SHA-1 block digests match. All findings are returned; reading stops at the first record
+// that cannot be parsed.
+func ValidateReader(r io.Reader, profiles ...*ValidationProfile) []Finding {
+	profiles = expandProfiles(profiles)
+	reader, err := NewReader(r)
+	if err != nil {
+		return []Finding{{Severity: SeverityError, Message: err.Error()}}
+	}
+
+	var findings []Finding
+	var previous *checker
+	for {
+		record, err := reader.Next()
+		if previous != nil && !bytes.Equal(reader.separator, []byte("\r\n\r\n")) {
+			previous.errorf("", "block is not followed by two CRLFs; Content-Length may not match the block")
+			findings = append(findings, previous.findings...)
+		}
+		if err == io.EOF {
+			return findings
+		}
+		if err != nil {
+			finding := Finding{Severity: SeverityError, Offset: reader.offset, Message: err.Error()}
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				finding.RecordID, finding.Field, finding.Message = parseErr.RecordID, parseErr.Field, parseErr.Err.Error()
+			}
+			return append(findings, finding)
+		}
+
+		c := &checker{offset: record.Offset, id: record.RecordID}
+		digest := newDigest()
+		var block bytes.Buffer
+		out := io.Writer(digest)
+		if isWARCFields(record.ContentType) && record.ContentLength <= DefaultMaxMemory {
+			out = io.MultiWriter(digest, &block)
+		}
+		if _, err := io.Copy(out, record.Block); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = fmt.Errorf("block is shorter than its Content-Length of %d bytes", record.ContentLength)
+			}
+			findings = append(findings, checkProfiles(record, profiles)...)
+			c.errorf("Content-Length", "%v", err)
+			return append(findings, c.findings...)
+		}
+		record.Content = block.Bytes()
+		findings = append(findings, checkProfiles(record, profiles)...)
+		if algorithm, _, _ := strings.Cut(record.BlockDigest, ":"); strings.EqualFold(algorithm, DigestAlgorithm) && !strings.EqualFold(record.BlockDigest, formatDigest(digest)) {
+			c.errorf("WARC-Block-Digest", "digest %s does not match the block, whose digest is %s", record.BlockDigest, formatDigest(digest))
+		}
+		findings = append(findings, c.findings...)
+		c.findings = nil
+		previous = c
+	}
+}
+
+// ValidateRecord applies the rules of profiles to a record, or those of SpecMinimumProfile
+// if none are given. v may be any of the record types accepted by Writer.WriteRecord; for
+// records not read by a Reader, the header checked is the one the record would be written
+// with. SpecMinimumProfile checks the header against the specification of the record's
+// version: mandatory fields, fields forbidden for its type, and the syntax of field values.
+// Streamed blocks are not read.
+func ValidateRecord(v any, profiles ...*ValidationProfile) []Finding {
+	var record *Record
+	switch r := v.(type) {
+	case *Record:
+		record = r
+	case Record:
+		record = &r
+	case warcRecorder:
+		rec := r.warcRecord()
+		rec.ContentLength = uint64(len(rec.Content))
+		record = &Record{WARCRecord: rec}
+	default:
+		return []Finding{{Severity: SeverityError, Message: fmt.Sprintf("unsupported record type %T", v)}}
+	}
+	return checkProfiles(record, expandProfiles(profiles))
+}
+
+// isWARCFields reports whether contentType is that of an application/warc-fields block.
+func isWARCFields(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), ContentTypeWARCFields)
+}
+
+// checker collects the findings of one record.
+type checker struct {
+	offset   int64
+	id       string
+	findings []Finding
+}
+
+func (c *checker) errorf(field, format string, args ...any) {
+	c.add(SeverityError, field, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) warnf(field, format string, args ...any) {
+	c.add(SeverityWarning, field, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) add(severity Severity, field, message string) {
+	c.findings = append(c.findings, Finding{Severity: severity, Offset: c.offset, RecordID: c.id, Field: field, Message: message})
+}
+
+// recordFields returns the named fields of record, as read or as they would be written.
+func recordFields(record *Record) []warcField {
+	if record.fields != nil {
+		return record.fields
+	}
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, reflect.ValueOf(record.WARCRecord), int64(record.ContentLength)); err != nil {
+		return nil
+	}
+	var fields []warcField
+	for _, line := range strings.Split(buf.String(), "\r\n")[1:] {
+		if name, value, found := strings.Cut(line, ":"); found {
+			fields = append(fields, warcField{name, strings.TrimSpace(value)})
+		}
+	}
+	return fields
+}
+
+// repeatableFields are the fields, in lower case, that may appear more than once in a header.
+var repeatableFields = map[string]bool{
+	"warc-concurrent-to": true,
+	"warc-protocol":      true,
+}
+
+var allRecordTypes = []WARCRecordType{
+	WARCTypeWarcinfo, WARCTypeResponse, WARCTypeResource, WARCTypeRequest,
+	WARCTypeMetadata, WARCTypeRevisit, WARCTypeConversion, WARCTypeContinuation,
+}
+
+// fieldRules lists the record types that must have a field and those it is forbidden in,
+// following sections 5 and 6 of the WARC 1.1 specification.
+var fieldRules = []struct {
+	field     string
+	required  []WARCRecordType
+	forbidden []WARCRecordType
+}{
+	{"WARC-Target-URI", []WARCRecordType{WARCTypeResponse, WARCTypeResource, WARCTypeRequest, WARCTypeRevisit, WARCTypeConversion, WARCTypeContinuation}, nil},
+	{"WARC-Concurrent-To", nil, []WARCRecordType{WARCTypeWarcinfo, WARCTypeConversion, WARCTypeContinuation}},
+	{"WARC-Refers-To", nil, []WARCRecordType{WARCTypeWarcinfo, WARCTypeResponse, WARCTypeResource, WARCTypeRequest, WARCTypeContinuation}},
+	{"WARC-Refers-To-Target-URI", nil, allExcept(WARCTypeRevisit)},
+	{"WARC-Refers-To-Date", nil, allExcept(WARCTypeRevisit)},
+	{"WARC-Profile", []WARCRecordType{WARCTypeRevisit}, nil},
+	{"WARC-Filename", nil, allExcept(WARCTypeWarcinfo)},
+	{"WARC-Warcinfo-ID", nil, []WARCRecordType{WARCTypeWarcinfo}},
+	{"WARC-Segment-Origin-ID", []WARCRecordType{WARCTypeContinuation}, allExcept(WARCTypeContinuation)},
+	{"WARC-Segment-Number", []WARCRecordType{WARCTypeContinuation}, nil},
+	{"WARC-Segment-Total-Length", nil, allExcept(WARCTypeContinuation)},
+}
+
+func allExcept(except WARCRecordType) []WARCRecordType {
+	var types []WARCRecordType
+	for _, t := range allRecordTypes {
+		if t != except {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func containsType(types []WARCRecordType, t WARCRecordType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	dateFormat1_0 = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`)
+	dateFormat1_1 = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,9})?Z$`)
+)
+
+// checkRecord checks the header of record.
+func checkRecord(record *Record) *checker {
+	c := &checker{offset: record.Offset, id: record.RecordID}
+	if record.Version != WARCVariant1_0 && record.Version != WARCVariant1_1 {
+		c.errorf("", "unsupported WARC version %q", record.Version)
+	}
+	fields := recordFields(record)
+
+	values := make(map[string][]string)
+	for _, field := range fields {
+		key := strings.ToLower(field.name)
+		values[key] = append(values[key], field.value)
+	}
+	get := func(name string) (string, bool) {
+		v, ok := values[strings.ToLower(name)]
+		if !ok {
+			return "", false
+		}
+		return v[0], true
+	}
+
+	reported := make(map[string]bool)
+	for _, field := range fields {
+		key := strings.ToLower(field.name)
+		if n := len(values[key]); n > 1 && !repeatableFields[key] && !reported[key] {
+			c.errorf(field.name, "appears %d times but may appear only once", n)
+			reported[key] = true
+		}
+	}
+
+	for _, name := range []string{"WARC-Record-ID", "Content-Length", "WARC-Date", "WARC-Type"} {
+		if _, ok := get(name); !ok {
+			c.errorf(name, "mandatory field is missing")
+		}
+	}
+
+	if id, ok := get("WARC-Record-ID"); ok {
+		c.checkID("WARC-Record-ID", id)
+	}
+	length, hasLength := get("Content-Length")
+	if hasLength && !isDigits(length) {
+		c.errorf("Content-Length", "%q is not a decimal number", length)
+	}
+	if date, ok := get("WARC-Date"); ok {
+		c.checkDate(record.Version, "WARC-Date", date)
+	}
+
+	typ, _ := get("WARC-Type")
+	recordType := WARCRecordType(typ)
+	known := containsType(allRecordTypes, recordType)
+	if typ != "" && !known {
+		c.warnf("WARC-Type", "unknown record type %q", typ)
+	}
+
+	if known {
+		for _, rule := range fieldRules {
+			_, present := get(rule.field)
+			switch {
+			case !present && containsType(rule.required, recordType):
+				c.errorf(rule.field, "mandatory for %s records", recordType)
+			case present && containsType(rule.forbidden, recordType):
+				c.errorf(rule.field, "must not be used in %s records", recordType)
+			}
+		}
+	}
+
+	if contentType, ok := get("Content-Type"); ok {
+		c.checkMediaType("Content-Type", contentType)
+	} else if hasLength && length != "0" && recordType != WARCTypeContinuation {
+		c.warnf("Content-Type", "should be present for records with a block")
+	}
+	if payloadType, ok := get("WARC-Identified-Payload-Type"); ok {
+		c.checkMediaType("WARC-Identified-Payload-Type", payloadType)
+	}
+
+	if uri, ok := get("WARC-Target-URI"); ok {
+		c.checkTargetURI("WARC-Target-URI", uri)
+	}
+	if uri, ok := get("WARC-Refers-To-Target-URI"); ok {
+		c.checkTargetURI("WARC-Refers-To-Target-URI", uri)
+	}
+	for _, value := range values["warc-concurrent-to"] {
+		for _, id := range strings.Split(value, ",") {
+			c.checkID("WARC-Concurrent-To", strings.TrimSpace(id))
+		}
+	}
+	for _, name := range []string{"WARC-Refers-To", "WARC-Warcinfo-ID", "WARC-Segment-Origin-ID"} {
+		if id, ok := get(name); ok {
+			c.checkID(name, id)
+		}
+	}
+	for _, name := range []string{"WARC-Block-Digest", "WARC-Payload-Digest"} {
+		if digest, ok := get(name); ok {
+			if algorithm, value, found := strings.Cut(digest, ":"); !found || algorithm == "" || value == "" {
+				c.errorf(name, "%q is not of the form algorithm:value", digest)
+			}
+		}
+	}
+	if ip, ok := get("WARC-IP-Address"); ok && net.ParseIP(ip) == nil {
+		c.errorf("WARC-IP-Address", "%q is not an IPv4 or IPv6 address", ip)
+	}
+	if truncated, ok := get("WARC-Truncated"); ok {
+		switch TruncatedReason(truncated) {
+		case TruncatedLength, TruncatedTime, TruncatedDisconnect, TruncatedUnspecified:
+		default:
+			c.warnf("WARC-Truncated", "unknown reason %q", truncated)
+		}
+	}
+
+	if number, ok := get("WARC-Segment-Number"); ok {
+		n, err := strconv.Atoi(number)
+		switch {
+		case err != nil || n < 1:
+			c.errorf("WARC-Segment-Number", "%q is not a positive integer", number)
+		case recordType == WARCTypeContinuation && n < 2:
+			c.errorf("WARC-Segment-Number", "continuation records start at segment 2, not %d", n)
+		case known && recordType != WARCTypeContinuation && n != 1:
+			c.errorf("WARC-Segment-Number", "a first segment has number 1, not %d", n)
+		}
+	}
+	if total, ok := get("WARC-Segment-Total-Length"); ok && !isDigits(total) {
+		c.errorf("WARC-Segment-Total-Length", "%q is not a decimal number", total)
+	}
+
+	if recordType == WARCTypeRevisit {
+		if _, ok := get("WARC-Refers-To"); !ok {
+			c.warnf("WARC-Refers-To", "should identify the record a revisit refers to")
+		}
+		if profile, _ := get("WARC-Profile"); strings.HasSuffix(profile, "/revisit/identical-payload-digest") {
+			if _, ok := get("WARC-Payload-Digest"); !ok {
+				c.errorf("WARC-Payload-Digest", "mandatory for revisits with the identical-payload-digest profile")
+			}
+		}
+	}
+	for _, name := range []string{"WARC-Refers-To-Target-URI", "WARC-Refers-To-Date"} {
+		_, ok := get(name)
+		switch {
+		case ok && record.Version == WARCVariant1_0:
+			c.warnf(name, "is defined by WARC 1.1, not 1.0")
+		case !ok && record.Version == WARCVariant1_1 && recordType == WARCTypeRevisit:
+			c.warnf(name, "should be present in WARC 1.1 revisit records")
+		}
+	}
+	if date, ok := get("WARC-Refers-To-Date"); ok {
+		c.checkDate(WARCVariant1_1, "WARC-Refers-To-Date", date)
+	}
+	return c
+}
+
+// checkID checks a record ID: a URI within angle brackets.
+func (c *checker) checkID(field, id string) {
+	if !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, ">") {
+		c.errorf(field, "record ID %q is not enclosed in angle brackets", id)
+		return
+	}
+	if u, err := url.Parse(id[1 : len(id)-1]); err != nil || u.Scheme == "" {
+		c.errorf(field, "record ID %q is not an absolute URI", id)
+	}
+}
+
+// checkTargetURI checks a URI given without angle brackets.
+func (c *checker) checkTargetURI(field, uri string) {
+	if strings.HasPrefix(uri, "<") && strings.HasSuffix(uri, ">") {
+		c.warnf(field, "URI %q should not be enclosed in angle brackets", uri)
+		uri = uri[1 : len(uri)-1]
+	}
+	if u, err := url.Parse(uri); err != nil || u.Scheme == "" {
+		c.errorf(field, "%q is not an absolute URI", uri)
+	}
+}
+
+// checkDate checks a date in the W3C profile of ISO 8601 allowed by version: to the second
+// in WARC 1.0, optionally with a fraction of a second in WARC 1.1, always in UTC.
+func (c *checker) checkDate(version WARCVariant, field, date string) {
+	format := dateFormat1_1
+	if version == WARCVariant1_0 {
+		format = dateFormat1_0
+	}
+	if !format.MatchString(date) {
+		c.errorf(field, "%q is not a UTC date in the format of WARC %s", date, version)
+		return
+	}
+	if _, err := time.Parse(time.RFC3339Nano, date); err != nil {
+		c.errorf(field, "%q is not a valid date", date)
+	}
+}
+
+// checkMediaType checks the syntax of a media type.
+func (c *checker) checkMediaType(field, value string) {
+	if _, _, err := mime.ParseMediaType(value); err != nil {
+		c.errorf(field, "%q is not a valid media type: %v", value, err)
+	}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,241 @@
+package warc_test
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+// rawRecord builds a record from header lines and a block, with a correct Content-Length
+// unless one is among the lines.
+func rawRecord(version string, lines []string, block string) string {
+	var b strings.Builder
+	b.WriteString("WARC/" + version + "\r\n")
+	hasLength := false
+	for _, line := range lines {
+		b.WriteString(line + "\r\n")
+		hasLength = hasLength || strings.HasPrefix(line, "Content-Length:")
+	}
+	if !hasLength {
+		b.WriteString("Content-Length: " + strconv.Itoa(len(block)) + "\r\n")
+	}
+	b.WriteString("\r\n" + block + "\r\n\r\n")
+	return b.String()
+}
+
+func TestValidateReader(t *testing.T) {
+	resource := []string{
+		"WARC-Type: resource",
+		"WARC-Record-ID: <urn:uuid:4f0c1f6a-1b7e-4b4a-9a3c-1c2d3e4f5a6b>",
+		"WARC-Date: 2024-01-01T10:00:00Z",
+		"WARC-Target-URI: http://example.com/",
+		"Content-Type: text/plain",
+	}
+	with := func(lines []string, extra ...string) []string {
+		return append(append([]string(nil), lines...), extra...)
+	}
+	without := func(lines []string, name string) []string {
+		var kept []string
+		for _, line := range lines {
+			if !strings.HasPrefix(line, name+":") {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	}
+	replace := func(lines []string, name, line string) []string {
+		return with(without(lines, name), line)
+	}
+
+	type want struct {
+		severity Severity
+		field    string
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []want
+	}{
+		{
+			name:  "valid resource",
+			input: rawRecord("1.1", resource, "Hello"),
+		},
+		{
+			name:  "valid digest",
+			input: rawRecord("1.1", with(resource, "WARC-Block-Digest: sha1:BIFJ6KTHOKKCKV5LKNK5O2XUIL4PMXQB"), "Hello, World!"),
+		},
+		{
+			name:  "wrong digest",
+			input: rawRecord("1.1", with(resource, "WARC-Block-Digest: sha1:BIFJ6KTHOKKCKV5LKNK5O2XUIL4PMXQB"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Block-Digest"}},
+		},
+		{
+			name:  "missing mandatory fields",
+			input: rawRecord("1.1", without(without(resource, "WARC-Record-ID"), "WARC-Date"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Record-ID"}, {SeverityError, "WARC-Date"}},
+		},
+		{
+			name:  "missing target URI",
+			input: rawRecord("1.1", without(resource, "WARC-Target-URI"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Target-URI"}},
+		},
+		{
+			name:  "record ID without brackets",
+			input: rawRecord("1.1", replace(resource, "WARC-Record-ID", "WARC-Record-ID: urn:uuid:1"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Record-ID"}},
+		},
+		{
+			name:  "fractional seconds in WARC 1.0",
+			input: rawRecord("1.0", replace(resource, "WARC-Date", "WARC-Date: 2024-01-01T10:00:00.123Z"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Date"}},
+		},
+		{
+			name:  "fractional seconds in WARC 1.1",
+			input: rawRecord("1.1", replace(resource, "WARC-Date", "WARC-Date: 2024-01-01T10:00:00.123Z"), "Hello"),
+		},
+		{
+			name:  "date with an offset",
+			input: rawRecord("1.1", replace(resource, "WARC-Date", "WARC-Date: 2024-01-01T10:00:00+02:00"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Date"}},
+		},
+		{
+			name:  "missing content type",
+			input: rawRecord("1.1", without(resource, "Content-Type"), "Hello"),
+			want:  []want{{SeverityWarning, "Content-Type"}},
+		},
+		{
+			name:  "duplicate field",
+			input: rawRecord("1.1", with(resource, "WARC-Target-URI: http://example.com/other"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Target-URI"}},
+		},
+		{
+			name:  "forbidden field",
+			input: rawRecord("1.1", with(resource, "WARC-Refers-To: <urn:uuid:2>", "WARC-Filename: a.warc"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Refers-To"}, {SeverityError, "WARC-Filename"}},
+		},
+		{
+			name: "revisit without references",
+			input: rawRecord("1.1", with(replace(resource, "WARC-Type", "WARC-Type: revisit"),
+				"WARC-Profile: http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"), ""),
+			want: []want{
+				{SeverityWarning, "WARC-Refers-To"},
+				{SeverityError, "WARC-Payload-Digest"},
+				{SeverityWarning, "WARC-Refers-To-Target-URI"},
+				{SeverityWarning, "WARC-Refers-To-Date"},
+			},
+		},
+		{
+			name:  "revisit without profile",
+			input: rawRecord("1.0", with(replace(resource, "WARC-Type", "WARC-Type: revisit"), "WARC-Refers-To: <urn:uuid:2>"), ""),
+			want:  []want{{SeverityError, "WARC-Profile"}},
+		},
+		{
+			name:  "continuation without segment fields",
+			input: rawRecord("1.1", without(replace(resource, "WARC-Type", "WARC-Type: continuation"), "Content-Type"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Segment-Origin-ID"}, {SeverityError, "WARC-Segment-Number"}},
+		},
+		{
+			name: "continuation with first segment number",
+			input: rawRecord("1.1", with(without(replace(resource, "WARC-Type", "WARC-Type: continuation"), "Content-Type"),
+				"WARC-Segment-Origin-ID: <urn:uuid:2>", "WARC-Segment-Number: 1"), "Hello"),
+			want: []want{{SeverityError, "WARC-Segment-Number"}},
+		},
+		{
+			name:  "invalid values",
+			input: rawRecord("1.1", with(resource, "WARC-IP-Address: example", "WARC-Payload-Digest: nodigest", "WARC-Truncated: bored"), "Hello"),
+			want:  []want{{SeverityError, "WARC-Payload-Digest"}, {SeverityError, "WARC-IP-Address"}, {SeverityWarning, "WARC-Truncated"}},
+		},
+		{
+			name:  "unknown type",
+			input: rawRecord("1.1", replace(resource, "WARC-Type", "WARC-Type: custom"), "Hello"),
+			want:  []want{{SeverityWarning, "WARC-Type"}},
+		},
+		{
+			name:  "block longer than Content-Length",
+			input: rawRecord("1.1", with(resource, "Content-Length: 3"), "Hello"),
+			want:  []want{{SeverityError, ""}, {SeverityError, ""}},
+		},
+		{
+			name:  "block shorter than Content-Length",
+			input: strings.TrimSuffix(rawRecord("1.1", with(resource, "Content-Length: 30"), "Hello"), "\r\n\r\n"),
+			want:  []want{{SeverityError, "Content-Length"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := ValidateReader(strings.NewReader(tt.input))
+			if len(findings) != len(tt.want) {
+				t.Fatalf("ValidateReader() = %v, want %d findings", findings, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if findings[i].Severity != w.severity || findings[i].Field != w.field {
+					t.Errorf("finding %d = %v, want %v on %q", i, findings[i], w.severity, w.field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateReaderOffsets(t *testing.T) {
+	valid := rawRecord("1.1", []string{
+		"WARC-Type: warcinfo",
+		"WARC-Record-ID: <urn:uuid:1>",
+		"WARC-Date: 2024-01-01T10:00:00Z",
+	}, "")
+	invalid := rawRecord("1.1", []string{
+		"WARC-Type: resource",
+		"WARC-Record-ID: <urn:uuid:2>",
+		"WARC-Date: 2024-01-01",
+		"WARC-Target-URI: http://example.com/",
+	}, "")
+
+	findings := ValidateReader(strings.NewReader(valid + invalid))
+	if len(findings) != 1 {
+		t.Fatalf("ValidateReader() = %v, want 1 finding", findings)
+	}
+	if findings[0].Offset != int64(len(valid)) || findings[0].RecordID != "<urn:uuid:2>" {
+		t.Errorf("finding at offset %d in %v, want offset %d in <urn:uuid:2>", findings[0].Offset, findings[0].RecordID, len(valid))
+	}
+}
+
+func TestValidateWrittenRecords(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewGzipWriter(&buf)
+	w.MaxRecordSize = 10
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	info := &WarcInfoRecord{WARCRecord: WARCRecord{Date: date}}
+	info.Software = "gwarc"
+	if err := w.WriteRecords(
+		info,
+		httpResponseRecord("http://example.com/", date, "a payload long enough to be segmented"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if findings := ValidateReader(&buf); len(findings) != 0 {
+		t.Errorf("ValidateReader() = %v, want no findings", findings)
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	record := &Record{WARCRecord: WARCRecord{
+		Version:  WARCVariant1_1,
+		RecordID: "<urn:uuid:1>",
+		Date:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Type:     WARCTypeRequest,
+	}}
+	findings := ValidateRecord(record)
+	if len(findings) != 1 || findings[0].Field != "WARC-Target-URI" || findings[0].Severity != SeverityError {
+		t.Errorf("ValidateRecord() = %v, want a missing WARC-Target-URI", findings)
+	}
+
+	record.TargetURI = "http://example.com/"
+	if findings := ValidateRecord(record); len(findings) != 0 {
+		t.Errorf("ValidateRecord() = %v, want no findings", findings)
+	}
+}
@@ -113,6 +113,9 @@ type WARCRecord struct {
 	// SegmentTotalLength specifies the total length of all segments
 	SegmentTotalLength uint64 `warc:"WARC-Segment-Total-Length,omitempty"`
 
+	// Extensions holds named fields not defined by the WARC specification, such as WARC-Protocol
+	Extensions map[string][]string
+
 	// Body
 
 	// Content holds the actual content block of the WARC record
@@ -154,40 +157,37 @@ type WarcInfoRecord struct {
 }
 
 func (w *WarcInfoRecord) MarshalWARCRecord() ([]byte, error) {
-	warcRecordResult, err := Marshal(w.WARCRecord)
-	if err != nil {
-		return nil, err
-	}
-
-	var extraRecordResult []byte
-	if w.Operator != "" {
-		extraRecordResult = append(extraRecordResult, []byte("operator: "+w.Operator+"\n")...)
-	}
-	if w.Software != "" {
-		extraRecordResult = append(extraRecordResult, []byte("software: "+w.Software+"\n")...)
-	}
-	if w.Robots != "" {
-		extraRecordResult = append(extraRecordResult, []byte("robots: "+w.Robots+"\n")...)
-	}
-	if w.Hostname != "" {
-		extraRecordResult = append(extraRecordResult, []byte("hostname: "+w.Hostname+"\n")...)
-	}
-	if w.IP != "" {
-		extraRecordResult = append(extraRecordResult, []byte("ip: "+w.IP+"\n")...)
-	}
-	if w.UserAgent != "" {
-		extraRecordResult = append(extraRecordResult, []byte("http-header-user-agent: "+w.UserAgent+"\n")...)
-	}
-	if w.From != "" {
-		extraRecordResult = append(extraRecordResult, []byte("http-header-from: "+w.From+"\n")...)
-	}
+	return Marshal(w.warcRecord())
+}
 
-	return append(warcRecordResult, extraRecordResult...), nil
+// warcRecord returns the record with the extra fields merged into its block.
+func (w WarcInfoRecord) warcRecord() WARCRecord {
+	record := w.WARCRecord
+	if record.Type == "" {
+		record.Type = WARCTypeWarcinfo
+	}
+	if record.ContentType == "" {
+		record.ContentType = ContentTypeWARCFields
+	}
+	record.Content = mergeFields(record.Content, []warcField{
+		{"operator", w.Operator},
+		{"software", w.Software},
+		{"robots", w.Robots},
+		{"hostname", w.Hostname},
+		{"ip", w.IP},
+		{"http-header-user-agent", w.UserAgent},
+		{"http-header-from", w.From},
+	})
+	record.ContentLength = uint64(len(record.Content))
+	return record
+}
 
+func (w *WarcInfoRecord) UnmarshalWARCRecord(data []byte) error {
+	return w.unmarshalWARCRecord(data, Limits{})
 }
 
-func (w *WarcInfoRecord) UnmarshalWARCRecord(data []byte) (err error) {
-	err = Unmarshal(data, &w.WARCRecord)
+func (w *WarcInfoRecord) unmarshalWARCRecord(data []byte, limits Limits) (err error) {
+	err = UnmarshalWithLimits(data, &w.WARCRecord, limits)
 	if err != nil {
 		return
 	}
@@ -211,6 +211,12 @@ func (w *WarcInfoRecord) UnmarshalWARCRecord(data []byte) (err error) {
 			w.Software = value
 		case "operator":
 			w.Operator = value
+		case "ip":
+			w.IP = value
+		case "http-header-user-agent":
+			w.UserAgent = value
+		case "http-header-from":
+			w.From = value
 		}
 	}
 
@@ -224,6 +230,9 @@ type metadataExtraRecord struct {
 	HopsFromSeed string `warc:"hopsFromSeed"`
 	// FetchTimeMs indicates the time taken to collect the archived URI (in milliseconds)
 	FetchTimeMs uint64 `warc:"fetchTimeMs"`
+	// Outlinks lists the links found in the archived resource, one "outlink" line each, as
+	// "URI hop-type context" in the form Heritrix writes
+	Outlinks []string `warc:"outlink"`
 }
 
 // MetadataRecord represents additional information about another record.
@@ -236,27 +245,40 @@ type MetadataRecord struct {
 }
 
 func (m *MetadataRecord) MarshalWARCRecord() ([]byte, error) {
-	warcRecordResult, err := Marshal(m.WARCRecord)
-	if err != nil {
-		return nil, err
-	}
+	return Marshal(m.warcRecord())
+}
 
-	var extraRecordResult []byte
-	if m.Via != "" {
-		extraRecordResult = append(extraRecordResult, []byte("via: "+m.Via+"\n")...)
+// warcRecord returns the record with the extra fields merged into its block.
+func (m MetadataRecord) warcRecord() WARCRecord {
+	record := m.WARCRecord
+	if record.Type == "" {
+		record.Type = WARCTypeMetadata
 	}
-	if m.HopsFromSeed != "" {
-		extraRecordResult = append(extraRecordResult, []byte("hopsFromSeed: "+m.HopsFromSeed+"\n")...)
+	if record.ContentType == "" {
+		record.ContentType = ContentTypeWARCFields
 	}
+	var fetchTimeMs string
 	if m.FetchTimeMs != 0 {
-		extraRecordResult = append(extraRecordResult, []byte("fetchTimeMs: "+strconv.FormatUint(m.FetchTimeMs, 10)+"\n")...)
-	}
+		fetchTimeMs = strconv.FormatUint(m.FetchTimeMs, 10)
+	}
+	record.Content = mergeFields(record.Content, []warcField{
+		{"via", m.Via},
+		{"hopsFromSeed", m.HopsFromSeed},
+		{"fetchTimeMs", fetchTimeMs},
+	})
+	if len(m.Outlinks) > 0 {
+		record.Content = replaceField(record.Content, "outlink", m.Outlinks)
+	}
+	record.ContentLength = uint64(len(record.Content))
+	return record
+}
 
-	return append(warcRecordResult, extraRecordResult...), nil
+func (m *MetadataRecord) UnmarshalWARCRecord(data []byte) error {
+	return m.unmarshalWARCRecord(data, Limits{})
 }
 
-func (m *MetadataRecord) UnmarshalWARCRecord(data []byte) (err error) {
-	err = Unmarshal(data, &m.WARCRecord)
+func (m *MetadataRecord) unmarshalWARCRecord(data []byte, limits Limits) (err error) {
+	err = UnmarshalWithLimits(data, &m.WARCRecord, limits)
 	if err != nil {
 		return
 	}
@@ -277,10 +299,12 @@ func (m *MetadataRecord) UnmarshalWARCRecord(data []byte) (err error) {
 		case "hopsFromSeed":
 			m.HopsFromSeed = value
 		case "fetchTimeMs":
-			m.FetchTimeMs, err = strconv.ParseUint(value, 10, 64)
-			if err != nil {
-				return
+			if m.FetchTimeMs, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return &ParseError{Category: CategorySyntax, RecordID: m.RecordID, Field: "fetchTimeMs",
+					Err: fmt.Errorf("invalid fetchTimeMs: %q", value)}
 			}
+		case "outlink":
+			m.Outlinks = append(m.Outlinks, value)
 		}
 	}
 
@@ -401,48 +425,16 @@ func (w *WARCRecord) Validate() error {
 	return nil
 }
 
-// Validate checks if all required fields are present and valid
+// Validate checks the fields the WARC specification requires of every record. The fields
+// of the warcinfo block are all optional; see ValidateRecord and the validation profiles
+// to require some of them.
 func (w *WarcInfoRecord) Validate() error {
-	if w.WARCRecord.Validate() != nil {
-		return fmt.Errorf("WARC record is invalid")
-	}
-	if w.Operator == "" {
-		return fmt.Errorf("operator is required")
-	}
-	if w.Software == "" {
-		return fmt.Errorf("software is required")
-	}
-	if w.Robots == "" {
-		return fmt.Errorf("robots is required")
-	}
-	if w.Hostname == "" {
-		return fmt.Errorf("hostname is required")
-	}
-	if w.IP == "" {
-		return fmt.Errorf("IP is required")
-	}
-	if w.UserAgent == "" {
-		return fmt.Errorf("http-header-user-agent is required")
-	}
-	if w.From == "" {
-		return fmt.Errorf("http-header-from is required")
-	}
-	return nil
+	return w.WARCRecord.Validate()
 }
 
-// Validate checks if all required fields are present and valid
+// Validate checks the fields the WARC specification requires of every record. The fields
+// of the metadata block are all optional; see ValidateRecord and the validation profiles
+// to require some of them.
 func (m *MetadataRecord) Validate() error {
-	if m.WARCRecord.Validate() != nil {
-		return fmt.Errorf("WARC record is invalid")
-	}
-	if m.Via == "" {
-		return fmt.Errorf("via is required")
-	}
-	if m.HopsFromSeed == "" {
-		return fmt.Errorf("hopsFromSeed is required")
-	}
-	if m.FetchTimeMs == 0 {
-		return fmt.Errorf("fetchTimeMs is required")
-	}
-	return nil
+	return m.WARCRecord.Validate()
 }
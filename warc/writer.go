@@ -0,0 +1,380 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/zenless-lab/gwarc/internal/zstd"
+)
+
+// Compression selects how a Writer compresses the records it writes.
+type Compression int
+
+const (
+	// CompressionNone writes records as plain WARC
+	CompressionNone Compression = iota
+	// CompressionGzip writes each record as its own gzip member, as in .warc.gz files
+	CompressionGzip
+	// CompressionZstd writes each record as its own zstd frame, as in .warc.zst files
+	CompressionZstd
+)
+
+// Record is a WARC record whose content block is streamed from Block rather than held in Content.
+// Block must yield exactly ContentLength bytes.
+type Record struct {
+	WARCRecord
+
+	// Block streams the record's content block
+	Block io.Reader
+
+	// Offset is the position of the record in the input it was read from, set by Reader.
+	// For compressed input it is the offset of the gzip member or zstd frame holding the record.
+	Offset int64
+	// File is the base name of the file the record was read from, set by Reader
+	File string
+
+	// fields holds the named fields of a record read by Reader, as they appeared
+	fields []warcField
+}
+
+// warcRecorder is implemented by the record types that can be written from memory.
+type warcRecorder interface {
+	warcRecord() WARCRecord
+}
+
+// warcRecord returns the record itself.
+func (w WARCRecord) warcRecord() WARCRecord {
+	return w
+}
+
+// Writer writes WARC records to an io.Writer.
+// It is safe for concurrent use; each call writes its records atomically.
+type Writer struct {
+	// Compression selects the compression applied to each record
+	Compression Compression
+	// DigestStore, if set, turns responses whose payload was archived before into revisit records
+	DigestStore DigestStore
+	// MaxPayloadSize, if positive, cuts the payload of larger records to MaxPayloadSize bytes
+	// and marks them with WARC-Truncated: length
+	MaxPayloadSize int64
+	// MaxRecordSize, if positive, splits records whose block is larger into a first segment
+	// and continuation records of at most MaxRecordSize bytes each. Warcinfo records, which
+	// describe the file they start, are never split.
+	MaxRecordSize int64
+	// Warcinfo, if set, is written at the start of every file of a rotating Writer, with a new
+	// record ID and the file's name. The records that follow refer to it by WARC-Warcinfo-ID.
+	Warcinfo *WarcInfoRecord
+	// ZstdDictionary, if set, is the dictionary CompressionZstd compresses records with:
+	// a zstd dictionary, as trained by "zstd --train", or raw content. It is stored in a
+	// skippable frame at the start of the output, and of every file of a rotating Writer.
+	// It must be set before the first write.
+	ZstdDictionary []byte
+
+	mu sync.Mutex
+	w  io.Writer
+	n  int64
+
+	// Rotation state, see NewRotatingWriter
+	rotating    bool
+	dir         string
+	prefix      string
+	maxFileSize int64
+	file        *os.File
+	serial      int
+	warcinfoID  string
+
+	// zstd compresses records for CompressionZstd, created by the first of them
+	zstd *zstd.Writer
+}
+
+// NewWriter returns a Writer writing uncompressed records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// NewGzipWriter returns a Writer writing each record to w as its own gzip member.
+func NewGzipWriter(w io.Writer) *Writer {
+	return &Writer{w: w, Compression: CompressionGzip}
+}
+
+// NewZstdWriter returns a Writer writing each record to w as its own zstd frame, compressed
+// with dict if not nil, which is stored at the start of w.
+func NewZstdWriter(w io.Writer, dict []byte) *Writer {
+	return &Writer{w: w, Compression: CompressionZstd, ZstdDictionary: dict}
+}
+
+// NewRotatingWriter returns a Writer writing to numbered files in dir, named prefix-00000.warc,
+// prefix-00001.warc and so on, with a .gz or .zst suffix for CompressionGzip or
+// CompressionZstd. A new
+// file is started before a group of records, or a continuation record, once the current file
+// has reached maxFileSize bytes; with MaxRecordSize set, large records are thus segmented
+// across files. The first file is created by the first write.
+func NewRotatingWriter(dir, prefix string, maxFileSize int64, compression Compression) *Writer {
+	return &Writer{Compression: compression, rotating: true, dir: dir, prefix: prefix, maxFileSize: maxFileSize}
+}
+
+// Filename returns the name of the file a rotating Writer is writing, if any.
+func (w *Writer) Filename() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return ""
+	}
+	return w.file.Name()
+}
+
+// Close closes the current file of a rotating Writer. Writing afterwards starts a new file.
+// It does nothing for Writers created by NewWriter, NewGzipWriter or NewZstdWriter.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeFile()
+}
+
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file, w.w, w.warcinfoID = nil, nil, ""
+	return err
+}
+
+// rotate opens the next file of a rotating Writer if none is open, or if mayRotate is set
+// and the current one is full, and writes its warcinfo record.
+func (w *Writer) rotate(mayRotate bool) error {
+	if !w.rotating {
+		return nil
+	}
+	if w.file != nil && !(mayRotate && w.maxFileSize > 0 && w.n >= w.maxFileSize) {
+		return nil
+	}
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%05d.warc", w.prefix, w.serial)
+	switch w.Compression {
+	case CompressionGzip:
+		name += ".gz"
+	case CompressionZstd:
+		name += ".zst"
+	}
+	file, err := os.OpenFile(filepath.Join(w.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	w.serial++
+	w.file, w.w, w.n = file, file, 0
+
+	if w.Warcinfo == nil {
+		return nil
+	}
+	info := *w.Warcinfo
+	info.RecordID = NewRecordID()
+	info.Date = time.Time{}
+	info.Filename = name
+	record, err := asRecord(&info)
+	if err != nil {
+		return err
+	}
+	if err := w.writeRecord(record); err != nil {
+		return err
+	}
+	w.warcinfoID = record.RecordID
+	return nil
+}
+
+// Offset returns the offset of the record written next in the underlying writer, or in the
+// current file of a rotating Writer: the number of bytes written so far, and for the first
+// record of zstd output with a dictionary, the size of the dictionary frame before it, as
+// Reader reports it.
+func (w *Writer) Offset() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.n == 0 && w.Compression == CompressionZstd && len(w.ZstdDictionary) > 0 {
+		return zstdSkippableHeaderSize + int64(len(w.ZstdDictionary))
+	}
+	return w.n
+}
+
+// WriteRecord writes a single record. v may be a WARCRecord, WarcInfoRecord, MetadataRecord
+// or Record, or a pointer to one of them. Missing Version, RecordID and Date are filled in,
+// and the block digest is computed for records held in memory.
+func (w *Writer) WriteRecord(v any) error {
+	return w.WriteRecords(v)
+}
+
+// WriteRecords writes records back to back; records written concurrently by other
+// goroutines never come between them.
+func (w *Writer) WriteRecords(records ...any) error {
+	prepared := make([]*Record, 0, len(records))
+	for _, v := range records {
+		record, err := asRecord(v)
+		if err != nil {
+			return err
+		}
+		prepared = append(prepared, record)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.rotate(true); err != nil {
+		return err
+	}
+	for _, record := range prepared {
+		record, release, err := w.truncate(record)
+		if err != nil {
+			return err
+		}
+		defer release()
+		record, remember, err := w.deduplicate(record)
+		if err != nil {
+			return err
+		}
+		if w.MaxRecordSize > 0 && record.ContentLength > uint64(w.MaxRecordSize) && record.Type != WARCTypeWarcinfo {
+			err = w.writeSegments(record)
+		} else {
+			err = w.writeRecord(record)
+		}
+		if err != nil {
+			return err
+		}
+		if err := remember(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asRecord converts any of the record types accepted by WriteRecord into a Record.
+func asRecord(v any) (*Record, error) {
+	var record *Record
+	switch r := v.(type) {
+	case *Record:
+		if r == nil {
+			return nil, fmt.Errorf("record is nil")
+		}
+		copied := *r
+		record = &copied
+	case Record:
+		record = &r
+	case warcRecorder:
+		if rv := reflect.ValueOf(r); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, fmt.Errorf("record is nil")
+		}
+		rec := r.warcRecord()
+		rec.ContentLength = uint64(len(rec.Content))
+		if rec.BlockDigest == "" {
+			rec.BlockDigest = Digest(rec.Content)
+		}
+		record = &Record{WARCRecord: rec, Block: bytes.NewReader(rec.Content)}
+	default:
+		return nil, fmt.Errorf("unsupported record type %T", v)
+	}
+
+	if record.Version == "" {
+		record.Version = WARCVariant1_1
+	}
+	if record.RecordID == "" {
+		record.RecordID = NewRecordID()
+	}
+	if record.Date.IsZero() {
+		record.Date = time.Now().UTC()
+	}
+	if err := record.WARCRecord.Validate(); err != nil {
+		return nil, err
+	}
+	if record.Block == nil {
+		if record.ContentLength != 0 {
+			return nil, fmt.Errorf("record %s has Content-Length %d but no block", record.RecordID, record.ContentLength)
+		}
+		record.Block = bytes.NewReader(nil)
+	}
+	return record, nil
+}
+
+// writeRecord writes one record, including the two CRLFs that end it.
+func (w *Writer) writeRecord(record *Record) error {
+	if w.warcinfoID != "" && record.WarcinfoID == "" && record.Type != WARCTypeWarcinfo {
+		record.WarcinfoID = w.warcinfoID
+	}
+
+	var header bytes.Buffer
+	if err := writeHeader(&header, reflect.ValueOf(record.WARCRecord), int64(record.ContentLength)); err != nil {
+		return err
+	}
+
+	out := io.Writer(countWriter{w})
+	var compressor io.WriteCloser
+	switch w.Compression {
+	case CompressionGzip:
+		compressor = gzip.NewWriter(out)
+	case CompressionZstd:
+		var err error
+		if compressor, err = w.zstdWriter(out); err != nil {
+			return err
+		}
+	}
+	if compressor != nil {
+		out = compressor
+	}
+
+	if _, err := out.Write(header.Bytes()); err != nil {
+		return err
+	}
+	if n, err := io.CopyN(out, record.Block, int64(record.ContentLength)); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("record %s block is %d bytes, want %d", record.RecordID, n, record.ContentLength)
+		}
+		return err
+	}
+	if _, err := io.WriteString(out, "\r\n\r\n"); err != nil {
+		return err
+	}
+	if compressor != nil {
+		return compressor.Close()
+	}
+	return nil
+}
+
+// zstdWriter returns the zstd.Writer compressing a record to out, first writing the
+// dictionary frame at the start of the output.
+func (w *Writer) zstdWriter(out io.Writer) (*zstd.Writer, error) {
+	if w.zstd == nil {
+		var dict *zstd.Dict
+		if len(w.ZstdDictionary) > 0 {
+			var err error
+			if dict, err = zstd.ParseDict(w.ZstdDictionary); err != nil {
+				return nil, fmt.Errorf("invalid zstd dictionary: %w", err)
+			}
+		}
+		w.zstd = zstd.NewWriter(out, dict)
+	} else {
+		w.zstd.Reset(out)
+	}
+	if w.n == 0 && len(w.ZstdDictionary) > 0 {
+		if err := zstd.WriteSkippable(out, zstdDictionaryFrame, w.ZstdDictionary); err != nil {
+			return nil, err
+		}
+	}
+	return w.zstd, nil
+}
+
+// countWriter writes to the Writer's destination, keeping its offset.
+type countWriter struct {
+	w *Writer
+}
+
+func (c countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.w.Write(p)
+	c.w.n += int64(n)
+	return n, err
+}
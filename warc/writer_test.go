@@ -0,0 +1,228 @@
+package warc_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+func TestWriterWriteRecord(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   any
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "in-memory record",
+			input: &WARCRecord{
+				Type:     WARCTypeResource,
+				Date:     date,
+				RecordID: "<urn:uuid:12345678>",
+				Content:  []byte("Hello, World!"),
+			},
+			want: []string{
+				"WARC/1.1\r\n",
+				"WARC-Record-ID: <urn:uuid:12345678>\r\n",
+				"WARC-Block-Digest: sha1:BIFJ6KTHOKKCKV5LKNK5O2XUIL4PMXQB\r\n",
+				"Content-Length: 13\r\n\r\nHello, World!\r\n\r\n",
+			},
+		},
+		{
+			name: "streamed record",
+			input: &Record{
+				WARCRecord: WARCRecord{
+					Version:       WARCVariant1_0,
+					Type:          WARCTypeResource,
+					Date:          date,
+					ContentLength: 5,
+				},
+				Block: strings.NewReader("Hello"),
+			},
+			want: []string{
+				"WARC/1.0\r\n",
+				"WARC-Record-ID: <urn:uuid:",
+				"Content-Length: 5\r\n\r\nHello\r\n\r\n",
+			},
+		},
+		{
+			name: "metadata record",
+			input: func() *MetadataRecord {
+				m := &MetadataRecord{WARCRecord: WARCRecord{Date: date}}
+				m.Via = "http://example.com/"
+				m.FetchTimeMs = 42
+				return m
+			}(),
+			want: []string{
+				"WARC-Type: metadata\r\n",
+				"Content-Type: application/warc-fields\r\n",
+				"Content-Length: 43\r\n\r\nvia: http://example.com/\r\nfetchTimeMs: 42\r\n\r\n\r\n",
+			},
+		},
+		{
+			name: "extension fields",
+			input: &WARCRecord{
+				Type:       WARCTypeResource,
+				Date:       date,
+				Extensions: map[string][]string{"WARC-Protocol": {"h2", "tls/1.3"}},
+			},
+			want: []string{
+				"WARC-Protocol: h2\r\nWARC-Protocol: tls/1.3\r\n",
+			},
+		},
+		{
+			name: "short block",
+			input: &Record{
+				WARCRecord: WARCRecord{Type: WARCTypeResource, ContentLength: 10},
+				Block:      strings.NewReader("Hello"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "missing type",
+			input:   &WARCRecord{Content: []byte("Hello")},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			input:   "invalid",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			err := w.WriteRecord(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("WriteRecord() = %q, want %q", got, want)
+				}
+			}
+			if strings.Count(got, "Content-Length:") != 1 {
+				t.Errorf("WriteRecord() = %q, want a single Content-Length", got)
+			}
+			if w.Offset() != int64(buf.Len()) {
+				t.Errorf("Offset() = %d, want %d", w.Offset(), buf.Len())
+			}
+		})
+	}
+}
+
+func TestGzipWriter(t *testing.T) {
+	records := []any{
+		&WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:1>", Content: []byte("one")},
+		&WARCRecord{Type: WARCTypeResource, RecordID: "<urn:uuid:2>", Content: []byte("two")},
+	}
+
+	var buf bytes.Buffer
+	w := NewGzipWriter(&buf)
+	var offsets []int64
+	for _, record := range records {
+		offsets = append(offsets, w.Offset())
+		if err := w.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every record starts a gzip member of its own
+	for i, offset := range offsets {
+		zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()[offset:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr.Multistream(false)
+		member, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got WARCRecord
+		if err := Unmarshal(member, &got); err != nil {
+			t.Fatal(err)
+		}
+		want := records[i].(*WARCRecord)
+		if got.RecordID != want.RecordID || !bytes.Equal(got.Content, want.Content) {
+			t.Errorf("member %d = %v %q, want %v %q", i, got.RecordID, got.Content, want.RecordID, want.Content)
+		}
+	}
+}
+
+func TestMetadataRecordRoundTrip(t *testing.T) {
+	m := &MetadataRecord{WARCRecord: WARCRecord{
+		Version:  WARCVariant1_1,
+		Type:     WARCTypeMetadata,
+		RecordID: "<urn:uuid:1>",
+		Date:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Content:  []byte("via: http://old.example/\r\noutlink: http://example.com/a L a/@href\r\n"),
+	}}
+	m.Via = "http://example.com/"
+	m.HopsFromSeed = "L"
+
+	data, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got MetadataRecord
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Via != m.Via || got.HopsFromSeed != m.HopsFromSeed {
+		t.Errorf("Via, HopsFromSeed = %v, %v, want %v, %v", got.Via, got.HopsFromSeed, m.Via, m.HopsFromSeed)
+	}
+	want := "via: http://example.com/\r\noutlink: http://example.com/a L a/@href\r\nhopsFromSeed: L\r\n"
+	if string(got.Content) != want {
+		t.Errorf("Content = %q, want %q", got.Content, want)
+	}
+}
+
+func TestMetadataRecordOutlinks(t *testing.T) {
+	m := &MetadataRecord{WARCRecord: WARCRecord{
+		Version:  WARCVariant1_1,
+		Type:     WARCTypeMetadata,
+		RecordID: "<urn:uuid:1>",
+		Date:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Content:  []byte("outlink: http://old.example/ L a/@href\r\nnote: kept\r\n"),
+	}}
+	m.Outlinks = []string{"http://example.com/a L a/@href", "http://example.com/b.png E img/@src"}
+
+	data, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got MetadataRecord
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.Outlinks, "\n") != strings.Join(m.Outlinks, "\n") {
+		t.Errorf("Outlinks = %q, want %q", got.Outlinks, m.Outlinks)
+	}
+	want := "note: kept\r\noutlink: http://example.com/a L a/@href\r\noutlink: http://example.com/b.png E img/@src\r\n"
+	if string(got.Content) != want {
+		t.Errorf("Content = %q, want %q", got.Content, want)
+	}
+
+	// Marshalled again, the outlinks read are not repeated
+	if data, err = Marshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("outlink: ")); n != 2 {
+		t.Errorf("marshalled again with %d outlinks, want 2", n)
+	}
+}
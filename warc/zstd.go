@@ -0,0 +1,123 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/zenless-lab/gwarc/internal/zstd"
+)
+
+// zstdDictionaryFrame is the number of the skippable frame holding the dictionary at the
+// start of .warc.zst files, whose magic number is 0x184D2A5D.
+const zstdDictionaryFrame = 13
+
+// Magic numbers of zstd frames and skippable frames, as they start the input
+const (
+	zstdMagic          = 0xFD2FB528
+	zstdSkippableMagic = 0x184D2A50
+)
+
+// zstdSkippableHeaderSize is the size of the magic number and length starting a skippable
+// frame
+const zstdSkippableHeaderSize = 8
+
+// isZstd reports whether magic, the first four bytes of the input, start a zstd frame or
+// skippable frame.
+func isZstd(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	m := binary.LittleEndian.Uint32(magic)
+	return m == zstdMagic || m&0xFFFFFFF0 == zstdSkippableMagic
+}
+
+// startZstd reads the dictionary frame at the start of zstd compressed input, if any, and
+// sets up the reading of the frames after it.
+func (r *Reader) startZstd() error {
+	var dict *zstd.Dict
+	if magic, _ := r.buf.Peek(4); binary.LittleEndian.Uint32(magic) == zstdSkippableMagic+zstdDictionaryFrame {
+		data, err := readZstdDictionary(r.src, r.Limits.withDefaults().MaxMemory)
+		if err != nil {
+			return err
+		}
+		if dict, err = zstd.ParseDict(data); err != nil {
+			return fmt.Errorf("invalid zstd dictionary: %w", err)
+		}
+		r.zstdDict = data
+	}
+	r.memberOffset = r.src.N()
+	r.zst = zstd.NewReader(r.src, dict)
+	r.br = bufio.NewReader(r.zst)
+	return nil
+}
+
+// readZstdDictionary reads a dictionary frame, decompressing the dictionary when it is
+// itself zstd compressed.
+func readZstdDictionary(r io.Reader, max int64) ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read zstd dictionary: %w", err)
+	}
+	size := int64(binary.LittleEndian.Uint32(header[4:]))
+	if size > max {
+		return nil, &LimitError{Limit: "MaxMemory", Max: max}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) < size {
+		return nil, fmt.Errorf("failed to read zstd dictionary: %w", io.ErrUnexpectedEOF)
+	}
+	if len(data) < 4 || binary.LittleEndian.Uint32(data) != zstdMagic {
+		return data, nil
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(zstd.NewReader(bytes.NewReader(data), nil), max+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress zstd dictionary: %w", err)
+	}
+	if int64(len(decompressed)) > max {
+		return nil, &LimitError{Limit: "MaxMemory", Max: max}
+	}
+	return decompressed, nil
+}
+
+// ZstdDictionary returns the dictionary the records of zstd compressed input are
+// compressed with, as stored at its start, or nil.
+func (r *Reader) ZstdDictionary() []byte {
+	return r.zstdDict
+}
+
+// ReadRecordAt returns the record at offset in r, which holds size bytes of WARC, as found
+// in an index: for compressed input, the offset of the gzip member or zstd frame holding
+// the record. The dictionary of .warc.zst input is read from its start. The Block of the
+// record reads from r.
+func ReadRecordAt(r io.ReaderAt, size, offset int64) (*Record, error) {
+	if offset < 0 || offset >= size {
+		return nil, fmt.Errorf("offset %d is outside the %d bytes of input", offset, size)
+	}
+	var in io.Reader = io.NewSectionReader(r, offset, size-offset)
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err == nil && offset > 0 &&
+		binary.LittleEndian.Uint32(header[:]) == zstdSkippableMagic+zstdDictionaryFrame {
+		// Read the dictionary frame first, so that the record's frame is decompressed with it
+		frame := zstdSkippableHeaderSize + int64(binary.LittleEndian.Uint32(header[4:]))
+		if frame > offset {
+			return nil, fmt.Errorf("offset %d is inside the zstd dictionary", offset)
+		}
+		in = io.MultiReader(io.NewSectionReader(r, 0, frame), in)
+	}
+	reader, err := NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	record, err := reader.Next()
+	if err != nil {
+		return nil, err
+	}
+	record.Offset = offset
+	return record, nil
+}
@@ -0,0 +1,168 @@
+package warc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/zenless-lab/gwarc/warc"
+)
+
+// zstdDictionary is raw content dictionary, primed with what WARC headers share.
+var zstdDictionary = []byte("WARC/1.1\r\nWARC-Record-ID: <urn:uuid:\r\nWARC-Date: 2024-01-01T10:00:00Z\r\n" +
+	"WARC-Type: response\r\nContent-Type: application/http; msgtype=response\r\n" +
+	"WARC-Block-Digest: sha1:\r\nWARC-Payload-Digest: sha1:\r\nWARC-Target-URI: http://example.com/\r\n" +
+	"Content-Length: \r\n\r\nHTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n")
+
+func TestZstdWriter(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, dict := range [][]byte{nil, zstdDictionary} {
+		var buf bytes.Buffer
+		w := NewZstdWriter(&buf, dict)
+		var offsets []int64
+		for i := 0; i < 3; i++ {
+			offsets = append(offsets, w.Offset())
+			record := httpResponseRecord(fmt.Sprintf("http://example.com/%d", i), date, fmt.Sprintf("page %d", i))
+			if err := w.WriteRecord(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if dict != nil && offsets[0] != int64(8+len(dict)) {
+			t.Errorf("first record at %d, want %d after the dictionary frame", offsets[0], 8+len(dict))
+		}
+
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; ; i++ {
+			record, err := r.Next()
+			if err == io.EOF {
+				if i != 3 {
+					t.Errorf("read %d records, want 3", i)
+				}
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, err := io.ReadAll(record.Block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := fmt.Sprintf("http://example.com/%d", i)
+			if record.TargetURI != want || !bytes.HasSuffix(block, []byte(fmt.Sprintf("page %d", i))) {
+				t.Errorf("record %d is %s %q", i, record.TargetURI, block)
+			}
+			if wantOffset := offsets[i]; record.Offset != wantOffset {
+				t.Errorf("record %d at offset %d, want %d", i, record.Offset, wantOffset)
+			}
+			at, err := ReadRecordAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()), record.Offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if at.RecordID != record.RecordID || at.Offset != record.Offset {
+				t.Errorf("ReadRecordAt(%d) = %s at %d, want %s", record.Offset, at.RecordID, at.Offset, record.RecordID)
+			}
+		}
+		if !bytes.Equal(r.ZstdDictionary(), dict) {
+			t.Errorf("ZstdDictionary() = %q, want %q", r.ZstdDictionary(), dict)
+		}
+	}
+}
+
+// records.warc.zst is compressed by the reference implementation, with a trained
+// dictionary itself zstd compressed in the dictionary frame.
+func TestZstdReader(t *testing.T) {
+	data, err := os.ReadFile("testdata/records.warc.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int64
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := io.ReadAll(record.Block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("page %d of the crawl", len(offsets)); !bytes.Contains(block, []byte(want)) {
+			t.Errorf("record %d block %q, want %q in it", len(offsets), block, want)
+		}
+		offsets = append(offsets, record.Offset)
+	}
+	if want := []int64{3852, 4094, 4337}; fmt.Sprint(offsets) != fmt.Sprint(want) {
+		t.Errorf("offsets = %v, want %v", offsets, want)
+	}
+	if dict := r.ZstdDictionary(); len(dict) < 4 || binary.LittleEndian.Uint32(dict) != 0xEC30A437 {
+		t.Errorf("ZstdDictionary() is not the decompressed zstd dictionary")
+	}
+
+	record, err := ReadRecordAt(bytes.NewReader(data), int64(len(data)), 4094)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TargetURI != "http://example.com/1" {
+		t.Errorf("ReadRecordAt(4094) = %s, want http://example.com/1", record.TargetURI)
+	}
+	if _, err := ReadRecordAt(bytes.NewReader(data), int64(len(data)), 100); err == nil {
+		t.Error("ReadRecordAt inside the dictionary frame succeeded")
+	}
+	if _, err := ReadRecordAt(bytes.NewReader(data), int64(len(data)), 4100); err == nil {
+		t.Error("ReadRecordAt inside a frame succeeded")
+	}
+}
+
+func TestRotatingZstdWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := NewRotatingWriter(dir, "test", 100, CompressionZstd)
+	w.ZstdDictionary = zstdDictionary
+	for i := 0; i < 2; i++ {
+		if err := w.WriteRecord(&WARCRecord{Type: WARCTypeResource, Content: bytes.Repeat([]byte("abc"), 100)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "test-*.warc.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("got %d files, want 2", len(paths))
+	}
+	// Every file starts with the dictionary
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.Next()
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(r.ZstdDictionary(), zstdDictionary) {
+			t.Errorf("%s has dictionary %q", path, r.ZstdDictionary())
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package wat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/htmlpayload"
+	"github.com/zenless-lab/gwarc/internal/iocount"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// MaxHTMLSize is the number of bytes of an HTML payload parsed for its metadata
+const MaxHTMLSize = htmlpayload.MaxSize
+
+// Generate reads records from r and writes their WAT records to w, as a Generator does.
+func Generate(w warc.RecordWriter, r warc.RecordReader) error {
+	var g Generator
+	return g.Generate(w, r)
+}
+
+// Generator writes WAT files, counting the HTML payloads it cannot describe.
+type Generator struct {
+	// Unsupported is the number of HTML payloads in a charset not supported, such as
+	// Shift_JIS or GBK, whose metadata records have no HTML-Metadata
+	Unsupported int
+}
+
+// Generate reads records from r and writes their WAT records to w: a warcinfo record
+// describing the WAT file, then for every record a metadata record holding its Document,
+// with the record's target URI and date, and a WARC-Refers-To field naming it.
+func (g *Generator) Generate(w warc.RecordWriter, r warc.RecordReader) error {
+	var warcinfoID string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		doc, err := NewDocument(record)
+		if err != nil {
+			return fmt.Errorf("record %s: %w", record.RecordID, err)
+		}
+		if m := doc.Envelope.PayloadMetadata.HTTPResponseMetadata; m != nil && m.unsupportedCharset {
+			g.Unsupported++
+		}
+		content, err := marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		group := make([]any, 0, 2)
+		if warcinfoID == "" {
+			info := &warc.WarcInfoRecord{WARCRecord: warc.WARCRecord{RecordID: warc.NewRecordID()}}
+			info.Software = "gwarc"
+			info.Content = []byte("format: WAT\r\n")
+			if record.File != "" {
+				info.Content = append(info.Content, "description: metadata of "+record.File+"\r\n"...)
+			}
+			warcinfoID = info.RecordID
+			group = append(group, info)
+		}
+		target := record.TargetURI
+		if target == "" {
+			target = record.Filename
+		}
+		group = append(group, &warc.WARCRecord{
+			Type:        warc.WARCTypeMetadata,
+			Date:        record.Date,
+			TargetURI:   target,
+			RefersTo:    record.RecordID,
+			WarcinfoID:  warcinfoID,
+			ContentType: ContentType,
+			Content:     content,
+		})
+		if err := w.WriteRecords(group...); err != nil {
+			return err
+		}
+	}
+}
+
+// marshal encodes doc without escaping HTML characters, as WAT files do.
+func marshal(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// NewDocument reads the block of record and returns its Document. HTML payloads in a charset
+// not supported, such as Shift_JIS or GBK, have no HTMLMetadata rather than garbled text.
+func NewDocument(record *warc.Record) (*Document, error) {
+	fields := record.Fields()
+	headerLength := len("WARC/" + string(record.Version) + "\r\n\r\n")
+	header := make(map[string]string, len(fields))
+	for _, f := range fields {
+		headerLength += len(f.Name + ": " + f.Value + "\r\n")
+		if previous, ok := header[f.Name]; ok {
+			header[f.Name] = previous + ", " + f.Value
+		} else {
+			header[f.Name] = f.Value
+		}
+	}
+	doc := &Document{
+		Container: Container{Filename: record.File, Offset: strconv.FormatInt(record.Offset, 10)},
+		Envelope: Envelope{
+			Format:             "WARC",
+			WARCHeaderLength:   strconv.Itoa(headerLength),
+			BlockDigest:        record.BlockDigest,
+			WARCHeaderMetadata: header,
+			PayloadMetadata:    PayloadMetadata{ActualContentType: record.ContentType},
+		},
+	}
+
+	block := iocount.NewReader(record.Block)
+	br := bufio.NewReader(block)
+	payload := &doc.Envelope.PayloadMetadata
+	isHTTP := strings.HasPrefix(record.ContentType, "application/http")
+	switch {
+	case isHTTP && (record.Type == warc.WARCTypeResponse || record.Type == warc.WARCTypeRevisit):
+		// A block that is not an HTTP response is described by its length alone
+		payload.HTTPResponseMetadata, _ = responseMetadata(block, br)
+	case isHTTP && record.Type == warc.WARCTypeRequest:
+		payload.HTTPRequestMetadata, _ = requestMetadata(block, br)
+	case record.Type == warc.WARCTypeWarcinfo:
+		fields, err := readFields(br)
+		if err != nil {
+			return nil, err
+		}
+		payload.WARCInfoMetadata = make(map[string]string)
+		for _, f := range fields {
+			payload.WARCInfoMetadata[f.Name] = f.Value
+		}
+	case record.Type == warc.WARCTypeMetadata && strings.HasPrefix(record.ContentType, warc.ContentTypeWARCFields):
+		fields, err := readFields(br)
+		if err != nil {
+			return nil, err
+		}
+		payload.WARCMetadataMetadata = &WARCMetadataMetadata{MetadataRecords: fields}
+	}
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, err
+	}
+	doc.Envelope.ActualContentLength = strconv.FormatInt(block.N(), 10)
+	if m := payload.HTTPResponseMetadata; m != nil {
+		headersLength, _ := strconv.ParseInt(m.HeadersLength, 10, 64)
+		m.EntityLength = strconv.FormatInt(block.N()-headersLength, 10)
+	}
+	if m := payload.HTTPRequestMetadata; m != nil {
+		headersLength, _ := strconv.ParseInt(m.HeadersLength, 10, 64)
+		m.EntityLength = strconv.FormatInt(block.N()-headersLength, 10)
+	}
+	return doc, nil
+}
+
+// responseMetadata reads the HTTP response from br, reading block, and parses its payload
+// when it is HTML.
+func responseMetadata(block *iocount.Reader, br *bufio.Reader) (*HTTPResponseMetadata, error) {
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	m := &HTTPResponseMetadata{
+		ResponseMessage: ResponseMessage{
+			Version: resp.Proto,
+			Status:  strconv.Itoa(resp.StatusCode),
+			Reason:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		},
+		Headers:                  joinHeader(resp.Header),
+		HeadersLength:            strconv.FormatInt(block.N()-int64(br.Buffered()), 10),
+		EntityTrailingSlopLength: "0",
+	}
+	if doc := htmlpayload.Response(resp); doc != nil {
+		html, err := doc.HTML()
+		if err != nil {
+			// Metadata decoded from the wrong charset would be garbage
+			m.unsupportedCharset = true
+			return m, nil
+		}
+		m.HTMLMetadata = ParseHTML(html)
+	}
+	return m, nil
+}
+
+// requestMetadata reads the HTTP request from br, reading block.
+func requestMetadata(block *iocount.Reader, br *bufio.Reader) (*HTTPRequestMetadata, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPRequestMetadata{
+		RequestMessage: RequestMessage{Method: req.Method, Path: req.RequestURI, Version: req.Proto},
+		Headers:        joinHeader(req.Header),
+		HeadersLength:  strconv.FormatInt(block.N()-int64(br.Buffered()), 10),
+	}, nil
+}
+
+// joinHeader returns the fields of header, repeated ones joined with ", ".
+func joinHeader(header http.Header) map[string]string {
+	joined := make(map[string]string, len(header))
+	for name, values := range header {
+		joined[name] = strings.Join(values, ", ")
+	}
+	return joined
+}
+
+// readFields reads the fields of an application/warc-fields block, whatever the length of
+// their lines.
+func readFields(br *bufio.Reader) ([]MetadataField, error) {
+	var fields []MetadataField
+	for {
+		line, err := br.ReadString('\n')
+		if name, value, found := strings.Cut(line, ":"); found {
+			fields = append(fields, MetadataField{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+		}
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package wat
+
+import (
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/htmltok"
+)
+
+// linkAttrs gives the attribute holding the URL of the elements listed in the Links of
+// HTMLMetadata.
+var linkAttrs = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"img":    "src",
+	"iframe": "src",
+	"frame":  "src",
+	"form":   "action",
+	"embed":  "src",
+	"object": "data",
+	"source": "src",
+	"video":  "src",
+	"audio":  "src",
+	"track":  "src",
+}
+
+// ParseHTML returns the metadata and links of an HTML document.
+func ParseHTML(document string) *HTMLMetadata {
+	m := &HTMLMetadata{}
+	z := htmltok.New(document)
+	var title, anchor *strings.Builder
+	anchorLink := -1
+	for {
+		token, ok := z.Next()
+		if !ok {
+			break
+		}
+		switch token.Kind {
+		case htmltok.Text:
+			if title != nil {
+				title.WriteString(token.Text)
+			}
+			if anchor != nil {
+				anchor.WriteString(token.Text)
+			}
+		case htmltok.EndTag:
+			switch token.Name {
+			case "title":
+				if title != nil && m.Head.Title == "" {
+					m.Head.Title = collapseSpace(title.String())
+				}
+				title = nil
+			case "a":
+				if anchor != nil && anchorLink >= 0 {
+					m.Links[anchorLink].Text = collapseSpace(anchor.String())
+				}
+				anchor, anchorLink = nil, -1
+			}
+		case htmltok.StartTag:
+			switch token.Name {
+			case "title":
+				title = &strings.Builder{}
+			case "base":
+				if href, ok := token.Attr("href"); ok && m.Head.Base == "" {
+					m.Head.Base = strings.TrimSpace(href)
+				}
+			case "meta":
+				meta := make(map[string]string, len(token.Attrs))
+				for _, a := range token.Attrs {
+					meta[a.Name] = a.Value
+				}
+				m.Head.Metas = append(m.Head.Metas, meta)
+			case "link":
+				if link, ok := newLink(&token, "href"); ok {
+					m.Head.Link = append(m.Head.Link, link)
+				}
+			case "script":
+				if link, ok := newLink(&token, "src"); ok {
+					m.Head.Scripts = append(m.Head.Scripts, link)
+				}
+			default:
+				attr, listed := linkAttrs[token.Name]
+				if !listed {
+					continue
+				}
+				link, ok := newLink(&token, attr)
+				if ok {
+					m.Links = append(m.Links, link)
+				}
+				if token.Name == "a" {
+					anchor, anchorLink = &strings.Builder{}, -1
+					if ok {
+						anchorLink = len(m.Links) - 1
+					}
+				}
+			}
+		}
+	}
+	return m
+}
+
+// newLink returns the link held in the attribute attr of the start tag token, if any.
+func newLink(token *htmltok.Token, attr string) (Link, bool) {
+	url, ok := token.Attr(attr)
+	if url = strings.TrimSpace(url); !ok || url == "" {
+		return Link{}, false
+	}
+	link := Link{Path: strings.ToUpper(token.Name) + "@/" + attr, URL: url}
+	link.Title, _ = token.Attr("title")
+	link.Alt, _ = token.Attr("alt")
+	link.Rel, _ = token.Attr("rel")
+	link.Type, _ = token.Attr("type")
+	return link, true
+}
+
+// collapseSpace trims s and replaces its runs of white space with single spaces.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
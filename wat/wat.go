@@ -0,0 +1,130 @@
+// Package wat generates WAT files: WARC files of metadata records, each holding a JSON
+// description of a record of another WARC file, in the layout Common Crawl publishes.
+package wat
+
+// ContentType is the content type of the JSON blocks of WAT records
+const ContentType = "application/json"
+
+// Document is the JSON block of a WAT record. As in Common Crawl's WAT files, numbers are
+// given as strings.
+type Document struct {
+	Container Container `json:"Container"`
+	Envelope  Envelope  `json:"Envelope"`
+}
+
+// Container locates the record described in the WARC file holding it.
+type Container struct {
+	Filename string `json:"Filename,omitempty"`
+	// Offset is the offset of the record, or of the gzip member or zstd frame holding it
+	Offset string `json:"Offset"`
+}
+
+// Envelope describes a WARC record.
+type Envelope struct {
+	// Format is "WARC"
+	Format              string `json:"Format"`
+	WARCHeaderLength    string `json:"WARC-Header-Length"`
+	BlockDigest         string `json:"Block-Digest,omitempty"`
+	ActualContentLength string `json:"Actual-Content-Length"`
+	// WARCHeaderMetadata holds the named fields of the record header, repeated ones joined
+	// with ", "
+	WARCHeaderMetadata map[string]string `json:"WARC-Header-Metadata"`
+	PayloadMetadata    PayloadMetadata   `json:"Payload-Metadata"`
+}
+
+// PayloadMetadata describes the block of a WARC record, in the member matching its type.
+type PayloadMetadata struct {
+	// ActualContentType is the Content-Type of the record
+	ActualContentType string `json:"Actual-Content-Type,omitempty"`
+
+	HTTPResponseMetadata *HTTPResponseMetadata `json:"HTTP-Response-Metadata,omitempty"`
+	HTTPRequestMetadata  *HTTPRequestMetadata  `json:"HTTP-Request-Metadata,omitempty"`
+	// WARCInfoMetadata holds the fields of a warcinfo block
+	WARCInfoMetadata     map[string]string     `json:"WARC-Info-Metadata,omitempty"`
+	WARCMetadataMetadata *WARCMetadataMetadata `json:"WARC-Metadata-Metadata,omitempty"`
+}
+
+// HTTPResponseMetadata describes an HTTP response.
+type HTTPResponseMetadata struct {
+	ResponseMessage ResponseMessage `json:"Response-Message"`
+	// Headers holds the header fields, repeated ones joined with ", "
+	Headers       map[string]string `json:"Headers"`
+	HeadersLength string            `json:"Headers-Length"`
+	// EntityLength is the number of bytes of the message after its headers
+	EntityLength             string `json:"Entity-Length"`
+	EntityTrailingSlopLength string `json:"Entity-Trailing-Slop-Length"`
+	// HTMLMetadata describes an HTML payload
+	HTMLMetadata *HTMLMetadata `json:"HTML-Metadata,omitempty"`
+
+	// unsupportedCharset reports that HTMLMetadata is left out for an HTML payload in a
+	// charset not supported
+	unsupportedCharset bool
+}
+
+// ResponseMessage is the status line of an HTTP response.
+type ResponseMessage struct {
+	Version string `json:"Version"`
+	Status  string `json:"Status"`
+	Reason  string `json:"Reason"`
+}
+
+// HTTPRequestMetadata describes an HTTP request.
+type HTTPRequestMetadata struct {
+	RequestMessage RequestMessage `json:"Request-Message"`
+	// Headers holds the header fields, repeated ones joined with ", "
+	Headers       map[string]string `json:"Headers"`
+	HeadersLength string            `json:"Headers-Length"`
+	EntityLength  string            `json:"Entity-Length"`
+}
+
+// RequestMessage is the request line of an HTTP request.
+type RequestMessage struct {
+	Method  string `json:"Method"`
+	Path    string `json:"Path"`
+	Version string `json:"Version"`
+}
+
+// WARCMetadataMetadata holds the fields of a metadata block.
+type WARCMetadataMetadata struct {
+	MetadataRecords []MetadataField `json:"Metadata-Records"`
+}
+
+// MetadataField is a field of a metadata block.
+type MetadataField struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// HTMLMetadata describes an HTML document.
+type HTMLMetadata struct {
+	Head Head `json:"Head"`
+	// Links are the links of the document outside its head: anchors, images, frames,
+	// forms and embedded media
+	Links []Link `json:"Links,omitempty"`
+}
+
+// Head holds the metadata of an HTML document.
+type Head struct {
+	Title string `json:"Title,omitempty"`
+	// Base is the href of the base element
+	Base string `json:"Base,omitempty"`
+	// Metas holds the attributes of every meta element
+	Metas []map[string]string `json:"Metas,omitempty"`
+	// Link lists the link elements
+	Link []Link `json:"Link,omitempty"`
+	// Scripts lists the scripts loaded by src
+	Scripts []Link `json:"Scripts,omitempty"`
+}
+
+// Link is a URL referenced by an HTML document, as written there.
+type Link struct {
+	// Path names the element and attribute holding the URL, such as "A@/href" or "IMG@/src"
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	// Text is the text of an anchor
+	Text  string `json:"text,omitempty"`
+	Title string `json:"title,omitempty"`
+	Alt   string `json:"alt,omitempty"`
+	Rel   string `json:"rel,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
@@ -0,0 +1,262 @@
+package wat_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+	. "github.com/zenless-lab/gwarc/wat"
+)
+
+const page = `<!DOCTYPE html>
+<html><head>
+<title> Example
+ page </title>
+<base href="http://example.com/base/">
+<meta charset="utf-8"><meta name="description" content="An example">
+<link rel="stylesheet" href="/style.css" type="text/css">
+<script src="app.js"></script>
+</head><body>
+<a href="/about" title="About us">About <b>us</b></a>
+<img src="logo.png" alt="Logo">
+<form action="/search"><input name="q"></form>
+<a name="anchor">not a link</a>
+</body></html>`
+
+func TestParseHTML(t *testing.T) {
+	got := ParseHTML(page)
+	want := &HTMLMetadata{
+		Head: Head{
+			Title:   "Example page",
+			Base:    "http://example.com/base/",
+			Metas:   []map[string]string{{"charset": "utf-8"}, {"name": "description", "content": "An example"}},
+			Link:    []Link{{Path: "LINK@/href", URL: "/style.css", Rel: "stylesheet", Type: "text/css"}},
+			Scripts: []Link{{Path: "SCRIPT@/src", URL: "app.js"}},
+		},
+		Links: []Link{
+			{Path: "A@/href", URL: "/about", Text: "About us", Title: "About us"},
+			{Path: "IMG@/src", URL: "logo.png", Alt: "Logo"},
+			{Path: "FORM@/action", URL: "/search"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHTML =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// writeSource writes a WARC file with a warcinfo, request, response and metadata record.
+func writeSource(t *testing.T) []byte {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	io.WriteString(gz, page)
+	gz.Close()
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	info := &warc.WarcInfoRecord{WARCRecord: warc.WARCRecord{RecordID: warc.NewRecordID(), Date: date, Filename: "source.warc"}}
+	info.Software = "test"
+	request := &warc.WARCRecord{
+		Type:        warc.WARCTypeRequest,
+		Date:        date,
+		TargetURI:   "http://example.com/",
+		ContentType: warc.ContentTypeHTTPRequest,
+		Content:     []byte("GET / HTTP/1.1\r\nHost: example.com\r\nAccept: text/html\r\n\r\n"),
+	}
+	head := "HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\nContent-Encoding: gzip\r\nSet-Cookie: a=1\r\nSet-Cookie: b=2\r\n\r\n"
+	response := &warc.WARCRecord{
+		Type:        warc.WARCTypeResponse,
+		Date:        date,
+		TargetURI:   "http://example.com/",
+		ContentType: warc.ContentTypeHTTPResponse,
+		Content:     append([]byte(head), body.Bytes()...),
+	}
+	metadata := &warc.MetadataRecord{WARCRecord: warc.WARCRecord{Date: date, TargetURI: "http://example.com/"}}
+	metadata.Via = "http://example.com/seed"
+	metadata.FetchTimeMs = 42
+
+	var buf bytes.Buffer
+	w := warc.NewGzipWriter(&buf)
+	if err := w.WriteRecords(info, request, response, metadata); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerate(t *testing.T) {
+	source := writeSource(t)
+	r, err := warc.NewReader(bytes.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := Generate(warc.NewWriter(&out), r); err != nil {
+		t.Fatal(err)
+	}
+
+	// The source records, in order, to check the WAT records against
+	r, err = warc.NewReader(bytes.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []*warc.Record
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, record)
+	}
+
+	wat, err := warc.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := wat.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != warc.WARCTypeWarcinfo {
+		t.Fatalf("WAT file starts with a %s record", info.Type)
+	}
+	var docs []Document
+	for i := 0; ; i++ {
+		record, err := wat.Next()
+		if err == io.EOF {
+			if i != len(sources) {
+				t.Errorf("got %d WAT records, want %d", i, len(sources))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		source := sources[i]
+		if record.Type != warc.WARCTypeMetadata || record.ContentType != ContentType || record.RefersTo != source.RecordID ||
+			!record.Date.Equal(source.Date) || record.WarcinfoID != info.RecordID {
+			t.Errorf("WAT record %d: %s %s refers to %s on %v, want metadata referring to %s", i, record.Type,
+				record.ContentType, record.RefersTo, record.Date, source.RecordID)
+		}
+		var doc Document
+		if err := json.NewDecoder(record.Block).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Container.Offset != strconv.FormatInt(source.Offset, 10) || doc.Envelope.WARCHeaderMetadata["WARC-Record-ID"] != source.RecordID {
+			t.Errorf("WAT record %d: container %+v, header %v", i, doc.Container, doc.Envelope.WARCHeaderMetadata)
+		}
+		if want := strconv.FormatInt(int64(source.ContentLength), 10); doc.Envelope.ActualContentLength != want {
+			t.Errorf("WAT record %d: Actual-Content-Length %s, want %s", i, doc.Envelope.ActualContentLength, want)
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) != 4 {
+		t.FailNow()
+	}
+
+	if m := docs[0].Envelope.PayloadMetadata.WARCInfoMetadata; m["software"] != "test" {
+		t.Errorf("WARC-Info-Metadata = %v", m)
+	}
+	if m := docs[1].Envelope.PayloadMetadata.HTTPRequestMetadata; m == nil || m.RequestMessage != (RequestMessage{"GET", "/", "HTTP/1.1"}) ||
+		m.Headers["Accept"] != "text/html" || m.EntityLength != "0" {
+		t.Errorf("HTTP-Request-Metadata = %+v", m)
+	}
+	response := docs[2].Envelope.PayloadMetadata.HTTPResponseMetadata
+	if response == nil {
+		t.Fatal("no HTTP-Response-Metadata")
+	}
+	if response.ResponseMessage != (ResponseMessage{"HTTP/1.1", "200", "OK"}) || response.Headers["Set-Cookie"] != "a=1, b=2" {
+		t.Errorf("HTTP-Response-Metadata = %+v", response)
+	}
+	if response.HTMLMetadata == nil || response.HTMLMetadata.Head.Title != "Example page" || len(response.HTMLMetadata.Links) != 3 {
+		t.Errorf("HTML-Metadata = %+v", response.HTMLMetadata)
+	}
+	if m := docs[3].Envelope.PayloadMetadata.WARCMetadataMetadata; m == nil ||
+		!reflect.DeepEqual(m.MetadataRecords, []MetadataField{{"via", "http://example.com/seed"}, {"fetchTimeMs", "42"}}) {
+		t.Errorf("WARC-Metadata-Metadata = %+v", m)
+	}
+}
+
+func TestGenerateUnsupportedCharset(t *testing.T) {
+	var source bytes.Buffer
+	err := warc.NewWriter(&source).WriteRecords(&warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResponse,
+		TargetURI:   "http://example.jp/",
+		ContentType: warc.ContentTypeHTTPResponse,
+		Content:     []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=Shift_JIS\r\n\r\n<title>\x93\xFA\x96\x7B</title>"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := warc.NewReader(&source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	var g Generator
+	if err := g.Generate(warc.NewWriter(&out), r); err != nil {
+		t.Fatal(err)
+	}
+	if g.Unsupported != 1 {
+		t.Errorf("Unsupported = %d, want 1", g.Unsupported)
+	}
+
+	wat, err := warc.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wat.Next()
+	record, err := wat.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc Document
+	if err := json.NewDecoder(record.Block).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if m := doc.Envelope.PayloadMetadata.HTTPResponseMetadata; m == nil || m.HTMLMetadata != nil {
+		t.Errorf("HTTP-Response-Metadata = %+v, want no HTML-Metadata", m)
+	}
+}
+
+func TestNewDocumentLongField(t *testing.T) {
+	long := strings.Repeat("x", 100<<10)
+	var source bytes.Buffer
+	err := warc.NewWriter(&source).WriteRecords(&warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeMetadata,
+		TargetURI:   "http://example.com/",
+		ContentType: warc.ContentTypeWARCFields,
+		Content:     []byte("outlink: " + long + "\r\nfetchTimeMs: 42\r\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := warc.NewReader(&source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := NewDocument(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := doc.Envelope.PayloadMetadata.WARCMetadataMetadata
+	if m == nil {
+		t.Fatal("no WARC-Metadata-Metadata")
+	}
+	if !reflect.DeepEqual(m.MetadataRecords, []MetadataField{{"outlink", long}, {"fetchTimeMs", "42"}}) {
+		t.Errorf("WARC-Metadata-Metadata has %d fields", len(m.MetadataRecords))
+	}
+}
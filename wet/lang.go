@@ -0,0 +1,127 @@
+package wet
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// scripts maps the writing systems that mostly identify a language to its ISO 639-3 code.
+// Han characters without kana are taken for Chinese.
+var scripts = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "kor"},
+	{unicode.Hiragana, "jpn"},
+	{unicode.Katakana, "jpn"},
+	{unicode.Han, "zho"},
+	{unicode.Cyrillic, "rus"},
+	{unicode.Greek, "ell"},
+	{unicode.Arabic, "ara"},
+	{unicode.Hebrew, "heb"},
+	{unicode.Thai, "tha"},
+	{unicode.Devanagari, "hin"},
+	{unicode.Armenian, "hye"},
+	{unicode.Georgian, "kat"},
+}
+
+// stopwords lists the most frequent words of languages written in the Latin script.
+var stopwords = map[string][]string{
+	"eng": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "was", "on", "are", "this", "you"},
+	"deu": {"der", "die", "und", "das", "ist", "nicht", "mit", "ein", "zu", "den", "von", "sie", "ich", "auf", "sich"},
+	"fra": {"le", "la", "les", "et", "des", "est", "une", "dans", "pour", "que", "du", "pas", "qui", "sur", "au"},
+	"spa": {"el", "la", "los", "que", "y", "en", "las", "por", "una", "del", "con", "para", "es", "se", "no"},
+	"ita": {"il", "di", "che", "e", "per", "non", "una", "sono", "del", "della", "con", "gli", "le", "si", "è"},
+	"por": {"o", "que", "e", "do", "da", "em", "um", "para", "não", "uma", "os", "com", "no", "se", "na"},
+	"nld": {"de", "het", "een", "en", "van", "is", "dat", "op", "niet", "met", "zijn", "voor", "ik", "te", "die"},
+}
+
+// stopwordLanguages maps each stopword to the languages listing it.
+var stopwordLanguages = func() map[string][]string {
+	m := make(map[string][]string)
+	for language, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], language)
+		}
+	}
+	return m
+}()
+
+// IdentifyLanguage returns the ISO 639-3 codes of the languages text is written in, the
+// main one first, or nil when it is too short to tell. Languages are told apart by their
+// writing system, and among those written in the Latin script by their most frequent
+// words; a language making up less than a fifth of the text is left out.
+func IdentifyLanguage(text string) []string {
+	counts := make(map[string]int)
+	latin, letters := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.language]++
+				break
+			}
+		}
+	}
+	if letters < 20 {
+		return nil
+	}
+	// Kanji are part of Japanese text
+	if counts["jpn"] > 0 {
+		counts["jpn"] += counts["zho"]
+		delete(counts, "zho")
+	}
+	if latin > 0 {
+		if language := latinLanguage(text); language != "" {
+			counts[language] += latin
+		}
+	}
+
+	var languages []string
+	for language, n := range counts {
+		if n*5 >= letters {
+			languages = append(languages, language)
+		}
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if counts[languages[i]] != counts[languages[j]] {
+			return counts[languages[i]] > counts[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+	return languages
+}
+
+// latinLanguage returns the language whose stopwords are the most frequent words of text,
+// if they are frequent enough to tell.
+func latinLanguage(text string) string {
+	scores := make(map[string]int)
+	words := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		words++
+		for _, language := range stopwordLanguages[word] {
+			scores[language]++
+		}
+	}
+	best, bestScore, second := "", 0, 0
+	for language, score := range scores {
+		if score > bestScore || score == bestScore && language < best {
+			best, bestScore, second = language, score, bestScore
+		} else if score > second {
+			second = score
+		}
+	}
+	// A tenth of the words are stopwords in most text, and the best language must stand out
+	if bestScore < 3 || bestScore*20 < words || bestScore == second {
+		return ""
+	}
+	return best
+}
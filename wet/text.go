@@ -0,0 +1,121 @@
+package wet
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zenless-lab/gwarc/internal/htmltok"
+)
+
+// hidden lists the elements whose content is not text of the page.
+var hidden = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"math":     true,
+}
+
+// blocks lists the elements that start and end a line of text.
+var blocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"br": true, "caption": true, "dd": true, "details": true, "dialog": true, "div": true,
+	"dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "summary": true, "table": true, "td": true,
+	"th": true, "tr": true, "ul": true, "option": true, "textarea": true, "title": true,
+}
+
+// Text returns the plain text of an HTML document: its title on the first line, then the
+// text of its body, without scripts, styles and other hidden content. Every block element,
+// such as a paragraph, heading, list item or table cell, is on lines of its own; white
+// space within them is collapsed, except in pre elements.
+func Text(document string) string {
+	t := &textWriter{}
+	z := htmltok.New(document)
+	// hiddenDepth counts the hidden elements open, and pre the preformatted ones
+	hiddenDepth, pre := 0, 0
+	inTitle, titleDone := false, false
+	for {
+		token, ok := z.Next()
+		if !ok {
+			break
+		}
+		switch token.Kind {
+		case htmltok.StartTag:
+			switch {
+			case token.Name == "title" && !titleDone:
+				inTitle = true
+			case hidden[token.Name] && !token.SelfClosing:
+				hiddenDepth++
+			}
+			if token.Name == "pre" || token.Name == "textarea" {
+				pre++
+			}
+			if blocks[token.Name] {
+				t.endLine()
+			}
+		case htmltok.EndTag:
+			switch {
+			case token.Name == "title" && inTitle:
+				inTitle, titleDone = false, true
+			case hidden[token.Name] && hiddenDepth > 0:
+				hiddenDepth--
+			}
+			if (token.Name == "pre" || token.Name == "textarea") && pre > 0 {
+				pre--
+			}
+			if blocks[token.Name] {
+				t.endLine()
+			}
+		case htmltok.Text:
+			switch {
+			case inTitle:
+				t.write(token.Text, false)
+			case hiddenDepth == 0:
+				t.write(token.Text, pre > 0)
+			}
+		}
+	}
+	t.endLine()
+	return strings.Join(t.lines, "\n")
+}
+
+// textWriter builds lines of text, collapsing white space.
+type textWriter struct {
+	lines []string
+	line  strings.Builder
+	space bool
+}
+
+func (t *textWriter) write(text string, preformatted bool) {
+	for _, r := range text {
+		switch {
+		case preformatted && r == '\n':
+			t.endLine()
+		case unicode.IsSpace(r) || r == ' ':
+			t.space = t.line.Len() > 0
+			if preformatted && r != '\r' {
+				t.line.WriteRune(r)
+				t.space = false
+			}
+		default:
+			if t.space {
+				t.line.WriteByte(' ')
+				t.space = false
+			}
+			t.line.WriteRune(r)
+		}
+	}
+}
+
+// endLine ends the current line, if it has any text.
+func (t *textWriter) endLine() {
+	if line := strings.TrimSpace(t.line.String()); line != "" {
+		t.lines = append(t.lines, line)
+	}
+	t.line.Reset()
+	t.space = false
+}
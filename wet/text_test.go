@@ -0,0 +1,67 @@
+package wet_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/zenless-lab/gwarc/wet"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			"blocks",
+			`<html><head><title>The  title</title><style>p { color: red }</style>
+<script>var x = "<p>not text</p>";</script></head>
+<body><h1>Heading</h1><p>First   paragraph
+with a <a href="/">link</a> and <b>bold</b> text.</p><ul><li>one</li><li>two<br>lines</li></ul>
+<noscript>Enable scripts</noscript><table><tr><td>cell 1</td><td>cell 2</td></tr></table></body></html>`,
+			"The title\nHeading\nFirst paragraph with a link and bold text.\none\ntwo\nlines\ncell 1\ncell 2",
+		},
+		{
+			"preformatted",
+			"<p>before</p><pre>  indented\n    code</pre><p>after</p>",
+			"before\nindented\ncode\nafter",
+		},
+		{
+			"entities",
+			"<p>Fish &amp; chips&nbsp;&mdash; &lt;tasty&gt;</p>",
+			"Fish & chips — <tasty>",
+		},
+		{
+			"no markup",
+			"just  text",
+			"just text",
+		},
+	}
+	for _, test := range tests {
+		if got := Text(test.document); got != test.want {
+			t.Errorf("%s: Text =\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestIdentifyLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"The quick brown fox jumps over the lazy dog, and it is the best thing that happened to the dog this year.", []string{"eng"}},
+		{"Der schnelle braune Fuchs springt über den faulen Hund, und das ist nicht das erste Mal, dass er sich so verhält.", []string{"deu"}},
+		{"Le renard brun rapide saute par-dessus le chien paresseux, et ce n'est pas la première fois que cela arrive dans la forêt.", []string{"fra"}},
+		{"日本語のテキストはひらがなとカタカナと漢字で書かれています。", []string{"jpn"}},
+		{"中文文本主要由汉字组成，这是一个用于测试语言识别的句子。", []string{"zho"}},
+		{"Быстрая коричневая лиса прыгает через ленивую собаку.", []string{"rus"}},
+		{"Быстрая коричневая лиса прыгает через ленивую собаку. The quick brown fox jumps over the lazy dog and it is the end of the story.", []string{"eng", "rus"}},
+		{"too short", nil},
+	}
+	for _, test := range tests {
+		if got := IdentifyLanguage(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("IdentifyLanguage(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package wet generates WET files: WARC files of conversion records, each holding the plain
+// text of an HTML response of another WARC file, in the layout Common Crawl publishes.
+package wet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zenless-lab/gwarc/internal/charset"
+	"github.com/zenless-lab/gwarc/internal/htmlpayload"
+	"github.com/zenless-lab/gwarc/warc"
+)
+
+// ContentType is the content type of the blocks of WET records
+const ContentType = "text/plain"
+
+// LanguageField is the extension field naming the languages of the text of a WET record, as
+// ISO 639-3 codes separated by commas
+const LanguageField = "WARC-Identified-Content-Language"
+
+// MaxHTMLSize is the number of bytes of an HTML payload converted to text
+const MaxHTMLSize = htmlpayload.MaxSize
+
+// ErrUnsupportedCharset is wrapped by the errors of Convert for HTML payloads in a charset
+// that cannot be decoded, such as Shift_JIS or GBK
+var ErrUnsupportedCharset = charset.ErrUnsupported
+
+// Generate reads records from r and writes a WET file to w, as a Generator does. HTML
+// payloads in a charset not supported are skipped; a Generator counts them.
+func Generate(w warc.RecordWriter, r warc.RecordReader) error {
+	var g Generator
+	return g.Generate(w, r)
+}
+
+// Generator writes WET files, counting the HTML payloads it cannot convert.
+type Generator struct {
+	// Unsupported is the number of HTML payloads skipped for being in a charset not
+	// supported
+	Unsupported int
+}
+
+// Generate reads records from r and writes a WET file to w: a warcinfo record describing
+// it, then a conversion record for every HTML response or resource record, as returned by
+// Convert. Other records are skipped, as are HTML payloads in a charset not supported,
+// counted in Unsupported.
+func (g *Generator) Generate(w warc.RecordWriter, r warc.RecordReader) error {
+	var warcinfoID string
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		conversion, err := Convert(record)
+		if errors.Is(err, ErrUnsupportedCharset) {
+			g.Unsupported++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("record %s: %w", record.RecordID, err)
+		}
+		if conversion == nil {
+			continue
+		}
+
+		group := make([]any, 0, 2)
+		if warcinfoID == "" {
+			info := &warc.WarcInfoRecord{WARCRecord: warc.WARCRecord{RecordID: warc.NewRecordID()}}
+			info.Software = "gwarc"
+			info.Content = []byte("format: WET\r\n")
+			if record.File != "" {
+				info.Content = append(info.Content, "description: text of "+record.File+"\r\n"...)
+			}
+			warcinfoID = info.RecordID
+			group = append(group, info)
+		}
+		conversion.WarcinfoID = warcinfoID
+		group = append(group, conversion)
+		if err := w.WriteRecords(group...); err != nil {
+			return err
+		}
+	}
+}
+
+// Convert returns the conversion record holding the text of the HTML payload of a response
+// or resource record, or nil for any other record. The record refers to the one converted
+// and shares its target URI and date; the payload is decoded from its content codings and
+// charset, and the languages identified in its text are given in LanguageField. Payloads in
+// a charset not supported fail with an error wrapping ErrUnsupportedCharset, rather than
+// giving garbled text.
+func Convert(record *warc.Record) (*warc.WARCRecord, error) {
+	doc := htmlpayload.Record(record)
+	if doc == nil {
+		return nil, nil
+	}
+	html, err := doc.HTML()
+	if err != nil {
+		return nil, err
+	}
+	text := Text(html)
+	conversion := &warc.WARCRecord{
+		Type:        warc.WARCTypeConversion,
+		Date:        record.Date,
+		TargetURI:   record.TargetURI,
+		RefersTo:    record.RecordID,
+		ContentType: ContentType,
+		Content:     []byte(text),
+	}
+	if languages := IdentifyLanguage(text); len(languages) > 0 {
+		conversion.Extensions = map[string][]string{LanguageField: {strings.Join(languages, ",")}}
+	}
+	return conversion, nil
+}
@@ -0,0 +1,115 @@
+package wet_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/zenless-lab/gwarc/warc"
+	. "github.com/zenless-lab/gwarc/wet"
+)
+
+func TestGenerate(t *testing.T) {
+	date := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	page := "<html><head><meta charset=\"iso-8859-1\"><title>Caf\xE9</title></head>" +
+		"<body><p>The caf\xE9 is open and it is the place to be for the people of the town.</p></body></html>"
+	html := &warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResponse,
+		Date:        date,
+		TargetURI:   "http://example.com/",
+		ContentType: warc.ContentTypeHTTPResponse,
+		Content:     []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n" + page),
+	}
+	image := &warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResponse,
+		Date:        date,
+		TargetURI:   "http://example.com/logo.png",
+		ContentType: warc.ContentTypeHTTPResponse,
+		Content:     []byte("HTTP/1.1 200 OK\r\nContent-Type: image/png\r\n\r\n\x89PNG"),
+	}
+	resource := &warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResource,
+		Date:        date,
+		TargetURI:   "file:///index.html",
+		ContentType: "text/html; charset=utf-8",
+		Content:     []byte("<p>Résumé</p>"),
+	}
+	// Shift_JIS is not supported, and its text is counted rather than garbled
+	japanese := &warc.WARCRecord{
+		RecordID:    warc.NewRecordID(),
+		Type:        warc.WARCTypeResource,
+		Date:        date,
+		TargetURI:   "http://example.jp/",
+		ContentType: "text/html; charset=Shift_JIS",
+		Content:     []byte("<p>\x93\xFA\x96\x7B</p>"),
+	}
+	var source bytes.Buffer
+	if err := warc.NewWriter(&source).WriteRecords(html, image, japanese, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := warc.NewReader(&source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	var g Generator
+	if err := g.Generate(warc.NewWriter(&out), r); err != nil {
+		t.Fatal(err)
+	}
+	if g.Unsupported != 1 {
+		t.Errorf("Unsupported = %d, want 1", g.Unsupported)
+	}
+
+	wet, err := warc.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := wet.Next()
+	if err != nil || info.Type != warc.WARCTypeWarcinfo {
+		t.Fatalf("WET file starts with %v, %v", info, err)
+	}
+	want := []struct {
+		source   *warc.WARCRecord
+		text     string
+		language string
+	}{
+		{html, "Café\nThe café is open and it is the place to be for the people of the town.", "eng"},
+		{resource, "Résumé", ""},
+	}
+	for i := 0; ; i++ {
+		record, err := wet.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("got %d conversion records, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(want) {
+			t.Fatalf("unexpected record %s", record.TargetURI)
+		}
+		w := want[i]
+		text, _ := io.ReadAll(record.Block)
+		if record.Type != warc.WARCTypeConversion || record.ContentType != ContentType || record.RefersTo != w.source.RecordID ||
+			record.TargetURI != w.source.TargetURI || !record.Date.Equal(date) || record.WarcinfoID != info.RecordID {
+			t.Errorf("record %d: %s %s for %s refers to %s", i, record.Type, record.ContentType, record.TargetURI, record.RefersTo)
+		}
+		if string(text) != w.text {
+			t.Errorf("record %d: text %q, want %q", i, text, w.text)
+		}
+		var language string
+		if values := record.Extensions[LanguageField]; len(values) > 0 {
+			language = values[0]
+		}
+		if language != w.language {
+			t.Errorf("record %d: %s %q, want %q", i, LanguageField, language, w.language)
+		}
+	}
+}